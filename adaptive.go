@@ -0,0 +1,226 @@
+package gopayamgostar
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// healthSmoothing is the weight of the latest outcome in the health scores
+const healthSmoothing = 0.1
+
+// EndpointHealth is the health of one endpoint. Score is an exponentially
+// smoothed success rate between 0 (failing) and 1 (healthy).
+type EndpointHealth struct {
+	Endpoint string
+	Score    float64
+	Requests int64
+	Failures int64
+}
+
+// HealthReport is the health of the server as seen by the client
+type HealthReport struct {
+	// Score is the smoothed success rate of all requests
+	Score     float64
+	Endpoints []EndpointHealth
+}
+
+// healthTracker keeps the smoothed success rates of the requests sent
+type healthTracker struct {
+	mu        sync.Mutex
+	overall   float64
+	endpoints map[string]*EndpointHealth
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{
+		overall:   1,
+		endpoints: map[string]*EndpointHealth{},
+	}
+}
+
+func (h *healthTracker) record(endpoint string, success bool) {
+	outcome := 0.0
+	if success {
+		outcome = 1
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	e, ok := h.endpoints[endpoint]
+	if !ok {
+		e = &EndpointHealth{Endpoint: endpoint, Score: 1}
+		h.endpoints[endpoint] = e
+	}
+	e.Requests++
+	if !success {
+		e.Failures++
+	}
+	e.Score += healthSmoothing * (outcome - e.Score)
+	h.overall += healthSmoothing * (outcome - h.overall)
+}
+
+// score returns the score of endpoint, 1 for endpoints without requests
+func (h *healthTracker) score(endpoint string) float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if e, ok := h.endpoints[endpoint]; ok {
+		return e.Score
+	}
+	return 1
+}
+
+func (h *healthTracker) overallScore() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.overall
+}
+
+func (h *healthTracker) report() HealthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	report := HealthReport{Score: h.overall}
+	for _, e := range h.endpoints {
+		report.Endpoints = append(report.Endpoints, *e)
+	}
+	sort.Slice(report.Endpoints, func(i, j int) bool {
+		return report.Endpoints[i].Endpoint < report.Endpoints[j].Endpoint
+	})
+	return report
+}
+
+// adaptiveTransport records the outcome of every attempt and, when
+// maxConcurrency is set, bounds the requests in flight to a share of it
+// matching the server health
+type adaptiveTransport struct {
+	next           http.RoundTripper
+	health         *healthTracker
+	maxConcurrency int
+
+	mu       sync.Mutex
+	inFlight int
+	released chan struct{}
+}
+
+func (t *adaptiveTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.acquire(req.Context()); err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	t.release()
+
+	t.health.record(req.URL.Path, isHealthy(resp, err))
+	return resp, err
+}
+
+// limit is the number of requests allowed in flight
+func (t *adaptiveTransport) limit() int {
+	limit := int(math.Ceil(float64(t.maxConcurrency) * t.health.overallScore()))
+	if limit < 1 {
+		limit = 1
+	}
+	return limit
+}
+
+func (t *adaptiveTransport) acquire(ctx context.Context) error {
+	if t.maxConcurrency <= 0 {
+		return nil
+	}
+	for {
+		t.mu.Lock()
+		if t.inFlight < t.limit() {
+			t.inFlight++
+			t.mu.Unlock()
+			return nil
+		}
+		if t.released == nil {
+			t.released = make(chan struct{})
+		}
+		released := t.released
+		t.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+func (t *adaptiveTransport) release() {
+	if t.maxConcurrency <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inFlight--
+	if t.released != nil {
+		close(t.released)
+		t.released = nil
+	}
+}
+
+func isHealthy(resp *http.Response, err error) bool {
+	return err == nil && resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests
+}
+
+// WithAdaptiveRetry retries failed requests (transport errors, 429 and 5xx)
+// up to maxRetries times and allows up to maxConcurrency requests in flight,
+// both scaled down by the smoothed success rate of the server. When a shared
+// server degrades the client backs off instead of adding to a retry storm,
+// and it recovers gradually as requests succeed again. A maxConcurrency of 0
+// leaves concurrency unbounded.
+func WithAdaptiveRetry(maxRetries, maxConcurrency int) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		h := newHealthTracker()
+		g.health = h
+
+		httpClient := g.restyClient.GetClient()
+		next := httpClient.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		httpClient.Transport = &adaptiveTransport{
+			next:           next,
+			health:         h,
+			maxConcurrency: maxConcurrency,
+		}
+
+		g.restyClient.
+			SetRetryCount(maxRetries).
+			AddRetryCondition(func(resp *resty.Response, err error) bool {
+				if resp == nil || resp.Request == nil {
+					return false
+				}
+				if err == nil && isHealthy(resp.RawResponse, nil) {
+					return false
+				}
+				allowed := int(math.Round(float64(maxRetries) * h.score(requestPath(resp.Request))))
+				return resp.Request.Attempt <= allowed
+			})
+	}
+}
+
+// Health returns the health scores tracked by WithAdaptiveRetry. The report is
+// empty when adaptive retries are not enabled.
+func (g *GoPayamgostar) Health() HealthReport {
+	if g.health == nil {
+		return HealthReport{}
+	}
+	return g.health.report()
+}
+
+func requestPath(r *resty.Request) string {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return r.URL
+	}
+	return u.Path
+}
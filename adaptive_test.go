@@ -0,0 +1,76 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveRetryShrinksWithHealth(t *testing.T) {
+	var attempts int32
+	var failing atomic.Bool
+	failing.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithAdaptiveRetry(3, 0))
+	client.RestyClient().SetRetryWaitTime(time.Millisecond).SetRetryMaxWaitTime(time.Millisecond)
+
+	for _, expected := range []int32{3, 3, 2} {
+		atomic.StoreInt32(&attempts, 0)
+		require.Error(t, client.DeleteForm(context.Background(), "token", "form"))
+		require.Equal(t, expected, atomic.LoadInt32(&attempts))
+	}
+
+	degraded := client.Health()
+	require.InDelta(t, 0.430, degraded.Score, 0.001)
+	require.Len(t, degraded.Endpoints, 1)
+	require.Equal(t, int64(8), degraded.Endpoints[0].Failures)
+
+	failing.Store(false)
+	require.NoError(t, client.DeleteForm(context.Background(), "token", "form"))
+	require.Greater(t, client.Health().Score, degraded.Score)
+}
+
+func TestAdaptiveRetryConcurrency(t *testing.T) {
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			peak := atomic.LoadInt32(&maxInFlight)
+			if current <= peak || atomic.CompareAndSwapInt32(&maxInFlight, peak, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithAdaptiveRetry(0, 2))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, client.DeleteForm(context.Background(), "token", "form"))
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(2), atomic.LoadInt32(&maxInFlight))
+	require.Equal(t, 1.0, client.Health().Score)
+}
@@ -0,0 +1,233 @@
+// Package archiver copies the attachments of CRM objects to S3 compatible
+// object storage such as AWS S3 or MinIO and can remove the CRM copies once
+// the archived ones have been verified, to keep the CRM storage small.
+package archiver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/pkg/errors"
+)
+
+const defaultPageSize = 100
+
+// ErrNotFound is returned by Store.Stat for keys that are not stored
+var ErrNotFound = errors.New("object not found")
+
+// ErrChecksumMismatch is reported for archived copies that do not match the
+// downloaded attachment
+var ErrChecksumMismatch = errors.New("archived object does not match the attachment")
+
+// Object describes an archived attachment
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+	// SHA256 is the hex encoded SHA-256 of the content
+	SHA256 string
+	// Metadata is stored as user metadata of the object, e.g. x-amz-meta-* headers
+	Metadata map[string]string
+}
+
+// Store is a bucket of an S3 compatible object storage. Put uploads object
+// with the size bytes of body, keeping object.SHA256 so that Stat can return
+// it, e.g. as x-amz-checksum-sha256 or user metadata. Stat returns
+// ErrNotFound for keys that are not stored.
+type Store interface {
+	Put(ctx context.Context, object Object, body io.Reader) error
+	Stat(ctx context.Context, key string) (*Object, error)
+}
+
+// Failure is an attachment that could not be archived or deleted
+type Failure struct {
+	CrmId        string
+	AttachmentID string
+	Err          error
+}
+
+// Report sums up an Archive run
+type Report struct {
+	// Archived are the keys of the attachments copied by this run
+	Archived []string
+	// Skipped are the keys of the attachments that were archived before
+	Skipped []string
+	// Deleted are the ids of the attachments removed from the CRM
+	Deleted []string
+	Failed  []Failure
+}
+
+// Archiver copies the attachments of the objects matched by Archive to a Store
+type Archiver struct {
+	client *gopayamgostar.GoPayamgostar
+	store  Store
+
+	// Prefix is prepended to the keys of DefaultKey
+	Prefix string
+	// Key returns the key an attachment is stored under, DefaultKey by default
+	Key func(prefix, typeKey string, attachment gopayamgostar.AttachmentInfo) string
+	// DeleteAfterVerify removes the CRM copy of an attachment once the
+	// archived copy has been read back with a matching size and checksum
+	DeleteAfterVerify bool
+	// PageSize is the number of objects fetched per request
+	PageSize int64
+	// TempDir holds the attachments while they are uploaded, os.TempDir by default
+	TempDir string
+}
+
+// New creates an archiver copying attachments with client to store
+func New(client *gopayamgostar.GoPayamgostar, store Store) *Archiver {
+	return &Archiver{
+		client:   client,
+		store:    store,
+		Key:      DefaultKey,
+		PageSize: defaultPageSize,
+	}
+}
+
+// DefaultKey lays attachments out as
+// <prefix>/<typeKey>/<crmId>/<attachmentId>/<fileName>
+func DefaultKey(prefix, typeKey string, attachment gopayamgostar.AttachmentInfo) string {
+	fileName := strings.NewReplacer("/", "_", "\\", "_").Replace(attachment.FileName)
+	if fileName == "" || fileName == "." || fileName == ".." {
+		fileName = "attachment"
+	}
+	return path.Join(prefix, typeKey, attachment.CRMObjectID, attachment.ID, fileName)
+}
+
+// Archive copies the attachments of the objects of typeKey matching queries.
+// Attachments whose key is already stored with the same size are skipped;
+// with DeleteAfterVerify they are downloaded again to compare checksums, and
+// every attachment is deleted from the CRM only after its archived copy has
+// been verified. A failing attachment is added to Report.Failed and the run goes
+// on; an error is only returned when the objects cannot be listed.
+func (a *Archiver) Archive(ctx context.Context, accessToken, typeKey string, queries []gopayamgostar.Query) (*Report, error) {
+	report := &Report{}
+
+	var seen int64
+	for page := int64(1); ; page++ {
+		result, err := a.client.FindFormPage(ctx, accessToken, typeKey, queries, page, a.PageSize)
+		if err != nil {
+			return report, errors.Wrapf(err, "could not fetch %s", typeKey)
+		}
+
+		for _, object := range result.Data {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			a.archiveObject(ctx, accessToken, typeKey, object.CRMID, report)
+		}
+
+		seen += int64(len(result.Data))
+		if int64(len(result.Data)) < a.PageSize || seen >= result.Total {
+			return report, nil
+		}
+	}
+}
+
+func (a *Archiver) archiveObject(ctx context.Context, accessToken, typeKey, crmId string, report *Report) {
+	attachments, err := a.client.ListAttachments(ctx, accessToken, crmId)
+	if err != nil {
+		report.Failed = append(report.Failed, Failure{CrmId: crmId, Err: err})
+		return
+	}
+
+	for _, attachment := range attachments {
+		if attachment.CRMObjectID == "" {
+			attachment.CRMObjectID = crmId
+		}
+		key := a.Key(a.Prefix, typeKey, attachment)
+
+		archived, err := a.archiveAttachment(ctx, accessToken, key, attachment)
+		if err != nil {
+			report.Failed = append(report.Failed, Failure{CrmId: crmId, AttachmentID: attachment.ID, Err: err})
+			continue
+		}
+		if archived {
+			report.Archived = append(report.Archived, key)
+		} else {
+			report.Skipped = append(report.Skipped, key)
+		}
+
+		if !a.DeleteAfterVerify {
+			continue
+		}
+		if err := a.client.DeleteAttachment(ctx, accessToken, attachment.ID); err != nil {
+			report.Failed = append(report.Failed, Failure{CrmId: crmId, AttachmentID: attachment.ID, Err: err})
+			continue
+		}
+		report.Deleted = append(report.Deleted, attachment.ID)
+	}
+}
+
+// archiveAttachment stores attachment under key unless it is stored already
+// and verifies the stored copy. It reports whether the attachment was uploaded.
+func (a *Archiver) archiveAttachment(ctx context.Context, accessToken, key string, attachment gopayamgostar.AttachmentInfo) (bool, error) {
+	existing, err := a.store.Stat(ctx, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return false, errors.Wrapf(err, "could not stat %s", key)
+	}
+	stored := err == nil && existing.Size == attachment.Size && existing.SHA256 != ""
+	if stored && !a.DeleteAfterVerify {
+		return false, nil
+	}
+
+	file, err := os.CreateTemp(a.TempDir, "attachment-*")
+	if err != nil {
+		return false, errors.Wrap(err, "could not buffer attachment")
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := a.client.DownloadAttachment(ctx, accessToken, attachment.ID, io.MultiWriter(file, hash))
+	if err != nil {
+		return false, err
+	}
+
+	object := Object{
+		Key:         key,
+		Size:        size,
+		ContentType: attachment.ContentType,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		Metadata: map[string]string{
+			"crm-id":        attachment.CRMObjectID,
+			"attachment-id": attachment.ID,
+			"file-name":     attachment.FileName,
+		},
+	}
+	if stored && existing.SHA256 == object.SHA256 {
+		// archived by an earlier run and verified against the CRM copy
+		return false, nil
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return false, errors.Wrap(err, "could not buffer attachment")
+	}
+	if err := a.store.Put(ctx, object, file); err != nil {
+		return false, errors.Wrapf(err, "could not upload %s", key)
+	}
+
+	if err := a.verify(ctx, object); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// verify reads back the stored object and compares it with object
+func (a *Archiver) verify(ctx context.Context, object Object) error {
+	stored, err := a.store.Stat(ctx, object.Key)
+	if err != nil {
+		return errors.Wrapf(err, "could not verify %s", object.Key)
+	}
+	if stored.Size != object.Size || stored.SHA256 != object.SHA256 {
+		return errors.Wrap(ErrChecksumMismatch, object.Key)
+	}
+	return nil
+}
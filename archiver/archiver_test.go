@@ -0,0 +1,149 @@
+package archiver_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/archiver"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore struct {
+	mu      sync.Mutex
+	objects map[string]archiver.Object
+	content map[string][]byte
+	corrupt bool
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{objects: map[string]archiver.Object{}, content: map[string][]byte{}}
+}
+
+func (s *memoryStore) Put(ctx context.Context, object archiver.Object, body io.Reader) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if s.corrupt {
+		data = append(data, '!')
+	}
+	sum := sha256.Sum256(data)
+	object.Size = int64(len(data))
+	object.SHA256 = hex.EncodeToString(sum[:])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[object.Key] = object
+	s.content[object.Key] = data
+	return nil
+}
+
+func (s *memoryStore) Stat(ctx context.Context, key string) (*archiver.Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object, ok := s.objects[key]
+	if !ok {
+		return nil, archiver.ErrNotFound
+	}
+	return &object, nil
+}
+
+func newCRM(t *testing.T, attachments map[string][]gopayamgostar.AttachmentInfo, content map[string]string) (*httptest.Server, *[]string) {
+	var deleted []string
+	config := gopayamgostar.NewClient("").Config
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/" + config.FindFormEndpoint:
+			var data []gopayamgostar.FormResponse
+			for crmId := range attachments {
+				data = append(data, gopayamgostar.FormResponse{CRMID: crmId})
+			}
+			_ = json.NewEncoder(w).Encode(gopayamgostar.FindFormResponse{Data: data, Total: int64(len(data))})
+		case "/" + config.ListAttachmentEndpoint:
+			var request struct {
+				CRMObjectID string `json:"crmObjectId"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			_ = json.NewEncoder(w).Encode(attachments[request.CRMObjectID])
+		case "/" + config.DownloadAttachmentEndpoint:
+			var request gopayamgostar.GetRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = io.WriteString(w, content[request.ID])
+		case "/" + config.DeleteAttachmentEndpoint:
+			var request gopayamgostar.GetRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			deleted = append(deleted, request.ID)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &deleted
+}
+
+func TestArchive(t *testing.T) {
+	server, deleted := newCRM(t,
+		map[string][]gopayamgostar.AttachmentInfo{
+			"order-1": {{ID: "att-1", FileName: "invoice.pdf", ContentType: "application/pdf", Size: 5}},
+		},
+		map[string]string{"att-1": "%PDF-"},
+	)
+
+	store := newMemoryStore()
+	a := archiver.New(gopayamgostar.NewClient(server.URL), store)
+	a.Prefix = "crm"
+	a.TempDir = t.TempDir()
+
+	report, err := a.Archive(context.Background(), "token", "Order", nil)
+	require.NoError(t, err)
+	require.Empty(t, report.Failed)
+	require.Equal(t, []string{"crm/Order/order-1/att-1/invoice.pdf"}, report.Archived)
+	require.Equal(t, "%PDF-", string(store.content["crm/Order/order-1/att-1/invoice.pdf"]))
+	require.Equal(t, "att-1", store.objects["crm/Order/order-1/att-1/invoice.pdf"].Metadata["attachment-id"])
+	require.Empty(t, *deleted)
+
+	// a second run skips the archived attachment and, once verified, deletes it
+	a.DeleteAfterVerify = true
+	report, err = a.Archive(context.Background(), "token", "Order", nil)
+	require.NoError(t, err)
+	require.Empty(t, report.Archived)
+	require.Equal(t, []string{"crm/Order/order-1/att-1/invoice.pdf"}, report.Skipped)
+	require.Equal(t, []string{"att-1"}, report.Deleted)
+	require.Equal(t, []string{"att-1"}, *deleted)
+}
+
+func TestArchiveChecksumMismatch(t *testing.T) {
+	server, deleted := newCRM(t,
+		map[string][]gopayamgostar.AttachmentInfo{
+			"order-1": {{ID: "att-1", FileName: "../notes.txt", Size: 4}},
+		},
+		map[string]string{"att-1": "note"},
+	)
+
+	store := newMemoryStore()
+	store.corrupt = true
+	a := archiver.New(gopayamgostar.NewClient(server.URL), store)
+	a.DeleteAfterVerify = true
+	a.TempDir = t.TempDir()
+
+	report, err := a.Archive(context.Background(), "token", "Order", nil)
+	require.NoError(t, err)
+	require.Len(t, report.Failed, 1)
+	require.Equal(t, "att-1", report.Failed[0].AttachmentID)
+	require.ErrorIs(t, report.Failed[0].Err, archiver.ErrChecksumMismatch)
+	require.Empty(t, *deleted, "attachments are kept when their copy cannot be verified")
+
+	_, ok := store.objects["Order/order-1/att-1/.._notes.txt"]
+	require.True(t, ok)
+}
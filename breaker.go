@@ -0,0 +1,141 @@
+package gopayamgostar
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// CircuitState is the state of a circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets requests through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails requests with ErrCircuitOpen
+	CircuitOpen
+	// CircuitHalfOpen lets a single request through to probe the server
+	CircuitHalfOpen
+)
+
+// circuitBreaker opens after threshold consecutive failures and probes the
+// server again after coolDown
+type circuitBreaker struct {
+	threshold int
+	coolDown  time.Duration
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probeAt  time.Time
+}
+
+// allow reports whether a request may be sent
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	switch b.state {
+	case CircuitOpen:
+		if now.Sub(b.openedAt) < b.coolDown {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probeAt = now
+		return true
+	case CircuitHalfOpen:
+		// a probe that never completed, e.g. canceled before it was sent,
+		// must not keep the circuit half open forever
+		if now.Sub(b.probeAt) < b.coolDown {
+			return false
+		}
+		b.probeAt = now
+		return true
+	}
+	return true
+}
+
+func (b *circuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *circuitBreaker) currentState() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.coolDown {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// breakerTransport records the outcome of every attempt
+type breakerTransport struct {
+	next    http.RoundTripper
+	breaker *circuitBreaker
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if req.Context().Err() == nil {
+		t.breaker.record(err == nil && resp.StatusCode < http.StatusInternalServerError)
+	}
+	return resp, err
+}
+
+// WithCircuitBreaker opens the circuit after failureThreshold consecutive
+// failed requests (transport errors and 5xx responses). While it is open,
+// requests fail immediately with ErrCircuitOpen instead of piling up on a
+// server that is down. After coolDown a single request is let through: the
+// circuit closes when it succeeds and opens again when it fails.
+func WithCircuitBreaker(failureThreshold int, coolDown time.Duration) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		if failureThreshold < 1 {
+			failureThreshold = 1
+		}
+		b := &circuitBreaker{
+			threshold: failureThreshold,
+			coolDown:  coolDown,
+		}
+		g.breaker = b
+
+		httpClient := g.restyClient.GetClient()
+		next := httpClient.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		httpClient.Transport = &breakerTransport{next: next, breaker: b}
+
+		g.restyClient.OnBeforeRequest(func(c *resty.Client, r *resty.Request) error {
+			if !b.allow() {
+				return ErrCircuitOpen
+			}
+			return nil
+		})
+	}
+}
+
+// CircuitState returns the state of the circuit breaker, CircuitClosed when
+// the client has none
+func (g *GoPayamgostar) CircuitState() CircuitState {
+	if g.breaker == nil {
+		return CircuitClosed
+	}
+	return g.breaker.currentState()
+}
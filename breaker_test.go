@@ -0,0 +1,55 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var hits int32
+	var failing atomic.Bool
+	failing.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithCircuitBreaker(2, 30*time.Millisecond))
+	ctx := context.Background()
+
+	require.Error(t, client.DeleteForm(ctx, "token", "form"))
+	require.Equal(t, gopayamgostar.CircuitClosed, client.CircuitState())
+	require.Error(t, client.DeleteForm(ctx, "token", "form"))
+	require.Equal(t, gopayamgostar.CircuitOpen, client.CircuitState())
+
+	err := client.DeleteForm(ctx, "token", "form")
+	require.ErrorIs(t, err, gopayamgostar.ErrCircuitOpen)
+	var apiErr *gopayamgostar.APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, int32(2), atomic.LoadInt32(&hits))
+
+	// a failed probe opens the circuit again
+	time.Sleep(40 * time.Millisecond)
+	require.Equal(t, gopayamgostar.CircuitHalfOpen, client.CircuitState())
+	require.Error(t, client.DeleteForm(ctx, "token", "form"))
+	require.ErrorIs(t, client.DeleteForm(ctx, "token", "form"), gopayamgostar.ErrCircuitOpen)
+	require.Equal(t, int32(3), atomic.LoadInt32(&hits))
+
+	// a successful probe closes it
+	failing.Store(false)
+	time.Sleep(40 * time.Millisecond)
+	require.NoError(t, client.DeleteForm(ctx, "token", "form"))
+	require.Equal(t, gopayamgostar.CircuitClosed, client.CircuitState())
+	require.NoError(t, client.DeleteForm(ctx, "token", "form"))
+}
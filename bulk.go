@@ -0,0 +1,170 @@
+package gopayamgostar
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// defaultBulkConcurrency is the number of items a bulk operation sends at
+// once when BulkOptions leaves it unset
+const defaultBulkConcurrency = 4
+
+// BulkOptions configures the bulk operations, such as CreatePersons
+type BulkOptions struct {
+	// Concurrency is the number of items sent at once, 4 when 0 or less
+	Concurrency int
+	// StopOnError skips the items not started yet once an item fails. The
+	// skipped items fail with context.Canceled.
+	StopOnError bool
+	// Progress is called after every item sent with the number of items sent
+	// so far, the failed ones included. Calls are serialized.
+	Progress func(done, total int)
+}
+
+// BulkResult is the result of an item of a bulk operation
+type BulkResult struct {
+	// CrmId is the crm id of the object created for the item
+	CrmId string
+	// Err is the error of the item, nil when it succeeded
+	Err error
+}
+
+// CreatePersons creates persons concurrently, as for the initial migration of
+// the contacts of another system. The results are in the order of requests.
+// When items fail, it also returns a *MultiError keyed by their indexes, so
+// that the failed requests can be retried. The creations share the rate
+// limiter of the client.
+func (g *GoPayamgostar) CreatePersons(ctx context.Context, accessToken string, requests []CreatePersonRequest, options BulkOptions) ([]BulkResult, error) {
+	results := make([]BulkResult, len(requests))
+	err := g.bulk(ctx, accessToken, len(requests), options, func(ctx context.Context, accessToken string, i int) error {
+		crmId, err := g.CreatePerson(ctx, accessToken, requests[i])
+		results[i].CrmId = crmId
+		return err
+	})
+	setBulkErrors(results, err)
+	return results, err
+}
+
+// BulkCreateForms creates forms concurrently, as for importing the records of
+// another system. The results are in the order of requests and, like those of
+// CreatePersons, come with a *MultiError keyed by the indexes of the failed
+// items; FailedRequests returns their payloads.
+func (g *GoPayamgostar) BulkCreateForms(ctx context.Context, accessToken string, requests []CreateFormRequest, options BulkOptions) ([]BulkResult, error) {
+	results := make([]BulkResult, len(requests))
+	err := g.bulk(ctx, accessToken, len(requests), options, func(ctx context.Context, accessToken string, i int) error {
+		crmId, err := g.CreateForm(ctx, accessToken, requests[i])
+		results[i].CrmId = crmId
+		return err
+	})
+	setBulkErrors(results, err)
+	return results, err
+}
+
+// CreatedIDs returns the crm ids of the objects created by a bulk operation
+func CreatedIDs(results []BulkResult) []string {
+	var ids []string
+	for _, result := range results {
+		if result.Err == nil {
+			ids = append(ids, result.CrmId)
+		}
+	}
+	return ids
+}
+
+// FailedRequests returns the requests of a bulk operation whose results
+// failed, to report them or send them again
+func FailedRequests[T any](requests []T, results []BulkResult) []T {
+	var failed []T
+	for i, result := range results {
+		if result.Err != nil {
+			failed = append(failed, requests[i])
+		}
+	}
+	return failed
+}
+
+// Batch runs ops, at most concurrency at once, and returns a *MultiError
+// keyed by the indexes of the failed ones, nil when all succeeded. errors.As
+// finds the *APIError of a failed op in it. A concurrency of 0 or less runs 4
+// at once. It is the executor of the bulk operations of the client, for user
+// code to batch its own calls the same way:
+//
+//	ops := make([]func(ctx context.Context) error, len(ids))
+//	for i, id := range ids {
+//		ops[i] = func(ctx context.Context) error {
+//			return client.DeletePerson(ctx, accessToken, id)
+//		}
+//	}
+//	err := gopayamgostar.Batch(ctx, 8, ops)
+//
+// The ops run in the low priority lane of the rate limiter, so that a batch
+// never delays the interactive calls of a client sharing it. Once an op is
+// rejected with 401 Unauthorized or 403 Forbidden, the ops not started yet are
+// skipped, failing with context.Canceled, since they would be rejected too.
+func Batch(ctx context.Context, concurrency int, ops []func(ctx context.Context) error) error {
+	return batch(ctx, concurrency, false, ops)
+}
+
+func batch(ctx context.Context, concurrency int, stopOnError bool, ops []func(ctx context.Context) error) error {
+	if concurrency <= 0 {
+		concurrency = defaultBulkConcurrency
+	}
+	group := newGroup(WithPriority(ctx, PriorityLow), "", concurrency)
+	group.CancelOnError = stopOnError
+
+	for i, op := range ops {
+		group.Go(strconv.Itoa(i), func(ctx context.Context, _ string) error {
+			err := op(ctx)
+			if rejectedCredentials(err) {
+				group.cancel()
+			}
+			return err
+		})
+	}
+	return group.Wait()
+}
+
+// rejectedCredentials reports whether err is the rejection of the token or
+// API key of a request
+func rejectedCredentials(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && (apiErr.Code == http.StatusUnauthorized || apiErr.Code == http.StatusForbidden)
+}
+
+// bulk runs item for the n items of a bulk operation with batch
+func (g *GoPayamgostar) bulk(ctx context.Context, accessToken string, n int, options BulkOptions, item func(ctx context.Context, accessToken string, i int) error) error {
+	var mu sync.Mutex
+	done := 0
+	ops := make([]func(ctx context.Context) error, n)
+	for i := range ops {
+		ops[i] = func(ctx context.Context) error {
+			err := item(ctx, accessToken, i)
+			if options.Progress != nil {
+				mu.Lock()
+				done++
+				options.Progress(done, n)
+				mu.Unlock()
+			}
+			return err
+		}
+	}
+	return batch(ctx, options.Concurrency, options.StopOnError, ops)
+}
+
+// setBulkErrors sets the errors of the items of err, the *MultiError of bulk,
+// in results
+func setBulkErrors(results []BulkResult, err error) {
+	var multi *MultiError
+	if !errors.As(err, &multi) {
+		return
+	}
+	for _, taskErr := range multi.Errors {
+		if i, err := strconv.Atoi(taskErr.Key); err == nil {
+			results[i].Err = taskErr.Err
+		}
+	}
+}
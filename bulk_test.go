@@ -0,0 +1,185 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/faultinject"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+)
+
+func TestCreatePersons(t *testing.T) {
+	t.Parallel()
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	requests := make([]gopayamgostar.CreatePersonRequest, 20)
+	for i := range requests {
+		requests[i] = gopayamgostar.CreatePersonRequest{CRMObjectTypeCode: "Person", FirstName: "Person " + strconv.Itoa(i)}
+	}
+	// no default phone
+	requests[7].PhoneContacts = []gopayamgostar.PhoneContact{{PhoneNumber: "09120000000"}}
+
+	var progress, totals []int
+	results, err := client.CreatePersons(ctx, token.AccessToken, requests, gopayamgostar.BulkOptions{
+		Concurrency: 3,
+		Progress: func(done, total int) {
+			progress = append(progress, done)
+			totals = append(totals, total)
+		},
+	})
+	var multi *gopayamgostar.MultiError
+	require.ErrorAs(t, err, &multi)
+	require.Equal(t, []string{"7"}, multi.Keys())
+	require.Len(t, progress, len(requests))
+	require.Equal(t, len(requests), progress[len(progress)-1])
+	require.Equal(t, len(requests), totals[0])
+
+	require.Len(t, results, len(requests))
+	for i, result := range results {
+		if i == 7 {
+			require.ErrorIs(t, result.Err, gopayamgostar.ErrNoDefaultPhone)
+			require.Empty(t, result.CrmId)
+			continue
+		}
+		require.NoError(t, result.Err)
+		person, ok := server.Person(result.CrmId)
+		require.True(t, ok)
+		require.Equal(t, requests[i].FirstName, person.FirstName)
+	}
+}
+
+func TestCreatePersonsStopOnError(t *testing.T) {
+	t.Parallel()
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	requests := make([]gopayamgostar.CreatePersonRequest, 5)
+	requests[0].PhoneContacts = []gopayamgostar.PhoneContact{{PhoneNumber: "09120000000"}}
+
+	results, err := client.Session(token.AccessToken).CreatePersons(ctx, requests, gopayamgostar.BulkOptions{Concurrency: 1, StopOnError: true})
+	require.Error(t, err)
+	require.ErrorIs(t, results[0].Err, gopayamgostar.ErrNoDefaultPhone)
+	for _, result := range results[1:] {
+		require.ErrorIs(t, result.Err, context.Canceled)
+		require.Empty(t, result.CrmId)
+	}
+}
+
+func TestBulkCreateForms(t *testing.T) {
+	t.Parallel()
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	faultinject.Install(client).Add(faultinject.Rule{Operation: "CreateForm", Skip: 2, Times: 1, StatusCode: http.StatusInternalServerError, Body: `{"message":"boom"}`})
+
+	requests := make([]gopayamgostar.CreateFormRequest, 5)
+	for i := range requests {
+		subject := "form " + strconv.Itoa(i)
+		requests[i] = gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Complaint", Subject: &subject}
+	}
+
+	results, err := client.BulkCreateForms(ctx, token.AccessToken, requests, gopayamgostar.BulkOptions{Concurrency: 1})
+	var multi *gopayamgostar.MultiError
+	require.ErrorAs(t, err, &multi)
+	require.Equal(t, []string{"2"}, multi.Keys())
+	require.Error(t, results[2].Err)
+	require.Equal(t, requests[2:3], gopayamgostar.FailedRequests(requests, results))
+
+	ids := gopayamgostar.CreatedIDs(results)
+	require.Len(t, ids, 4)
+	for _, id := range ids {
+		_, ok := server.Form(id)
+		require.True(t, ok)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	t.Parallel()
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	personID := server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Ali"})
+
+	ctx := gopayamgostar.WithPriority(context.Background(), gopayamgostar.PriorityHigh)
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	var running, maxRunning int32
+	ops := make([]func(ctx context.Context) error, 6)
+	for i := range ops {
+		ops[i] = func(ctx context.Context) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			if gopayamgostar.PriorityFromContext(ctx) != gopayamgostar.PriorityLow {
+				return errors.New("not in the batch lane")
+			}
+			id := personID
+			if i == 4 {
+				id = "missing"
+			}
+			_, err := client.GetPersonInfoById(gopayamgostar.WithoutCache(ctx), token.AccessToken, id)
+			return err
+		}
+	}
+
+	err = gopayamgostar.Batch(ctx, 2, ops)
+	var multi *gopayamgostar.MultiError
+	require.ErrorAs(t, err, &multi)
+	require.Equal(t, []string{"4"}, multi.Keys())
+	var apiErr *gopayamgostar.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusNotFound, apiErr.Code)
+	require.LessOrEqual(t, atomic.LoadInt32(&maxRunning), int32(2))
+
+	// the ops following a rejected token are skipped
+	var calls int32
+	ops = make([]func(ctx context.Context) error, 4)
+	for i := range ops {
+		ops[i] = func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			_, err := client.GetPersonInfoById(gopayamgostar.WithoutCache(ctx), "revoked", personID)
+			return err
+		}
+	}
+	err = gopayamgostar.Batch(ctx, 1, ops)
+	require.ErrorAs(t, err, &multi)
+	require.Len(t, multi.Errors, 4)
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	require.ErrorIs(t, multi.Errors[3], context.Canceled)
+}
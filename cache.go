@@ -0,0 +1,138 @@
+package gopayamgostar
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Cache stores the responses of read-only methods for WithCache. Values are
+// the JSON bodies returned by the server.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+	Delete(ctx context.Context, key string)
+}
+
+// MemoryCache is a Cache kept in memory. Expired entries are dropped when
+// they are read.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+}
+
+type memoryCacheEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewMemoryCache creates an empty MemoryCache
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: map[string]memoryCacheEntry{}}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = memoryCacheEntry{value: value, expiresAt: time.Now().Add(ttl)}
+}
+
+func (c *MemoryCache) Delete(ctx context.Context, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// WithCache caches the results of GetPersonInfoById, GetFormInfoById and
+// ListColors in cache for ttl. Entries are kept per access token, so that
+// users with different permissions do not read each other's objects; a
+// renewed token does not read the entries of the token it replaces.
+// UpdateForm, DeleteForm, DeletePurchase, StartProcess and UpdatePerson drop
+// the object they change from the cache of their token; changes made by other
+// users, tokens or clients show up once ttl has passed, or at once for
+// requests made with WithoutCache.
+func WithCache(cache Cache, ttl time.Duration) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		g.cache = cache
+		g.cacheTTL = ttl
+	}
+}
+
+func personCacheKey(accessToken, crmId string) string {
+	return "person:" + crmId + ":" + tokenScope(accessToken)
+}
+
+func formCacheKey(accessToken, crmId string) string {
+	return "form:" + crmId + ":" + tokenScope(accessToken)
+}
+
+func colorsCacheKey(accessToken string) string {
+	return "colors:" + tokenScope(accessToken)
+}
+
+// tokenScope identifies accessToken in cache keys by a hash of the whole
+// token. The claims of the token are not verified by the client, so they
+// cannot tell whose entries a token may read: a renewed token starts with an
+// empty cache instead.
+func tokenScope(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return hex.EncodeToString(sum[:16])
+}
+
+var noCacheContextKey = contextKey("no-cache")
+
+// WithoutCache returns a context that makes the requests using it skip the
+// cache of WithCache and read from the server. Their results still refresh
+// the cache.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheContextKey, true)
+}
+
+// cached decodes the cached value of key into v and reports whether there was one
+func (g *GoPayamgostar) cached(ctx context.Context, key string, v interface{}) bool {
+	if g.cache == nil {
+		return false
+	}
+	if skip, _ := ctx.Value(noCacheContextKey).(bool); skip {
+		return false
+	}
+	data, ok := g.cache.Get(ctx, key)
+	if !ok {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+// storeCached caches the response body data under key
+func (g *GoPayamgostar) storeCached(ctx context.Context, key string, data []byte) {
+	if g.cache == nil {
+		return
+	}
+	g.cache.Set(ctx, key, data, g.cacheTTL)
+}
+
+// invalidateCached drops key from the cache, unless the change was not sent
+// by a dry run
+func (g *GoPayamgostar) invalidateCached(ctx context.Context, key string) {
+	if g.cache == nil || g.dryRunning(ctx) {
+		return
+	}
+	g.cache.Delete(ctx, key)
+}
@@ -0,0 +1,116 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+)
+
+func TestWithCache(t *testing.T) {
+	t.Parallel()
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+
+	client := server.Client(gopayamgostar.WithCache(gopayamgostar.NewMemoryCache(), time.Minute))
+	var gets int32
+	client.RegisterRequestHook(func(info gopayamgostar.HookInfo, req *http.Request) error {
+		if info.Endpoint == "GetForm" {
+			atomic.AddInt32(&gets, 1)
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	formID, err := client.CreateForm(ctx, token.AccessToken, gopayamgostar.CreateFormRequest{
+		CRMObjectTypeCode: "Order",
+		Subject:           gopayamgostar.StringP("first order"),
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		form, err := client.GetFormInfoById(ctx, token.AccessToken, formID)
+		require.NoError(t, err)
+		require.Equal(t, "first order", form.Subject)
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&gets))
+
+	_, err = client.GetFormInfoById(gopayamgostar.WithoutCache(ctx), token.AccessToken, formID)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), atomic.LoadInt32(&gets))
+
+	_, err = client.UpdateForm(ctx, token.AccessToken, gopayamgostar.UpdateFormRequest{CrmId: formID, Subject: "updated"})
+	require.NoError(t, err)
+	form, err := client.GetFormInfoById(ctx, token.AccessToken, formID)
+	require.NoError(t, err)
+	require.Equal(t, "updated", form.Subject, "updates invalidate the cached form")
+	require.Equal(t, int32(3), atomic.LoadInt32(&gets))
+
+	require.NoError(t, client.DeleteForm(ctx, token.AccessToken, formID))
+	_, err = client.GetFormInfoById(ctx, token.AccessToken, formID)
+	require.Error(t, err, "deletes invalidate the cached form")
+}
+
+func TestMemoryCacheExpiry(t *testing.T) {
+	t.Parallel()
+
+	cache := gopayamgostar.NewMemoryCache()
+	ctx := context.Background()
+	cache.Set(ctx, "key", []byte("value"), time.Hour)
+	cache.Set(ctx, "expired", []byte("value"), -time.Second)
+
+	value, ok := cache.Get(ctx, "key")
+	require.True(t, ok)
+	require.Equal(t, "value", string(value))
+	_, ok = cache.Get(ctx, "expired")
+	require.False(t, ok)
+
+	cache.Delete(ctx, "key")
+	_, ok = cache.Get(ctx, "key")
+	require.False(t, ok)
+}
+
+func TestCacheIsPerUser(t *testing.T) {
+	t.Parallel()
+
+	var gets int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&gets, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"crmId":"person-1","firstName":"Ali"}`))
+	}))
+	defer server.Close()
+
+	token := func(claims string) string {
+		return "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".c2lnbmF0dXJl"
+	}
+	ctx := context.Background()
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithCache(gopayamgostar.NewMemoryCache(), time.Minute))
+
+	for _, accessToken := range []string{
+		token(`{"sub":"admin","iat":1}`),
+		token(`{"sub":"admin","iat":1}`),
+		token(`{"sub":"admin","iat":2}`),
+		token(`{"sub":"support","iat":1}`),
+		"eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","iat":1}`)) + ".",
+		"opaque-1",
+		"opaque-1",
+		"opaque-2",
+	} {
+		_, err := client.GetPersonInfoById(ctx, accessToken, "person-1")
+		require.NoError(t, err)
+	}
+	require.Equal(t, int32(6), atomic.LoadInt32(&gets), "only the same token reads the cache, not tokens naming the same user")
+}
@@ -1,33 +1,115 @@
 package gopayamgostar
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
 	"github.com/go-resty/resty/v2"
-	"github.com/google/uuid"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 )
 
 type GoPayamgostar struct {
-	basePath    string
-	restyClient *resty.Client
-	Config      struct {
-		AuthEndpoint           string
-		RefreshTokenEndpoint   string
-		GetFormEndpoint        string
-		CreateFormEndpoint     string
-		FindFormEndpoint       string
-		UpdateFormEndpoint     string
-		GetPersonEndpoint      string
-		FindPersonEndpoint     string
-		CreatePurchaseEndpoint string
-		DeletePurchaseEndpoint string
-	}
+	basePath            string
+	restyClient         *resty.Client
+	streamRequestBodies bool
+	gzipRequests        bool
+	dryRun              bool
+	codec               Codec
+	stableFindSort      bool
+	schema              schemaPins
+	debugHistory        *debugHistory
+	idGenerator         IDGenerator
+	random              *lockedRand
+	health              *healthTracker
+	breaker             *circuitBreaker
+	rateLimiter         *RateLimiter
+	backoff             resty.RetryAfterFunc
+	policies            map[string]Policy
+	hooks               *clientHooks
+	transport           *hooksTransport
+	ownTransport        *http.Transport
+	serverInfo          *serverInfo
+	redactedHeaders     map[string]bool
+	tokens              *tokenCache
+	heldToken           atomic.Value
+	apiKey              string
+	successStatus       map[string][]int
+	events              *eventBus
+	cache               Cache
+	cacheTTL            time.Duration
+	ref                 *RefData
+	endpointNamesOnce   sync.Once
+	endpointNames       map[string]string
+	Config              EndpointConfig
+}
+
+// EndpointConfig holds the paths of the endpoints, relative to the base path
+// of the client. Change them with WithEndpoint or WithEndpointConfig.
+type EndpointConfig struct {
+	AuthEndpoint                   string
+	RefreshTokenEndpoint           string
+	GetFormEndpoint                string
+	CreateFormEndpoint             string
+	FindFormEndpoint               string
+	UpdateFormEndpoint             string
+	GetPersonEndpoint              string
+	FindPersonEndpoint             string
+	CreatePurchaseEndpoint         string
+	DeletePurchaseEndpoint         string
+	CreateTaskEndpoint             string
+	GetTaskEndpoint                string
+	UpdateTaskEndpoint             string
+	CompleteTaskEndpoint           string
+	FindTaskEndpoint               string
+	CreateTicketEndpoint           string
+	GetTicketEndpoint              string
+	ReplyTicketEndpoint            string
+	CloseTicketEndpoint            string
+	FindTicketEndpoint             string
+	CreateNoteEndpoint             string
+	ListNoteEndpoint               string
+	FindInvoiceEndpoint            string
+	DeleteFormEndpoint             string
+	CreateReceiptEndpoint          string
+	DeleteReceiptEndpoint          string
+	SendEmailEndpoint              string
+	EmailStatusEndpoint            string
+	CreateOpportunityEndpoint      string
+	GetOpportunityEndpoint         string
+	UpdateOpportunityStageEndpoint string
+	FindOpportunityEndpoint        string
+	GetObjectTypeEndpoint          string
+	ListUserEndpoint               string
+	GetUserEndpoint                string
+	CurrentUserEndpoint            string
+	UploadAttachmentEndpoint       string
+	ListAttachmentEndpoint         string
+	DownloadAttachmentEndpoint     string
+	GetPicklistEndpoint            string
+	AddTagsEndpoint                string
+	RemoveTagsEndpoint             string
+	ReplaceTagsEndpoint            string
+	ListTagsEndpoint               string
+	UpdatePersonEndpoint           string
+	ListColorEndpoint              string
+	StartProcessEndpoint           string
+	RenderFormPDFEndpoint          string
+	DeleteAttachmentEndpoint       string
+	GetProductEndpoint             string
+	CreatePersonEndpoint           string
+	DeletePersonEndpoint           string
+	LogoutEndpoint                 string
 }
 
 const (
@@ -39,24 +121,58 @@ func makeURL(path ...string) string {
 	return strings.Join(path, urlSeparator)
 }
 
+// endpointName maps a request path to the name of its Config endpoint, e.g.
+// "CreateForm", for use in logs and metric labels. Unknown paths are "other".
+func (g *GoPayamgostar) endpointName(path string) string {
+	g.endpointNamesOnce.Do(func() {
+		g.endpointNames = map[string]string{}
+		prefix := ""
+		if u, err := url.Parse(g.basePath); err == nil {
+			prefix = strings.TrimRight(u.Path, urlSeparator)
+		}
+		config := reflect.ValueOf(g.Config)
+		for i := 0; i < config.NumField(); i++ {
+			name := strings.TrimSuffix(config.Type().Field(i).Name, "Endpoint")
+			g.endpointNames[prefix+urlSeparator+config.Field(i).String()] = name
+		}
+	})
+
+	if name, ok := g.endpointNames[path]; ok {
+		return name
+	}
+	return "other"
+}
+
 // GetRequest returns a request for calling endpoints.
 func (g *GoPayamgostar) GetRequest(ctx context.Context) *resty.Request {
 	var err HTTPErrorResponse
+	ctx = g.withPolicy(ctx)
 	return injectTracingHeaders(
 		ctx, g.restyClient.R().
 			SetContext(ctx).
+			SetHeader(correlationIDHeader, g.idGenerator.NewID()).
 			SetError(&err),
 	)
 }
 
-func getID(resp *resty.Response) (string, error) {
+// unmarshalBody decodes a JSON response body into v with the codec of the
+// client. Several endpoints answer 200 with an empty body on success, which
+// leaves v untouched.
+func (g *GoPayamgostar) unmarshalBody(data []byte, v interface{}) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	return g.jsonCodec().Unmarshal(data, v)
+}
+
+func (g *GoPayamgostar) getID(resp *resty.Response) (string, error) {
 	// Define a struct to match the expected response structure
 	var result struct {
 		CrmId string `json:"crmId"`
 	}
 
 	// Unmarshal the response body into the result struct
-	err := json.Unmarshal(resp.Body(), &result)
+	err := g.unmarshalBody(resp.Body(), &result)
 	if err != nil {
 		return "", fmt.Errorf("failed to unmarshal response: %w", err)
 	}
@@ -88,17 +204,19 @@ func injectTracingHeaders(ctx context.Context, req *resty.Request) *resty.Reques
 }
 
 // GetRequestWithBearerAuthNoCache returns a JSON base request configured with an auth token and no-cache header.
+// An empty token uses the one set with SetAccessToken.
 func (g *GoPayamgostar) GetRequestWithBearerAuthNoCache(ctx context.Context, token string) *resty.Request {
 	return g.GetRequest(ctx).
-		SetAuthToken(token).
+		SetAuthToken(g.tokenOrHeld(token)).
 		SetHeader("Content-Type", "application/json").
 		SetHeader("Cache-Control", "no-cache")
 }
 
 // GetRequestWithBearerAuth returns a JSON base request configured with an auth token.
+// An empty token uses the one set with SetAccessToken.
 func (g *GoPayamgostar) GetRequestWithBearerAuth(ctx context.Context, token string) *resty.Request {
 	return g.GetRequest(ctx).
-		SetAuthToken(token).
+		SetAuthToken(g.tokenOrHeld(token)).
 		SetHeader("Content-Type", "application/json")
 }
 
@@ -106,6 +224,10 @@ func NewClient(basePath string, options ...func(*GoPayamgostar)) *GoPayamgostar
 	c := GoPayamgostar{
 		basePath:    strings.TrimRight(basePath, urlSeparator),
 		restyClient: resty.New(),
+		idGenerator: UUIDGenerator{},
+		tokens:      newTokenCache(strings.TrimRight(basePath, urlSeparator)),
+		serverInfo:  &serverInfo{},
+		events:      &eventBus{},
 	}
 
 	c.Config.AuthEndpoint = makeURL("api", "v2", "auth", "login")
@@ -118,16 +240,199 @@ func NewClient(basePath string, options ...func(*GoPayamgostar)) *GoPayamgostar
 	c.Config.FindPersonEndpoint = makeURL("api", "v2", "crmobject", "person", "find")
 	c.Config.CreatePurchaseEndpoint = makeURL("api", "v2", "crmobject", "invoice", "purchase", "create")
 	c.Config.DeletePurchaseEndpoint = makeURL("api", "v2", "crmobject", "invoice", "purchase", "delete")
+	c.Config.CreateTaskEndpoint = makeURL("api", "v2", "crmobject", "task", "create")
+	c.Config.GetTaskEndpoint = makeURL("api", "v2", "crmobject", "task", "get")
+	c.Config.UpdateTaskEndpoint = makeURL("api", "v2", "crmobject", "task", "update")
+	c.Config.CompleteTaskEndpoint = makeURL("api", "v2", "crmobject", "task", "complete")
+	c.Config.FindTaskEndpoint = makeURL("api", "v2", "crmobject", "task", "find")
+	c.Config.CreateTicketEndpoint = makeURL("api", "v2", "crmobject", "ticket", "create")
+	c.Config.GetTicketEndpoint = makeURL("api", "v2", "crmobject", "ticket", "get")
+	c.Config.ReplyTicketEndpoint = makeURL("api", "v2", "crmobject", "ticket", "reply")
+	c.Config.CloseTicketEndpoint = makeURL("api", "v2", "crmobject", "ticket", "close")
+	c.Config.FindTicketEndpoint = makeURL("api", "v2", "crmobject", "ticket", "find")
+	c.Config.CreateNoteEndpoint = makeURL("api", "v2", "crmobject", "note", "create")
+	c.Config.ListNoteEndpoint = makeURL("api", "v2", "crmobject", "note", "list")
+	c.Config.FindInvoiceEndpoint = makeURL("api", "v2", "crmobject", "invoice", "find")
+	c.Config.DeleteFormEndpoint = makeURL("api", "v2", "crmobject", "form", "delete")
+	c.Config.CreateReceiptEndpoint = makeURL("api", "v2", "crmobject", "receipt", "create")
+	c.Config.DeleteReceiptEndpoint = makeURL("api", "v2", "crmobject", "receipt", "delete")
+	c.Config.SendEmailEndpoint = makeURL("api", "v2", "email", "send")
+	c.Config.EmailStatusEndpoint = makeURL("api", "v2", "email", "status")
+	c.Config.CreateOpportunityEndpoint = makeURL("api", "v2", "crmobject", "opportunity", "create")
+	c.Config.GetOpportunityEndpoint = makeURL("api", "v2", "crmobject", "opportunity", "get")
+	c.Config.UpdateOpportunityStageEndpoint = makeURL("api", "v2", "crmobject", "opportunity", "stage", "update")
+	c.Config.FindOpportunityEndpoint = makeURL("api", "v2", "crmobject", "opportunity", "find")
+	c.Config.GetObjectTypeEndpoint = makeURL("api", "v2", "crmobject", "type", "get")
+	c.Config.ListUserEndpoint = makeURL("api", "v2", "user", "list")
+	c.Config.GetUserEndpoint = makeURL("api", "v2", "user", "get")
+	c.Config.CurrentUserEndpoint = makeURL("api", "v2", "user", "current")
+	c.Config.UploadAttachmentEndpoint = makeURL("api", "v2", "crmobject", "attachment", "upload")
+	c.Config.ListAttachmentEndpoint = makeURL("api", "v2", "crmobject", "attachment", "list")
+	c.Config.DownloadAttachmentEndpoint = makeURL("api", "v2", "crmobject", "attachment", "download")
+	c.Config.GetPicklistEndpoint = makeURL("api", "v2", "picklist", "get")
+	c.Config.AddTagsEndpoint = makeURL("api", "v2", "crmobject", "tag", "add")
+	c.Config.RemoveTagsEndpoint = makeURL("api", "v2", "crmobject", "tag", "remove")
+	c.Config.ReplaceTagsEndpoint = makeURL("api", "v2", "crmobject", "tag", "replace")
+	c.Config.ListTagsEndpoint = makeURL("api", "v2", "crmobject", "tag", "list")
+	c.Config.UpdatePersonEndpoint = makeURL("api", "v2", "crmobject", "person", "update")
+	c.Config.ListColorEndpoint = makeURL("api", "v2", "crmobject", "color", "list")
+	c.Config.StartProcessEndpoint = makeURL("api", "v2", "crmobject", "process", "start")
+	c.Config.RenderFormPDFEndpoint = makeURL("api", "v2", "crmobject", "print")
+	c.Config.DeleteAttachmentEndpoint = makeURL("api", "v2", "crmobject", "attachment", "delete")
+	c.Config.GetProductEndpoint = makeURL("api", "v2", "product", "get")
+	c.Config.CreatePersonEndpoint = makeURL("api", "v2", "crmobject", "person", "create")
+	c.Config.DeletePersonEndpoint = makeURL("api", "v2", "crmobject", "person", "delete")
+	c.Config.LogoutEndpoint = makeURL("api", "v2", "auth", "logout")
+
+	c.tokens.refresh = c.RefreshToken
+	c.ref = newRefData(&c)
+	c.restyClient.SetJSONUnmarshaler(c.unmarshalBody)
+	c.installHooks()
+	c.restyClient.SetRetryAfter(c.retryAfter)
 
 	for _, option := range options {
 		option(&c)
 	}
+	c.installPolicyRetries()
 
 	return &c
 }
 
+// WithTimeout limits every attempt of a request to timeout, connecting and
+// reading the response included. Use a context deadline to limit a whole
+// call with its retries.
+func WithTimeout(timeout time.Duration) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		g.restyClient.SetTimeout(timeout)
+	}
+}
+
+// WithUserAgent sends userAgent as the User-Agent header of every request
+func WithUserAgent(userAgent string) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		g.restyClient.SetHeader("User-Agent", userAgent)
+	}
+}
+
+// WithRestyClient makes the client send its requests with restyClient, e.g.
+// one with custom TLS or proxy settings. Pass it before the other options:
+// they configure the resty client in place, so settings made before it are
+// lost.
+func WithRestyClient(restyClient *resty.Client) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		g.restyClient = restyClient.
+			SetJSONUnmarshaler(g.unmarshalBody).
+			SetRetryAfter(g.retryAfter)
+		if g.codec != nil {
+			g.restyClient.SetJSONMarshaler(g.codec.Marshal)
+		}
+		g.installHooks()
+	}
+}
+
+// WithTransport sends the requests of the client through transport, under the
+// transports of the other options such as WithCircuitBreaker and WithMetrics.
+// Pass it before WithMaxIdleConns and WithIdleTimeout for them to tune it.
+func WithTransport(transport http.RoundTripper) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		g.transport.next = transport
+	}
+}
+
+// WithMaxIdleConns keeps up to n idle keep-alive connections to the server
+// open, instead of the 2 of net/http, so that services sending many concurrent
+// requests reuse their connections instead of opening new ones
+func WithMaxIdleConns(n int) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		if transport := g.tunedTransport(); transport != nil {
+			transport.MaxIdleConns = n
+			transport.MaxIdleConnsPerHost = n
+		}
+	}
+}
+
+// WithIdleTimeout closes the keep-alive connections that stayed idle for
+// timeout, 90 seconds by default. Lower it when a load balancer drops idle
+// connections sooner.
+func WithIdleTimeout(timeout time.Duration) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		if transport := g.tunedTransport(); transport != nil {
+			transport.IdleConnTimeout = timeout
+		}
+	}
+}
+
+// tunedTransport returns the *http.Transport the client sends its requests
+// through, cloning the shared one on first use so that tuning never changes
+// other clients. It returns nil when WithTransport set a different kind of
+// transport, which the options then leave as it is.
+func (g *GoPayamgostar) tunedTransport() *http.Transport {
+	if g.ownTransport != nil && g.transport.next == g.ownTransport {
+		return g.ownTransport
+	}
+	transport, ok := g.transport.next.(*http.Transport)
+	if !ok {
+		return nil
+	}
+	g.ownTransport = transport.Clone()
+	g.transport.next = g.ownTransport
+	return g.ownTransport
+}
+
+// WithDebug logs every request and response of the client with the logger of
+// the resty client. The logs include the access tokens, so do not enable it
+// in production.
+func WithDebug() func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		g.restyClient.SetDebug(true)
+	}
+}
+
+// WithStreamingRequestBodies makes the client encode large payloads (CreateForm,
+// CreatePurchaseInvoice) straight onto the connection instead of marshaling them into
+// memory first.
+func WithStreamingRequestBodies() func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		g.streamRequestBodies = true
+	}
+}
+
+// WithStableFindSort appends a CrmId sort to every find request. Records that
+// share the same sort value, such as a bulk import with a single ModifyDate,
+// are otherwise returned in an arbitrary order per page, so paging through
+// them skips and duplicates rows.
+func WithStableFindSort() func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		g.stableFindSort = true
+	}
+}
+
+// findRequest validates request, appends the CrmId tiebreaker to it when
+// WithStableFindSort is enabled and the request is not sorted on it already,
+// and sets the fields of WithFields
+func (g *GoPayamgostar) findRequest(ctx context.Context, request FindRequest) (FindRequest, error) {
+	if err := request.Validate(); err != nil {
+		return request, err
+	}
+	request = projectedFindRequest(ctx, request)
+	if !g.stableFindSort {
+		return request, nil
+	}
+	for _, sort := range request.Sorts {
+		if strings.EqualFold(sort.Field, crmIdSortField) {
+			return request, nil
+		}
+	}
+	request.Sorts = append(request.Sorts, Sort{Field: crmIdSortField})
+	return request, nil
+}
+
 // RestyClient returns the internal resty g.
-// This can be used to configure the g.
+// This can be used to configure the g. Prefer the options of NewClient:
+// changing the resty client while requests are in flight is racy.
 func (g *GoPayamgostar) RestyClient() *resty.Client {
 	return g.restyClient
 }
@@ -137,12 +442,13 @@ func (g *GoPayamgostar) SetRestyClient(restyClient *resty.Client) {
 	g.restyClient = restyClient
 }
 
-func checkForError(resp *resty.Response, err error, errMessage string) error {
+func (g *GoPayamgostar) checkForError(resp *resty.Response, err error, errMessage string) error {
 	if err != nil {
 		return &APIError{
 			Code:    0,
 			Message: errors.Wrap(err, errMessage).Error(),
 			Type:    ParseAPIErrType(err),
+			err:     err,
 		}
 	}
 
@@ -153,7 +459,11 @@ func checkForError(resp *resty.Response, err error, errMessage string) error {
 		}
 	}
 
-	if resp.IsError() {
+	if resp.Header().Get(DryRunHeader) != "" {
+		return errors.Wrap(ErrDryRun, errMessage)
+	}
+
+	if !g.succeeded(resp) {
 		var msg string
 
 		if e, ok := resp.Error().(*HTTPErrorResponse); ok && e.NotEmpty() {
@@ -162,22 +472,56 @@ func checkForError(resp *resty.Response, err error, errMessage string) error {
 			msg = resp.Status()
 		}
 
-		return &APIError{
-			Code:    resp.StatusCode(),
-			Message: msg,
-			Type:    ParseAPIErrType(err),
+		apiErr := &APIError{
+			Code:      resp.StatusCode(),
+			Message:   msg,
+			Type:      matchAPIErrType(msg + "\n" + string(resp.Body())),
+			Body:      resp.Body(),
+			RequestID: resp.Header().Get(requestIDHeader),
+			err:       statusErrors[resp.StatusCode()],
+		}
+		if request := resp.Request; request != nil {
+			if apiErr.RequestID == "" {
+				apiErr.RequestID = request.Header.Get(correlationIDHeader)
+			}
+			if request.RawRequest != nil {
+				apiErr.Endpoint = request.Method + " " + request.RawRequest.URL.Path
+			}
 		}
+		return statusError(apiErr)
 	}
 
 	return nil
 }
 
+// requestBody returns the value to hand to resty as the request body.
+func (g *GoPayamgostar) requestBody(v interface{}) interface{} {
+	if g.streamRequestBodies {
+		return newJSONStream(v)
+	}
+	return v
+}
+
 func (g *GoPayamgostar) getFullEndpointURL(path ...string) string {
 	path = append([]string{g.basePath, g.Config.AuthEndpoint}, path...)
 	return makeURL(path...)
 }
 
+// AdminAuthenticate logs in with the credentials of an admin. Concurrent
+// calls with the same credentials share a single login and the token is
+// reused until shortly before it expires, then renewed with its refresh
+// token, see ClearTokenCache. With WithAPIKey, it returns an empty token
+// without logging in.
 func (g *GoPayamgostar) AdminAuthenticate(ctx context.Context, username string, password string) (*JWT, error) {
+	if g.apiKey != "" {
+		return &JWT{}, nil
+	}
+	return g.tokens.login(ctx, "admin", username, password, func(ctx context.Context) (*JWT, error) {
+		return g.adminAuthenticate(ctx, username, password)
+	})
+}
+
+func (g *GoPayamgostar) adminAuthenticate(ctx context.Context, username string, password string) (*JWT, error) {
 	const errMessage = "could not get token"
 
 	var token JWT
@@ -190,20 +534,75 @@ func (g *GoPayamgostar) AdminAuthenticate(ctx context.Context, username string,
 		Username:     username,
 		Password:     password,
 		PlatformType: int(enums.Web),
-		DeviceId:     uuid.NewString(),
+		DeviceId:     g.idGenerator.NewID(),
 	}
 	resp, err := req.SetBody(model).
 		SetResult(&token).
 		Post(g.basePath + "/" + g.Config.AuthEndpoint)
 
-	if err := checkForError(resp, err, errMessage); err != nil {
+	if err := g.checkForError(resp, err, errMessage); err != nil {
 		return nil, err
 	}
 
 	return &token, nil
 }
 
+// RefreshToken exchanges the refresh token of a JWT for a new JWT, so that
+// long-running services renew their token without logging in again.
+// AdminAuthenticate and UserAuthenticate already renew the tokens they cache
+// this way once they expire.
+func (g *GoPayamgostar) RefreshToken(ctx context.Context, refreshToken string) (*JWT, error) {
+	const errMessage = "could not refresh token"
+
+	var token JWT
+
+	resp, err := g.GetRequest(ctx).
+		SetBody(RefreshTokenRequest{RefreshToken: refreshToken}).
+		SetResult(&token).
+		Post(g.basePath + "/" + g.Config.RefreshTokenEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// Logout ends the session of accessToken on the server, so that short-lived
+// jobs do not leave it open until it times out. The token is dropped from the
+// token cache and, when it is the one set with SetAccessToken, released.
+func (g *GoPayamgostar) Logout(ctx context.Context, accessToken string) error {
+	const errMessage = "could not logout"
+
+	accessToken = g.tokenOrHeld(accessToken)
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		Post(g.basePath + "/" + g.Config.LogoutEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return err
+	}
+
+	g.tokens.revoke(ctx, accessToken)
+	g.heldToken.CompareAndSwap(accessToken, "")
+	return nil
+}
+
+// UserAuthenticate logs in with the credentials of a customer user. Concurrent
+// calls with the same credentials share a single login and the token is
+// reused until shortly before it expires, then renewed with its refresh
+// token, see ClearTokenCache. With WithAPIKey, it returns an empty token
+// without logging in.
 func (g *GoPayamgostar) UserAuthenticate(ctx context.Context, username string, password string) (*JWT, error) {
+	if g.apiKey != "" {
+		return &JWT{}, nil
+	}
+	return g.tokens.login(ctx, "user", username, password, func(ctx context.Context) (*JWT, error) {
+		return g.userAuthenticate(ctx, username, password)
+	})
+}
+
+func (g *GoPayamgostar) userAuthenticate(ctx context.Context, username string, password string) (*JWT, error) {
 	const errMessage = "could not get token(customer)"
 
 	var token JWT
@@ -216,13 +615,13 @@ func (g *GoPayamgostar) UserAuthenticate(ctx context.Context, username string, p
 		Username:     username,
 		Password:     password,
 		PlatformType: 1,
-		DeviceId:     uuid.NewString(),
+		DeviceId:     g.idGenerator.NewID(),
 	}
 	resp, err := req.SetBody(model).
 		SetResult(&token).
 		Post(g.basePath + "/" + g.Config.AuthEndpoint)
 
-	if err := checkForError(resp, err, errMessage); err != nil {
+	if err := g.checkForError(resp, err, errMessage); err != nil {
 		return nil, err
 	}
 
@@ -232,12 +631,20 @@ func (g *GoPayamgostar) UserAuthenticate(ctx context.Context, username string, p
 func (g *GoPayamgostar) GetPersonInfoById(ctx context.Context, accessToken, crmId string) (*PersonInfo, error) {
 	const errMessage = "could not get user info"
 
+	// a cached person may lack the list fields, the responses with them can
+	// still be cached for the calls without
+	listFields := includeListFields(ctx)
+
 	var result PersonInfo
+	if !listFields && g.cached(ctx, personCacheKey(accessToken, crmId), &result) {
+		return &result, nil
+	}
 
 	model := GetRequest{
 		ID:                   crmId,
 		ShowPreviews:         *BoolP(false),
 		ShowExtendedPreviews: *BoolP(true),
+		IncludeListFields:    listFields,
 	}
 
 	resp, err := g.GetRequestWithBearerAuth(ctx, accessToken).
@@ -245,10 +652,16 @@ func (g *GoPayamgostar) GetPersonInfoById(ctx context.Context, accessToken, crmI
 		SetResult(&result).
 		Post(g.basePath + "/" + g.Config.GetPersonEndpoint)
 
-	if err := checkForError(resp, err, errMessage); err != nil {
+	if err := g.checkForError(resp, err, errMessage); err != nil {
 		return nil, err
 	}
 
+	if staleness, ok := servedStale(resp); ok {
+		result.Staleness = staleness
+		return &result, ErrServedStale
+	}
+	g.storeCached(ctx, personCacheKey(accessToken, crmId), resp.Body())
+
 	return &result, nil
 }
 
@@ -256,6 +669,9 @@ func (g *GoPayamgostar) GetFormInfoById(ctx context.Context, accessToken, crmId
 	const errMessage = "could not get form info"
 
 	var result FormInfo
+	if g.cached(ctx, formCacheKey(accessToken, crmId), &result) {
+		return &result, nil
+	}
 
 	model := GetRequest{
 		ID:                   crmId,
@@ -268,51 +684,171 @@ func (g *GoPayamgostar) GetFormInfoById(ctx context.Context, accessToken, crmId
 		SetResult(&result).
 		Post(g.basePath + "/" + g.Config.GetFormEndpoint)
 
-	if err := checkForError(resp, err, errMessage); err != nil {
+	if err := g.checkForError(resp, err, errMessage); err != nil {
 		return nil, err
 	}
 
+	if staleness, ok := servedStale(resp); ok {
+		result.Staleness = staleness
+		return &result, ErrServedStale
+	}
+	g.storeCached(ctx, formCacheKey(accessToken, crmId), resp.Body())
+
 	return &result, nil
 }
 
+// CreatePurchase creates a purchase invoice.
+//
+// Deprecated: use CreatePurchaseInvoice.
 func (g *GoPayamgostar) CreatePurchase(ctx context.Context, accessToken string, purchase CreatePurchase) (string, error) {
+	request, err := purchase.Request()
+	if err != nil {
+		return "", errors.Wrap(err, "could not create purchase")
+	}
+	// the dates are sent as the caller wrote them
+	return g.createPurchase(ctx, accessToken, request.CRMObjectTypeCode, legacyPurchaseRequest{
+		CreatePurchaseRequest: request,
+		InvoiceDate:           purchase.InvoiceDate,
+		ExpireDate:            purchase.ExpireDate,
+	})
+}
+
+// CreatePurchaseInvoice creates a purchase invoice and returns its crm id
+func (g *GoPayamgostar) CreatePurchaseInvoice(ctx context.Context, accessToken string, request CreatePurchaseRequest) (string, error) {
+	return g.createPurchase(ctx, accessToken, request.CRMObjectTypeCode, request)
+}
+
+func (g *GoPayamgostar) createPurchase(ctx context.Context, accessToken string, typeCode string, body interface{}) (string, error) {
 	const errMessage = "could not create purchase"
 
+	if err := g.verifySchema(ctx, accessToken, typeCode); err != nil {
+		return "", err
+	}
+
 	resp, err := g.GetRequestWithBearerAuth(ctx, accessToken).
-		SetBody(purchase).
+		SetHeader(idempotencyKeyHeader, g.idGenerator.NewID()).
+		SetBody(g.requestBody(body)).
 		Post(g.basePath + "/" + g.Config.CreatePurchaseEndpoint)
 
-	if err := checkForError(resp, err, errMessage); err != nil {
+	if err := g.checkForError(resp, err, errMessage); err != nil {
 		return "", err
 	}
 
-	crmid, err := getID(resp)
+	crmid, err := g.getID(resp)
 	if err != nil {
 		return "", err
 	}
 
+	g.publish(ctx, PurchaseCreated{CrmId: crmid})
 	return crmid, nil
 }
 
-func (g *GoPayamgostar) DeletePurchase(ctx context.Context, accessToken string, purchaseID string) error {
+func (g *GoPayamgostar) DeletePurchase(ctx context.Context, accessToken string, purchaseID string, option enums.DeleteOption) (*DeleteResult, error) {
 	const errMessage = "could not delete purchase"
 
+	defer g.invalidateCached(ctx, formCacheKey(accessToken, purchaseID))
+
+	var result DeleteResult
+
 	request := DeleteRequest{
 		Id:     purchaseID,
-		Option: 1,
+		Option: option,
 	}
 
 	resp, err := g.GetRequestWithBearerAuth(ctx, accessToken).
 		SetBody(request).
 		Post(g.basePath + "/" + g.Config.DeletePurchaseEndpoint)
 
-	return checkForError(resp, err, errMessage)
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	if err := g.unmarshalBody(resp.Body(), &result); err != nil {
+		return nil, fmt.Errorf("%s: %w", errMessage, err)
+	}
+	if result.CrmId == "" {
+		result.CrmId = purchaseID
+	}
+
+	g.publish(ctx, PurchaseDeleted{CrmId: purchaseID})
+	return &result, nil
 }
 
-func (g *GoPayamgostar) FindPersonByName(ctx context.Context, accessToken string, typeKey string, firstName string, lastName string) (*FindResponse, error) {
-	const errMessage = "could find person"
+// DeletePurchaseBulk deletes the given purchases in order and stops at the first
+// failure. The results of the purchases deleted so far are returned with the error.
+func (g *GoPayamgostar) DeletePurchaseBulk(ctx context.Context, accessToken string, purchaseIDs []string, option enums.DeleteOption) ([]DeleteResult, error) {
+	results := make([]DeleteResult, 0, len(purchaseIDs))
+
+	for _, purchaseID := range purchaseIDs {
+		result, err := g.DeletePurchase(ctx, accessToken, purchaseID, option)
+		if err != nil {
+			return results, errors.Wrapf(err, "purchase %s", purchaseID)
+		}
+		results = append(results, *result)
+	}
+
+	return results, nil
+}
+
+// FindPersonByName returns the persons of typeKey with the given names, with
+// the options of FindPersonsByName.
+//
+// Deprecated: find rows only carry a subset of the person fields. Use
+// FindPersonsByName and GetPersonInfoById for the full person.
+func (g *GoPayamgostar) FindPersonByName(ctx context.Context, accessToken string, typeKey string, firstName string, lastName string, options ...FindPersonOption) (*FindResponse, error) {
+	opts := newFindPersonOptions(options)
 
 	var result FindResponse
+	resp, err := g.findPersonByName(ctx, accessToken, typeKey, firstName, lastName, opts, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkMatches(opts, &result.Data, &result.Total, PersonInfo.names, firstName, lastName); err != nil {
+		return &result, err
+	}
+
+	if _, stale := servedStale(resp); stale {
+		return &result, ErrServedStale
+	}
+
+	return &result, nil
+}
+
+// FindPersonsByName returns the persons of typeKey with the given names. Without
+// options it returns the first 10 matches; use MaxResults to be told when
+// there are more, and UniqueMatch and ExactMatch before acting on the match.
+// The matches found are returned with ErrAmbiguousMatch and ErrTooManyMatches.
+func (g *GoPayamgostar) FindPersonsByName(ctx context.Context, accessToken string, typeKey string, firstName string, lastName string, options ...FindPersonOption) (*FindPersonResponse, error) {
+	opts := newFindPersonOptions(options)
+
+	var result FindPersonResponse
+	resp, err := g.findPersonByName(ctx, accessToken, typeKey, firstName, lastName, opts, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	staleness, stale := servedStale(resp)
+	if stale {
+		result.Staleness = staleness
+	}
+
+	if err := checkMatches(opts, &result.Data, &result.Total, PersonRow.names, firstName, lastName); err != nil {
+		return &result, err
+	}
+
+	if stale {
+		return &result, ErrServedStale
+	}
+
+	// Return the result
+	return &result, nil
+}
+
+// findPersonByName finds the persons with the given names and decodes the
+// page into result
+func (g *GoPayamgostar) findPersonByName(ctx context.Context, accessToken string, typeKey string, firstName string, lastName string, opts findPersonOptions, result interface{}) (*resty.Response, error) {
+	const errMessage = "could find person"
 
 	request := FindRequest{
 		TypeKey: typeKey,
@@ -331,51 +867,112 @@ func (g *GoPayamgostar) FindPersonByName(ctx context.Context, accessToken string
 			},
 		},
 		PageNumber: 1,
-		PageSize:   10,
+		PageSize:   opts.pageSize(),
+	}
+
+	request, err := g.findRequest(ctx, request)
+	if err != nil {
+		return nil, err
 	}
 
 	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
 		SetBody(request).
 		Post(g.basePath + "/" + g.Config.FindPersonEndpoint)
 
-	if err := checkForError(resp, err, errMessage); err != nil {
+	if err := g.checkForError(resp, err, errMessage); err != nil {
 		return nil, err
 	}
 
 	// Unmarshal response into the result struct
-	if err := json.Unmarshal(resp.Body(), &result); err != nil {
+	if err := g.unmarshalBody(resp.Body(), result); err != nil {
 		return nil, fmt.Errorf("%s: %w", errMessage, err)
 	}
 
-	// Return the result
+	return resp, nil
+}
+
+// FindPersonsPage returns one page of the persons of typeKey matching queries
+func (g *GoPayamgostar) FindPersonsPage(ctx context.Context, accessToken string, typeKey string, queries []Query, pageNumber, pageSize int64) (*FindPersonResponse, error) {
+	const errMessage = "could find person"
+
+	var result FindPersonResponse
+
+	request := FindRequest{
+		TypeKey:    typeKey,
+		Queries:    queries,
+		PageNumber: pageNumber,
+		PageSize:   pageSize,
+	}
+
+	request, err := g.findRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.FindPersonEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	if err := g.unmarshalBody(resp.Body(), &result); err != nil {
+		return nil, fmt.Errorf("%s: %w", errMessage, err)
+	}
+
+	if staleness, ok := servedStale(resp); ok {
+		result.Staleness = staleness
+		return &result, ErrServedStale
+	}
+
 	return &result, nil
 }
 
 func (g *GoPayamgostar) FindForm(ctx context.Context, accessToken string, typeKey string, queries []Query) (*FindFormResponse, error) {
+	return g.FindFormPage(ctx, accessToken, typeKey, queries, 1, 10)
+}
+
+// FindFormPage returns one page of the forms of typeKey matching queries
+func (g *GoPayamgostar) FindFormPage(ctx context.Context, accessToken string, typeKey string, queries []Query, pageNumber, pageSize int64) (*FindFormResponse, error) {
 	const errMessage = "could find form"
 
 	var result FindFormResponse
 
+	if err := g.verifySchema(ctx, accessToken, typeKey); err != nil {
+		return nil, err
+	}
+
 	request := FindRequest{
 		TypeKey:    *StringP(typeKey),
 		Queries:    queries,
-		PageNumber: *Int64P(1),
-		PageSize:   *Int64P(10),
+		PageNumber: pageNumber,
+		PageSize:   pageSize,
+	}
+
+	request, err := g.findRequest(ctx, request)
+	if err != nil {
+		return nil, err
 	}
 
 	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
 		SetBody(request).
 		Post(g.basePath + "/" + g.Config.FindFormEndpoint)
 
-	if err := checkForError(resp, err, errMessage); err != nil {
+	if err := g.checkForError(resp, err, errMessage); err != nil {
 		return nil, err
 	}
 
 	// Unmarshal response into the result struct
-	if err := json.Unmarshal(resp.Body(), &result); err != nil {
+	if err := g.unmarshalBody(resp.Body(), &result); err != nil {
 		return nil, fmt.Errorf("%s: %w", errMessage, err)
 	}
 
+	if staleness, ok := servedStale(resp); ok {
+		result.Staleness = staleness
+		return &result, ErrServedStale
+	}
+
 	// Return the result
 	return &result, nil
 }
@@ -383,37 +980,1010 @@ func (g *GoPayamgostar) FindForm(ctx context.Context, accessToken string, typeKe
 func (g *GoPayamgostar) UpdateForm(ctx context.Context, accessToken string, request UpdateFormRequest) (string, error) {
 	const errMessage = "could not update form"
 
+	defer g.invalidateCached(ctx, formCacheKey(accessToken, request.CrmId))
+
 	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
 		SetBody(request).
 		Post(g.basePath + "/" + g.Config.UpdateFormEndpoint)
 
-	if err := checkForError(resp, err, errMessage); err != nil {
+	if err := g.checkForError(resp, err, errMessage); err != nil {
 		return "", err
 	}
 
-	crmid, err := getID(resp)
+	crmid, err := g.getID(resp)
 	if err != nil {
 		return "", err
 	}
 
+	g.publish(ctx, FormUpdated{CrmId: request.CrmId})
 	return crmid, nil
 }
 
 func (g *GoPayamgostar) CreateForm(ctx context.Context, accessToken string, request CreateFormRequest) (string, error) {
 	const errMessage = "could not create form"
 
+	if err := g.verifySchema(ctx, accessToken, request.CRMObjectTypeCode); err != nil {
+		return "", err
+	}
+
 	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
-		SetBody(request).
+		SetHeader(idempotencyKeyHeader, g.idGenerator.NewID()).
+		SetBody(g.requestBody(request)).
 		Post(g.basePath + "/" + g.Config.CreateFormEndpoint)
 
-	if err := checkForError(resp, err, errMessage); err != nil {
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return "", err
+	}
+
+	crmid, err := g.getID(resp)
+	if err != nil {
+		return "", err
+	}
+
+	g.publish(ctx, FormCreated{CrmId: crmid})
+	return crmid, nil
+}
+
+func (g *GoPayamgostar) CreateTask(ctx context.Context, accessToken string, request CreateTaskRequest) (string, error) {
+	const errMessage = "could not create task"
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetHeader(idempotencyKeyHeader, g.idGenerator.NewID()).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.CreateTaskEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return "", err
+	}
+
+	crmid, err := g.getID(resp)
+	if err != nil {
+		return "", err
+	}
+
+	return crmid, nil
+}
+
+func (g *GoPayamgostar) GetTask(ctx context.Context, accessToken, crmId string) (*TaskInfo, error) {
+	const errMessage = "could not get task"
+
+	var result TaskInfo
+
+	model := GetRequest{
+		ID:                   crmId,
+		ShowPreviews:         *BoolP(true),
+		ShowExtendedPreviews: *BoolP(true),
+	}
+
+	resp, err := g.GetRequestWithBearerAuth(ctx, accessToken).
+		SetBody(model).
+		SetResult(&result).
+		Post(g.basePath + "/" + g.Config.GetTaskEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (g *GoPayamgostar) UpdateTask(ctx context.Context, accessToken string, request UpdateTaskRequest) (string, error) {
+	const errMessage = "could not update task"
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.UpdateTaskEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
 		return "", err
 	}
 
-	crmid, err := getID(resp)
+	crmid, err := g.getID(resp)
 	if err != nil {
 		return "", err
 	}
 
 	return crmid, nil
 }
+
+func (g *GoPayamgostar) CompleteTask(ctx context.Context, accessToken string, request CompleteTaskRequest) error {
+	const errMessage = "could not complete task"
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.CompleteTaskEndpoint)
+
+	return g.checkForError(resp, err, errMessage)
+}
+
+func (g *GoPayamgostar) FindTasks(ctx context.Context, accessToken string, queries []Query) (*FindTaskResponse, error) {
+	const errMessage = "could not find tasks"
+
+	var result FindTaskResponse
+
+	request := FindRequest{
+		TypeKey:    *StringP("Task"),
+		Queries:    queries,
+		PageNumber: *Int64P(1),
+		PageSize:   *Int64P(10),
+	}
+
+	request, err := g.findRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.FindTaskEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	// Unmarshal response into the result struct
+	if err := g.unmarshalBody(resp.Body(), &result); err != nil {
+		return nil, fmt.Errorf("%s: %w", errMessage, err)
+	}
+
+	// Return the result
+	return &result, nil
+}
+
+func (g *GoPayamgostar) CreateTicket(ctx context.Context, accessToken string, request CreateTicketRequest) (string, error) {
+	const errMessage = "could not create ticket"
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetHeader(idempotencyKeyHeader, g.idGenerator.NewID()).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.CreateTicketEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return "", err
+	}
+
+	crmid, err := g.getID(resp)
+	if err != nil {
+		return "", err
+	}
+
+	return crmid, nil
+}
+
+func (g *GoPayamgostar) GetTicket(ctx context.Context, accessToken, crmId string) (*TicketInfo, error) {
+	const errMessage = "could not get ticket"
+
+	var result TicketInfo
+
+	model := GetRequest{
+		ID:                   crmId,
+		ShowPreviews:         *BoolP(true),
+		ShowExtendedPreviews: *BoolP(true),
+	}
+
+	resp, err := g.GetRequestWithBearerAuth(ctx, accessToken).
+		SetBody(model).
+		SetResult(&result).
+		Post(g.basePath + "/" + g.Config.GetTicketEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (g *GoPayamgostar) ReplyToTicket(ctx context.Context, accessToken string, request ReplyTicketRequest) (string, error) {
+	const errMessage = "could not reply to ticket"
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.ReplyTicketEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return "", err
+	}
+
+	crmid, err := g.getID(resp)
+	if err != nil {
+		return "", err
+	}
+
+	return crmid, nil
+}
+
+func (g *GoPayamgostar) CloseTicket(ctx context.Context, accessToken string, request CloseTicketRequest) error {
+	const errMessage = "could not close ticket"
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.CloseTicketEndpoint)
+
+	return g.checkForError(resp, err, errMessage)
+}
+
+func (g *GoPayamgostar) FindTickets(ctx context.Context, accessToken string, queries []Query) (*FindTicketResponse, error) {
+	return g.FindTicketsPage(ctx, accessToken, queries, 1, 10)
+}
+
+// FindTicketsPage returns one page of the tickets matching queries
+func (g *GoPayamgostar) FindTicketsPage(ctx context.Context, accessToken string, queries []Query, pageNumber, pageSize int64) (*FindTicketResponse, error) {
+	const errMessage = "could not find tickets"
+
+	var result FindTicketResponse
+
+	request := FindRequest{
+		TypeKey:    *StringP("Ticket"),
+		Queries:    queries,
+		PageNumber: pageNumber,
+		PageSize:   pageSize,
+	}
+
+	request, err := g.findRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.FindTicketEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	// Unmarshal response into the result struct
+	if err := g.unmarshalBody(resp.Body(), &result); err != nil {
+		return nil, fmt.Errorf("%s: %w", errMessage, err)
+	}
+
+	// Return the result
+	return &result, nil
+}
+
+// AddNote attaches a note to a person, form or invoice and returns the note id
+func (g *GoPayamgostar) AddNote(ctx context.Context, accessToken, crmId string, note NoteRequest) (string, error) {
+	const errMessage = "could not add note"
+
+	request := createNoteRequest{
+		CRMObjectID: crmId,
+		NoteRequest: note,
+	}
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetHeader(idempotencyKeyHeader, g.idGenerator.NewID()).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.CreateNoteEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return "", err
+	}
+
+	id, err := g.getID(resp)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// ListNotes returns the note history of a person, form or invoice
+func (g *GoPayamgostar) ListNotes(ctx context.Context, accessToken, crmId string) ([]Note, error) {
+	const errMessage = "could not list notes"
+
+	var result []Note
+
+	request := listNotesRequest{
+		CRMObjectID: crmId,
+	}
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(request).
+		SetResult(&result).
+		Post(g.basePath + "/" + g.Config.ListNoteEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetInvoicesForIdentity returns every invoice of an identity issued within dateRange
+// whose stage is one of states. A zero bound of dateRange and an empty states slice
+// are not filtered on.
+func (g *GoPayamgostar) GetInvoicesForIdentity(ctx context.Context, accessToken, identityId string, dateRange DateRange, states []string) ([]InvoiceSummary, error) {
+	const errMessage = "could not get invoices"
+	const pageSize = 50
+
+	queries := []Query{
+		{
+			LogicalOperator: int(enums.And),
+			FieldOperator:   int(enums.Equals),
+			Field:           "IdentityId",
+			Value:           identityId,
+		},
+	}
+	if !dateRange.From.IsZero() {
+		queries = append(queries, Query{
+			LogicalOperator: int(enums.And),
+			FieldOperator:   int(enums.GreaterThanOrEqual),
+			Field:           "InvoiceDate",
+			Value:           dateRange.From.Format(invoiceDateLayout),
+		})
+	}
+	if !dateRange.To.IsZero() {
+		queries = append(queries, Query{
+			LogicalOperator: int(enums.And),
+			FieldOperator:   int(enums.LessThanOrEqual),
+			Field:           "InvoiceDate",
+			Value:           dateRange.To.Format(invoiceDateLayout),
+		})
+	}
+	if len(states) > 0 {
+		queries = append(queries, Query{
+			LogicalOperator: int(enums.And),
+			FieldOperator:   int(enums.In),
+			Field:           "StageId",
+			Value:           strings.Join(states, ","),
+		})
+	}
+
+	var invoices []InvoiceSummary
+	for page := int64(1); ; page++ {
+		var result FindInvoiceResponse
+
+		request := FindRequest{
+			TypeKey:    *StringP("Invoice"),
+			Queries:    queries,
+			PageNumber: page,
+			PageSize:   pageSize,
+		}
+
+		request, err := g.findRequest(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+			SetBody(request).
+			Post(g.basePath + "/" + g.Config.FindInvoiceEndpoint)
+
+		if err := g.checkForError(resp, err, errMessage); err != nil {
+			return nil, err
+		}
+
+		if err := g.unmarshalBody(resp.Body(), &result); err != nil {
+			return nil, fmt.Errorf("%s: %w", errMessage, err)
+		}
+
+		invoices = append(invoices, result.Data...)
+		if len(result.Data) < pageSize || int64(len(invoices)) >= result.Total {
+			return invoices, nil
+		}
+	}
+}
+
+func (g *GoPayamgostar) DeleteForm(ctx context.Context, accessToken string, formID string) error {
+	const errMessage = "could not delete form"
+
+	defer g.invalidateCached(ctx, formCacheKey(accessToken, formID))
+
+	request := DeleteRequest{
+		Id:     formID,
+		Option: enums.DeleteOnly,
+	}
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.DeleteFormEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return err
+	}
+
+	g.publish(ctx, FormDeleted{CrmId: formID})
+	return nil
+}
+
+func (g *GoPayamgostar) CreateReceipt(ctx context.Context, accessToken string, request CreateReceiptRequest) (string, error) {
+	const errMessage = "could not create receipt"
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetHeader(idempotencyKeyHeader, g.idGenerator.NewID()).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.CreateReceiptEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return "", err
+	}
+
+	crmid, err := g.getID(resp)
+	if err != nil {
+		return "", err
+	}
+
+	return crmid, nil
+}
+
+func (g *GoPayamgostar) DeleteReceipt(ctx context.Context, accessToken string, receiptID string) error {
+	const errMessage = "could not delete receipt"
+
+	request := DeleteRequest{
+		Id:     receiptID,
+		Option: enums.DeleteOnly,
+	}
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.DeleteReceiptEndpoint)
+
+	return g.checkForError(resp, err, errMessage)
+}
+
+// SendEmail sends a mail through the CRM email module and returns the email id
+func (g *GoPayamgostar) SendEmail(ctx context.Context, accessToken string, request SendEmailRequest) (string, error) {
+	const errMessage = "could not send email"
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetHeader(idempotencyKeyHeader, g.idGenerator.NewID()).
+		SetBody(g.requestBody(request)).
+		Post(g.basePath + "/" + g.Config.SendEmailEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return "", err
+	}
+
+	id, err := g.getID(resp)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (g *GoPayamgostar) GetEmailStatus(ctx context.Context, accessToken, emailId string) (*EmailStatus, error) {
+	const errMessage = "could not get email status"
+
+	var result EmailStatus
+
+	model := GetRequest{
+		ID: emailId,
+	}
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(model).
+		SetResult(&result).
+		Post(g.basePath + "/" + g.Config.EmailStatusEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (g *GoPayamgostar) CreateOpportunity(ctx context.Context, accessToken string, request CreateOpportunityRequest) (string, error) {
+	const errMessage = "could not create opportunity"
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetHeader(idempotencyKeyHeader, g.idGenerator.NewID()).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.CreateOpportunityEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return "", err
+	}
+
+	crmid, err := g.getID(resp)
+	if err != nil {
+		return "", err
+	}
+
+	return crmid, nil
+}
+
+func (g *GoPayamgostar) GetOpportunity(ctx context.Context, accessToken, crmId string) (*OpportunityInfo, error) {
+	const errMessage = "could not get opportunity"
+
+	var result OpportunityInfo
+
+	model := GetRequest{
+		ID:                   crmId,
+		ShowPreviews:         *BoolP(true),
+		ShowExtendedPreviews: *BoolP(true),
+	}
+
+	resp, err := g.GetRequestWithBearerAuth(ctx, accessToken).
+		SetBody(model).
+		SetResult(&result).
+		Post(g.basePath + "/" + g.Config.GetOpportunityEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (g *GoPayamgostar) UpdateOpportunityStage(ctx context.Context, accessToken string, request UpdateOpportunityStageRequest) error {
+	const errMessage = "could not update opportunity stage"
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.UpdateOpportunityStageEndpoint)
+
+	return g.checkForError(resp, err, errMessage)
+}
+
+func (g *GoPayamgostar) FindOpportunities(ctx context.Context, accessToken string, queries []Query) (*FindOpportunityResponse, error) {
+	return g.FindOpportunitiesPage(ctx, accessToken, queries, 1, 10)
+}
+
+// FindOpportunitiesPage returns one page of the opportunities matching queries
+func (g *GoPayamgostar) FindOpportunitiesPage(ctx context.Context, accessToken string, queries []Query, pageNumber, pageSize int64) (*FindOpportunityResponse, error) {
+	const errMessage = "could not find opportunities"
+
+	var result FindOpportunityResponse
+
+	request := FindRequest{
+		TypeKey:    *StringP("Opportunity"),
+		Queries:    queries,
+		PageNumber: pageNumber,
+		PageSize:   pageSize,
+	}
+
+	request, err := g.findRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.FindOpportunityEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	// Unmarshal response into the result struct
+	if err := g.unmarshalBody(resp.Body(), &result); err != nil {
+		return nil, fmt.Errorf("%s: %w", errMessage, err)
+	}
+
+	// Return the result
+	return &result, nil
+}
+
+// GetObjectType returns the definition of the CRM object type with the given code
+func (g *GoPayamgostar) GetObjectType(ctx context.Context, accessToken, typeCode string) (*ObjectType, error) {
+	const errMessage = "could not get object type"
+
+	var result ObjectType
+
+	request := getObjectTypeRequest{
+		Code: typeCode,
+	}
+
+	resp, err := g.GetRequestWithBearerAuth(ctx, accessToken).
+		SetBody(request).
+		SetResult(&result).
+		Post(g.basePath + "/" + g.Config.GetObjectTypeEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (g *GoPayamgostar) ListUsers(ctx context.Context, accessToken string) ([]User, error) {
+	const errMessage = "could not list users"
+
+	var result []User
+
+	resp, err := g.GetRequestWithBearerAuth(ctx, accessToken).
+		SetResult(&result).
+		Post(g.basePath + "/" + g.Config.ListUserEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (g *GoPayamgostar) GetUserByUsername(ctx context.Context, accessToken, username string) (*User, error) {
+	const errMessage = "could not get user"
+
+	var result User
+
+	request := getUserRequest{
+		Username: username,
+	}
+
+	resp, err := g.GetRequestWithBearerAuth(ctx, accessToken).
+		SetBody(request).
+		SetResult(&result).
+		Post(g.basePath + "/" + g.Config.GetUserEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetCurrentUser returns the CRM user the access token belongs to
+func (g *GoPayamgostar) GetCurrentUser(ctx context.Context, accessToken string) (*User, error) {
+	const errMessage = "could not get current user"
+
+	var result User
+
+	resp, err := g.GetRequestWithBearerAuth(ctx, accessToken).
+		SetResult(&result).
+		Post(g.basePath + "/" + g.Config.CurrentUserEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// UploadAttachment attaches a file to a person, form or invoice and returns the attachment id
+func (g *GoPayamgostar) UploadAttachment(ctx context.Context, accessToken, crmId, filename string, content io.Reader) (string, error) {
+	const errMessage = "could not upload attachment"
+
+	var result AttachmentInfo
+
+	resp, err := g.GetRequest(ctx).
+		SetAuthToken(g.tokenOrHeld(accessToken)).
+		SetFormData(map[string]string{
+			"crmObjectId": crmId,
+		}).
+		SetFileReader("file", filename, content).
+		SetResult(&result).
+		Post(g.basePath + "/" + g.Config.UploadAttachmentEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return "", err
+	}
+
+	return result.ID, nil
+}
+
+// ListAttachments returns the files attached to a person, form or invoice
+func (g *GoPayamgostar) ListAttachments(ctx context.Context, accessToken, crmId string) ([]AttachmentInfo, error) {
+	const errMessage = "could not list attachments"
+
+	var result []AttachmentInfo
+
+	request := listAttachmentsRequest{
+		CRMObjectID: crmId,
+	}
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(request).
+		SetResult(&result).
+		Post(g.basePath + "/" + g.Config.ListAttachmentEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// DownloadAttachment streams the content of an attachment into w without
+// buffering it in memory and returns the number of bytes written.
+func (g *GoPayamgostar) DownloadAttachment(ctx context.Context, accessToken, attachmentId string, w io.Writer) (int64, error) {
+	const errMessage = "could not download attachment"
+
+	model := GetRequest{
+		ID: attachmentId,
+	}
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(model).
+		SetDoNotParseResponse(true).
+		Post(g.basePath + "/" + g.Config.DownloadAttachmentEndpoint)
+
+	if resp != nil && resp.RawBody() != nil {
+		defer resp.RawBody().Close()
+	}
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(w, resp.RawBody())
+	if err != nil {
+		return n, errors.Wrap(err, errMessage)
+	}
+
+	return n, nil
+}
+
+// DeleteAttachment removes an attachment from the object it is attached to
+func (g *GoPayamgostar) DeleteAttachment(ctx context.Context, accessToken, attachmentId string) error {
+	const errMessage = "could not delete attachment"
+
+	model := GetRequest{
+		ID: attachmentId,
+	}
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(model).
+		Post(g.basePath + "/" + g.Config.DeleteAttachmentEndpoint)
+
+	return g.checkForError(resp, err, errMessage)
+}
+
+// GetPicklist returns the items of a server picklist such as SourceType or PhoneType
+func (g *GoPayamgostar) GetPicklist(ctx context.Context, accessToken, name string) ([]PicklistItem, error) {
+	const errMessage = "could not get picklist"
+
+	var result []PicklistItem
+
+	request := getPicklistRequest{
+		Name: name,
+	}
+
+	resp, err := g.GetRequestWithBearerAuth(ctx, accessToken).
+		SetBody(request).
+		SetResult(&result).
+		Post(g.basePath + "/" + g.Config.GetPicklistEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// AddTags adds tags to a CRM object, keeping its existing tags
+func (g *GoPayamgostar) AddTags(ctx context.Context, accessToken, crmId string, tags []string) error {
+	return g.changeTags(ctx, accessToken, g.Config.AddTagsEndpoint, "could not add tags", crmId, tags)
+}
+
+// RemoveTags removes tags from a CRM object
+func (g *GoPayamgostar) RemoveTags(ctx context.Context, accessToken, crmId string, tags []string) error {
+	return g.changeTags(ctx, accessToken, g.Config.RemoveTagsEndpoint, "could not remove tags", crmId, tags)
+}
+
+// ReplaceTags replaces all tags of a CRM object
+func (g *GoPayamgostar) ReplaceTags(ctx context.Context, accessToken, crmId string, tags []string) error {
+	return g.changeTags(ctx, accessToken, g.Config.ReplaceTagsEndpoint, "could not replace tags", crmId, tags)
+}
+
+func (g *GoPayamgostar) changeTags(ctx context.Context, accessToken, endpoint, errMessage, crmId string, tags []string) error {
+	request := tagsRequest{
+		CRMObjectID: crmId,
+		Tags:        tags,
+	}
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(request).
+		Post(g.basePath + "/" + endpoint)
+
+	return g.checkForError(resp, err, errMessage)
+}
+
+// ListTags returns the names of the tags defined in the CRM
+func (g *GoPayamgostar) ListTags(ctx context.Context, accessToken string) ([]string, error) {
+	const errMessage = "could not list tags"
+
+	var result []string
+
+	resp, err := g.GetRequestWithBearerAuth(ctx, accessToken).
+		SetResult(&result).
+		Post(g.basePath + "/" + g.Config.ListTagsEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// UpdatePerson updates the given fields of a person. Phone contacts, when
+// present, must have exactly one default.
+func (g *GoPayamgostar) UpdatePerson(ctx context.Context, accessToken string, request UpdatePersonRequest) (string, error) {
+	const errMessage = "could not update person"
+
+	defer g.invalidateCached(ctx, personCacheKey(accessToken, request.CrmId))
+
+	if request.PhoneContacts != nil {
+		if err := ValidatePhoneContacts(request.PhoneContacts); err != nil {
+			return "", err
+		}
+	}
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.UpdatePersonEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return "", err
+	}
+
+	crmid, err := g.getID(resp)
+	if err != nil {
+		return "", err
+	}
+
+	g.publish(ctx, PersonUpdated{CrmId: request.CrmId})
+	return crmid, nil
+}
+
+// CreatePerson creates a person and returns its crm id
+func (g *GoPayamgostar) CreatePerson(ctx context.Context, accessToken string, request CreatePersonRequest) (string, error) {
+	const errMessage = "could not create person"
+
+	if err := ValidatePhoneContacts(request.PhoneContacts); err != nil {
+		return "", err
+	}
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetHeader(idempotencyKeyHeader, g.idGenerator.NewID()).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.CreatePersonEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return "", err
+	}
+
+	crmid, err := g.getID(resp)
+	if err != nil {
+		return "", err
+	}
+
+	g.publish(ctx, PersonCreated{CrmId: crmid})
+	return crmid, nil
+}
+
+// DeletePerson deletes the person crmId
+func (g *GoPayamgostar) DeletePerson(ctx context.Context, accessToken, crmId string) error {
+	const errMessage = "could not delete person"
+
+	defer g.invalidateCached(ctx, personCacheKey(accessToken, crmId))
+
+	request := DeleteRequest{
+		Id:     crmId,
+		Option: enums.DeleteOnly,
+	}
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.DeletePersonEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return err
+	}
+
+	g.publish(ctx, PersonDeleted{CrmId: crmId})
+	return nil
+}
+
+// SetDefaultPhone makes phoneId the default phone contact of a person
+func (g *GoPayamgostar) SetDefaultPhone(ctx context.Context, accessToken, identityId, phoneId string) error {
+	person, err := g.GetPersonInfoById(WithoutCache(ctx), accessToken, identityId)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	contacts := make([]PhoneContact, len(person.PhoneContacts))
+	for i, contact := range person.PhoneContacts {
+		contact.Default = contact.ID == phoneId
+		found = found || contact.Default
+		contacts[i] = contact
+	}
+	if !found {
+		return errors.Errorf("person %s has no phone contact %s", identityId, phoneId)
+	}
+
+	_, err = g.UpdatePerson(ctx, accessToken, UpdatePersonRequest{
+		CrmId:         identityId,
+		PhoneContacts: contacts,
+	})
+	return err
+}
+
+// ListColors returns the colors configured in the CRM, their ids are the values
+// of the ColorID fields of create requests
+func (g *GoPayamgostar) ListColors(ctx context.Context, accessToken string) ([]Color, error) {
+	const errMessage = "could not list colors"
+
+	var result []Color
+	if g.cached(ctx, colorsCacheKey(accessToken), &result) {
+		return result, nil
+	}
+
+	resp, err := g.GetRequestWithBearerAuth(ctx, accessToken).
+		SetResult(&result).
+		Post(g.basePath + "/" + g.Config.ListColorEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+	g.storeCached(ctx, colorsCacheKey(accessToken), resp.Body())
+
+	return result, nil
+}
+
+// GetProductByCode returns the product with the catalog code
+func (g *GoPayamgostar) GetProductByCode(ctx context.Context, accessToken, code string) (*Product, error) {
+	const errMessage = "could not get product"
+
+	var result Product
+
+	request := getProductRequest{
+		Code: code,
+	}
+
+	resp, err := g.GetRequestWithBearerAuth(ctx, accessToken).
+		SetBody(request).
+		SetResult(&result).
+		Post(g.basePath + "/" + g.Config.GetProductEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// StartProcess starts the BPMS process processId on the object crmId
+func (g *GoPayamgostar) StartProcess(ctx context.Context, accessToken, crmId, processId string) error {
+	const errMessage = "could not start process"
+
+	defer g.invalidateCached(ctx, formCacheKey(accessToken, crmId))
+
+	request := startProcessRequest{
+		CRMObjectID: crmId,
+		ProcessID:   processId,
+	}
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(request).
+		Post(g.basePath + "/" + g.Config.StartProcessEndpoint)
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return err
+	}
+
+	g.publish(ctx, FormUpdated{CrmId: crmId})
+	return nil
+}
+
+// RenderFormPDF streams the printed PDF of the form crmId into w and returns
+// the number of bytes written.
+func (g *GoPayamgostar) RenderFormPDF(ctx context.Context, accessToken, crmId string, w io.Writer) (int64, error) {
+	const errMessage = "could not render form pdf"
+
+	model := GetRequest{
+		ID: crmId,
+	}
+
+	resp, err := g.GetRequestWithBearerAuthNoCache(ctx, accessToken).
+		SetBody(model).
+		SetDoNotParseResponse(true).
+		Post(g.basePath + "/" + g.Config.RenderFormPDFEndpoint)
+
+	if resp != nil && resp.RawBody() != nil {
+		defer resp.RawBody().Close()
+	}
+
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(w, resp.RawBody())
+	if err != nil {
+		return n, errors.Wrap(err, errMessage)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,127 @@
+package gopayamgostar
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
+	"github.com/go-resty/resty/v2"
+)
+
+// GoPayamgostarIface holds all the methods a GoPayamgostar client provides.
+// Depend on it instead of *GoPayamgostar to mock the client in unit tests.
+// The methods taking an access token are also methods of Session.
+type GoPayamgostarIface interface {
+	GetRequest(ctx context.Context) *resty.Request
+	GetRequestWithBearerAuthNoCache(ctx context.Context, token string) *resty.Request
+	GetRequestWithBearerAuth(ctx context.Context, token string) *resty.Request
+	RestyClient() *resty.Client
+	SetRestyClient(restyClient *resty.Client)
+	DebugHistory() []DebugEntry
+	Health() HealthReport
+	CircuitState() CircuitState
+	RateLimiter() *RateLimiter
+	ServerInfo() ServerInfo
+	RegisterRequestHook(hook RequestHook)
+	RegisterResponseHook(hook ResponseHook)
+	Subscribe(handler EventHandler) func()
+	NewGroup(ctx context.Context, accessToken string, limit int) *Group
+	Do(ctx context.Context, accessToken string, method, path string, body, result interface{}) error
+
+	// Auth
+	AdminAuthenticate(ctx context.Context, username string, password string) (*JWT, error)
+	UserAuthenticate(ctx context.Context, username string, password string) (*JWT, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*JWT, error)
+	Logout(ctx context.Context, accessToken string) error
+	ClearTokenCache()
+	SetAccessToken(token string)
+	AccessToken() string
+	Session(accessToken string) *Session
+
+	// Persons
+	GetPersonInfoById(ctx context.Context, accessToken, crmId string) (*PersonInfo, error)
+	FindPersonByName(ctx context.Context, accessToken string, typeKey string, firstName string, lastName string, options ...FindPersonOption) (*FindResponse, error)
+	FindPersonsByName(ctx context.Context, accessToken string, typeKey string, firstName string, lastName string, options ...FindPersonOption) (*FindPersonResponse, error)
+	FindPersonsPage(ctx context.Context, accessToken string, typeKey string, queries []Query, pageNumber, pageSize int64) (*FindPersonResponse, error)
+	FindPersonsStream(ctx context.Context, accessToken string, typeKey string, queries []Query) (<-chan PersonRow, <-chan error)
+	CreatePerson(ctx context.Context, accessToken string, request CreatePersonRequest) (string, error)
+	CreatePersons(ctx context.Context, accessToken string, requests []CreatePersonRequest, options BulkOptions) ([]BulkResult, error)
+	UpdatePerson(ctx context.Context, accessToken string, request UpdatePersonRequest) (string, error)
+	DeletePerson(ctx context.Context, accessToken, crmId string) error
+	SetDefaultPhone(ctx context.Context, accessToken, identityId, phoneId string) error
+	GetIdentityTimeline(ctx context.Context, accessToken, identityId string, formTypes ...string) ([]TimelineEntry, error)
+
+	// Forms
+	GetFormInfoById(ctx context.Context, accessToken, crmId string) (*FormInfo, error)
+	FindForm(ctx context.Context, accessToken string, typeKey string, queries []Query) (*FindFormResponse, error)
+	FindFormPage(ctx context.Context, accessToken string, typeKey string, queries []Query, pageNumber, pageSize int64) (*FindFormResponse, error)
+	FindFormsStream(ctx context.Context, accessToken string, typeKey string, queries []Query) (<-chan FormResponse, <-chan error)
+	CreateForm(ctx context.Context, accessToken string, request CreateFormRequest) (string, error)
+	BulkCreateForms(ctx context.Context, accessToken string, requests []CreateFormRequest, options BulkOptions) ([]BulkResult, error)
+	UpdateForm(ctx context.Context, accessToken string, request UpdateFormRequest) (string, error)
+	DeleteForm(ctx context.Context, accessToken string, formID string) error
+	WaitForObjectCondition(ctx context.Context, accessToken, crmId string, predicate func(*FormInfo) bool, pollInterval time.Duration) (*FormInfo, error)
+	GetObjectType(ctx context.Context, accessToken, typeCode string) (*ObjectType, error)
+	StartProcess(ctx context.Context, accessToken, crmId, processId string) error
+
+	// Invoices
+	CreatePurchase(ctx context.Context, accessToken string, purchase CreatePurchase) (string, error)
+	CreatePurchaseInvoice(ctx context.Context, accessToken string, request CreatePurchaseRequest) (string, error)
+	DeletePurchase(ctx context.Context, accessToken string, purchaseID string, option enums.DeleteOption) (*DeleteResult, error)
+	DeletePurchaseBulk(ctx context.Context, accessToken string, purchaseIDs []string, option enums.DeleteOption) ([]DeleteResult, error)
+	GetInvoicesForIdentity(ctx context.Context, accessToken, identityId string, dateRange DateRange, states []string) ([]InvoiceSummary, error)
+	CreateReceipt(ctx context.Context, accessToken string, request CreateReceiptRequest) (string, error)
+	DeleteReceipt(ctx context.Context, accessToken string, receiptID string) error
+	RenderFormPDF(ctx context.Context, accessToken, crmId string, w io.Writer) (int64, error)
+	GenerateInvoicePDFs(ctx context.Context, accessToken string, crmIds []string, dir string, workers int) (*InvoicePDFManifest, error)
+
+	// Tasks
+	CreateTask(ctx context.Context, accessToken string, request CreateTaskRequest) (string, error)
+	GetTask(ctx context.Context, accessToken, crmId string) (*TaskInfo, error)
+	UpdateTask(ctx context.Context, accessToken string, request UpdateTaskRequest) (string, error)
+	CompleteTask(ctx context.Context, accessToken string, request CompleteTaskRequest) error
+	FindTasks(ctx context.Context, accessToken string, queries []Query) (*FindTaskResponse, error)
+
+	// Tickets
+	CreateTicket(ctx context.Context, accessToken string, request CreateTicketRequest) (string, error)
+	GetTicket(ctx context.Context, accessToken, crmId string) (*TicketInfo, error)
+	ReplyToTicket(ctx context.Context, accessToken string, request ReplyTicketRequest) (string, error)
+	CloseTicket(ctx context.Context, accessToken string, request CloseTicketRequest) error
+	FindTickets(ctx context.Context, accessToken string, queries []Query) (*FindTicketResponse, error)
+	FindTicketsPage(ctx context.Context, accessToken string, queries []Query, pageNumber, pageSize int64) (*FindTicketResponse, error)
+
+	// Opportunities
+	CreateOpportunity(ctx context.Context, accessToken string, request CreateOpportunityRequest) (string, error)
+	GetOpportunity(ctx context.Context, accessToken, crmId string) (*OpportunityInfo, error)
+	UpdateOpportunityStage(ctx context.Context, accessToken string, request UpdateOpportunityStageRequest) error
+	FindOpportunities(ctx context.Context, accessToken string, queries []Query) (*FindOpportunityResponse, error)
+	FindOpportunitiesPage(ctx context.Context, accessToken string, queries []Query, pageNumber, pageSize int64) (*FindOpportunityResponse, error)
+
+	// Notes, attachments and tags
+	AddNote(ctx context.Context, accessToken, crmId string, note NoteRequest) (string, error)
+	ListNotes(ctx context.Context, accessToken, crmId string) ([]Note, error)
+	UploadAttachment(ctx context.Context, accessToken, crmId, filename string, content io.Reader) (string, error)
+	ListAttachments(ctx context.Context, accessToken, crmId string) ([]AttachmentInfo, error)
+	DownloadAttachment(ctx context.Context, accessToken, attachmentId string, w io.Writer) (int64, error)
+	DeleteAttachment(ctx context.Context, accessToken, attachmentId string) error
+	AddTags(ctx context.Context, accessToken, crmId string, tags []string) error
+	RemoveTags(ctx context.Context, accessToken, crmId string, tags []string) error
+	ReplaceTags(ctx context.Context, accessToken, crmId string, tags []string) error
+	ListTags(ctx context.Context, accessToken string) ([]string, error)
+
+	// Email
+	SendEmail(ctx context.Context, accessToken string, request SendEmailRequest) (string, error)
+	GetEmailStatus(ctx context.Context, accessToken, emailId string) (*EmailStatus, error)
+
+	// Users and lookups
+	ListUsers(ctx context.Context, accessToken string) ([]User, error)
+	GetUserByUsername(ctx context.Context, accessToken, username string) (*User, error)
+	GetCurrentUser(ctx context.Context, accessToken string) (*User, error)
+	GetPicklist(ctx context.Context, accessToken, name string) ([]PicklistItem, error)
+	ListColors(ctx context.Context, accessToken string) ([]Color, error)
+	GetProductByCode(ctx context.Context, accessToken, code string) (*Product, error)
+	Ref() *RefData
+}
+
+var _ GoPayamgostarIface = (*GoPayamgostar)(nil)
@@ -4,23 +4,30 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/faultinject"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/erfandiakoo/gopayamgostar/v2/seed"
 	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
 	"github.com/go-resty/resty/v2"
 	"github.com/stretchr/testify/require"
-	"golang.org/x/exp/rand"
 )
 
 type configAdmin struct {
@@ -47,30 +54,18 @@ var (
 	testUserID string
 )
 
-type RestyLogWriter struct {
-	io.Writer
+// testLogWriter writes the client logs to the test log
+type testLogWriter struct {
 	t testing.TB
 }
 
-func (w *RestyLogWriter) Errorf(format string, v ...interface{}) {
-	w.write("[ERROR] "+format, v...)
-}
-
-func (w *RestyLogWriter) Warnf(format string, v ...interface{}) {
-	w.write("[WARN] "+format, v...)
-}
-
-func (w *RestyLogWriter) Debugf(format string, v ...interface{}) {
-	w.write("[DEBUG] "+format, v...)
-}
-
-func (w *RestyLogWriter) write(format string, v ...interface{}) {
-	w.t.Logf(format, v...)
+func (w testLogWriter) Write(p []byte) (int, error) {
+	w.t.Log(strings.TrimRight(string(p), "\n"))
+	return len(p), nil
 }
 
 func GetConfig(t testing.TB) *Config {
 	configOnce.Do(func() {
-		rand.Seed(uint64(time.Now().UTC().UnixNano()))
 		configFileName, ok := os.LookupEnv("GOPAYAMGOSTAR_TEST_CONFIG")
 		if !ok {
 			configFileName = filepath.Join("testdata", "config.json")
@@ -98,7 +93,6 @@ func GetConfig(t testing.TB) *Config {
 
 func NewClientWithDebug(t testing.TB) *gopayamgostar.GoPayamgostar {
 	cfg := GetConfig(t)
-	client := gopayamgostar.NewClient(cfg.HostName)
 	cond := func(resp *resty.Response, err error) bool {
 		if resp != nil && resp.IsError() {
 			if e, ok := resp.Error().(*gopayamgostar.HTTPErrorResponse); ok {
@@ -108,53 +102,13 @@ func NewClientWithDebug(t testing.TB) *gopayamgostar.GoPayamgostar {
 		}
 		return false
 	}
+	logger := slog.New(slog.NewTextHandler(testLogWriter{t: t}, &slog.HandlerOptions{Level: slog.LevelDebug}))
 
-	restyClient := client.RestyClient()
-
-	// restyClient.AddRetryCondition(
-	// 	func(r *resty.Response, err error) bool {
-	// 		if err != nil || r.RawResponse.StatusCode == 500 || r.RawResponse.StatusCode == 502 {
-	// 			return true
-	// 		}
-
-	// 		return false
-	// 	},
-	// ).SetRetryCount(5).SetRetryWaitTime(10 * time.Millisecond)
-
-	restyClient.
-		// SetDebug(true).
-		SetLogger(&RestyLogWriter{
-			t: t,
-		}).
-		SetRetryCount(10).
-		SetRetryWaitTime(2 * time.Second).
-		AddRetryCondition(cond)
-
-	return client
-}
-
-// FailRequest fails requests and returns an error
-//
-//	err - returned error or nil to return the default error
-//	failN - number of requests to be failed
-//	skipN = number of requests to be executed and not failed by this function
-func FailRequest(client *gopayamgostar.GoPayamgostar, err error, failN, skipN int) *gopayamgostar.GoPayamgostar {
-	client.RestyClient().OnBeforeRequest(
-		func(c *resty.Client, r *resty.Request) error {
-			if skipN > 0 {
-				skipN--
-				return nil
-			}
-			if failN == 0 {
-				return nil
-			}
-			failN--
-			if err == nil {
-				err = fmt.Errorf("an error for request: %+v", r)
-			}
-			return err
-		},
+	client := gopayamgostar.NewClient(cfg.HostName,
+		gopayamgostar.WithLogger(logger),
+		gopayamgostar.WithRetry(10, 2*time.Second, 2*time.Second, cond),
 	)
+
 	return client
 }
 
@@ -214,7 +168,7 @@ func GetUserInfo(t *testing.T) {
 	)
 	require.NoError(t, err, "Failed to fetch userinfo")
 	t.Log(userInfo)
-	FailRequest(client, nil, 1, 0)
+	faultinject.Install(client).Add(faultinject.Rule{Times: 1, Err: faultinject.ErrInjected})
 	_, err = client.GetPersonInfoById(
 		context.Background(),
 		token.AccessToken,
@@ -233,7 +187,7 @@ func Test_GetUserInfo(t *testing.T) {
 	)
 	require.NoError(t, err, "Failed to fetch userinfo")
 	t.Log(userInfo)
-	FailRequest(client, nil, 1, 0)
+	faultinject.Install(client).Add(faultinject.Rule{Times: 1, Err: faultinject.ErrInjected})
 	_, err = client.GetPersonInfoById(
 		context.Background(),
 		token.AccessToken,
@@ -252,7 +206,7 @@ func Test_GetFormInfo(t *testing.T) {
 	)
 	require.NoError(t, err, "Failed to fetch forminfo")
 	t.Log(formInfo)
-	FailRequest(client, nil, 1, 0)
+	faultinject.Install(client).Add(faultinject.Rule{Times: 1, Err: faultinject.ErrInjected})
 	_, err = client.GetPersonInfoById(
 		context.Background(),
 		token.AccessToken,
@@ -260,52 +214,26 @@ func Test_GetFormInfo(t *testing.T) {
 	require.Error(t, err, "")
 }
 
+// CreatePurchase seeds a person with a purchase invoice and returns a
+// teardown deleting them, with the id of the invoice
 func CreatePurchase(t *testing.T, client *gopayamgostar.GoPayamgostar) (func(), string) {
 	token := GetToken(t, client)
 
-	purchase := gopayamgostar.CreatePurchase{
-		CRMObjectTypeCode: "PurchaseInvoice",
-		Details: []gopayamgostar.Detail{
-			{
-				IsService:      *gopayamgostar.BoolP(true),
-				BaseUnitPrice:  *gopayamgostar.Int64P(1000),
-				FinalUnitPrice: *gopayamgostar.Int64P(1000),
-				TotalUnitPrice: *gopayamgostar.Int64P(1000),
-				Count:          1,
-				ReturnedCount:  1,
-				TotalVat:       0,
-				TotalToll:      0,
-				TotalDiscount:  0,
-				ProductCode:    "product-2",
-			},
-		},
-		FinalValue: *gopayamgostar.Int64P(1000),
-		TotalValue: *gopayamgostar.Int64P(1000),
-		IdentityID: "f845cf77-fec4-4631-b106-7f3d8580321b",
-		ColorID:    1,
-		Discount:   0,
-		Vat:        0,
-		Toll:       0,
-	}
-	purchaseID, err := client.CreatePurchase(
-		context.Background(),
-		token.AccessToken,
-		purchase,
-	)
-
-	require.NoError(t, err, "CreatePurchase failed")
-	purchase.CrmId = purchaseID
+	graph, teardown, err := seed.Create(context.Background(), client, token.AccessToken, seed.Spec{
+		Persons: []seed.Person{{Ref: "buyer", FirstName: "gopayamgostar", LastName: "test", Phones: []string{"09120000000"}}},
+		Invoices: []seed.Invoice{{Ref: "purchase", Person: "buyer", Lines: []seed.Line{
+			{ProductCode: "product-2", Count: 1, UnitPrice: gopayamgostar.NewMoney(1000), Service: true},
+		}}},
+	})
+	require.NoError(t, err, "CreatePurchaseInvoice failed")
 
-	t.Logf("Created Purchase: %+v", purchase)
-	// tearDown := func() {
-	// 	err := client.DeletePurchase(
-	// 		context.Background(),
-	// 		token.AccessToken,
-	// 		purchaseID)
-	// 	require.NoError(t, err, "Delete Purchase")
-	// }
+	purchaseID := graph.ID("purchase")
+	t.Logf("Created Purchase %s", purchaseID)
+	tearDown := func() {
+		require.NoError(t, teardown(), "Delete Purchase")
+	}
 
-	return nil, purchase.CRMObjectTypeCode
+	return tearDown, purchaseID
 }
 
 func Test_CreatePurchase(t *testing.T) {
@@ -329,7 +257,7 @@ func Test_FindPersonInfoByName(t *testing.T) {
 	)
 	require.NoError(t, err, "Failed to fetch personInfo")
 	t.Log(personInfo)
-	FailRequest(client, nil, 1, 0)
+	faultinject.Install(client).Add(faultinject.Rule{Times: 1, Err: faultinject.ErrInjected})
 	_, err = client.FindPersonByName(
 		context.Background(),
 		token.AccessToken,
@@ -353,7 +281,7 @@ func FindPersonInfoByName(t *testing.T) {
 	)
 	require.NoError(t, err, "Failed to fetch personInfo")
 	t.Log(userInfo)
-	FailRequest(client, nil, 1, 0)
+	faultinject.Install(client).Add(faultinject.Rule{Times: 1, Err: faultinject.ErrInjected})
 	_, err = client.FindPersonByName(
 		context.Background(),
 		token.AccessToken,
@@ -429,7 +357,7 @@ func Test_UpdateForm(t *testing.T) {
 	t.Log("CRMId:", crmid)
 
 	// Test failure case (simulate request failure)
-	FailRequest(client, nil, 1, 0)
+	faultinject.Install(client).Add(faultinject.Rule{Times: 1, Err: faultinject.ErrInjected})
 
 	_, err = client.UpdateForm(
 		context.Background(),
@@ -450,7 +378,7 @@ func Test_CreateForm(t *testing.T) {
 		ExtendedProperties: []gopayamgostar.ExtendedProperty{
 			{
 				UserKey: "DepositDate",
-				Value:   *gopayamgostar.StringP("1403/12/12"),
+				Value:   gopayamgostar.NewJalaliDate(1403, 12, 12).String(),
 			},
 			{
 				UserKey: "DepositAmount",
@@ -508,7 +436,7 @@ func Test_CreateForm(t *testing.T) {
 	t.Log("CRMId:", crmid)
 
 	// Test failure case (simulate request failure)
-	FailRequest(client, nil, 1, 0)
+	faultinject.Install(client).Add(faultinject.Rule{Times: 1, Err: faultinject.ErrInjected})
 
 	_, err = client.CreateForm(
 		context.Background(),
@@ -517,3 +445,1212 @@ func Test_CreateForm(t *testing.T) {
 	)
 	require.Error(t, err, "Expected error but got nil")
 }
+
+func Test_CreateTask(t *testing.T) {
+	t.Parallel()
+	client := NewClientWithDebug(t)
+	token := GetToken(t, client)
+
+	dueDate := time.Now().Add(24 * time.Hour)
+	taskID, err := client.CreateTask(
+		context.Background(),
+		token.AccessToken,
+		gopayamgostar.CreateTaskRequest{
+			CRMObjectTypeCode:  "Task",
+			Subject:            "Follow up settlement request",
+			DueDate:            &dueDate,
+			RelatedCRMObjectID: gopayamgostar.StringP("d81d07dd-cdc2-479a-99d5-0270a1f8f07d"),
+			IdentityID:         gopayamgostar.StringP("f845cf77-fec4-4631-b106-7f3d8580321b"),
+		},
+	)
+	require.NoError(t, err, "Failed to create task")
+
+	task, err := client.GetTask(context.Background(), token.AccessToken, taskID)
+	require.NoError(t, err, "Failed to get task")
+	require.Equal(t, "Follow up settlement request", task.Subject)
+
+	err = client.CompleteTask(
+		context.Background(),
+		token.AccessToken,
+		gopayamgostar.CompleteTaskRequest{CrmId: taskID},
+	)
+	require.NoError(t, err, "Failed to complete task")
+
+	faultinject.Install(client).Add(faultinject.Rule{Times: 1, Err: faultinject.ErrInjected})
+	_, err = client.GetTask(context.Background(), token.AccessToken, taskID)
+	require.Error(t, err, "")
+}
+
+func TestTasks(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	client := server.Client()
+	ctx := context.Background()
+
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	dueDate := time.Date(2024, 3, 21, 9, 30, 0, 0, time.UTC)
+	taskID, err := client.CreateTask(ctx, token.AccessToken, gopayamgostar.CreateTaskRequest{
+		CRMObjectTypeCode:  "Task",
+		Subject:            "Follow up settlement request",
+		DueDate:            &dueDate,
+		RelatedCRMObjectID: gopayamgostar.StringP("form-1"),
+		IdentityID:         gopayamgostar.StringP("person-1"),
+	})
+	require.NoError(t, err)
+
+	task, err := client.GetTask(ctx, token.AccessToken, taskID)
+	require.NoError(t, err)
+	require.Equal(t, "Follow up settlement request", task.Subject)
+	require.Equal(t, "form-1", task.RelatedCRMObjectID)
+	require.Equal(t, "person-1", task.IdentityID)
+	require.Equal(t, dueDate, task.DueDate.Time)
+	require.False(t, task.IsCompleted)
+
+	_, err = client.UpdateTask(ctx, token.AccessToken, gopayamgostar.UpdateTaskRequest{
+		CrmId:              taskID,
+		Subject:            "Call about the settlement request",
+		DueDate:            &dueDate,
+		RelatedCRMObjectID: gopayamgostar.StringP("form-1"),
+		AssignedToUserName: gopayamgostar.StringP("support"),
+	})
+	require.NoError(t, err)
+
+	found, err := client.FindTasks(ctx, token.AccessToken, []gopayamgostar.Query{{Field: "AssignedToUserName", Value: "support"}})
+	require.NoError(t, err)
+	require.Len(t, found.Data, 1)
+	require.Equal(t, "Call about the settlement request", found.Data[0].Subject)
+
+	require.NoError(t, client.CompleteTask(ctx, token.AccessToken, gopayamgostar.CompleteTaskRequest{CrmId: taskID}))
+	stored, ok := server.Task(taskID)
+	require.True(t, ok)
+	require.True(t, stored.IsCompleted)
+	require.NotNil(t, stored.CompletionDate)
+
+	err = client.CompleteTask(ctx, token.AccessToken, gopayamgostar.CompleteTaskRequest{CrmId: taskID})
+	require.ErrorIs(t, err, gopayamgostar.ErrConflict)
+	_, err = client.GetTask(ctx, token.AccessToken, "task-2")
+	require.ErrorIs(t, err, gopayamgostar.ErrNotFound)
+}
+
+func Test_CreateTicket(t *testing.T) {
+	t.Parallel()
+	client := NewClientWithDebug(t)
+	token := GetToken(t, client)
+
+	ticketID, err := client.CreateTicket(
+		context.Background(),
+		token.AccessToken,
+		gopayamgostar.CreateTicketRequest{
+			CRMObjectTypeCode: "Ticket",
+			Subject:           "Deposit not settled",
+			IdentityID:        "f845cf77-fec4-4631-b106-7f3d8580321b",
+			Priority:          enums.TicketPriorityHigh,
+		},
+	)
+	require.NoError(t, err, "Failed to create ticket")
+
+	_, err = client.ReplyToTicket(
+		context.Background(),
+		token.AccessToken,
+		gopayamgostar.ReplyTicketRequest{CrmId: ticketID, Body: "We are checking"},
+	)
+	require.NoError(t, err, "Failed to reply to ticket")
+
+	ticket, err := client.GetTicket(context.Background(), token.AccessToken, ticketID)
+	require.NoError(t, err, "Failed to get ticket")
+	require.Equal(t, enums.TicketPriorityHigh, ticket.Priority)
+
+	err = client.CloseTicket(
+		context.Background(),
+		token.AccessToken,
+		gopayamgostar.CloseTicketRequest{CrmId: ticketID},
+	)
+	require.NoError(t, err, "Failed to close ticket")
+}
+
+func TestTickets(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("support", "secret")
+	client := server.Client()
+	ctx := context.Background()
+
+	token, err := client.AdminAuthenticate(ctx, "support", "secret")
+	require.NoError(t, err)
+
+	ticketID, err := client.CreateTicket(ctx, token.AccessToken, gopayamgostar.CreateTicketRequest{
+		CRMObjectTypeCode: "Ticket",
+		Subject:           "Deposit not settled",
+		IdentityID:        "person-1",
+		Priority:          enums.TicketPriorityHigh,
+	})
+	require.NoError(t, err)
+
+	replyID, err := client.ReplyToTicket(ctx, token.AccessToken, gopayamgostar.ReplyTicketRequest{CrmId: ticketID, Body: "We are checking"})
+	require.NoError(t, err)
+
+	ticket, err := client.GetTicket(ctx, token.AccessToken, ticketID)
+	require.NoError(t, err)
+	require.Equal(t, "Deposit not settled", ticket.Subject)
+	require.Equal(t, enums.TicketPriorityHigh, ticket.Priority)
+	require.Equal(t, enums.TicketWaitingForCustomer, ticket.Status)
+	require.Len(t, ticket.Replies, 1)
+	require.Equal(t, replyID, ticket.Replies[0].ID)
+	require.Equal(t, "We are checking", ticket.Replies[0].Body)
+	require.Equal(t, "support", ticket.Replies[0].UserName)
+
+	_, err = client.CreateTicket(ctx, token.AccessToken, gopayamgostar.CreateTicketRequest{Subject: "Other customer", IdentityID: "person-2"})
+	require.NoError(t, err)
+	found, err := client.FindTickets(ctx, token.AccessToken, []gopayamgostar.Query{{Field: "IdentityId", Value: "person-1"}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), found.Total)
+	require.Equal(t, ticketID, found.Data[0].CRMID)
+
+	require.NoError(t, client.CloseTicket(ctx, token.AccessToken, gopayamgostar.CloseTicketRequest{CrmId: ticketID}))
+	stored, ok := server.Ticket(ticketID)
+	require.True(t, ok)
+	require.Equal(t, enums.TicketClosed, stored.Status)
+
+	_, err = client.ReplyToTicket(ctx, token.AccessToken, gopayamgostar.ReplyTicketRequest{CrmId: ticketID, Body: "Reopen"})
+	require.ErrorIs(t, err, gopayamgostar.ErrConflict)
+	_, err = client.GetTicket(ctx, token.AccessToken, "ticket-2")
+	require.ErrorIs(t, err, gopayamgostar.ErrNotFound)
+}
+
+func Test_AddNote(t *testing.T) {
+	t.Parallel()
+	client := NewClientWithDebug(t)
+	token := GetToken(t, client)
+
+	noteID, err := client.AddNote(
+		context.Background(),
+		token.AccessToken,
+		"f845cf77-fec4-4631-b106-7f3d8580321b",
+		gopayamgostar.NoteRequest{Text: "Called the customer about the deposit"},
+	)
+	require.NoError(t, err, "Failed to add note")
+
+	notes, err := client.ListNotes(
+		context.Background(),
+		token.AccessToken,
+		"f845cf77-fec4-4631-b106-7f3d8580321b",
+	)
+	require.NoError(t, err, "Failed to list notes")
+
+	found := false
+	for _, note := range notes {
+		found = found || note.ID == noteID
+	}
+	require.True(t, found, "Added note is not listed")
+}
+
+func TestNotes(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("support", "secret")
+	client := server.Client()
+	ctx := context.Background()
+
+	token, err := client.AdminAuthenticate(ctx, "support", "secret")
+	require.NoError(t, err)
+
+	notes, err := client.ListNotes(ctx, token.AccessToken, "person-1")
+	require.NoError(t, err)
+	require.Empty(t, notes)
+
+	first, err := client.AddNote(ctx, token.AccessToken, "person-1", gopayamgostar.NoteRequest{Text: "Called the customer about the deposit"})
+	require.NoError(t, err)
+	second, err := client.AddNote(ctx, token.AccessToken, "person-1", gopayamgostar.NoteRequest{Text: "Waiting for the receipt", IsPrivate: true})
+	require.NoError(t, err)
+	_, err = client.AddNote(ctx, token.AccessToken, "person-2", gopayamgostar.NoteRequest{Text: "Other customer"})
+	require.NoError(t, err)
+
+	notes, err = client.ListNotes(ctx, token.AccessToken, "person-1")
+	require.NoError(t, err)
+	require.Len(t, notes, 2)
+	require.Equal(t, first, notes[0].ID)
+	require.Equal(t, "person-1", notes[0].CRMObjectID)
+	require.Equal(t, "Called the customer about the deposit", notes[0].Text)
+	require.Equal(t, "support", notes[0].CreatorUserName)
+	require.Equal(t, second, notes[1].ID)
+	require.True(t, notes[1].IsPrivate)
+
+	_, err = client.AddNote(ctx, token.AccessToken, "person-1", gopayamgostar.NoteRequest{Text: " "})
+	var apiErr *gopayamgostar.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusBadRequest, apiErr.Code)
+}
+
+func Test_GetCurrentUser(t *testing.T) {
+	t.Parallel()
+	cfg := GetConfig(t)
+	client := NewClientWithDebug(t)
+	token := GetToken(t, client)
+
+	user, err := client.GetCurrentUser(context.Background(), token.AccessToken)
+	require.NoError(t, err, "Failed to get current user")
+	require.Equal(t, cfg.Admin.UserName, user.Username)
+
+	users, err := client.ListUsers(context.Background(), token.AccessToken)
+	require.NoError(t, err, "Failed to list users")
+	require.NotEmpty(t, users)
+
+	byName, err := client.GetUserByUsername(context.Background(), token.AccessToken, cfg.Admin.UserName)
+	require.NoError(t, err, "Failed to get user by username")
+	require.Equal(t, user.ID, byName.ID)
+}
+
+func TestUsers(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	server.AddUser("support", "secret")
+	server.SetUser(gopayamgostar.User{Username: "support", DisplayName: "Support Desk", Email: "support@example.com", IsActive: true, Roles: []string{"Agent"}})
+	client := server.Client()
+	ctx := context.Background()
+
+	token, err := client.AdminAuthenticate(ctx, "support", "secret")
+	require.NoError(t, err)
+
+	user, err := client.GetCurrentUser(ctx, token.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, "support", user.Username)
+	require.Equal(t, "Support Desk", user.DisplayName)
+	require.Equal(t, []string{"Agent"}, user.Roles)
+	require.NotEmpty(t, user.ID)
+
+	users, err := client.ListUsers(ctx, token.AccessToken)
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	require.Equal(t, "admin", users[0].Username)
+	require.True(t, users[0].IsActive)
+
+	byName, err := client.GetUserByUsername(ctx, token.AccessToken, "support")
+	require.NoError(t, err)
+	require.Equal(t, user.ID, byName.ID)
+
+	_, err = client.GetUserByUsername(ctx, token.AccessToken, "nobody")
+	require.ErrorIs(t, err, gopayamgostar.ErrNotFound)
+
+	server.RevokeTokens()
+	_, err = client.GetCurrentUser(ctx, token.AccessToken)
+	require.ErrorIs(t, err, gopayamgostar.ErrUnauthorized)
+}
+
+func Test_Tags(t *testing.T) {
+	t.Parallel()
+	client := NewClientWithDebug(t)
+	token := GetToken(t, client)
+	const formID = "d81d07dd-cdc2-479a-99d5-0270a1f8f07d"
+
+	tags, err := client.ListTags(context.Background(), token.AccessToken)
+	require.NoError(t, err, "Failed to list tags")
+	require.NotEmpty(t, tags)
+
+	err = client.AddTags(context.Background(), token.AccessToken, formID, []string{"تایید کارشناس"})
+	require.NoError(t, err, "Failed to add tags")
+
+	err = client.RemoveTags(context.Background(), token.AccessToken, formID, []string{"تایید کارشناس"})
+	require.NoError(t, err, "Failed to remove tags")
+
+	err = client.ReplaceTags(context.Background(), token.AccessToken, formID, []string{"تایید کارشناس"})
+	require.NoError(t, err, "Failed to replace tags")
+}
+
+func TestTags(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	server.DefineTags("تایید کارشناس", "urgent", "vip")
+	client := server.Client()
+	ctx := context.Background()
+
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	formID, err := client.CreateForm(ctx, token.AccessToken, gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "SettlementRequest"})
+	require.NoError(t, err)
+
+	tags, err := client.ListTags(ctx, token.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, []string{"urgent", "vip", "تایید کارشناس"}, tags)
+
+	require.NoError(t, client.AddTags(ctx, token.AccessToken, formID, []string{"تایید کارشناس", "urgent"}))
+	gopayamgostartest.RequireObjectHasTag(t, client, token.AccessToken, formID, "urgent")
+
+	require.NoError(t, client.RemoveTags(ctx, token.AccessToken, formID, []string{"urgent"}))
+	form, ok := server.Form(formID)
+	require.True(t, ok)
+	require.Equal(t, []interface{}{"تایید کارشناس"}, form.Tags)
+
+	require.NoError(t, client.ReplaceTags(ctx, token.AccessToken, formID, []string{"vip"}))
+	form, _ = server.Form(formID)
+	require.Equal(t, []interface{}{"vip"}, form.Tags)
+
+	var apiErr *gopayamgostar.APIError
+	err = client.AddTags(ctx, token.AccessToken, formID, []string{"undefined"})
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusBadRequest, apiErr.Code)
+	err = client.AddTags(ctx, token.AccessToken, "form-2", []string{"vip"})
+	require.ErrorIs(t, err, gopayamgostar.ErrNotFound)
+}
+
+func Test_ListColors(t *testing.T) {
+	t.Parallel()
+	client := NewClientWithDebug(t)
+	token := GetToken(t, client)
+
+	colors, err := client.ListColors(context.Background(), token.AccessToken)
+	require.NoError(t, err, "Failed to list colors")
+	require.NotEmpty(t, colors)
+}
+
+func TestListColors(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	server.SetColors(gopayamgostar.Color{ID: 1, Name: "Red"}, gopayamgostar.Color{ID: 2, Name: "Green"})
+	client := server.Client(gopayamgostar.WithCache(gopayamgostar.NewMemoryCache(), time.Minute))
+	ctx := context.Background()
+
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	colors, err := client.ListColors(ctx, token.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, []gopayamgostar.Color{{ID: 1, Name: "Red"}, {ID: 2, Name: "Green"}}, colors)
+
+	// the colors are cached until read WithoutCache
+	server.SetColors(gopayamgostar.Color{ID: 3, Name: "Blue"})
+	colors, err = client.ListColors(ctx, token.AccessToken)
+	require.NoError(t, err)
+	require.Len(t, colors, 2)
+	colors, err = client.ListColors(gopayamgostar.WithoutCache(ctx), token.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, []gopayamgostar.Color{{ID: 3, Name: "Blue"}}, colors)
+
+	_, err = client.ListColors(ctx, "invalid")
+	require.ErrorIs(t, err, gopayamgostar.ErrUnauthorized)
+}
+
+// ----------------
+// Offline tests
+// ----------------
+
+func newLargeCreateFormRequest(properties int) gopayamgostar.CreateFormRequest {
+	request := gopayamgostar.CreateFormRequest{
+		CRMObjectTypeCode: "SettlementRequest",
+		ColorID:           1,
+	}
+	for i := 0; i < properties; i++ {
+		request.ExtendedProperties = append(request.ExtendedProperties, gopayamgostar.ExtendedProperty{
+			UserKey: fmt.Sprintf("Field%d", i),
+			Value:   strings.Repeat("x", 64),
+		})
+	}
+	return request
+}
+
+func newCreateFormServer(t testing.TB, check func(gopayamgostar.CreateFormRequest)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request gopayamgostar.CreateFormRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if check != nil {
+			check(request)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"crmId":"d81d07dd-cdc2-479a-99d5-0270a1f8f07d"}`))
+	}))
+}
+
+func TestCreateFormStreaming(t *testing.T) {
+	createRequest := newLargeCreateFormRequest(100)
+	server := newCreateFormServer(t, func(got gopayamgostar.CreateFormRequest) {
+		require.Equal(t, createRequest, got)
+	})
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithStreamingRequestBodies())
+	crmid, err := client.CreateForm(context.Background(), "token", createRequest)
+	require.NoError(t, err)
+	require.Equal(t, "d81d07dd-cdc2-479a-99d5-0270a1f8f07d", crmid)
+}
+
+func TestCreateFormStreamingRetry(t *testing.T) {
+	createRequest := newLargeCreateFormRequest(10)
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var got gopayamgostar.CreateFormRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		require.Equal(t, createRequest, got)
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"crmId":"d81d07dd-cdc2-479a-99d5-0270a1f8f07d"}`))
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithStreamingRequestBodies())
+	client.RestyClient().
+		SetRetryCount(1).
+		SetRetryWaitTime(time.Millisecond).
+		AddRetryCondition(func(r *resty.Response, err error) bool {
+			return r != nil && r.StatusCode() == http.StatusBadGateway
+		})
+	_, err := client.CreateForm(context.Background(), "token", createRequest)
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts)
+}
+
+func BenchmarkCreateForm(b *testing.B) {
+	createRequest := newLargeCreateFormRequest(5000)
+	server := newCreateFormServer(b, nil)
+	defer server.Close()
+
+	for _, bench := range []struct {
+		name    string
+		options []func(*gopayamgostar.GoPayamgostar)
+	}{
+		{name: "Buffered"},
+		{name: "Streaming", options: []func(*gopayamgostar.GoPayamgostar){gopayamgostar.WithStreamingRequestBodies()}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			client := gopayamgostar.NewClient(server.URL, bench.options...)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := client.CreateForm(context.Background(), "token", createRequest); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDeletePurchaseBulk(t *testing.T) {
+	var options []enums.DeleteOption
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request gopayamgostar.DeleteRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		options = append(options, request.Option)
+		w.Header().Set("Content-Type", "application/json")
+		switch request.Id {
+		case "purchase-1":
+			_, _ = w.Write([]byte(`{"crmId":"purchase-1","affectedRelatedRecords":["receipt-1"],"warnings":["receipt removed"]}`))
+		case "purchase-2":
+			// an empty body is a successful delete without details
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL)
+	results, err := client.DeletePurchaseBulk(
+		context.Background(),
+		"token",
+		[]string{"purchase-1", "purchase-2", "purchase-3", "purchase-4"},
+		enums.DeleteOnly,
+	)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "purchase-3")
+	require.Equal(t, []gopayamgostar.DeleteResult{
+		{CrmId: "purchase-1", AffectedRelatedRecords: []string{"receipt-1"}, Warnings: []string{"receipt removed"}},
+		{CrmId: "purchase-2"},
+	}, results)
+	require.Equal(t, []enums.DeleteOption{enums.DeleteOnly, enums.DeleteOnly, enums.DeleteOnly}, options)
+}
+
+func TestGetInvoicesForIdentity(t *testing.T) {
+	var requests []gopayamgostar.FindRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request gopayamgostar.FindRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		requests = append(requests, request)
+
+		count := 50
+		if request.PageNumber == 2 {
+			count = 10
+		}
+		var response gopayamgostar.FindInvoiceResponse
+		response.Total = 60
+		for i := 0; i < count; i++ {
+			response.Data = append(response.Data, gopayamgostar.InvoiceSummary{
+				CRMID:      fmt.Sprintf("invoice-%d-%d", request.PageNumber, i),
+				CreatDate:  gopayamgostar.CustomTime{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+				ModifyDate: gopayamgostar.CustomTime{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+			})
+		}
+		body, _ := json.Marshal(response)
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL)
+	invoices, err := client.GetInvoicesForIdentity(
+		context.Background(),
+		"token",
+		"f845cf77-fec4-4631-b106-7f3d8580321b",
+		gopayamgostar.DateRange{From: time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)},
+		[]string{"Approved", "Paid"},
+	)
+	require.NoError(t, err)
+	require.Len(t, invoices, 60)
+	require.Len(t, requests, 2)
+
+	queries := requests[0].Queries
+	require.Len(t, queries, 3)
+	require.Equal(t, "IdentityId", queries[0].Field)
+	require.Equal(t, "2024-03-20T00:00:00", queries[1].Value)
+	require.Equal(t, int(enums.In), queries[2].FieldOperator)
+	require.Equal(t, "Approved,Paid", queries[2].Value)
+}
+
+func TestEmptyResponseBodies(t *testing.T) {
+	for name, body := range map[string]string{
+		"empty":      "",
+		"whitespace": " \r\n\t",
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(body))
+			}))
+			defer server.Close()
+
+			client := gopayamgostar.NewClient(server.URL)
+
+			crmid, err := client.UpdateForm(context.Background(), "token", gopayamgostar.UpdateFormRequest{})
+			require.NoError(t, err)
+			require.Empty(t, crmid)
+
+			formInfo, err := client.GetFormInfoById(context.Background(), "token", "d81d07dd-cdc2-479a-99d5-0270a1f8f07d")
+			require.NoError(t, err)
+			require.Empty(t, formInfo.CRMID)
+
+			forms, err := client.FindForm(context.Background(), "token", "BankAccount", nil)
+			require.NoError(t, err)
+			require.Empty(t, forms.Data)
+
+			_, err = client.DeletePurchase(context.Background(), "token", "purchase-1", enums.DeleteOnly)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	for name, body := range map[string]string{
+		"map":  `{"title":"One or more validation errors occurred.","errors":{"Amount":["must be positive"],"City":["is required","is too long"]}}`,
+		"list": `{"errors":[{"field":"Amount","message":"must be positive"},{"key":"City","message":"is required"},{"key":"City","message":"is too long"}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(body))
+			}))
+			defer server.Close()
+
+			_, err := gopayamgostar.NewClient(server.URL).CreateForm(context.Background(), "token", gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Complaint"})
+			var validationErr *gopayamgostar.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			require.Equal(t, map[string][]string{
+				"Amount": {"must be positive"},
+				"City":   {"is required", "is too long"},
+			}, validationErr.Fields)
+			require.EqualError(t, err, "400 Bad Request: Amount: must be positive; City: is required, is too long")
+
+			var apiErr *gopayamgostar.APIError
+			require.ErrorAs(t, err, &apiErr)
+			require.Equal(t, http.StatusBadRequest, apiErr.Code)
+		})
+	}
+
+	// 400 responses without field errors stay plain API errors
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"errorMessage":"bad request"}`))
+	}))
+	defer server.Close()
+	_, err := gopayamgostar.NewClient(server.URL).CreateForm(context.Background(), "token", gopayamgostar.CreateFormRequest{})
+	var validationErr *gopayamgostar.ValidationError
+	require.False(t, errors.As(err, &validationErr))
+	require.EqualError(t, err, "400 Bad Request: bad request")
+}
+
+func TestSentinelErrors(t *testing.T) {
+	for code, sentinel := range map[int]error{
+		http.StatusNotFound:        gopayamgostar.ErrNotFound,
+		http.StatusUnauthorized:    gopayamgostar.ErrUnauthorized,
+		http.StatusConflict:        gopayamgostar.ErrConflict,
+		http.StatusTooManyRequests: gopayamgostar.ErrRateLimited,
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+		}))
+
+		err := gopayamgostar.NewClient(server.URL).DeleteForm(context.Background(), "token", "form-1")
+		server.Close()
+		require.ErrorIs(t, err, sentinel, code)
+		for _, other := range []error{gopayamgostar.ErrNotFound, gopayamgostar.ErrUnauthorized, gopayamgostar.ErrConflict, gopayamgostar.ErrRateLimited} {
+			if other != sentinel {
+				require.NotErrorIs(t, err, other, code)
+			}
+		}
+		var apiErr *gopayamgostar.APIError
+		require.ErrorAs(t, err, &apiErr)
+		require.Equal(t, code, apiErr.Code)
+	}
+}
+
+func TestErrorTypes(t *testing.T) {
+	var status int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := int(atomic.LoadInt32(&status))
+		if code == http.StatusNotFound {
+			w.Header().Set("X-Request-Id", "request-1")
+		}
+		w.WriteHeader(code)
+		_, _ = fmt.Fprintf(w, `{"status":%d}`, code)
+	}))
+	defer server.Close()
+	client := gopayamgostar.NewClient(server.URL)
+
+	call := func(code int) error {
+		atomic.StoreInt32(&status, int32(code))
+		_, err := client.GetFormInfoById(context.Background(), "token", "form-1")
+		require.Error(t, err)
+
+		var apiErr *gopayamgostar.APIError
+		require.ErrorAs(t, err, &apiErr)
+		require.Equal(t, code, apiErr.Code)
+		require.Equal(t, "POST /api/v2/crmobject/form/get", apiErr.Endpoint)
+		require.Equal(t, fmt.Sprintf(`{"status":%d}`, code), string(apiErr.Body))
+		require.NotEmpty(t, apiErr.RequestID)
+		return err
+	}
+
+	var notFound *gopayamgostar.NotFoundError
+	err := call(http.StatusNotFound)
+	require.ErrorAs(t, err, &notFound)
+	require.Equal(t, "request-1", notFound.RequestID)
+	require.ErrorIs(t, err, gopayamgostar.ErrNotFound)
+
+	var authErr *gopayamgostar.AuthError
+	require.ErrorAs(t, call(http.StatusUnauthorized), &authErr)
+	require.ErrorAs(t, call(http.StatusForbidden), &authErr)
+
+	var serverErr *gopayamgostar.ServerError
+	err = call(http.StatusBadGateway)
+	require.ErrorAs(t, err, &serverErr)
+	require.False(t, errors.As(err, &notFound))
+	require.EqualError(t, err, "502 Bad Gateway")
+
+	err = call(http.StatusConflict)
+	require.False(t, errors.As(err, &serverErr))
+	require.ErrorIs(t, err, gopayamgostar.ErrConflict)
+	_, ok := err.(*gopayamgostar.APIError)
+	require.True(t, ok, "the other status codes still return a bare *APIError")
+}
+
+func TestUploadAttachment(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		require.Equal(t, "d81d07dd-cdc2-479a-99d5-0270a1f8f07d", r.FormValue("crmObjectId"))
+		file, header, err := r.FormFile("file")
+		require.NoError(t, err)
+		defer file.Close()
+		content, err := io.ReadAll(file)
+		require.NoError(t, err)
+		require.Equal(t, "receipt.txt", header.Filename)
+		require.Equal(t, "scanned receipt", string(content))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"attachment-1","fileName":"receipt.txt","createDate":"2024-03-20T10:00:00"}`))
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL)
+	id, err := client.UploadAttachment(
+		context.Background(),
+		"token",
+		"d81d07dd-cdc2-479a-99d5-0270a1f8f07d",
+		"receipt.txt",
+		strings.NewReader("scanned receipt"),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "attachment-1", id)
+}
+
+func TestDownloadAttachment(t *testing.T) {
+	content := strings.Repeat("receipt scan ", 100000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request gopayamgostar.GetRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		if request.ID != "attachment-1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = io.Copy(w, strings.NewReader(content))
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL)
+
+	var buf strings.Builder
+	n, err := client.DownloadAttachment(context.Background(), "token", "attachment-1", &buf)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)), n)
+	require.Equal(t, content, buf.String())
+
+	_, err = client.DownloadAttachment(context.Background(), "token", "attachment-2", &buf)
+	require.Error(t, err)
+}
+
+func TestSetDefaultPhone(t *testing.T) {
+	var update gopayamgostar.UpdatePersonRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v2/crmobject/person/get":
+			_, _ = w.Write([]byte(`{"crmId":"person-1","creatDate":"2024-01-01T00:00:00","modifyDate":"2024-01-01T00:00:00","phoneContacts":[
+				{"id":"phone-1","phoneType":"Mobile","phoneNumber":"09120000000","default":true},
+				{"id":"phone-2","phoneType":"Work","phoneNumber":"02100000000","default":false}]}`))
+		case "/api/v2/crmobject/person/update":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&update))
+			_, _ = w.Write([]byte(`{"crmId":"person-1"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL)
+	require.NoError(t, client.SetDefaultPhone(context.Background(), "token", "person-1", "phone-2"))
+	require.Equal(t, "person-1", update.CrmId)
+	require.False(t, update.PhoneContacts[0].Default)
+	require.True(t, update.PhoneContacts[1].Default)
+	require.Equal(t, enums.PhoneWork, update.PhoneContacts[1].PhoneType)
+
+	require.Error(t, client.SetDefaultPhone(context.Background(), "token", "person-1", "phone-3"))
+}
+
+func TestGetIdentityTimeline(t *testing.T) {
+	day := func(d int) gopayamgostar.CustomTime {
+		return gopayamgostar.CustomTime{Time: time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var response interface{}
+		switch {
+		case strings.Contains(r.URL.Path, "/note/"):
+			response = []gopayamgostar.Note{{ID: "note", CreateDate: day(4)}}
+		case strings.Contains(r.URL.Path, "/task/"):
+			response = gopayamgostar.FindTaskResponse{Data: []gopayamgostar.TaskInfo{{CRMID: "task", CreatDate: day(2)}}}
+		case strings.Contains(r.URL.Path, "/invoice/"):
+			response = gopayamgostar.FindInvoiceResponse{Total: 1, Data: []gopayamgostar.InvoiceSummary{{CRMID: "invoice", CreatDate: day(1)}}}
+		case strings.Contains(r.URL.Path, "/ticket/"):
+			response = gopayamgostar.FindTicketResponse{Data: []gopayamgostar.TicketInfo{{CRMID: "ticket", CreatDate: day(5)}}}
+		case strings.Contains(r.URL.Path, "/form/"):
+			response = gopayamgostar.FindFormResponse{Data: []gopayamgostar.FormResponse{{CRMID: "form", CreatDate: day(3)}}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		body, _ := json.Marshal(response)
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL)
+	timeline, err := client.GetIdentityTimeline(context.Background(), "token", "identity", "Order")
+	require.NoError(t, err)
+
+	var ids []string
+	for _, entry := range timeline {
+		ids = append(ids, entry.CrmId)
+	}
+	require.Equal(t, []string{"invoice", "task", "form", "note", "ticket"}, ids)
+	require.Equal(t, gopayamgostar.TimelineInvoice, timeline[0].Kind)
+}
+
+func TestStartProcess(t *testing.T) {
+	var body map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.True(t, strings.HasSuffix(r.URL.Path, "/process/start"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL)
+	require.NoError(t, client.StartProcess(context.Background(), "token", "object", "process"))
+	require.Equal(t, map[string]string{"crmObjectId": "object", "processId": "process"}, body)
+}
+
+func TestSendEmail(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v2/email/send", r.URL.Path)
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"crmId":"email-1"}`))
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL)
+	id, err := client.SendEmail(context.Background(), "token", gopayamgostar.SendEmailRequest{
+		To:       []string{"sara@example.com", "ali@example.com"},
+		Cc:       []string{"sales@example.com"},
+		Subject:  "Invoice 1042",
+		HTMLBody: "<p>Your invoice is attached.</p>",
+		Attachments: []gopayamgostar.EmailAttachment{
+			{FileName: "invoice.pdf", ContentType: "application/pdf", Content: []byte("%PDF-1.4")},
+		},
+		RelatedCRMObjectID: gopayamgostar.StringP("invoice-1"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "email-1", id)
+
+	require.Equal(t, []interface{}{"sara@example.com", "ali@example.com"}, body["to"])
+	require.Equal(t, []interface{}{"sales@example.com"}, body["cc"])
+	require.NotContains(t, body, "bcc")
+	require.Equal(t, "Invoice 1042", body["subject"])
+	require.Equal(t, "invoice-1", body["relatedCrmObjectId"])
+	require.Equal(t, []interface{}{map[string]interface{}{
+		"fileName":    "invoice.pdf",
+		"contentType": "application/pdf",
+		"content":     "JVBERi0xLjQ=",
+	}}, body["attachments"])
+}
+
+func TestGetEmailStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v2/email/status", r.URL.Path)
+		var request gopayamgostar.GetRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Header().Set("Content-Type", "application/json")
+		switch request.ID {
+		case "email-1":
+			_, _ = w.Write([]byte(`{"id":"email-1","status":"Sent","sentDate":"2024-03-20T10:00:00"}`))
+		case "email-2":
+			_, _ = w.Write([]byte(`{"id":"email-2","status":"Failed","errorMessage":"mailbox full","sentDate":null}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errorMessage":"email not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL)
+	ctx := context.Background()
+
+	status, err := client.GetEmailStatus(ctx, "token", "email-1")
+	require.NoError(t, err)
+	require.Equal(t, "Sent", status.Status)
+	require.Empty(t, status.ErrorMessage)
+	require.NotNil(t, status.SentDate)
+	require.Equal(t, time.Date(2024, 3, 20, 10, 0, 0, 0, time.UTC), status.SentDate.Time.UTC())
+
+	status, err = client.GetEmailStatus(ctx, "token", "email-2")
+	require.NoError(t, err)
+	require.Equal(t, "Failed", status.Status)
+	require.Equal(t, "mailbox full", status.ErrorMessage)
+	require.Nil(t, status.SentDate)
+
+	_, err = client.GetEmailStatus(ctx, "token", "email-3")
+	require.ErrorIs(t, err, gopayamgostar.ErrNotFound)
+}
+
+func TestOpportunities(t *testing.T) {
+	bodies := map[string]map[string]interface{}{}
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies[r.URL.Path] = body
+
+		w.Header().Set("Content-Type", "application/json")
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errorMessage":"stage is closed"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v2/crmobject/opportunity/create":
+			_, _ = w.Write([]byte(`{"crmId":"opportunity-1"}`))
+		case "/api/v2/crmobject/opportunity/get":
+			_, _ = w.Write([]byte(`{"crmId":"opportunity-1","subject":"Renewal","identityId":"person-1","amount":25000000,
+				"expectedCloseDate":"2024-06-01T00:00:00","stageId":"stage-2","stageName":"Negotiation","probability":60}`))
+		case "/api/v2/crmobject/opportunity/stage/update":
+		case "/api/v2/crmobject/opportunity/find":
+			_, _ = w.Write([]byte(`{"data":[{"crmId":"opportunity-1","amount":25000000},{"crmId":"opportunity-2","amount":1000}],"total":2}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL)
+	ctx := context.Background()
+	closeDate := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	id, err := client.CreateOpportunity(ctx, "token", gopayamgostar.CreateOpportunityRequest{
+		CRMObjectTypeCode: "Opportunity",
+		Subject:           "Renewal",
+		IdentityID:        "person-1",
+		Amount:            25000000,
+		ExpectedCloseDate: &closeDate,
+		StageID:           gopayamgostar.StringP("stage-1"),
+		Probability:       30,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "opportunity-1", id)
+	create := bodies["/api/v2/crmobject/opportunity/create"]
+	require.Equal(t, "Opportunity", create["crmObjectTypeCode"])
+	require.Equal(t, "person-1", create["identityId"])
+	require.Equal(t, float64(25000000), create["amount"])
+	require.Equal(t, "2024-06-01T00:00:00Z", create["expectedCloseDate"])
+	require.Equal(t, "stage-1", create["stageId"])
+	require.Equal(t, float64(30), create["probability"])
+
+	opportunity, err := client.GetOpportunity(ctx, "token", "opportunity-1")
+	require.NoError(t, err)
+	require.Equal(t, "opportunity-1", bodies["/api/v2/crmobject/opportunity/get"]["id"])
+	require.Equal(t, "Renewal", opportunity.Subject)
+	require.Equal(t, int64(25000000), opportunity.Amount)
+	require.Equal(t, "Negotiation", opportunity.StageName)
+	require.Equal(t, 60, opportunity.Probability)
+	require.Equal(t, closeDate, opportunity.ExpectedCloseDate.Time.UTC())
+
+	require.NoError(t, client.UpdateOpportunityStage(ctx, "token", gopayamgostar.UpdateOpportunityStageRequest{
+		CrmId:       "opportunity-1",
+		StageID:     "stage-2",
+		Probability: gopayamgostar.IntP(60),
+	}))
+	require.Equal(t, map[string]interface{}{
+		"crmId":       "opportunity-1",
+		"stageId":     "stage-2",
+		"probability": float64(60),
+		"description": nil,
+	}, bodies["/api/v2/crmobject/opportunity/stage/update"])
+
+	found, err := client.FindOpportunities(ctx, "token", []gopayamgostar.Query{{Field: "IdentityId", Value: "person-1"}})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), found.Total)
+	require.Len(t, found.Data, 2)
+	require.Equal(t, "opportunity-2", found.Data[1].CRMID)
+	find := bodies["/api/v2/crmobject/opportunity/find"]
+	require.Equal(t, "Opportunity", find["typeKey"])
+	require.Equal(t, float64(1), find["pageNumber"])
+	require.Equal(t, float64(10), find["pageSize"])
+
+	failing.Store(true)
+	requireBadRequest := func(err error) {
+		t.Helper()
+		var apiErr *gopayamgostar.APIError
+		require.ErrorAs(t, err, &apiErr)
+		require.Equal(t, http.StatusBadRequest, apiErr.Code)
+		require.Contains(t, apiErr.Message, "stage is closed")
+	}
+	_, err = client.CreateOpportunity(ctx, "token", gopayamgostar.CreateOpportunityRequest{Subject: "Renewal"})
+	requireBadRequest(err)
+	_, err = client.GetOpportunity(ctx, "token", "opportunity-1")
+	requireBadRequest(err)
+	requireBadRequest(client.UpdateOpportunityStage(ctx, "token", gopayamgostar.UpdateOpportunityStageRequest{CrmId: "opportunity-1", StageID: "stage-3"}))
+	_, err = client.FindOpportunities(ctx, "token", nil)
+	requireBadRequest(err)
+}
+
+func TestStableFindSort(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies = append(bodies, body)
+		_, _ = w.Write([]byte(`{"data":[],"total":0}`))
+	}))
+	defer server.Close()
+
+	_, err := gopayamgostar.NewClient(server.URL).FindForm(context.Background(), "token", "Order", nil)
+	require.NoError(t, err)
+	_, err = gopayamgostar.NewClient(server.URL, gopayamgostar.WithStableFindSort()).
+		FindFormPage(context.Background(), "token", "Order", nil, 2, 50)
+	require.NoError(t, err)
+
+	require.Len(t, bodies, 2)
+	require.NotContains(t, bodies[0], "sorts")
+	require.Equal(t, []interface{}{map[string]interface{}{"field": "CrmId", "descending": false}}, bodies[1]["sorts"])
+}
+
+func TestWaitForObjectCondition(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stage := "Pending"
+		if atomic.AddInt32(&calls, 1) >= 3 {
+			stage = "Approved"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"CrmId":"order-1","StageId":%q}`, stage)
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL)
+	approved := func(object *gopayamgostar.FormInfo) bool {
+		return object.StageID == "Approved"
+	}
+
+	object, err := client.WaitForObjectCondition(context.Background(), "token", "order-1", approved, time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, "Approved", object.StageID)
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	object, err = client.WaitForObjectCondition(ctx, "token", "order-1", func(*gopayamgostar.FormInfo) bool { return false }, time.Millisecond)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, "order-1", object.CRMID)
+}
+
+type countingTransport struct {
+	requests int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.requests, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClientOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "crm-sync/1.0" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("X-Slow") != "" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{}
+	client := gopayamgostar.NewClient(server.URL,
+		gopayamgostar.WithRestyClient(resty.New().SetHeader("X-Custom", "1")),
+		gopayamgostar.WithUserAgent("crm-sync/1.0"),
+		gopayamgostar.WithCircuitBreaker(5, time.Second),
+		gopayamgostar.WithTransport(transport),
+		gopayamgostar.WithTimeout(100*time.Millisecond),
+	)
+	var hooked int32
+	client.RegisterRequestHook(func(info gopayamgostar.HookInfo, req *http.Request) error {
+		atomic.AddInt32(&hooked, 1)
+		require.Equal(t, "1", req.Header.Get("X-Custom"))
+		return nil
+	})
+
+	ctx := context.Background()
+	require.NoError(t, client.DeleteForm(ctx, "token", "form"))
+	require.Equal(t, int32(1), atomic.LoadInt32(&transport.requests))
+	require.Equal(t, int32(1), atomic.LoadInt32(&hooked))
+
+	client.RestyClient().SetHeader("X-Slow", "1")
+	require.Error(t, client.DeleteForm(ctx, "token", "form"))
+}
+
+func TestConnectionPoolOptions(t *testing.T) {
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	burst := func(client *gopayamgostar.GoPayamgostar) int32 {
+		atomic.StoreInt32(&conns, 0)
+		for round := 0; round < 2; round++ {
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					require.NoError(t, client.DeleteForm(context.Background(), "token", "form"))
+				}()
+			}
+			wg.Wait()
+		}
+		return atomic.LoadInt32(&conns)
+	}
+
+	// net/http keeps 2 idle connections, so the second round opens more
+	require.Greater(t, burst(gopayamgostar.NewClient(server.URL)), int32(5))
+	require.Equal(t, int32(5), burst(gopayamgostar.NewClient(server.URL, gopayamgostar.WithMaxIdleConns(10))))
+
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithMaxIdleConns(10), gopayamgostar.WithIdleTimeout(time.Millisecond))
+	atomic.StoreInt32(&conns, 0)
+	require.NoError(t, client.DeleteForm(context.Background(), "token", "form"))
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, client.DeleteForm(context.Background(), "token", "form"))
+	require.Equal(t, int32(2), atomic.LoadInt32(&conns), "the idle connection was closed")
+
+	// other kinds of transports are left as they are
+	transport := &countingTransport{}
+	client = gopayamgostar.NewClient(server.URL, gopayamgostar.WithTransport(transport), gopayamgostar.WithMaxIdleConns(10))
+	require.NoError(t, client.DeleteForm(context.Background(), "token", "form"))
+	require.Equal(t, int32(1), atomic.LoadInt32(&transport.requests))
+}
+
+type recordingLogger struct {
+	mu   sync.Mutex
+	logs strings.Builder
+}
+
+func (l *recordingLogger) Errorf(format string, v ...interface{}) { l.printf(format, v...) }
+func (l *recordingLogger) Warnf(format string, v ...interface{})  { l.printf(format, v...) }
+func (l *recordingLogger) Debugf(format string, v ...interface{}) { l.printf(format, v...) }
+
+func (l *recordingLogger) printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(&l.logs, format, v...)
+}
+
+func TestWithStaticHeaders(t *testing.T) {
+	var missing int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-License-Key") != "secret-license" || r.Header.Get("X-Tenant") != "branch-1" {
+			atomic.AddInt32(&missing, 1)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"accessToken":"token"}`))
+	}))
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	client := gopayamgostar.NewClient(server.URL,
+		gopayamgostar.WithStaticHeaders(map[string]string{"X-License-Key": "secret-license"}),
+		gopayamgostar.WithStaticHeaders(map[string]string{"x-tenant": "branch-1"}),
+		gopayamgostar.WithDebug(),
+	)
+	client.RestyClient().SetLogger(logger)
+
+	ctx := context.Background()
+	_, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteForm(ctx, "token", "form"))
+	require.Zero(t, atomic.LoadInt32(&missing))
+
+	logs := logger.logs.String()
+	require.Contains(t, logs, "X-License-Key")
+	require.NotContains(t, logs, "secret-license")
+	require.NotContains(t, logs, "branch-1")
+}
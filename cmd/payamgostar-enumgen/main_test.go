@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	src, err := render("crmenums", "http://crm.local", []picklist{
+		{
+			Name: "PhoneType",
+			Items: []gopayamgostar.PicklistItem{
+				{Key: "mobile", Name: "موبایل"},
+				{Key: "work-phone", Name: "محل کار"},
+				{Name: "منزل"},
+				{Key: "mobile", Name: "Mobile 2"},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `// Code generated by payamgostar-enumgen from http://crm.local. DO NOT EDIT.
+
+package crmenums
+
+type PhoneType string
+
+const (
+	PhoneTypeMobile    PhoneType = "موبایل"
+	PhoneTypeWorkPhone PhoneType = "محل کار"
+	PhoneTypeمنزل      PhoneType = "منزل"
+	PhoneTypeMobile2   PhoneType = "Mobile 2"
+)
+`, string(src))
+}
@@ -0,0 +1,315 @@
+// Command payamgostar calls a Payamgostar deployment from the shell, for
+// support engineers looking into the data of a tenant without writing Go:
+//
+//	payamgostar login
+//	payamgostar person get <crmId>
+//	payamgostar person find [-type Person] [-limit 20] FirstName=Ali City~Teh
+//	payamgostar form get <crmId>
+//	payamgostar form create <request.json | ->
+//	payamgostar form find -type Complaint [-limit 20] [queries]
+//	payamgostar purchase create <request.json | ->
+//	payamgostar purchase delete [-related] <crmId>
+//
+// Results are written to stdout as JSON. The credentials are read from the
+// file given with -config or PAYAMGOSTAR_CONFIG, in the format of
+// testdata/config.json, and the token of a login is cached in the user cache
+// directory until it expires. Queries are written field=value, with the
+// operators =, !=, >, >=, <, <= and ~ for contains; create requests are the
+// JSON of CreateFormRequest and CreatePurchaseRequest.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
+	"github.com/pkg/errors"
+)
+
+const defaultLimit = 20
+
+type config struct {
+	HostName string `json:"hostname"`
+	Admin    struct {
+		UserName string `json:"username"`
+		Password string `json:"password"`
+	} `json:"admin"`
+}
+
+// queryOperators are the operators of the queries of find commands, the
+// longer ones first
+var queryOperators = []struct {
+	token    string
+	operator enums.FieldOperator
+}{
+	{"!=", enums.NotEqual},
+	{">=", enums.GreaterThanOrEqual},
+	{"<=", enums.LessThanOrEqual},
+	{"=", enums.Equals},
+	{"~", enums.TextContains},
+	{">", enums.GreateThan},
+	{"<", enums.LessThan},
+}
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// cli holds the state of an invocation
+type cli struct {
+	client *gopayamgostar.GoPayamgostar
+	config config
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+// run executes the command of args and returns the exit code
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("payamgostar", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configFile := flags.String("config", envOr("PAYAMGOSTAR_CONFIG", "config.json"), "path of the connection config")
+	tokenDir := flags.String("token-dir", defaultTokenDir(), "directory caching the tokens of logins, empty to disable")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: payamgostar [flags] login | person get|find | form get|create|find | purchase create|delete")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	c := &cli{stdin: stdin, stdout: stdout}
+	if err := c.setup(*configFile, *tokenDir); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if err := c.dispatch(ctx, flags.Args()); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func (c *cli) setup(configFile, tokenDir string) error {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return errors.Wrap(err, "cannot read config")
+	}
+	if err := json.Unmarshal(data, &c.config); err != nil {
+		return errors.Wrap(err, "cannot parse config")
+	}
+
+	var options []func(*gopayamgostar.GoPayamgostar)
+	if tokenDir != "" {
+		store, err := gopayamgostar.NewFileTokenStore(tokenDir)
+		if err != nil {
+			return err
+		}
+		options = append(options, gopayamgostar.WithTokenStore(store))
+	}
+	c.client = gopayamgostar.NewClient(c.config.HostName, options...)
+	return nil
+}
+
+func (c *cli) dispatch(ctx context.Context, args []string) error {
+	if args[0] == "login" {
+		token, err := c.login(ctx)
+		if err != nil {
+			return err
+		}
+		return c.print(token)
+	}
+	if len(args) < 2 {
+		return errors.Errorf("unknown command %q", args[0])
+	}
+	command, rest := args[0]+" "+args[1], args[2:]
+
+	switch command {
+	case "person get":
+		return get(ctx, c, rest, c.client.GetPersonInfoById)
+	case "person find":
+		return c.find(ctx, rest, "Person", func(ctx context.Context, accessToken, typeKey string, queries []gopayamgostar.Query, limit int64) (interface{}, error) {
+			return c.client.FindPersonsPage(ctx, accessToken, typeKey, queries, 1, limit)
+		})
+	case "form get":
+		return get(ctx, c, rest, c.client.GetFormInfoById)
+	case "form create":
+		var request gopayamgostar.CreateFormRequest
+		return c.create(ctx, rest, &request, func(ctx context.Context, accessToken string) (string, error) {
+			return c.client.CreateForm(ctx, accessToken, request)
+		})
+	case "form find":
+		return c.find(ctx, rest, "", func(ctx context.Context, accessToken, typeKey string, queries []gopayamgostar.Query, limit int64) (interface{}, error) {
+			return c.client.FindFormPage(ctx, accessToken, typeKey, queries, 1, limit)
+		})
+	case "purchase create":
+		var request gopayamgostar.CreatePurchaseRequest
+		return c.create(ctx, rest, &request, func(ctx context.Context, accessToken string) (string, error) {
+			return c.client.CreatePurchaseInvoice(ctx, accessToken, request)
+		})
+	case "purchase delete":
+		return c.deletePurchase(ctx, rest)
+	}
+	return errors.Errorf("unknown command %q", command)
+}
+
+func (c *cli) login(ctx context.Context) (*gopayamgostar.JWT, error) {
+	token, err := c.client.AdminAuthenticate(ctx, c.config.Admin.UserName, c.config.Admin.Password)
+	if err != nil {
+		return nil, errors.Wrap(err, "login failed")
+	}
+	return token, nil
+}
+
+func get[T any](ctx context.Context, c *cli, args []string, fetch func(ctx context.Context, accessToken, crmId string) (*T, error)) error {
+	if len(args) != 1 {
+		return errors.New("expected a crm id")
+	}
+	token, err := c.login(ctx)
+	if err != nil {
+		return err
+	}
+	object, err := fetch(ctx, token.AccessToken, args[0])
+	if err != nil {
+		return err
+	}
+	return c.print(object)
+}
+
+func (c *cli) find(ctx context.Context, args []string, defaultType string, find func(ctx context.Context, accessToken, typeKey string, queries []gopayamgostar.Query, limit int64) (interface{}, error)) error {
+	flags := flag.NewFlagSet("find", flag.ContinueOnError)
+	typeKey := flags.String("type", defaultType, "object type code")
+	limit := flags.Int64("limit", defaultLimit, "number of objects returned")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *typeKey == "" {
+		return errors.New("-type is required")
+	}
+
+	queries := make([]gopayamgostar.Query, 0, flags.NArg())
+	for _, arg := range flags.Args() {
+		query, err := parseQuery(arg)
+		if err != nil {
+			return err
+		}
+		queries = append(queries, query)
+	}
+
+	token, err := c.login(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := find(ctx, token.AccessToken, *typeKey, queries, *limit)
+	if err != nil {
+		return err
+	}
+	return c.print(result)
+}
+
+// create decodes the JSON file of args into request and calls create
+func (c *cli) create(ctx context.Context, args []string, request interface{}, create func(ctx context.Context, accessToken string) (string, error)) error {
+	if len(args) != 1 {
+		return errors.New("expected a request file, - for stdin")
+	}
+	var r io.Reader = c.stdin
+	if args[0] != "-" {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(request); err != nil {
+		return errors.Wrap(err, "cannot parse request")
+	}
+
+	token, err := c.login(ctx)
+	if err != nil {
+		return err
+	}
+	crmId, err := create(ctx, token.AccessToken)
+	if err != nil {
+		return err
+	}
+	return c.print(map[string]string{"crmId": crmId})
+}
+
+func (c *cli) deletePurchase(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("delete", flag.ContinueOnError)
+	related := flags.Bool("related", false, "delete the records related to the purchase too")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("expected a crm id")
+	}
+
+	option := enums.DeleteOnly
+	if *related {
+		option = enums.DeleteWithRelated
+	}
+	token, err := c.login(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := c.client.DeletePurchase(ctx, token.AccessToken, flags.Arg(0), option)
+	if err != nil {
+		return err
+	}
+	return c.print(result)
+}
+
+func (c *cli) print(v interface{}) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(v)
+}
+
+// parseQuery parses a query written field=value
+func parseQuery(arg string) (gopayamgostar.Query, error) {
+	best, at := -1, len(arg)
+	for i, op := range queryOperators {
+		if n := strings.Index(arg, op.token); n > 0 && n < at {
+			best, at = i, n
+		}
+	}
+	if best < 0 {
+		return gopayamgostar.Query{}, errors.Errorf("invalid query %q, expected field=value", arg)
+	}
+	op := queryOperators[best]
+	return gopayamgostar.Query{
+		Field:         arg[:at],
+		FieldOperator: int(op.operator),
+		Value:         arg[at+len(op.token):],
+	}, nil
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func defaultTokenDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "payamgostar", "tokens")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	personID := server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Ali", LastName: "Rezaei"})
+	server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Sara", LastName: "Ahmadi"})
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(configFile, []byte(`{"hostname":"`+server.URL+`","admin":{"username":"admin","password":"secret"}}`), 0o600))
+	tokenDir := filepath.Join(dir, "tokens")
+
+	invoke := func(stdin string, args ...string) (string, string, int) {
+		var stdout, stderr bytes.Buffer
+		args = append([]string{"-config", configFile, "-token-dir", tokenDir}, args...)
+		code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+		return stdout.String(), stderr.String(), code
+	}
+
+	out, _, code := invoke("", "login")
+	require.Zero(t, code)
+	var token gopayamgostar.JWT
+	require.NoError(t, json.Unmarshal([]byte(out), &token))
+	require.NotEmpty(t, token.AccessToken)
+	entries, err := os.ReadDir(tokenDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "the token is cached")
+
+	out, _, code = invoke("", "person", "get", personID)
+	require.Zero(t, code)
+	var person gopayamgostar.PersonInfo
+	require.NoError(t, json.Unmarshal([]byte(out), &person))
+	require.Equal(t, "Rezaei", person.LastName)
+
+	out, _, code = invoke("", "person", "find", "-limit", "5", "FirstName~Sa")
+	require.Zero(t, code)
+	var persons gopayamgostar.FindPersonResponse
+	require.NoError(t, json.Unmarshal([]byte(out), &persons))
+	require.Len(t, persons.Data, 1)
+	require.Equal(t, "Ahmadi", persons.Data[0].LastName)
+
+	out, _, code = invoke(`{"CrmObjectTypeCode":"Complaint","Subject":"late","IdentityId":"`+personID+`"}`, "form", "create", "-")
+	require.Zero(t, code)
+	var created map[string]string
+	require.NoError(t, json.Unmarshal([]byte(out), &created))
+	form, ok := server.Form(created["crmId"])
+	require.True(t, ok)
+	require.Equal(t, "late", form.Subject)
+
+	out, _, code = invoke("", "form", "find", "-type", "Complaint", "IdentityId="+personID)
+	require.Zero(t, code)
+	var forms gopayamgostar.FindFormResponse
+	require.NoError(t, json.Unmarshal([]byte(out), &forms))
+	require.Len(t, forms.Data, 1)
+
+	requestFile := filepath.Join(dir, "purchase.json")
+	require.NoError(t, os.WriteFile(requestFile, []byte(`{"crmObjectTypeCode":"Invoice","identityId":"`+personID+`"}`), 0o600))
+	out, _, code = invoke("", "purchase", "create", requestFile)
+	require.Zero(t, code)
+	require.NoError(t, json.Unmarshal([]byte(out), &created))
+	_, ok = server.Purchase(created["crmId"])
+	require.True(t, ok)
+
+	_, _, code = invoke("", "purchase", "delete", "-related", created["crmId"])
+	require.Zero(t, code)
+	_, ok = server.Purchase(created["crmId"])
+	require.False(t, ok)
+
+	_, stderr, code := invoke("", "person", "get", "missing")
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "404")
+	_, stderr, code = invoke(`{"Unknown":1}`, "form", "create", "-")
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "cannot parse request")
+	_, stderr, code = invoke("", "form", "find")
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "-type is required")
+	_, stderr, code = invoke("", "person", "delete")
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, `unknown command "person delete"`)
+}
+
+func TestParseQuery(t *testing.T) {
+	for arg, want := range map[string]gopayamgostar.Query{
+		"FirstName=Ali":      {Field: "FirstName", Value: "Ali", FieldOperator: int(enums.Equals)},
+		"Amount>=100":        {Field: "Amount", Value: "100", FieldOperator: int(enums.GreaterThanOrEqual)},
+		"Status!=closed":     {Field: "Status", Value: "closed", FieldOperator: int(enums.NotEqual)},
+		"Subject~a=b":        {Field: "Subject", Value: "a=b", FieldOperator: int(enums.TextContains)},
+		"ModifyDate<2024-01": {Field: "ModifyDate", Value: "2024-01", FieldOperator: int(enums.LessThan)},
+	} {
+		query, err := parseQuery(arg)
+		require.NoError(t, err, arg)
+		require.Equal(t, want, query, arg)
+	}
+	_, err := parseQuery("=Ali")
+	require.Error(t, err)
+}
@@ -0,0 +1,41 @@
+package gopayamgostar
+
+import "encoding/json"
+
+// Codec encodes the request bodies and decodes the response bodies of a
+// client. Set one with WithCodec to replace encoding/json by a faster
+// implementation such as json-iterator or sonic.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// stdCodec is the encoding/json Codec used by default
+type stdCodec struct{}
+
+func (stdCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (stdCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// WithCodec makes the client encode and decode the JSON bodies of its
+// requests with codec. The bodies of WithStreamingRequestBodies are still
+// encoded with encoding/json, as they are written to the connection as they
+// are encoded.
+func WithCodec(codec Codec) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		g.codec = codec
+		g.restyClient.SetJSONMarshaler(codec.Marshal)
+	}
+}
+
+// jsonCodec returns the codec of WithCodec, encoding/json by default
+func (g *GoPayamgostar) jsonCodec() Codec {
+	if g.codec == nil {
+		return stdCodec{}
+	}
+	return g.codec
+}
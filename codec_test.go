@@ -0,0 +1,72 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+)
+
+// countingCodec is encoding/json counting its calls
+type countingCodec struct {
+	marshals, unmarshals int32
+}
+
+func (c *countingCodec) Marshal(v interface{}) ([]byte, error) {
+	atomic.AddInt32(&c.marshals, 1)
+	return json.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v interface{}) error {
+	atomic.AddInt32(&c.unmarshals, 1)
+	return json.Unmarshal(data, v)
+}
+
+func TestWithCodec(t *testing.T) {
+	t.Parallel()
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	personID := server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Ali", LastName: "Rezaei"})
+
+	codec := &countingCodec{}
+	ctx := context.Background()
+	client := server.Client(gopayamgostar.WithCodec(codec))
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	calls := func() (int32, int32) {
+		return atomic.SwapInt32(&codec.marshals, 0), atomic.SwapInt32(&codec.unmarshals, 0)
+	}
+	calls()
+
+	id, err := client.CreateForm(ctx, token.AccessToken, gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Complaint"})
+	require.NoError(t, err)
+	require.NotEmpty(t, id)
+	marshals, unmarshals := calls()
+	require.Equal(t, int32(1), marshals, "the request body")
+	require.Equal(t, int32(1), unmarshals, "the crm id")
+
+	forms, err := client.FindForm(ctx, token.AccessToken, "Complaint", nil)
+	require.NoError(t, err)
+	require.Len(t, forms.Data, 1)
+	marshals, unmarshals = calls()
+	require.Equal(t, int32(1), marshals)
+	require.Equal(t, int32(1), unmarshals)
+
+	found, err := client.FindPersonByName(ctx, token.AccessToken, "Person", "Ali", "Rezaei")
+	require.NoError(t, err)
+	require.Len(t, found.Data, 1)
+	person, err := client.GetPersonInfoById(ctx, token.AccessToken, personID)
+	require.NoError(t, err)
+	require.Equal(t, "Rezaei", person.LastName)
+	marshals, unmarshals = calls()
+	require.Equal(t, int32(2), marshals)
+	require.Equal(t, int32(2), unmarshals)
+}
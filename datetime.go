@@ -0,0 +1,122 @@
+package gopayamgostar
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	ptime "github.com/yaa110/go-persian-calendar"
+)
+
+// DateTimeLayout is the layout DateTime is encoded with
+const DateTimeLayout = "2006-01-02T15:04:05"
+
+// dateTimeLayouts are the Gregorian layouts the CRM returns; times without an
+// offset are in the Iran time zone
+var dateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// jalaliClockLayouts are the layouts of the time part of Jalali date times
+var jalaliClockLayouts = []string{"15:04:05", "15:04"}
+
+// DateTime is a point in time the CRM returns in mixed formats: ISO 8601 with
+// or without an offset, Jalali strings such as "1403/12/12" or
+// "1403/12/12 14:30", or null. The zero value is an unset time and is encoded
+// as JSON null.
+type DateTime struct {
+	time.Time
+}
+
+// NewDateTime returns t as a DateTime
+func NewDateTime(t time.Time) DateTime {
+	return DateTime{Time: t}
+}
+
+// ParseDateTime parses a date time in any of the formats DateTime accepts.
+// Persian and Arabic digits are accepted. "" parses to the zero time.
+func ParseDateTime(value string) (DateTime, error) {
+	value = strings.TrimSpace(normalizeDigits(value))
+	if value == "" {
+		return DateTime{}, nil
+	}
+
+	for _, layout := range dateTimeLayouts {
+		// Jalali years also match the Gregorian layouts
+		if t, err := time.ParseInLocation(layout, value, ptime.Iran()); err == nil && t.Year() >= gregorianYearThreshold {
+			return DateTime{Time: t}, nil
+		}
+	}
+
+	datePart, clockPart, _ := strings.Cut(value, " ")
+	if !strings.Contains(value, " ") {
+		datePart, clockPart, _ = strings.Cut(value, "T")
+	}
+	datePart = strings.ReplaceAll(datePart, "-", "/")
+	date, err := ParseJalaliDate(JalaliDateLayout, datePart)
+	if err != nil {
+		return DateTime{}, errors.Errorf("could not parse date time %q", value)
+	}
+	if clockPart == "" {
+		return DateTime{Time: date.Time()}, nil
+	}
+	for _, layout := range jalaliClockLayouts {
+		if clock, err := time.Parse(layout, clockPart); err == nil {
+			t := ptime.Date(date.Year, ptime.Month(date.Month), date.Day, clock.Hour(), clock.Minute(), clock.Second(), 0, ptime.Iran())
+			return DateTime{Time: t.Time()}, nil
+		}
+	}
+	return DateTime{}, errors.Errorf("could not parse date time %q", value)
+}
+
+// Jalali returns the Jalali date of the time in the Iran time zone, the zero
+// date for the zero time
+func (d DateTime) Jalali() JalaliDate {
+	if d.IsZero() {
+		return JalaliDate{}
+	}
+	return JalaliDateOf(d.Time)
+}
+
+// String formats the time with DateTimeLayout in the Iran time zone, the zero
+// time as ""
+func (d DateTime) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.In(ptime.Iran()).Format(DateTimeLayout)
+}
+
+// MarshalJSON encodes the time as a DateTimeLayout string in the Iran time
+// zone, the zero time as null
+func (d DateTime) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON decodes the formats ParseDateTime accepts. null and "" decode
+// to the zero time.
+func (d *DateTime) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*d = DateTime{}
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := ParseDateTime(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
@@ -0,0 +1,63 @@
+package gopayamgostar_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+)
+
+func TestParseDateTime(t *testing.T) {
+	t.Parallel()
+
+	iran := time.FixedZone("IRST", 3*3600+1800)
+	want := time.Date(2025, 3, 2, 14, 30, 0, 0, iran)
+	for _, value := range []string{
+		"2025-03-02T14:30:00",
+		"2025-03-02T14:30:00.000",
+		"2025-03-02T11:00:00Z",
+		"2025-03-02 14:30:00",
+		"1403/12/12 14:30",
+		"1403-12-12T14:30:00",
+		"۱۴۰۳/۱۲/۱۲ ۱۴:۳۰",
+	} {
+		parsed, err := gopayamgostar.ParseDateTime(value)
+		require.NoError(t, err, value)
+		require.True(t, want.Equal(parsed.Time), "%s: %s", value, parsed)
+	}
+
+	date, err := gopayamgostar.ParseDateTime("1403/12/12")
+	require.NoError(t, err)
+	require.Equal(t, gopayamgostar.NewJalaliDate(1403, 12, 12), date.Jalali())
+	require.Equal(t, "2025-03-02T00:00:00", date.String())
+
+	_, err = gopayamgostar.ParseDateTime("1402/12/30")
+	require.Error(t, err)
+	_, err = gopayamgostar.ParseDateTime("yesterday")
+	require.Error(t, err)
+}
+
+func TestDateTimeJSON(t *testing.T) {
+	t.Parallel()
+
+	var person gopayamgostar.PersonInfo
+	require.NoError(t, json.Unmarshal([]byte(`{"birthDate":"1370/05/20","customerDate":"2024-01-15T09:00:00"}`), &person))
+	require.Equal(t, gopayamgostar.NewJalaliDate(1370, 5, 20), person.BirthDate.Jalali())
+	require.True(t, person.BirthDate.Before(person.CustomerDate.Time))
+
+	data, err := json.Marshal(person.CustomerDate)
+	require.NoError(t, err)
+	require.JSONEq(t, `"2024-01-15T09:00:00"`, string(data))
+
+	require.NoError(t, json.Unmarshal([]byte(`{"birthDate":null,"customerDate":""}`), &person))
+	require.True(t, person.BirthDate.IsZero())
+	require.True(t, person.CustomerDate.IsZero())
+	require.True(t, person.CustomerDate.Jalali().IsZero())
+
+	data, err = json.Marshal(gopayamgostar.DateTime{})
+	require.NoError(t, err)
+	require.Equal(t, "null", string(data))
+}
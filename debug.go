@@ -0,0 +1,133 @@
+package gopayamgostar
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// DebugEntry summarizes one request/response exchange with the CRM
+type DebugEntry struct {
+	Time         time.Time
+	Method       string
+	URL          string
+	Attempt      int
+	StatusCode   int
+	Duration     time.Duration
+	Error        string
+	RequestBody  string
+	ResponseBody string
+}
+
+// debugHistory is a fixed size ring buffer of the most recent exchanges
+type debugHistory struct {
+	mu        sync.Mutex
+	entries   []DebugEntry
+	next      int
+	full      bool
+	bodyLimit int
+}
+
+// WithDebugHistory keeps summaries of the last size requests in memory so they
+// can be dumped with DebugHistory when an error surfaces, without verbose
+// logging having been enabled. Request and response bodies are truncated to
+// bodyLimit bytes; a bodyLimit of 0 leaves bodies out. Passwords and tokens
+// are redacted from the bodies as in WithDumpWriter.
+func WithDebugHistory(size, bodyLimit int) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		if size <= 0 {
+			return
+		}
+		h := &debugHistory{
+			entries:   make([]DebugEntry, size),
+			bodyLimit: bodyLimit,
+		}
+		g.debugHistory = h
+
+		g.restyClient.OnAfterResponse(func(c *resty.Client, resp *resty.Response) error {
+			h.add(DebugEntry{
+				Time:         resp.Request.Time,
+				Method:       resp.Request.Method,
+				URL:          resp.Request.URL,
+				Attempt:      resp.Request.Attempt,
+				StatusCode:   resp.StatusCode(),
+				Duration:     resp.Time(),
+				RequestBody:  h.truncate(redactBody(requestBodyString(resp.Request.Body))),
+				ResponseBody: h.truncate(redactBody(string(resp.Body()))),
+			})
+			return nil
+		})
+		g.restyClient.OnError(func(req *resty.Request, err error) {
+			if respErr, ok := err.(*resty.ResponseError); ok && respErr.Response.RawResponse != nil {
+				// the exchange was already recorded when the response arrived
+				return
+			}
+			h.add(DebugEntry{
+				Time:        req.Time,
+				Method:      req.Method,
+				URL:         req.URL,
+				Attempt:     req.Attempt,
+				Error:       err.Error(),
+				RequestBody: h.truncate(redactBody(requestBodyString(req.Body))),
+			})
+		})
+	}
+}
+
+// DebugHistory returns the recorded exchanges, oldest first. It is empty unless
+// the client was created with WithDebugHistory.
+func (g *GoPayamgostar) DebugHistory() []DebugEntry {
+	if g.debugHistory == nil {
+		return nil
+	}
+	return g.debugHistory.snapshot()
+}
+
+func (h *debugHistory) add(entry DebugEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+func (h *debugHistory) snapshot() []DebugEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.full {
+		return append([]DebugEntry(nil), h.entries[:h.next]...)
+	}
+	return append(append([]DebugEntry(nil), h.entries[h.next:]...), h.entries[:h.next]...)
+}
+
+func (h *debugHistory) truncate(s string) string {
+	if len(s) > h.bodyLimit {
+		return s[:h.bodyLimit]
+	}
+	return s
+}
+
+func requestBodyString(body interface{}) string {
+	switch b := body.(type) {
+	case nil:
+		return ""
+	case string:
+		return b
+	case []byte:
+		return string(b)
+	case *jsonStream:
+		return "(streamed)"
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+}
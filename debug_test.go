@@ -0,0 +1,69 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugHistory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/v2/crmobject/form/delete" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"form was not found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"crmId":"d81d07dd-cdc2-479a-99d5-0270a1f8f07d"}`))
+	}))
+
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithDebugHistory(2, 10))
+	require.Empty(t, client.DebugHistory())
+
+	_, err := client.CreateForm(context.Background(), "token", gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "SettlementRequest"})
+	require.NoError(t, err)
+	_, err = client.UpdateForm(context.Background(), "token", gopayamgostar.UpdateFormRequest{})
+	require.NoError(t, err)
+	err = client.DeleteForm(context.Background(), "token", "missing")
+	require.Error(t, err)
+
+	history := client.DebugHistory()
+	require.Len(t, history, 2)
+	require.Equal(t, server.URL+"/api/v2/crmobject/form/update", history[0].URL)
+	require.Equal(t, http.StatusNotFound, history[1].StatusCode)
+	require.Equal(t, `{"message"`, history[1].ResponseBody)
+	require.Len(t, history[1].RequestBody, 10)
+
+	server.Close()
+	err = client.DeleteForm(context.Background(), "token", "missing")
+	require.Error(t, err)
+
+	history = client.DebugHistory()
+	require.Len(t, history, 2)
+	require.NotEmpty(t, history[1].Error)
+	require.Zero(t, history[1].StatusCode)
+}
+
+func TestDebugHistoryRedactsCredentials(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+
+	client := server.Client(gopayamgostar.WithDebugHistory(4, 4096))
+	token, err := client.AdminAuthenticate(context.Background(), "admin", "secret")
+	require.NoError(t, err)
+
+	history := client.DebugHistory()
+	require.Len(t, history, 1)
+	require.Contains(t, history[0].RequestBody, `"admin"`)
+	for _, entry := range history {
+		require.NotContains(t, entry.RequestBody, "secret")
+		require.NotContains(t, entry.ResponseBody, token.AccessToken)
+		require.NotContains(t, entry.ResponseBody, token.RefreshToken)
+	}
+}
@@ -0,0 +1,85 @@
+package gopayamgostar
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
+)
+
+// lockedRand is a math/rand source that is safe for concurrent use
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{r: rand.New(rand.NewSource(seed))}
+}
+
+func (l *lockedRand) Int63n(n int64) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Int63n(n)
+}
+
+func (l *lockedRand) Read(p []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.r.Read(p)
+}
+
+// seededIDGenerator generates version 4 UUIDs from a seeded source
+type seededIDGenerator struct {
+	rand *lockedRand
+}
+
+// NewID implements IDGenerator
+func (s seededIDGenerator) NewID() string {
+	var id uuid.UUID
+	s.rand.Read(id[:])
+	id[6] = (id[6] & 0x0f) | 0x40
+	id[8] = (id[8] & 0x3f) | 0x80
+	return id.String()
+}
+
+// WithDeterministicMode makes every randomized behavior of the client
+// reproducible from seed: generated IDs (device IDs, correlation IDs,
+// idempotency keys) and the jitter of retry backoff. It is meant for debugging
+// flaky integrations, not for production use.
+func WithDeterministicMode(seed int64) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		g.random = newLockedRand(seed)
+		g.idGenerator = seededIDGenerator{rand: g.random}
+		g.backoff = g.jitterBackoff
+	}
+}
+
+// jitterBackoff is a resty.RetryAfterFunc computing an exponential backoff with
+// full jitter from the client's random source.
+func (g *GoPayamgostar) jitterBackoff(c *resty.Client, resp *resty.Response) (time.Duration, error) {
+	min, max := c.RetryWaitTime, c.RetryMaxWaitTime
+	if min <= 0 {
+		min = time.Millisecond
+	}
+	if max < min {
+		max = min
+	}
+
+	attempt := 1
+	if resp != nil && resp.Request != nil && resp.Request.Attempt > 0 {
+		attempt = resp.Request.Attempt
+	}
+
+	ceiling := min
+	for i := 1; i < attempt && ceiling < max; i++ {
+		ceiling *= 2
+	}
+	if ceiling > max {
+		ceiling = max
+	}
+
+	return min + time.Duration(g.random.Int63n(int64(ceiling-min)+1)), nil
+}
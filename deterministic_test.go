@@ -0,0 +1,42 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeterministicMode(t *testing.T) {
+	var ids []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get("X-Correlation-ID"), r.Header.Get("Idempotency-Key"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"crmId":"d81d07dd-cdc2-479a-99d5-0270a1f8f07d"}`))
+	}))
+	defer server.Close()
+
+	run := func(seed int64) []string {
+		ids = nil
+		client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithDeterministicMode(seed))
+		for i := 0; i < 3; i++ {
+			_, err := client.CreateForm(context.Background(), "token", gopayamgostar.CreateFormRequest{})
+			require.NoError(t, err)
+		}
+		return ids
+	}
+
+	first := run(42)
+	require.Equal(t, first, run(42))
+	require.NotEqual(t, first, run(43))
+
+	for _, id := range first {
+		parsed, err := uuid.Parse(id)
+		require.NoError(t, err)
+		require.Equal(t, uuid.Version(4), parsed.Version())
+	}
+}
@@ -0,0 +1,102 @@
+package gopayamgostar
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DryRunHeader is set on the responses made up for the requests a dry run
+// did not send
+const DryRunHeader = "X-Dry-Run"
+
+// ErrDryRun is returned by the calls a dry run did not send, see WithDryRun
+var ErrDryRun = errors.New("dry run: the request was not sent")
+
+var dryRunContextKey = contextKey("dry-run")
+
+// mutatingEndpointPrefixes are the prefixes of the names of the endpoints
+// changing data, which a dry run does not send
+var mutatingEndpointPrefixes = []string{
+	"Create", "Update", "Delete", "Complete", "Reply", "Close", "Send",
+	"Upload", "Add", "Remove", "Replace", "Start",
+}
+
+// WithDryRun makes the client skip the requests changing data, such as
+// CreateForm, CreatePurchaseInvoice and UpdateForm, for verifying migration
+// scripts against a production configuration. Reads and logins are sent.
+// Skipped requests are validated as the client would send them, go through
+// the hooks, WithLogger and WithDumpWriter, which shows their payloads, and
+// fail with ErrDryRun, without publishing events or touching the cache.
+// Requests to endpoints the client does not know, such as those of Do, are
+// skipped unless they are GETs.
+func WithDryRun() func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		g.dryRun = true
+	}
+}
+
+// DryRun returns a context making the calls using it dry runs, see WithDryRun
+func DryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunContextKey, true)
+}
+
+// dryRunning reports whether the calls using ctx are dry runs
+func (g *GoPayamgostar) dryRunning(ctx context.Context) bool {
+	dry, _ := ctx.Value(dryRunContextKey).(bool)
+	return dry || g.dryRun
+}
+
+// skipped reports whether req is a change a dry run does not send
+func (g *GoPayamgostar) skipped(req *http.Request) bool {
+	if !g.dryRunning(req.Context()) {
+		return false
+	}
+	name := g.endpointName(req.URL.Path)
+	if name == "other" {
+		return req.Method != http.MethodGet
+	}
+	for _, prefix := range mutatingEndpointPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// dryRunResponse returns the empty response of a change skipped by a dry run,
+// marked with DryRunHeader, after checking that its body is JSON
+func (g *GoPayamgostar) dryRunResponse(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(req.Header.Get("Content-Type"), "json") && len(data) > 0 && !json.Valid(data) {
+			return nil, errors.New("dry run: the request body is not valid JSON")
+		}
+	}
+
+	body := `{}`
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Content-Type":   {"application/json"},
+			"Content-Length": {strconv.Itoa(len(body))},
+			DryRunHeader:     {"true"},
+		},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,85 @@
+package gopayamgostar_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
+)
+
+func TestWithDryRun(t *testing.T) {
+	t.Parallel()
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	personID := server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Ali", LastName: "Rezaei"})
+
+	var dump bytes.Buffer
+	ctx := context.Background()
+	client := server.Client(gopayamgostar.WithDryRun(), gopayamgostar.WithDumpWriter(&dump))
+	var events []gopayamgostar.Event
+	client.Subscribe(func(ctx context.Context, event gopayamgostar.Event) {
+		events = append(events, event)
+	})
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err, "logins are sent")
+
+	subject := "late"
+	id, err := client.CreateForm(ctx, token.AccessToken, gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Complaint", Subject: &subject, IdentityID: personID})
+	require.ErrorIs(t, err, gopayamgostar.ErrDryRun)
+	require.Empty(t, id)
+	require.Contains(t, dump.String(), `"Subject":"late"`)
+	require.Contains(t, dump.String(), "X-Dry-Run: true")
+
+	_, err = client.CreatePurchaseInvoice(ctx, token.AccessToken, gopayamgostar.CreatePurchaseRequest{CRMObjectTypeCode: "Invoice", IdentityID: personID})
+	require.ErrorIs(t, err, gopayamgostar.ErrDryRun)
+	_, err = client.DeletePurchase(ctx, token.AccessToken, "purchase-1", enums.DeleteOnly)
+	require.ErrorIs(t, err, gopayamgostar.ErrDryRun)
+	require.Empty(t, events, "no events are published for changes that were not sent")
+
+	person, err := client.GetPersonInfoById(ctx, token.AccessToken, personID)
+	require.NoError(t, err, "reads are sent")
+	require.Equal(t, "Rezaei", person.LastName)
+	forms, err := client.FindForm(ctx, token.AccessToken, "Complaint", nil)
+	require.NoError(t, err)
+	require.Empty(t, forms.Data)
+
+	// unknown endpoints are only sent for GETs
+	require.ErrorIs(t, client.Do(ctx, token.AccessToken, http.MethodPost, "api/v2/unknown", map[string]string{}, nil), gopayamgostar.ErrDryRun)
+	err = client.Do(ctx, token.AccessToken, http.MethodGet, "api/v2/unknown", nil, nil)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, gopayamgostar.ErrDryRun)
+
+	// per call
+	client = server.Client(gopayamgostar.WithCache(gopayamgostar.NewMemoryCache(), time.Minute))
+	_, err = client.CreateForm(gopayamgostar.DryRun(ctx), token.AccessToken, gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Complaint"})
+	require.ErrorIs(t, err, gopayamgostar.ErrDryRun)
+	id, err = client.CreateForm(ctx, token.AccessToken, gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Complaint", Subject: &subject})
+	require.NoError(t, err)
+	_, ok := server.Form(id)
+	require.True(t, ok)
+
+	_, err = client.GetFormInfoById(ctx, token.AccessToken, id)
+	require.NoError(t, err)
+	var gets int
+	client.RegisterRequestHook(func(info gopayamgostar.HookInfo, req *http.Request) error {
+		if info.Endpoint == "GetForm" {
+			gets++
+		}
+		return nil
+	})
+	_, err = client.UpdateForm(gopayamgostar.DryRun(ctx), token.AccessToken, gopayamgostar.UpdateFormRequest{CrmId: id, Subject: "changed"})
+	require.ErrorIs(t, err, gopayamgostar.ErrDryRun)
+	form, err := client.GetFormInfoById(ctx, token.AccessToken, id)
+	require.NoError(t, err)
+	require.Equal(t, "late", form.Subject)
+	require.Zero(t, gets, "dry runs leave the cache alone")
+}
@@ -0,0 +1,152 @@
+package gopayamgostar
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// dumpRedactedHeaders are the headers whose values are redacted in dumps on
+// top of the static headers of the client
+var dumpRedactedHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"Set-Cookie":    true,
+	APIKeyHeader:    true,
+}
+
+// dumpRedactedFields are the JSON fields whose values are redacted in dumped
+// bodies, lower cased
+var dumpRedactedFields = map[string]bool{
+	"password":      true,
+	"accesstoken":   true,
+	"access_token":  true,
+	"refreshtoken":  true,
+	"refresh_token": true,
+}
+
+// WithDumpWriter writes every attempt of the client to w as its request and
+// response in HTTP form, for capturing the exact payloads the CRM rejects
+// without enabling WithDebug. Credentials are redacted: the Authorization,
+// cookie and static headers, and the passwords and tokens of the bodies.
+// Dumps are written whole, one at a time, so w may be shared by clients.
+func WithDumpWriter(w io.Writer) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		d := &dumper{w: w, client: g}
+		g.restyClient.OnAfterResponse(func(c *resty.Client, resp *resty.Response) error {
+			d.dump(resp.Request, resp, nil)
+			return nil
+		})
+		g.restyClient.OnError(func(req *resty.Request, err error) {
+			if respErr, ok := err.(*resty.ResponseError); ok && respErr.Response.RawResponse != nil {
+				// the exchange was dumped when the response arrived
+				return
+			}
+			d.dump(req, nil, err)
+		})
+	}
+}
+
+// dumper writes the dumps of a client
+type dumper struct {
+	mu     sync.Mutex
+	w      io.Writer
+	client *GoPayamgostar
+}
+
+func (d *dumper) dump(req *resty.Request, resp *resty.Response, err error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %s (attempt %d, %s)\n", req.Method, req.URL, req.Attempt, req.Time.Format(time.RFC3339Nano))
+	header := req.Header
+	if req.RawRequest != nil {
+		header = req.RawRequest.Header
+	}
+	d.writeHeader(&buf, header)
+	writeDumpBody(&buf, requestBodyString(req.Body))
+
+	switch {
+	case err != nil:
+		fmt.Fprintf(&buf, "error: %v\n", err)
+	case resp != nil:
+		fmt.Fprintf(&buf, "%s (%s)\n", resp.Status(), resp.Time())
+		d.writeHeader(&buf, resp.Header())
+		writeDumpBody(&buf, string(resp.Body()))
+	}
+	buf.WriteString("\n")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, _ = d.w.Write(buf.Bytes())
+}
+
+func (d *dumper) writeHeader(buf *bytes.Buffer, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			if dumpRedactedHeaders[name] || d.client.redactedHeaders[name] {
+				value = redactedHeaderValue
+			}
+			fmt.Fprintf(buf, "%s: %s\n", name, value)
+		}
+	}
+}
+
+// writeDumpBody writes body after a blank line. JSON bodies holding
+// dumpRedactedFields are written with their values redacted, the others as
+// they were sent.
+func writeDumpBody(buf *bytes.Buffer, body string) {
+	buf.WriteString("\n")
+	if strings.TrimSpace(body) == "" {
+		return
+	}
+	buf.WriteString(redactBody(body))
+	buf.WriteString("\n")
+}
+
+// redactBody returns body with the values of dumpRedactedFields redacted if
+// it is JSON holding any, else body as it is
+func redactBody(body string) string {
+	var v interface{}
+	if json.Unmarshal([]byte(body), &v) == nil && redactFields(v) {
+		if data, err := json.Marshal(v); err == nil {
+			return string(data)
+		}
+	}
+	return body
+}
+
+// redactFields replaces the values of dumpRedactedFields in v and reports
+// whether there were any
+func redactFields(v interface{}) bool {
+	redacted := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if dumpRedactedFields[strings.ToLower(key)] {
+				v[key] = redactedHeaderValue
+				redacted = true
+			} else if redactFields(value) {
+				redacted = true
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			if redactFields(value) {
+				redacted = true
+			}
+		}
+	}
+	return redacted
+}
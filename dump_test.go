@@ -0,0 +1,55 @@
+package gopayamgostar_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+)
+
+func TestWithDumpWriter(t *testing.T) {
+	t.Parallel()
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+
+	var dump bytes.Buffer
+	ctx := context.Background()
+	client := server.Client(
+		gopayamgostar.WithStaticHeaders(map[string]string{"X-License": "license-key"}),
+		gopayamgostar.WithDumpWriter(&dump),
+	)
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	subject := "late"
+	_, err = client.CreateForm(ctx, token.AccessToken, gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Complaint", Subject: &subject})
+	require.NoError(t, err)
+	_, err = client.GetFormInfoById(ctx, token.AccessToken, "missing")
+	require.Error(t, err)
+
+	out := dump.String()
+	require.Contains(t, out, "POST "+server.URL+"/api/v2/auth/login (attempt 1, ")
+	require.Contains(t, out, `"password":"[REDACTED]"`)
+	require.Contains(t, out, `"accessToken":"[REDACTED]"`)
+	require.Contains(t, out, "Authorization: [REDACTED]")
+	require.Contains(t, out, "X-License: [REDACTED]")
+	require.NotContains(t, out, "secret")
+	require.NotContains(t, out, "license-key")
+	require.NotContains(t, out, token.AccessToken)
+
+	require.Contains(t, out, `"Subject":"late"`, "bodies without credentials are dumped as sent")
+	require.Contains(t, out, "404 Not Found (")
+	require.Contains(t, out, `{"errorMessage":"form not found"}`)
+
+	// transport errors
+	dump.Reset()
+	server.Close()
+	_, err = client.GetFormInfoById(ctx, token.AccessToken, "form-1")
+	require.Error(t, err)
+	require.Contains(t, dump.String(), "error: ")
+}
@@ -0,0 +1,101 @@
+package gopayamgostar
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+)
+
+// defaultEndpointPrefix starts the paths of the default endpoints
+const defaultEndpointPrefix = "api/v2"
+
+// WithEndpoint maps the endpoint name to path, relative to the base path of
+// the client. name is the EndpointConfig field with or without its Endpoint
+// suffix, e.g. "GetForm". It panics for unknown names.
+func WithEndpoint(name, path string) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		field := reflect.ValueOf(&g.Config).Elem().FieldByName(strings.TrimSuffix(name, "Endpoint") + "Endpoint")
+		if !field.IsValid() {
+			panic(fmt.Sprintf("gopayamgostar: unknown endpoint %q", name))
+		}
+		field.SetString(strings.Trim(path, urlSeparator))
+	}
+}
+
+// WithEndpointConfig lets configure change the endpoint paths, e.g. to route
+// some of them through an API gateway
+func WithEndpointConfig(configure func(config *EndpointConfig)) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		configure(&g.Config)
+	}
+}
+
+// WithEndpointPrefix replaces the api/v2 prefix of the endpoints that still
+// have it by prefix, for installations serving the API under another path
+func WithEndpointPrefix(prefix string) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		prefix = strings.Trim(prefix, urlSeparator)
+		config := reflect.ValueOf(&g.Config).Elem()
+		for i := 0; i < config.NumField(); i++ {
+			path := config.Field(i).String()
+			if rest, ok := strings.CutPrefix(path, defaultEndpointPrefix+urlSeparator); ok {
+				config.Field(i).SetString(strings.TrimPrefix(makeURL(prefix, rest), urlSeparator))
+			}
+		}
+	}
+}
+
+// maxRedirects is the number of redirects followed, as by http.Client
+const maxRedirects = 10
+
+// WithSuccessStatus makes the responses of the endpoint name succeed with the
+// given status codes only, for endpoints answering 201, 202 or 204, or a
+// redirect behind some gateways. name is the EndpointConfig field with or
+// without its Endpoint suffix, e.g. "CreateForm"; it panics for unknown
+// names. Redirects with a listed status are not followed. Endpoints without
+// codes succeed with the statuses below 400.
+func WithSuccessStatus(name string, codes ...int) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		name := strings.TrimSuffix(name, "Endpoint")
+		if _, ok := reflect.TypeOf(g.Config).FieldByName(name + "Endpoint"); !ok {
+			panic(fmt.Sprintf("gopayamgostar: unknown endpoint %q", name))
+		}
+		if g.successStatus != nil {
+			g.successStatus[name] = codes
+			return
+		}
+		g.successStatus = map[string][]int{name: codes}
+
+		httpClient := g.restyClient.GetClient()
+		next := httpClient.CheckRedirect
+		httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if slices.Contains(g.successStatus[g.endpointName(via[0].URL.Path)], req.Response.StatusCode) {
+				return http.ErrUseLastResponse
+			}
+			if next != nil {
+				return next(req, via)
+			}
+			if len(via) >= maxRedirects {
+				return errors.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		}
+	}
+}
+
+// succeeded reports whether resp has a success status of its endpoint
+func (g *GoPayamgostar) succeeded(resp *resty.Response) bool {
+	if g.successStatus == nil || resp.Request == nil || resp.Request.RawRequest == nil {
+		return !resp.IsError()
+	}
+	codes, ok := g.successStatus[g.endpointName(resp.Request.RawRequest.URL.Path)]
+	if !ok {
+		return !resp.IsError()
+	}
+	return slices.Contains(codes, resp.StatusCode())
+}
@@ -0,0 +1,87 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointOptions(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL+"/crm",
+		gopayamgostar.WithEndpointPrefix("/gateway/v2/"),
+		gopayamgostar.WithEndpoint("DeleteForm", "/legacy/form/remove"),
+		gopayamgostar.WithEndpoint("DeleteReceiptEndpoint", "legacy/receipt/remove"),
+		gopayamgostar.WithEndpointConfig(func(config *gopayamgostar.EndpointConfig) {
+			config.DeleteAttachmentEndpoint = "files/delete"
+		}),
+	)
+	require.Equal(t, "gateway/v2/crmobject/form/get", client.Config.GetFormEndpoint)
+
+	ctx := context.Background()
+	require.NoError(t, client.DeleteForm(ctx, "token", "form"))
+	require.NoError(t, client.DeleteReceipt(ctx, "token", "receipt"))
+	require.NoError(t, client.DeleteAttachment(ctx, "token", "attachment"))
+	require.NoError(t, client.StartProcess(ctx, "token", "form", "process"))
+	require.Equal(t, []string{
+		"/crm/legacy/form/remove",
+		"/crm/legacy/receipt/remove",
+		"/crm/files/delete",
+		"/crm/gateway/v2/crmobject/process/start",
+	}, paths)
+
+	require.PanicsWithValue(t, `gopayamgostar: unknown endpoint "Missing"`, func() {
+		gopayamgostar.NewClient(server.URL, gopayamgostar.WithEndpoint("Missing", "x"))
+	})
+}
+
+func TestWithSuccessStatus(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/crmobject/form/delete", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/api/v2/crmobject/receipt/delete", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	})
+	mux.HandleFunc("/api/v2/crmobject/attachment/delete", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusFound)
+	})
+	mux.HandleFunc("/api/v2/crmobject/process/start", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/elsewhere", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL,
+		gopayamgostar.WithSuccessStatus("DeleteForm", http.StatusNoContent),
+		gopayamgostar.WithSuccessStatus("DeleteReceiptEndpoint", http.StatusOK, http.StatusFound),
+		gopayamgostar.WithSuccessStatus("StartProcess", http.StatusAccepted),
+	)
+
+	ctx := context.Background()
+	require.NoError(t, client.DeleteForm(ctx, "token", "form"))
+	require.NoError(t, client.DeleteReceipt(ctx, "token", "receipt"), "the redirect is not followed")
+	require.NoError(t, client.DeleteAttachment(ctx, "token", "attachment"), "other endpoints follow redirects")
+
+	err := client.StartProcess(ctx, "token", "form", "process")
+	var apiErr *gopayamgostar.APIError
+	require.ErrorAs(t, err, &apiErr, "200 is not expected")
+	require.Equal(t, http.StatusOK, apiErr.Code)
+
+	require.PanicsWithValue(t, `gopayamgostar: unknown endpoint "Missing"`, func() {
+		gopayamgostar.NewClient(server.URL, gopayamgostar.WithSuccessStatus("Missing", http.StatusOK))
+	})
+}
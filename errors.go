@@ -1,9 +1,54 @@
 package gopayamgostar
 
 import (
+	"encoding/json"
+	"net/http"
+	"sort"
 	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrSchemaVersionMismatch is returned when a pinned object type no longer has
+// the CRMObjectTypeID the client was pinned to
+var ErrSchemaVersionMismatch = errors.New("object type schema does not match the pinned version")
+
+var (
+	// ErrNoDefaultPhone is returned when phone contacts have no default contact
+	ErrNoDefaultPhone = errors.New("phone contacts have no default")
+	// ErrMultipleDefaultPhones is returned when more than one phone contact is default
+	ErrMultipleDefaultPhones = errors.New("phone contacts have more than one default")
 )
 
+// ErrInvalidQuery is returned without contacting the server when a find
+// request fails its Validate
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Sentinel errors the *APIError of a response matches with errors.Is by its
+// status code, e.g. errors.Is(err, ErrNotFound) for a 404
+var (
+	// ErrNotFound is matched by 404 Not Found responses
+	ErrNotFound = errors.New("not found")
+	// ErrUnauthorized is matched by 401 Unauthorized responses
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrConflict is matched by 409 Conflict responses
+	ErrConflict = errors.New("conflict")
+	// ErrRateLimited is matched by 429 Too Many Requests responses
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// statusErrors maps status codes to their sentinel errors
+var statusErrors = map[int]error{
+	http.StatusNotFound:        ErrNotFound,
+	http.StatusUnauthorized:    ErrUnauthorized,
+	http.StatusConflict:        ErrConflict,
+	http.StatusTooManyRequests: ErrRateLimited,
+}
+
+// ErrCircuitOpen is returned without contacting the server while the circuit
+// breaker of the client is open, see WithCircuitBreaker
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
 // HTTPErrorResponse is a model of an error response
 type HTTPErrorResponse struct {
 	Error       string `json:"error,omitempty"`
@@ -36,3 +81,146 @@ func (e HTTPErrorResponse) String() string {
 func (e HTTPErrorResponse) NotEmpty() bool {
 	return len(e.Error) > 0 || len(e.Message) > 0 || len(e.Description) > 0
 }
+
+// ErrServedStale is returned together with a result read from the mirror
+// while the CRM was unreachable, see WithMirror. The result is usable; its
+// Staleness field holds the age of the data.
+var ErrServedStale = errors.New("served stale data from the mirror")
+
+// requestIDHeader is the header the server identifies requests with
+const requestIDHeader = "X-Request-Id"
+
+// The errors of some status codes wrap the *APIError of the response in a
+// type of their own. A type assertion to *APIError does not match them; use
+// errors.As, which finds the *APIError of every failed response.
+
+// NotFoundError is returned for 404 Not Found responses
+type NotFoundError struct {
+	*APIError
+}
+
+// Unwrap returns the *APIError of the response
+func (e *NotFoundError) Unwrap() error {
+	return e.APIError
+}
+
+// AuthError is returned for 401 Unauthorized and 403 Forbidden responses
+type AuthError struct {
+	*APIError
+}
+
+// Unwrap returns the *APIError of the response
+func (e *AuthError) Unwrap() error {
+	return e.APIError
+}
+
+// ServerError is returned for 5xx responses
+type ServerError struct {
+	*APIError
+}
+
+// Unwrap returns the *APIError of the response
+func (e *ServerError) Unwrap() error {
+	return e.APIError
+}
+
+// statusError returns apiErr as the error type of its status code
+func statusError(apiErr *APIError) error {
+	switch code := apiErr.Code; {
+	case code == http.StatusBadRequest:
+		if fields := parseFieldErrors(apiErr.Body); fields != nil {
+			return &ValidationError{Fields: fields, apiErr: apiErr}
+		}
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return &AuthError{apiErr}
+	case code == http.StatusNotFound:
+		return &NotFoundError{apiErr}
+	case code >= http.StatusInternalServerError:
+		return &ServerError{apiErr}
+	}
+	return apiErr
+}
+
+// ValidationError is returned when the server rejects a request with 400 Bad
+// Request and tells which fields or extended properties were invalid. It
+// unwraps to the *APIError of the response.
+type ValidationError struct {
+	// Fields maps the names of the rejected fields and extended properties to
+	// their messages
+	Fields map[string][]string
+
+	apiErr *APIError
+}
+
+// Error lists the rejected fields with their messages, sorted by field
+func (e *ValidationError) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var res strings.Builder
+	res.WriteString(e.apiErr.Message)
+	for i, name := range names {
+		if i == 0 {
+			res.WriteString(": ")
+		} else {
+			res.WriteString("; ")
+		}
+		res.WriteString(name + ": " + strings.Join(e.Fields[name], ", "))
+	}
+	return res.String()
+}
+
+// Unwrap returns the *APIError of the response
+func (e *ValidationError) Unwrap() error {
+	return e.apiErr
+}
+
+// validationErrorBody is the body of a 400 response with field errors. The
+// errors come either as a map of field to messages or as a list.
+type validationErrorBody struct {
+	Errors json.RawMessage `json:"errors"`
+}
+
+type fieldError struct {
+	Field   string `json:"field"`
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+// parseFieldErrors returns the field errors of body, nil when it has none
+func parseFieldErrors(body []byte) map[string][]string {
+	var parsed validationErrorBody
+	if json.Unmarshal(body, &parsed) != nil || len(parsed.Errors) == 0 {
+		return nil
+	}
+
+	var fields map[string][]string
+	if json.Unmarshal(parsed.Errors, &fields) == nil {
+		if len(fields) == 0 {
+			return nil
+		}
+		return fields
+	}
+
+	var list []fieldError
+	if json.Unmarshal(parsed.Errors, &list) != nil {
+		return nil
+	}
+	fields = map[string][]string{}
+	for _, item := range list {
+		name := item.Field
+		if name == "" {
+			name = item.Key
+		}
+		if name != "" {
+			fields[name] = append(fields[name], item.Message)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
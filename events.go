@@ -0,0 +1,120 @@
+package gopayamgostar
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Event is published by the client after an operation changed an object, see
+// Subscribe. It is one of PersonCreated, PersonUpdated, PersonDeleted,
+// FormCreated, FormUpdated, FormDeleted, PurchaseCreated or PurchaseDeleted.
+type Event interface {
+	// ObjectID returns the crm id of the changed object
+	ObjectID() string
+}
+
+// PersonCreated is published by CreatePerson
+type PersonCreated struct {
+	CrmId string
+}
+
+// PersonUpdated is published by UpdatePerson and SetDefaultPhone
+type PersonUpdated struct {
+	CrmId string
+}
+
+// PersonDeleted is published by DeletePerson
+type PersonDeleted struct {
+	CrmId string
+}
+
+// FormCreated is published by CreateForm
+type FormCreated struct {
+	CrmId string
+}
+
+// FormUpdated is published by UpdateForm and StartProcess
+type FormUpdated struct {
+	CrmId string
+}
+
+// FormDeleted is published by DeleteForm
+type FormDeleted struct {
+	CrmId string
+}
+
+// PurchaseCreated is published by CreatePurchaseInvoice and CreatePurchase
+type PurchaseCreated struct {
+	CrmId string
+}
+
+// PurchaseDeleted is published by DeletePurchase and DeletePurchaseBulk
+type PurchaseDeleted struct {
+	CrmId string
+}
+
+func (e PersonCreated) ObjectID() string   { return e.CrmId }
+func (e PersonUpdated) ObjectID() string   { return e.CrmId }
+func (e PersonDeleted) ObjectID() string   { return e.CrmId }
+func (e FormCreated) ObjectID() string     { return e.CrmId }
+func (e FormUpdated) ObjectID() string     { return e.CrmId }
+func (e FormDeleted) ObjectID() string     { return e.CrmId }
+func (e PurchaseCreated) ObjectID() string { return e.CrmId }
+func (e PurchaseDeleted) ObjectID() string { return e.CrmId }
+
+// EventHandler receives the events of a client with the context of the
+// operation that published them
+type EventHandler func(ctx context.Context, event Event)
+
+// eventBus holds the subscribers of a client
+type eventBus struct {
+	mu          sync.RWMutex
+	next        uint64
+	subscribers []subscriber
+}
+
+type subscriber struct {
+	id      uint64
+	handler EventHandler
+}
+
+// Subscribe calls handler after every successful operation that changed an
+// object, so that caches kept outside of the client can drop it precisely:
+//
+//	unsubscribe := client.Subscribe(func(ctx context.Context, event gopayamgostar.Event) {
+//		switch event.(type) {
+//		case gopayamgostar.PersonUpdated, gopayamgostar.PersonDeleted:
+//			redis.Del(ctx, "person:"+event.ObjectID())
+//		}
+//	})
+//
+// Handlers run synchronously before the operation returns, in the order they
+// subscribed, and must not block. It returns a function unsubscribing handler.
+func (g *GoPayamgostar) Subscribe(handler EventHandler) func() {
+	g.events.mu.Lock()
+	defer g.events.mu.Unlock()
+	g.events.next++
+	id := g.events.next
+	// copied on write, as publish calls the handlers of a snapshot
+	g.events.subscribers = append(slices.Clip(g.events.subscribers), subscriber{id: id, handler: handler})
+
+	return func() {
+		g.events.mu.Lock()
+		defer g.events.mu.Unlock()
+		g.events.subscribers = slices.DeleteFunc(slices.Clone(g.events.subscribers), func(s subscriber) bool {
+			return s.id == id
+		})
+	}
+}
+
+// publish passes event to the subscribers
+func (g *GoPayamgostar) publish(ctx context.Context, event Event) {
+	g.events.mu.RLock()
+	subscribers := g.events.subscribers
+	g.events.mu.RUnlock()
+
+	for _, s := range subscribers {
+		s.handler(ctx, event)
+	}
+}
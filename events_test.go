@@ -0,0 +1,63 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
+)
+
+func TestSubscribe(t *testing.T) {
+	t.Parallel()
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	accessToken := token.AccessToken
+
+	var events, second []gopayamgostar.Event
+	client.Subscribe(func(ctx context.Context, event gopayamgostar.Event) {
+		events = append(events, event)
+	})
+	unsubscribe := client.Subscribe(func(ctx context.Context, event gopayamgostar.Event) {
+		second = append(second, event)
+	})
+
+	personID, err := client.CreatePerson(ctx, accessToken, gopayamgostar.CreatePersonRequest{CRMObjectTypeCode: "Person", FirstName: "Ali"})
+	require.NoError(t, err)
+	formID, err := client.CreateForm(ctx, accessToken, gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Order", IdentityID: personID})
+	require.NoError(t, err)
+	_, err = client.UpdateForm(ctx, accessToken, gopayamgostar.UpdateFormRequest{CrmId: formID, Subject: "updated"})
+	require.NoError(t, err)
+	unsubscribe()
+	unsubscribe()
+
+	require.NoError(t, client.DeleteForm(ctx, accessToken, formID))
+	require.Error(t, client.DeleteForm(ctx, accessToken, formID), "failed operations publish nothing")
+	purchaseID, err := client.CreatePurchaseInvoice(ctx, accessToken, gopayamgostar.CreatePurchaseRequest{CRMObjectTypeCode: "Invoice", IdentityID: personID})
+	require.NoError(t, err)
+	_, err = client.DeletePurchase(ctx, accessToken, purchaseID, enums.DeleteOnly)
+	require.NoError(t, err)
+	require.NoError(t, client.Session(accessToken).DeletePerson(ctx, personID))
+
+	require.Equal(t, []gopayamgostar.Event{
+		gopayamgostar.PersonCreated{CrmId: personID},
+		gopayamgostar.FormCreated{CrmId: formID},
+		gopayamgostar.FormUpdated{CrmId: formID},
+		gopayamgostar.FormDeleted{CrmId: formID},
+		gopayamgostar.PurchaseCreated{CrmId: purchaseID},
+		gopayamgostar.PurchaseDeleted{CrmId: purchaseID},
+		gopayamgostar.PersonDeleted{CrmId: personID},
+	}, events)
+	require.Equal(t, events[:3], second)
+	require.Equal(t, personID, events[0].ObjectID())
+}
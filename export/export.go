@@ -0,0 +1,220 @@
+// Package export writes CRM objects returned by finds to CSV, for reporting
+// jobs dumping CRM data into spreadsheets. An object becomes a row holding the
+// selected object fields followed by its extended properties, one column per
+// user key:
+//
+//	result, err := client.FindFormPage(ctx, accessToken, "Complaint", queries, 1, 500)
+//	if err != nil {
+//		return err
+//	}
+//	return export.Forms(file, result.Data, export.Options{ExcelBOM: true})
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/pkg/errors"
+)
+
+// DefaultFormFields are the form fields written when Options.Fields is empty
+var DefaultFormFields = []string{"crmId", "crmObjectTypeCode", "refId", "subject", "description", "identityId", "stageId", "creatDate", "modifyDate"}
+
+// DefaultPersonFields are the person fields written when Options.Fields is empty
+var DefaultPersonFields = []string{"crmId", "crmObjectTypeCode", "refId", "firstName", "lastName", "nationalCode", "email", "customerNumber", "creatDate", "modifyDate"}
+
+// zeroTime is how the zero dates of objects are marshalled, written as empty
+// cells
+const zeroTime = "0001-01-01T00:00:00"
+
+// utf8BOM is the byte order mark Excel needs to read a CSV file as UTF-8
+const utf8BOM = "\ufeff"
+
+// Options selects the columns of the CSV and its format
+type Options struct {
+	// Fields are the JSON names of the object fields to write, in order,
+	// DefaultFormFields or DefaultPersonFields by default
+	Fields []string
+	// Properties are the user keys of the extended properties to write after
+	// the fields, in order. Nil writes the ones of the objects of the first
+	// write, in the order they appear. Properties named like a field are
+	// left out.
+	Properties []string
+	// Comma is the field delimiter, ',' by default
+	Comma rune
+	// ExcelBOM starts the file with a UTF-8 byte order mark, without which
+	// Excel shows Persian text garbled
+	ExcelBOM bool
+}
+
+// Writer writes the objects of one kind to CSV, the header first. It writes
+// pages of objects as they are fetched, with the columns chosen at the first
+// write.
+type Writer struct {
+	w       io.Writer
+	csv     *csv.Writer
+	options Options
+
+	// fields and properties are the columns, set by the first write
+	fields     []string
+	properties []string
+	started    bool
+}
+
+// NewWriter creates a Writer writing to w. Call Flush once done.
+func NewWriter(w io.Writer, options Options) *Writer {
+	writer := &Writer{w: w, csv: csv.NewWriter(w), options: options}
+	if options.Comma != 0 {
+		writer.csv.Comma = options.Comma
+	}
+	return writer
+}
+
+// WriteForms writes a row per form
+func (w *Writer) WriteForms(forms []gopayamgostar.FormResponse) error {
+	return write(w, forms, DefaultFormFields, func(form gopayamgostar.FormResponse) []gopayamgostar.ExtendedProperty {
+		return form.ExtendedProperties
+	})
+}
+
+// WritePersons writes a row per person
+func (w *Writer) WritePersons(persons []gopayamgostar.PersonRow) error {
+	return write(w, persons, DefaultPersonFields, func(person gopayamgostar.PersonRow) []gopayamgostar.ExtendedProperty {
+		return person.ExtendedProperties
+	})
+}
+
+// Flush writes the buffered rows and returns the first error of the writer
+func (w *Writer) Flush() error {
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+// Forms writes forms to w as CSV
+func Forms(w io.Writer, forms []gopayamgostar.FormResponse, options Options) error {
+	writer := NewWriter(w, options)
+	if err := writer.WriteForms(forms); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// Persons writes persons to w as CSV, such as the Data of a
+// FindPersonResponse. Use PersonInfo.Row for the persons of GetPersonInfoById.
+func Persons(w io.Writer, persons []gopayamgostar.PersonRow, options Options) error {
+	writer := NewWriter(w, options)
+	if err := writer.WritePersons(persons); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func write[T any](w *Writer, objects []T, defaultFields []string, properties func(T) []gopayamgostar.ExtendedProperty) error {
+	if !w.started {
+		keys := w.options.Properties
+		if keys == nil {
+			keys = propertyKeys(objects, properties)
+		}
+		if err := w.start(defaultFields, keys); err != nil {
+			return err
+		}
+	}
+
+	for _, object := range objects {
+		data, err := json.Marshal(object)
+		if err != nil {
+			return errors.Wrap(err, "could not export object")
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var all map[string]interface{}
+		if err := decoder.Decode(&all); err != nil {
+			return errors.Wrap(err, "could not export object")
+		}
+
+		values := map[string]string{}
+		for _, property := range properties(object) {
+			values[property.UserKey] = property.Value
+		}
+
+		row := make([]string, 0, len(w.fields)+len(w.properties))
+		for _, field := range w.fields {
+			row = append(row, cell(all[field]))
+		}
+		for _, key := range w.properties {
+			row = append(row, values[key])
+		}
+		if err := w.csv.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// start chooses the columns and writes the header
+func (w *Writer) start(defaultFields, keys []string) error {
+	w.started = true
+	w.fields = w.options.Fields
+	if len(w.fields) == 0 {
+		w.fields = defaultFields
+	}
+
+	isField := map[string]bool{}
+	for _, field := range w.fields {
+		isField[field] = true
+	}
+	for _, key := range keys {
+		if !isField[key] {
+			w.properties = append(w.properties, key)
+		}
+	}
+
+	if w.options.ExcelBOM {
+		if _, err := io.WriteString(w.w, utf8BOM); err != nil {
+			return err
+		}
+	}
+	return w.csv.Write(append(append([]string{}, w.fields...), w.properties...))
+}
+
+// propertyKeys returns the user keys of the properties of objects, in the
+// order they appear
+func propertyKeys[T any](objects []T, properties func(T) []gopayamgostar.ExtendedProperty) []string {
+	var keys []string
+	seen := map[string]bool{}
+	for _, object := range objects {
+		for _, property := range properties(object) {
+			if !seen[property.UserKey] {
+				seen[property.UserKey] = true
+				keys = append(keys, property.UserKey)
+			}
+		}
+	}
+	return keys
+}
+
+// cell formats a decoded JSON value, writing objects and arrays as JSON
+func cell(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		if value == zeroTime {
+			return ""
+		}
+		return value
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,89 @@
+package export_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/export"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForms(t *testing.T) {
+	created := gopayamgostar.CustomTime{Time: time.Date(2024, 3, 20, 10, 30, 0, 0, time.UTC)}
+	forms := []gopayamgostar.FormResponse{
+		{
+			CRMID:      "form-1",
+			Subject:    "late, delivery",
+			IdentityID: "person-1",
+			CreatDate:  created,
+			ExtendedProperties: []gopayamgostar.ExtendedProperty{
+				{UserKey: "City", Value: "تهران"},
+				{UserKey: "subject", Value: "shadowed"},
+			},
+		},
+		{
+			CRMID:              "form-2",
+			ExtendedProperties: []gopayamgostar.ExtendedProperty{{UserKey: "Amount", Value: "1000"}},
+		},
+	}
+
+	var out bytes.Buffer
+	err := export.Forms(&out, forms, export.Options{Fields: []string{"crmId", "subject", "identityId", "stageId", "creatDate"}})
+	require.NoError(t, err)
+	require.Equal(t, strings.Join([]string{
+		"crmId,subject,identityId,stageId,creatDate,City,Amount",
+		`form-1,"late, delivery",person-1,,2024-03-20T10:30:00,تهران,`,
+		"form-2,,,,,,1000",
+		"",
+	}, "\n"), out.String())
+}
+
+func TestWriter(t *testing.T) {
+	var out bytes.Buffer
+	writer := export.NewWriter(&out, export.Options{Properties: []string{"Score"}, Comma: ';', ExcelBOM: true})
+
+	require.NoError(t, writer.WritePersons([]gopayamgostar.PersonRow{
+		{CRMID: "person-1", FirstName: "Ali", ExtendedProperties: []gopayamgostar.ExtendedProperty{{UserKey: "Score", Value: "7"}}},
+	}))
+	// the columns are kept for the following pages
+	require.NoError(t, writer.WritePersons([]gopayamgostar.PersonRow{
+		{CRMID: "person-2", FirstName: "Sara", ExtendedProperties: []gopayamgostar.ExtendedProperty{{UserKey: "City", Value: "Shiraz"}}},
+	}))
+	require.NoError(t, writer.Flush())
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Equal(t, []string{
+		"\ufeffcrmId;crmObjectTypeCode;refId;firstName;lastName;nationalCode;email;customerNumber;creatDate;modifyDate;Score",
+		"person-1;;;Ali;;;;;;;7",
+		"person-2;;;Sara;;;;;;;",
+	}, lines)
+}
+
+func TestPersonsOfFind(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	server.AddPerson(gopayamgostar.PersonInfo{CRMID: "person-1", FirstName: "Ali", LastName: "Rezaei", ExtendedProperties: []gopayamgostar.ExtendedProperty{{UserKey: "City", Value: "Tehran"}}})
+	server.AddPerson(gopayamgostar.PersonInfo{CRMID: "person-2", FirstName: "Sara", LastName: "Ahmadi", ExtendedProperties: []gopayamgostar.ExtendedProperty{{UserKey: "Score", Value: "7"}}})
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	result, err := client.FindPersonsPage(ctx, token.AccessToken, "Person", nil, 1, 10)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, export.Persons(&out, result.Data, export.Options{Fields: []string{"firstName", "lastName"}}))
+	require.Equal(t, strings.Join([]string{
+		"firstName,lastName,City,Score",
+		"Ali,Rezaei,Tehran,",
+		"Sara,Ahmadi,,7",
+		"",
+	}, "\n"), out.String())
+}
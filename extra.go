@@ -0,0 +1,163 @@
+package gopayamgostar
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// knownFieldsCache holds the lower cased JSON names of the fields of a type
+var knownFieldsCache sync.Map
+
+func knownFields(t reflect.Type) map[string]bool {
+	if fields, ok := knownFieldsCache.Load(t); ok {
+		return fields.(map[string]bool)
+	}
+
+	fields := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for embedded := range knownFields(field.Type) {
+				fields[embedded] = true
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[strings.ToLower(name)] = true
+	}
+
+	knownFieldsCache.Store(t, fields)
+	return fields
+}
+
+// unmarshalWithExtra decodes data into v, a pointer to a struct without a
+// custom UnmarshalJSON, and returns the members no field of v captured.
+// Member names are matched case insensitively, as encoding/json does.
+func unmarshalWithExtra(data []byte, v interface{}) (map[string]json.RawMessage, error) {
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+
+	fields := knownFields(reflect.TypeOf(v).Elem())
+	var extra map[string]json.RawMessage
+	for name, value := range members {
+		if fields[strings.ToLower(name)] {
+			continue
+		}
+		if extra == nil {
+			extra = map[string]json.RawMessage{}
+		}
+		extra[name] = value
+	}
+	return extra, nil
+}
+
+// marshalWithExtra encodes v, a struct without a custom MarshalJSON, and adds
+// the members of extra that do not collide with its fields
+func marshalWithExtra(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	fields := knownFields(reflect.TypeOf(v))
+	for name, value := range extra {
+		if !fields[strings.ToLower(name)] {
+			members[name] = value
+		}
+	}
+	return json.Marshal(members)
+}
+
+// UnmarshalJSON keeps the members FormInfo has no field for in Extra
+func (f *FormInfo) UnmarshalJSON(data []byte) error {
+	type plain FormInfo
+	extra, err := unmarshalWithExtra(data, (*plain)(f))
+	f.Extra = extra
+	return err
+}
+
+// MarshalJSON writes Extra back along the known fields
+func (f FormInfo) MarshalJSON() ([]byte, error) {
+	type plain FormInfo
+	return marshalWithExtra(plain(f), f.Extra)
+}
+
+// UnmarshalJSON keeps the members FormResponse has no field for in Extra
+func (f *FormResponse) UnmarshalJSON(data []byte) error {
+	type plain FormResponse
+	extra, err := unmarshalWithExtra(data, (*plain)(f))
+	f.Extra = extra
+	return err
+}
+
+// MarshalJSON writes Extra back along the known fields
+func (f FormResponse) MarshalJSON() ([]byte, error) {
+	type plain FormResponse
+	return marshalWithExtra(plain(f), f.Extra)
+}
+
+// UnmarshalJSON keeps the members PersonInfo has no field for in Extra
+func (p *PersonInfo) UnmarshalJSON(data []byte) error {
+	type plain PersonInfo
+	extra, err := unmarshalWithExtra(data, (*plain)(p))
+	p.Extra = extra
+	return err
+}
+
+// MarshalJSON writes Extra back along the known fields
+func (p PersonInfo) MarshalJSON() ([]byte, error) {
+	type plain PersonInfo
+	return marshalWithExtra(plain(p), p.Extra)
+}
+
+// MarshalJSON sends Extra along the known fields, so that fields read by
+// FormInfo and unknown to this version of the client are not dropped
+func (r UpdateFormRequest) MarshalJSON() ([]byte, error) {
+	type plain UpdateFormRequest
+	return marshalWithExtra(plain(r), r.Extra)
+}
+
+// UpdateRequest returns a request updating the form to its current values,
+// Extra included. Change the fields to update before sending it.
+func (f FormInfo) UpdateRequest() UpdateFormRequest {
+	request := UpdateFormRequest{
+		CrmId:              f.CRMID,
+		ExtendedProperties: f.ExtendedProperties,
+		RefId:              f.RefID,
+		IdentityId:         f.IdentityID,
+		Description:        f.Description,
+		Subject:            f.Subject,
+		Extra:              f.Extra,
+	}
+	if parent, ok := f.ParentCRMObjectID.(string); ok && parent != "" {
+		request.ParentCrmObjectId = &parent
+	}
+	if stage, ok := f.StageID.(string); ok && stage != "" {
+		request.StageId = &stage
+	}
+	for _, tag := range f.Tags {
+		if s, ok := tag.(string); ok {
+			request.Tags = append(request.Tags, s)
+		}
+	}
+	return request
+}
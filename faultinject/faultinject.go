@@ -0,0 +1,186 @@
+// Package faultinject injects latency, errors and altered responses into the
+// requests of a client, so integration tests can verify how code built on
+// the client behaves when the CRM is slow or failing.
+package faultinject
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/pkg/errors"
+)
+
+// ErrInjected is a transport error to inject with Rule.Err
+var ErrInjected = errors.New("faultinject: injected error")
+
+// Rule describes a fault and the requests it applies to. Faults are applied
+// per attempt, so requests retried by the client see them again.
+type Rule struct {
+	// Operation restricts the rule to the requests of one client operation,
+	// named after its Config endpoint without the Endpoint suffix, e.g.
+	// "CreateForm". An empty Operation matches every request.
+	Operation string
+	// Skip is the number of matching requests let through before the rule applies
+	Skip int
+	// Times is the number of requests the rule applies to, 0 for no limit
+	Times int
+	// Probability applies the rule to a random share of the requests, 0 or 1
+	// to apply it to all of them
+	Probability float64
+
+	// Latency delays the request before it is sent
+	Latency time.Duration
+	// Err fails the request with a transport error
+	Err error
+	// StatusCode answers the request with this status and Body instead of
+	// sending it to the server
+	StatusCode int
+	Body       string
+	// Mutate alters the response of the server
+	Mutate func(*http.Response)
+}
+
+type rule struct {
+	Rule
+	path    string
+	skipped int
+	applied int
+}
+
+// Injector applies rules to the requests of a client
+type Injector struct {
+	next      http.RoundTripper
+	endpoints map[string]string
+
+	mu    sync.Mutex
+	rules []*rule
+}
+
+// Install wraps the transport of client with an Injector without rules
+func Install(client *gopayamgostar.GoPayamgostar) *Injector {
+	i := &Injector{endpoints: map[string]string{}}
+
+	config := reflect.ValueOf(client.Config)
+	for n := 0; n < config.NumField(); n++ {
+		name := strings.TrimSuffix(config.Type().Field(n).Name, "Endpoint")
+		i.endpoints[name] = config.Field(n).String()
+	}
+
+	httpClient := client.RestyClient().GetClient()
+	i.next = httpClient.Transport
+	if i.next == nil {
+		i.next = http.DefaultTransport
+	}
+	httpClient.Transport = i
+
+	return i
+}
+
+// Add adds a rule. The first rule matching a request is applied. It panics
+// when Operation is not an operation of the client.
+func (i *Injector) Add(r Rule) *Injector {
+	added := &rule{Rule: r}
+	if r.Operation != "" {
+		path, ok := i.endpoints[r.Operation]
+		if !ok {
+			panic(fmt.Sprintf("faultinject: unknown operation %q", r.Operation))
+		}
+		added.path = "/" + path
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rules = append(i.rules, added)
+	return i
+}
+
+// Reset removes every rule
+func (i *Injector) Reset() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rules = nil
+}
+
+// Applied returns the number of requests the rules were applied to
+func (i *Injector) Applied() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	applied := 0
+	for _, r := range i.rules {
+		applied += r.applied
+	}
+	return applied
+}
+
+// RoundTrip implements http.RoundTripper
+func (i *Injector) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := i.match(req)
+	if r == nil {
+		return i.next.RoundTrip(req)
+	}
+
+	if r.Latency > 0 {
+		timer := time.NewTimer(r.Latency)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	if r.StatusCode != 0 {
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+			StatusCode: r.StatusCode,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(r.Body)),
+			Request:    req,
+		}, nil
+	}
+
+	resp, err := i.next.RoundTrip(req)
+	if err == nil && r.Mutate != nil {
+		r.Mutate(resp)
+	}
+	return resp, err
+}
+
+// match returns the rule to apply to req and counts it as applied
+func (i *Injector) match(req *http.Request) *rule {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, r := range i.rules {
+		if r.path != "" && !strings.HasSuffix(req.URL.Path, r.path) {
+			continue
+		}
+		if r.Times > 0 && r.applied >= r.Times {
+			continue
+		}
+		if r.skipped < r.Skip {
+			r.skipped++
+			continue
+		}
+		if r.Probability > 0 && r.Probability < 1 && rand.Float64() >= r.Probability {
+			continue
+		}
+		r.applied++
+		return r
+	}
+	return nil
+}
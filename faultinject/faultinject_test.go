@@ -0,0 +1,82 @@
+package faultinject_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/faultinject"
+	"github.com/stretchr/testify/require"
+)
+
+func newServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"crmId":"server"}`))
+	}))
+}
+
+func TestInjector(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL)
+	injector := faultinject.Install(client).
+		Add(faultinject.Rule{Operation: "CreateForm", Skip: 1, Times: 1, Err: faultinject.ErrInjected}).
+		Add(faultinject.Rule{Operation: "DeleteForm", StatusCode: http.StatusServiceUnavailable, Body: `{"errorMessage":"maintenance"}`})
+	ctx := context.Background()
+
+	id, err := client.CreateForm(ctx, "token", gopayamgostar.CreateFormRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "server", id)
+
+	_, err = client.CreateForm(ctx, "token", gopayamgostar.CreateFormRequest{})
+	require.ErrorContains(t, err, faultinject.ErrInjected.Error())
+
+	_, err = client.CreateForm(ctx, "token", gopayamgostar.CreateFormRequest{})
+	require.NoError(t, err)
+
+	err = client.DeleteForm(ctx, "token", "form")
+	var apiErr *gopayamgostar.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusServiceUnavailable, apiErr.Code)
+	require.Contains(t, apiErr.Message, "maintenance")
+
+	require.Equal(t, 2, injector.Applied())
+	injector.Reset()
+	require.NoError(t, client.DeleteForm(ctx, "token", "form"))
+}
+
+func TestInjectorLatencyAndMutate(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL)
+	faultinject.Install(client).Add(faultinject.Rule{
+		Latency: 20 * time.Millisecond,
+		Mutate: func(resp *http.Response) {
+			resp.StatusCode = http.StatusBadGateway
+			resp.Status = "502 Bad Gateway"
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	_, err := client.CreateForm(ctx, "token", gopayamgostar.CreateFormRequest{})
+	require.ErrorContains(t, err, context.DeadlineExceeded.Error())
+
+	_, err = client.CreateForm(context.Background(), "token", gopayamgostar.CreateFormRequest{})
+	var apiErr *gopayamgostar.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusBadGateway, apiErr.Code)
+}
+
+func TestUnknownOperation(t *testing.T) {
+	injector := faultinject.Install(gopayamgostar.NewClient("http://localhost"))
+	require.Panics(t, func() {
+		injector.Add(faultinject.Rule{Operation: "NoSuchOperation"})
+	})
+}
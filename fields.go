@@ -0,0 +1,49 @@
+package gopayamgostar
+
+import (
+	"context"
+	"strings"
+)
+
+var (
+	fieldsContextKey     = contextKey("fields")
+	listFieldsContextKey = contextKey("list-fields")
+)
+
+// WithFields returns a context that makes the find requests using it, such as
+// FindForm, FindPersonsPage and the streams, return only fields: the fields
+// of the objects and the user keys of their extended properties. The CrmId is
+// always returned. It reduces the payload of wide form types with dozens of
+// extended properties; the fields not returned are left zero.
+func WithFields(ctx context.Context, fields ...string) context.Context {
+	return context.WithValue(ctx, fieldsContextKey, fields)
+}
+
+// projectedFindRequest sets the fields of WithFields on request, adding the CrmId
+func projectedFindRequest(ctx context.Context, request FindRequest) FindRequest {
+	fields, _ := ctx.Value(fieldsContextKey).([]string)
+	if len(fields) == 0 {
+		return request
+	}
+	request.Fields = append([]string(nil), fields...)
+	for _, field := range fields {
+		if strings.EqualFold(field, crmIdSortField) {
+			return request
+		}
+	}
+	request.Fields = append(request.Fields, crmIdSortField)
+	return request
+}
+
+// WithListFields returns a context that makes GetPersonInfoById using it ask
+// for the list fields of the person, returned in PersonInfo.IncludedFields.
+// They are left out by default since they can make the payload much larger.
+func WithListFields(ctx context.Context) context.Context {
+	return context.WithValue(ctx, listFieldsContextKey, true)
+}
+
+// includeListFields reports whether ctx was returned by WithListFields
+func includeListFields(ctx context.Context) bool {
+	include, _ := ctx.Value(listFieldsContextKey).(bool)
+	return include
+}
@@ -0,0 +1,58 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+)
+
+func TestWithFields(t *testing.T) {
+	t.Parallel()
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	subject, description := "late", "the order arrived a week late"
+	id, err := client.CreateForm(ctx, token.AccessToken, gopayamgostar.CreateFormRequest{
+		CRMObjectTypeCode: "Complaint",
+		Subject:           &subject,
+		Description:       &description,
+		ExtendedProperties: []gopayamgostar.ExtendedProperty{
+			{UserKey: "Amount", Value: "1000"},
+			{UserKey: "Notes", Value: "a long text"},
+		},
+	})
+	require.NoError(t, err)
+
+	forms, err := client.FindForm(gopayamgostar.WithFields(ctx, "subject", "Amount"), token.AccessToken, "Complaint", nil)
+	require.NoError(t, err)
+	require.Len(t, forms.Data, 1)
+	form := forms.Data[0]
+	require.Equal(t, id, form.CRMID, "the crm id is always returned")
+	require.Equal(t, "late", form.Subject)
+	require.Empty(t, form.Description)
+	require.Empty(t, form.CRMObjectTypeCode)
+	require.Equal(t, []gopayamgostar.ExtendedProperty{{UserKey: "Amount", Value: "1000"}}, form.ExtendedProperties)
+
+	forms, err = client.FindForm(ctx, token.AccessToken, "Complaint", nil)
+	require.NoError(t, err)
+	require.Equal(t, description, forms.Data[0].Description)
+	require.Len(t, forms.Data[0].ExtendedProperties, 2)
+
+	server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Ali", LastName: "Rezaei"})
+	persons, err := client.FindPersonsPage(gopayamgostar.WithFields(ctx, "lastName"), token.AccessToken, "Person", nil, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, persons.Data, 1)
+	require.NotEmpty(t, persons.Data[0].CRMID)
+	require.Equal(t, "Rezaei", persons.Data[0].LastName)
+	require.Empty(t, persons.Data[0].FirstName)
+}
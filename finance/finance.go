@@ -0,0 +1,170 @@
+// Package finance bundles the back-office finance flows built on gopayamgostar:
+// deposit verification against BankAccount forms, settlement requests and
+// invoice settlement receipts.
+package finance
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrDepositNotFound is returned when no BankAccount form matches a deposit
+	ErrDepositNotFound = errors.New("finance: deposit not found")
+	// ErrDepositAmbiguous is returned when several BankAccount forms match a deposit
+	ErrDepositAmbiguous = errors.New("finance: deposit matches several bank records")
+)
+
+// Service runs finance workflows. The type codes default to the ones used by
+// standard Payamgostar installations and can be changed after New.
+type Service struct {
+	client *gopayamgostar.GoPayamgostar
+
+	DepositTypeKey     string
+	SettlementTypeCode string
+	ReceiptTypeCode    string
+}
+
+// New creates a finance service on top of client
+func New(client *gopayamgostar.GoPayamgostar) *Service {
+	return &Service{
+		client:             client,
+		DepositTypeKey:     "BankAccount",
+		SettlementTypeCode: "SettlementRequest",
+		ReceiptTypeCode:    "Receipt",
+	}
+}
+
+// Deposit identifies a bank deposit made by a customer
+type Deposit struct {
+	TrackingNumber string
+	Amount         int64
+	// Date is the deposit date
+	Date gopayamgostar.JalaliDate
+}
+
+// SettlementRequest describes a settlement request form
+type SettlementRequest struct {
+	IdentityID    string
+	Deposit       Deposit
+	CenterDetails string
+	Description   string
+	// ExtendedProperties are appended to the ones derived from the deposit
+	ExtendedProperties []gopayamgostar.ExtendedProperty
+}
+
+// Settlement is the input of Settle
+type Settlement struct {
+	SettlementRequest
+	InvoiceID string
+}
+
+// SettlementResult holds the objects created by Settle
+type SettlementResult struct {
+	DepositID           string
+	SettlementRequestID string
+	ReceiptID           string
+}
+
+// VerifyDeposit finds the BankAccount form recording the deposit
+func (s *Service) VerifyDeposit(ctx context.Context, accessToken string, deposit Deposit) (*gopayamgostar.FormResponse, error) {
+	queries := []gopayamgostar.Query{
+		{
+			LogicalOperator: int(enums.And),
+			FieldOperator:   int(enums.Equals),
+			Field:           "TrackingNumber",
+			Value:           deposit.TrackingNumber,
+		},
+		{
+			LogicalOperator: int(enums.And),
+			FieldOperator:   int(enums.Equals),
+			Field:           "DepositAmount",
+			Value:           strconv.FormatInt(deposit.Amount, 10),
+		},
+	}
+
+	result, err := s.client.FindForm(ctx, accessToken, s.DepositTypeKey, queries)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(result.Data) {
+	case 0:
+		return nil, ErrDepositNotFound
+	case 1:
+		return &result.Data[0], nil
+	default:
+		return nil, ErrDepositAmbiguous
+	}
+}
+
+// CreateSettlementRequest creates a settlement request form for a deposit
+func (s *Service) CreateSettlementRequest(ctx context.Context, accessToken string, request SettlementRequest) (string, error) {
+	properties := []gopayamgostar.ExtendedProperty{
+		{UserKey: "DepositDate", Value: request.Deposit.Date.String()},
+		{UserKey: "DepositAmount", Value: strconv.FormatInt(request.Deposit.Amount, 10)},
+		{UserKey: "TrackingNumber", Value: request.Deposit.TrackingNumber},
+	}
+	if request.CenterDetails != "" {
+		properties = append(properties, gopayamgostar.ExtendedProperty{UserKey: "CenterDetails", Value: request.CenterDetails})
+	}
+	if request.Description != "" {
+		properties = append(properties, gopayamgostar.ExtendedProperty{UserKey: "FurtherDescription", Value: request.Description})
+	}
+
+	return s.client.CreateForm(ctx, accessToken, gopayamgostar.CreateFormRequest{
+		CRMObjectTypeCode:  s.SettlementTypeCode,
+		ExtendedProperties: append(properties, request.ExtendedProperties...),
+		IdentityID:         request.IdentityID,
+		ColorID:            1,
+	})
+}
+
+// SettleInvoice records a receipt paying invoiceID with the deposit
+func (s *Service) SettleInvoice(ctx context.Context, accessToken, identityID, invoiceID string, deposit Deposit) (string, error) {
+	request := gopayamgostar.CreateReceiptRequest{
+		CRMObjectTypeCode: s.ReceiptTypeCode,
+		IdentityID:        identityID,
+		Amount:            deposit.Amount,
+		TrackingNumber:    gopayamgostar.StringP(deposit.TrackingNumber),
+		RelatedInvoiceID:  gopayamgostar.StringP(invoiceID),
+	}
+	if !deposit.Date.IsZero() {
+		request.ReceiptDate = &deposit.Date
+	}
+
+	return s.client.CreateReceipt(ctx, accessToken, request)
+}
+
+// Settle verifies the deposit, creates a settlement request and settles the
+// invoice with a receipt. When a later step fails the objects created by the
+// earlier steps are deleted again; a failed compensation is reported together
+// with the original error.
+func (s *Service) Settle(ctx context.Context, accessToken string, settlement Settlement) (*SettlementResult, error) {
+	deposit, err := s.VerifyDeposit(ctx, accessToken, settlement.Deposit)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not verify deposit")
+	}
+
+	result := &SettlementResult{DepositID: deposit.CRMID}
+
+	result.SettlementRequestID, err = s.CreateSettlementRequest(ctx, accessToken, settlement.SettlementRequest)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create settlement request")
+	}
+
+	result.ReceiptID, err = s.SettleInvoice(ctx, accessToken, settlement.IdentityID, settlement.InvoiceID, settlement.Deposit)
+	if err != nil {
+		err = errors.Wrap(err, "could not settle invoice")
+		if cerr := s.client.DeleteForm(context.WithoutCancel(ctx), accessToken, result.SettlementRequestID); cerr != nil {
+			return nil, errors.Wrapf(err, "settlement request %s was not rolled back: %v", result.SettlementRequestID, cerr)
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
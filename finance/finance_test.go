@@ -0,0 +1,175 @@
+package finance_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/faultinject"
+	"github.com/erfandiakoo/gopayamgostar/v2/finance"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/stretchr/testify/require"
+)
+
+func newService(t *testing.T) (*gopayamgostartest.Server, *gopayamgostar.GoPayamgostar, *finance.Service, string) {
+	server := gopayamgostartest.NewServer()
+	t.Cleanup(server.Close)
+	server.AddUser("admin", "secret")
+	client := server.Client()
+
+	token, err := client.AdminAuthenticate(context.Background(), "admin", "secret")
+	require.NoError(t, err)
+
+	return server, client, finance.New(client), token.AccessToken
+}
+
+func addDeposit(t *testing.T, client *gopayamgostar.GoPayamgostar, accessToken, trackingNumber, amount string) string {
+	id, err := client.CreateForm(context.Background(), accessToken, gopayamgostar.CreateFormRequest{
+		CRMObjectTypeCode: "BankAccount",
+		ExtendedProperties: []gopayamgostar.ExtendedProperty{
+			{UserKey: "TrackingNumber", Value: trackingNumber},
+			{UserKey: "DepositAmount", Value: amount},
+		},
+	})
+	require.NoError(t, err)
+	return id
+}
+
+func settlementRequests(t *testing.T, client *gopayamgostar.GoPayamgostar, accessToken string) []gopayamgostar.FormResponse {
+	result, err := client.FindForm(context.Background(), accessToken, "SettlementRequest", nil)
+	require.NoError(t, err)
+	return result.Data
+}
+
+func TestVerifyDeposit(t *testing.T) {
+	_, client, service, accessToken := newService(t)
+	ctx := context.Background()
+
+	id := addDeposit(t, client, accessToken, "TRK-1", "1500000")
+	addDeposit(t, client, accessToken, "TRK-2", "900000")
+	addDeposit(t, client, accessToken, "TRK-2", "900000")
+
+	deposit, err := service.VerifyDeposit(ctx, accessToken, finance.Deposit{TrackingNumber: "TRK-1", Amount: 1500000})
+	require.NoError(t, err)
+	require.Equal(t, id, deposit.CRMID)
+
+	_, err = service.VerifyDeposit(ctx, accessToken, finance.Deposit{TrackingNumber: "TRK-1", Amount: 150000})
+	require.ErrorIs(t, err, finance.ErrDepositNotFound)
+
+	_, err = service.VerifyDeposit(ctx, accessToken, finance.Deposit{TrackingNumber: "TRK-2", Amount: 900000})
+	require.ErrorIs(t, err, finance.ErrDepositAmbiguous)
+}
+
+func TestVerifyDepositQueries(t *testing.T) {
+	_, client, service, accessToken := newService(t)
+
+	var request map[string]interface{}
+	client.RegisterRequestHook(func(info gopayamgostar.HookInfo, req *http.Request) error {
+		if info.Endpoint != "FindForm" {
+			return nil
+		}
+		body, err := req.GetBody()
+		require.NoError(t, err)
+		defer body.Close()
+		return json.NewDecoder(body).Decode(&request)
+	})
+
+	_, err := service.VerifyDeposit(context.Background(), accessToken, finance.Deposit{TrackingNumber: "TRK-1", Amount: 1500000})
+	require.ErrorIs(t, err, finance.ErrDepositNotFound)
+
+	// the operator is left out of the wire request for equality, which is
+	// what the server applies to a query without one
+	require.Equal(t, "BankAccount", request["typeKey"])
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"logicalOperator": float64(0), "operator": float64(0), "field": "TrackingNumber", "value": "TRK-1"},
+		map[string]interface{}{"logicalOperator": float64(0), "operator": float64(0), "field": "DepositAmount", "value": "1500000"},
+	}, request["queries"])
+}
+
+func TestSettleInvoice(t *testing.T) {
+	_, client, service, accessToken := newService(t)
+	injector := faultinject.Install(client).
+		Add(faultinject.Rule{Operation: "CreateReceipt", StatusCode: http.StatusOK, Body: `{"crmId":"receipt-1"}`})
+
+	id, err := service.SettleInvoice(context.Background(), accessToken, "person-1", "invoice-1", finance.Deposit{TrackingNumber: "TRK-1", Amount: 1500000})
+	require.NoError(t, err)
+	require.Equal(t, "receipt-1", id)
+	require.Equal(t, 1, injector.Applied())
+}
+
+func TestSettle(t *testing.T) {
+	server, client, service, accessToken := newService(t)
+	faultinject.Install(client).
+		Add(faultinject.Rule{Operation: "CreateReceipt", StatusCode: http.StatusOK, Body: `{"crmId":"receipt-1"}`})
+
+	depositID := addDeposit(t, client, accessToken, "TRK-1", "1500000")
+	settlement := finance.Settlement{
+		SettlementRequest: finance.SettlementRequest{
+			IdentityID: "person-1",
+			Deposit:    finance.Deposit{TrackingNumber: "TRK-1", Amount: 1500000},
+		},
+		InvoiceID: "invoice-1",
+	}
+
+	result, err := service.Settle(context.Background(), accessToken, settlement)
+	require.NoError(t, err)
+	require.Equal(t, depositID, result.DepositID)
+	require.Equal(t, "receipt-1", result.ReceiptID)
+
+	form, ok := server.Form(result.SettlementRequestID)
+	require.True(t, ok)
+	require.Equal(t, "SettlementRequest", form.CRMObjectTypeCode)
+	require.Equal(t, "person-1", form.IdentityID)
+}
+
+func TestSettleRollsBack(t *testing.T) {
+	_, client, service, accessToken := newService(t)
+	faultinject.Install(client).
+		Add(faultinject.Rule{Operation: "CreateReceipt", Latency: time.Second, StatusCode: http.StatusOK, Body: `{"crmId":"receipt-1"}`})
+
+	addDeposit(t, client, accessToken, "TRK-1", "1500000")
+	settlement := finance.Settlement{
+		SettlementRequest: finance.SettlementRequest{
+			IdentityID: "person-1",
+			Deposit:    finance.Deposit{TrackingNumber: "TRK-1", Amount: 1500000},
+		},
+		InvoiceID: "invoice-1",
+	}
+
+	// the receipt outlives the deadline of the caller, the rollback must not
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := service.Settle(ctx, accessToken, settlement)
+	require.ErrorContains(t, err, "could not settle invoice")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NotContains(t, err.Error(), "was not rolled back")
+	require.Empty(t, settlementRequests(t, client, accessToken))
+}
+
+func TestSettleRollbackFails(t *testing.T) {
+	_, client, service, accessToken := newService(t)
+	faultinject.Install(client).
+		Add(faultinject.Rule{Operation: "CreateReceipt", StatusCode: http.StatusBadRequest, Body: `{"errorMessage":"invoice is closed"}`}).
+		Add(faultinject.Rule{Operation: "DeleteForm", StatusCode: http.StatusBadRequest, Body: `{"errorMessage":"form is locked"}`})
+
+	addDeposit(t, client, accessToken, "TRK-1", "1500000")
+	settlement := finance.Settlement{
+		SettlementRequest: finance.SettlementRequest{
+			IdentityID: "person-1",
+			Deposit:    finance.Deposit{TrackingNumber: "TRK-1", Amount: 1500000},
+		},
+		InvoiceID: "invoice-1",
+	}
+
+	_, err := service.Settle(context.Background(), accessToken, settlement)
+	require.ErrorContains(t, err, "invoice is closed")
+	require.ErrorContains(t, err, "was not rolled back")
+	require.ErrorContains(t, err, "form is locked")
+
+	requests := settlementRequests(t, client, accessToken)
+	require.Len(t, requests, 1)
+	require.Contains(t, err.Error(), requests[0].CRMID)
+}
@@ -0,0 +1,110 @@
+package gopayamgostar
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// findPersonPageSize is the number of persons FindPersonsByName returns by default
+const findPersonPageSize = 10
+
+var (
+	// ErrAmbiguousMatch is returned by FindPersonsByName with UniqueMatch when
+	// more than one person has the name. The matches are returned with it.
+	ErrAmbiguousMatch = errors.New("more than one person matches")
+	// ErrTooManyMatches is returned by FindPersonsByName with MaxResults when
+	// more persons match than the limit. The first ones are returned with it.
+	ErrTooManyMatches = errors.New("more persons match than the limit")
+)
+
+// FindPersonOption changes how FindPersonsByName matches persons
+type FindPersonOption func(*findPersonOptions)
+
+type findPersonOptions struct {
+	exact      bool
+	unique     bool
+	maxResults int
+}
+
+// ExactMatch keeps the persons whose first and last names equal the ones
+// searched, ignoring surrounding spaces and the Arabic forms of Persian
+// letters, and drops the ones the server matched loosely
+func ExactMatch() FindPersonOption {
+	return func(o *findPersonOptions) {
+		o.exact = true
+	}
+}
+
+// UniqueMatch fails with ErrAmbiguousMatch when more than one person matches,
+// so that callers do not pick one of several namesakes by accident
+func UniqueMatch() FindPersonOption {
+	return func(o *findPersonOptions) {
+		o.unique = true
+	}
+}
+
+// MaxResults returns up to n persons and fails with ErrTooManyMatches when
+// more match, instead of silently returning the first page
+func MaxResults(n int) FindPersonOption {
+	return func(o *findPersonOptions) {
+		o.maxResults = n
+	}
+}
+
+func newFindPersonOptions(options []FindPersonOption) findPersonOptions {
+	var opts findPersonOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	return opts
+}
+
+func (o findPersonOptions) pageSize() int64 {
+	if o.maxResults > 0 {
+		return int64(o.maxResults)
+	}
+	return findPersonPageSize
+}
+
+// checkMatches applies o to the rows of a find, dropping loose matches in
+// place
+func checkMatches[T any](o findPersonOptions, data *[]T, total *int64, names func(T) (string, string), firstName, lastName string) error {
+	if o.exact {
+		rows := (*data)[:0]
+		for _, row := range *data {
+			first, last := names(row)
+			if sameName(first, firstName) && sameName(last, lastName) {
+				rows = append(rows, row)
+			}
+		}
+		*total -= int64(len(*data) - len(rows))
+		*data = rows
+	}
+
+	switch {
+	case o.unique && *total > 1:
+		return errors.Wrapf(ErrAmbiguousMatch, "%d persons named %q %q", *total, firstName, lastName)
+	case o.maxResults > 0 && *total > int64(o.maxResults):
+		return errors.Wrapf(ErrTooManyMatches, "%d persons named %q %q, limit %d", *total, firstName, lastName, o.maxResults)
+	}
+	return nil
+}
+
+func (p PersonInfo) names() (string, string) {
+	return p.FirstName, p.LastName
+}
+
+func (p PersonRow) names() (string, string) {
+	return p.FirstName, p.LastName
+}
+
+var nameReplacer = strings.NewReplacer("ي", "ی", "ى", "ی", "ك", "ک", "‌", " ")
+
+// sameName compares names the way users type them
+func sameName(a, b string) bool {
+	normalize := func(s string) string {
+		return strings.Join(strings.Fields(nameReplacer.Replace(s)), " ")
+	}
+	return normalize(a) == normalize(b)
+}
@@ -0,0 +1,95 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+)
+
+func TestFindPersonsByNameOptions(t *testing.T) {
+	t.Parallel()
+
+	rows := []gopayamgostar.PersonRow{
+		{CRMID: "1", FirstName: "علي", LastName: " رضایی"},
+		{CRMID: "2", FirstName: "علی", LastName: "رضایی‌فر"},
+		{CRMID: "3", FirstName: "علی", LastName: "رضایی"},
+	}
+	var pageSizes []int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request gopayamgostar.FindRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		pageSizes = append(pageSizes, request.PageSize)
+		page := rows
+		if int64(len(page)) > request.PageSize {
+			page = page[:request.PageSize]
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(gopayamgostar.FindPersonResponse{Data: page, Total: int64(len(rows))})
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client := gopayamgostar.NewClient(server.URL)
+
+	found, err := client.FindPersonsByName(ctx, "token", "Person", "علی", "رضایی")
+	require.NoError(t, err)
+	require.Len(t, found.Data, 3)
+
+	found, err = client.FindPersonsByName(ctx, "token", "Person", "علی", "رضایی", gopayamgostar.ExactMatch())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), found.Total)
+	require.Equal(t, "1", found.Data[0].CRMID, "Arabic letters and spaces are ignored")
+	require.Equal(t, "3", found.Data[1].CRMID)
+
+	found, err = client.FindPersonsByName(ctx, "token", "Person", "علی", "رضایی", gopayamgostar.ExactMatch(), gopayamgostar.UniqueMatch())
+	require.ErrorIs(t, err, gopayamgostar.ErrAmbiguousMatch)
+	require.Len(t, found.Data, 2, "the matches are returned with the error")
+
+	rows = rows[1:]
+	found, err = client.FindPersonsByName(ctx, "token", "Person", "علی", "رضایی", gopayamgostar.ExactMatch(), gopayamgostar.UniqueMatch())
+	require.NoError(t, err)
+	require.Equal(t, "3", found.Data[0].CRMID)
+
+	found, err = client.FindPersonsByName(ctx, "token", "Person", "علی", "رضایی", gopayamgostar.MaxResults(1))
+	require.ErrorIs(t, err, gopayamgostar.ErrTooManyMatches)
+	require.Len(t, found.Data, 1)
+
+	_, err = client.Session("token").FindPersonsByName(ctx, "Person", "علی", "رضایی", gopayamgostar.MaxResults(2))
+	require.NoError(t, err)
+	require.Equal(t, []int64{10, 10, 10, 10, 1, 2}, pageSizes)
+}
+
+func TestFindPersonByNameDeprecated(t *testing.T) {
+	t.Parallel()
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	properties := []gopayamgostar.ExtendedProperty{{UserKey: "City", Value: "Tehran"}}
+	server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Ali", LastName: "Rezaei", ExtendedProperties: properties})
+	server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Ali", LastName: "Rezaeifar"})
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	rows, err := client.FindPersonsByName(ctx, token.AccessToken, "Person", "Ali", "Rezaei", gopayamgostar.ExactMatch())
+	require.NoError(t, err)
+	require.Len(t, rows.Data, 1)
+	require.Equal(t, properties, rows.Data[0].ExtendedProperties)
+
+	var found *gopayamgostar.FindResponse
+	found, err = client.FindPersonByName(ctx, token.AccessToken, "Person", "Ali", "Rezaei", gopayamgostar.ExactMatch())
+	require.NoError(t, err)
+	require.Len(t, found.Data, 1)
+	require.Equal(t, int64(1), found.Total)
+	require.Equal(t, properties, found.Data[0].ExtendedProperties)
+}
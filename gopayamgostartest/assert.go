@@ -0,0 +1,92 @@
+package gopayamgostartest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+)
+
+// eventuallyPollInterval is the first poll interval of EventuallyStage
+const eventuallyPollInterval = 200 * time.Millisecond
+
+// The assertions below read the object through client, bypassing its cache,
+// so they work the same against the fake Server and a real Payamgostar.
+
+// RequireObjectHasTag fails the test unless the object crmId has tag. It
+// returns the object read.
+func RequireObjectHasTag(t testing.TB, client gopayamgostar.GoPayamgostarIface, accessToken, crmId, tag string) *gopayamgostar.FormInfo {
+	t.Helper()
+	object := requireObject(t, client, accessToken, crmId)
+	tags := objectTags(object)
+	if !slices.Contains(tags, tag) {
+		t.Fatalf("object %s: expected tag %q, got tags %q", crmId, tag, tags)
+	}
+	return object
+}
+
+// RequireExtendedEquals fails the test unless the extended property key of
+// the object crmId has the value want. It returns the object read.
+func RequireExtendedEquals(t testing.TB, client gopayamgostar.GoPayamgostarIface, accessToken, crmId, key, want string) *gopayamgostar.FormInfo {
+	t.Helper()
+	object := requireObject(t, client, accessToken, crmId)
+	for _, property := range object.ExtendedProperties {
+		if property.UserKey != key {
+			continue
+		}
+		if property.Value != want {
+			t.Fatalf("object %s: expected %s to be %q, got %q", crmId, key, want, property.Value)
+		}
+		return object
+	}
+	t.Fatalf("object %s: expected %s to be %q, the property is not set", crmId, key, want)
+	return nil
+}
+
+// EventuallyStage fails the test unless the object crmId reaches the stage
+// stageID within timeout, as it does when a process moves it asynchronously.
+// It returns the object in that stage.
+func EventuallyStage(t testing.TB, client gopayamgostar.GoPayamgostarIface, accessToken, crmId, stageID string, timeout time.Duration) *gopayamgostar.FormInfo {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	object, err := client.WaitForObjectCondition(ctx, accessToken, crmId, func(object *gopayamgostar.FormInfo) bool {
+		return objectStage(object) == stageID
+	}, eventuallyPollInterval)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) && object != nil:
+		t.Fatalf("object %s: expected stage %q within %s, still in %q", crmId, stageID, timeout, objectStage(object))
+	case err != nil:
+		t.Fatalf("object %s: waiting for stage %q: %v", crmId, stageID, err)
+	}
+	return object
+}
+
+func requireObject(t testing.TB, client gopayamgostar.GoPayamgostarIface, accessToken, crmId string) *gopayamgostar.FormInfo {
+	t.Helper()
+	object, err := client.GetFormInfoById(gopayamgostar.WithoutCache(context.Background()), accessToken, crmId)
+	if err != nil {
+		t.Fatalf("object %s: %v", crmId, err)
+	}
+	return object
+}
+
+func objectTags(object *gopayamgostar.FormInfo) []string {
+	tags := make([]string, 0, len(object.Tags))
+	for _, tag := range object.Tags {
+		tags = append(tags, fmt.Sprint(tag))
+	}
+	return tags
+}
+
+func objectStage(object *gopayamgostar.FormInfo) string {
+	if object.StageID == nil {
+		return ""
+	}
+	return fmt.Sprint(object.StageID)
+}
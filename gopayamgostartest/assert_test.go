@@ -0,0 +1,86 @@
+package gopayamgostartest_test
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder records the failure of an assertion instead of failing the test
+type recorder struct {
+	testing.TB
+	failure string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.failure = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+// failure runs assert and returns the message it failed with, "" when it passed
+func failure(t *testing.T, assert func(t testing.TB)) string {
+	r := &recorder{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert(r)
+	}()
+	<-done
+	return r.failure
+}
+
+func TestAssertions(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	accessToken := token.AccessToken
+
+	draft := "draft"
+	crmId, err := client.CreateForm(ctx, accessToken, gopayamgostar.CreateFormRequest{
+		CRMObjectTypeCode:  "Order",
+		StageID:            &draft,
+		Tags:               []string{"vip"},
+		ExtendedProperties: []gopayamgostar.ExtendedProperty{{UserKey: "City", Value: "Tehran"}},
+	})
+	require.NoError(t, err)
+
+	gopayamgostartest.RequireObjectHasTag(t, client, accessToken, crmId, "vip")
+	gopayamgostartest.RequireExtendedEquals(t, client, accessToken, crmId, "City", "Tehran")
+	require.Equal(t, `object `+crmId+`: expected tag "new", got tags ["vip"]`, failure(t, func(t testing.TB) {
+		gopayamgostartest.RequireObjectHasTag(t, client, accessToken, crmId, "new")
+	}))
+	require.Equal(t, `object `+crmId+`: expected City to be "Shiraz", got "Tehran"`, failure(t, func(t testing.TB) {
+		gopayamgostartest.RequireExtendedEquals(t, client, accessToken, crmId, "City", "Shiraz")
+	}))
+	require.Equal(t, `object `+crmId+`: expected Zip to be "1", the property is not set`, failure(t, func(t testing.TB) {
+		gopayamgostartest.RequireExtendedEquals(t, client, accessToken, crmId, "Zip", "1")
+	}))
+
+	require.Equal(t, `object `+crmId+`: expected stage "done" within 300ms, still in "draft"`, failure(t, func(t testing.TB) {
+		gopayamgostartest.EventuallyStage(t, client, accessToken, crmId, "done", 300*time.Millisecond)
+	}))
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		form, _ := server.Form(crmId)
+		request := form.UpdateRequest()
+		done := "done"
+		request.StageId = &done
+		_, _ = client.UpdateForm(ctx, accessToken, request)
+	}()
+	object := gopayamgostartest.EventuallyStage(t, client, accessToken, crmId, "done", 5*time.Second)
+	require.Equal(t, "done", object.StageID)
+}
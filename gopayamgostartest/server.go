@@ -0,0 +1,1160 @@
+// Package gopayamgostartest provides an in-memory fake Payamgostar server for
+// tests. It implements authentication, token refresh and logout, person CRUD,
+// form CRUD and tags, purchase creation and deletion, tasks, tickets, notes,
+// users and colors on the endpoints a default client calls, so code built on the SDK can be tested without a real tenant.
+// Its assertions, such as RequireObjectHasTag and EventuallyStage, also work
+// against a real tenant.
+package gopayamgostartest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
+	"github.com/google/uuid"
+)
+
+// tokenLifetime is the lifetime of the access tokens the server issues
+const tokenLifetime = time.Hour
+
+type form struct {
+	info       gopayamgostar.FormInfo
+	createDate time.Time
+	modifyDate time.Time
+}
+
+// Server is a fake Payamgostar server keeping its state in memory
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	users     map[string]string
+	accounts  map[string]gopayamgostar.User
+	tokens    map[string]string
+	refreshes map[string]string
+	// sessions maps access tokens to the refresh tokens issued with them
+	sessions  map[string]string
+	persons   map[string]gopayamgostar.PersonInfo
+	forms     map[string]*form
+	purchases map[string]gopayamgostar.CreatePurchaseRequest
+	tasks     map[string]*gopayamgostar.TaskInfo
+	tickets   map[string]*gopayamgostar.TicketInfo
+	// notes maps crm ids to the notes of the object, oldest first
+	notes map[string][]gopayamgostar.Note
+	// tags are the names of the tags defined in the CRM
+	tags   map[string]bool
+	colors []gopayamgostar.Color
+}
+
+// NewServer starts a fake server. Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		users:     map[string]string{},
+		accounts:  map[string]gopayamgostar.User{},
+		tokens:    map[string]string{},
+		refreshes: map[string]string{},
+		sessions:  map[string]string{},
+		persons:   map[string]gopayamgostar.PersonInfo{},
+		forms:     map[string]*form{},
+		purchases: map[string]gopayamgostar.CreatePurchaseRequest{},
+		tasks:     map[string]*gopayamgostar.TaskInfo{},
+		tickets:   map[string]*gopayamgostar.TicketInfo{},
+		notes:     map[string][]gopayamgostar.Note{},
+		tags:      map[string]bool{},
+	}
+
+	config := gopayamgostar.NewClient("").Config
+	mux := http.NewServeMux()
+	mux.HandleFunc("/"+config.AuthEndpoint, s.authenticate)
+	mux.HandleFunc("/"+config.RefreshTokenEndpoint, s.refresh)
+	mux.HandleFunc("/"+config.LogoutEndpoint, s.authorized(s.logout))
+	mux.HandleFunc("/"+config.GetPersonEndpoint, s.authorized(s.getPerson))
+	mux.HandleFunc("/"+config.FindPersonEndpoint, s.authorized(s.findPersons))
+	mux.HandleFunc("/"+config.CreatePersonEndpoint, s.authorized(s.createPerson))
+	mux.HandleFunc("/"+config.DeletePersonEndpoint, s.authorized(s.deletePerson))
+	mux.HandleFunc("/"+config.CreateFormEndpoint, s.authorized(s.createForm))
+	mux.HandleFunc("/"+config.GetFormEndpoint, s.authorized(s.getForm))
+	mux.HandleFunc("/"+config.UpdateFormEndpoint, s.authorized(s.updateForm))
+	mux.HandleFunc("/"+config.FindFormEndpoint, s.authorized(s.findForms))
+	mux.HandleFunc("/"+config.DeleteFormEndpoint, s.authorized(s.deleteForm))
+	mux.HandleFunc("/"+config.CreatePurchaseEndpoint, s.authorized(s.createPurchase))
+	mux.HandleFunc("/"+config.DeletePurchaseEndpoint, s.authorized(s.deletePurchase))
+	mux.HandleFunc("/"+config.CreateTaskEndpoint, s.authorized(s.createTask))
+	mux.HandleFunc("/"+config.GetTaskEndpoint, s.authorized(s.getTask))
+	mux.HandleFunc("/"+config.UpdateTaskEndpoint, s.authorized(s.updateTask))
+	mux.HandleFunc("/"+config.CompleteTaskEndpoint, s.authorized(s.completeTask))
+	mux.HandleFunc("/"+config.FindTaskEndpoint, s.authorized(s.findTasks))
+	mux.HandleFunc("/"+config.CreateTicketEndpoint, s.authorized(s.createTicket))
+	mux.HandleFunc("/"+config.GetTicketEndpoint, s.authorized(s.getTicket))
+	mux.HandleFunc("/"+config.ReplyTicketEndpoint, s.authorized(s.replyTicket))
+	mux.HandleFunc("/"+config.CloseTicketEndpoint, s.authorized(s.closeTicket))
+	mux.HandleFunc("/"+config.FindTicketEndpoint, s.authorized(s.findTickets))
+	mux.HandleFunc("/"+config.CreateNoteEndpoint, s.authorized(s.createNote))
+	mux.HandleFunc("/"+config.ListNoteEndpoint, s.authorized(s.listNotes))
+	mux.HandleFunc("/"+config.ListUserEndpoint, s.authorized(s.listUsers))
+	mux.HandleFunc("/"+config.GetUserEndpoint, s.authorized(s.getUser))
+	mux.HandleFunc("/"+config.CurrentUserEndpoint, s.authorized(s.currentUser))
+	mux.HandleFunc("/"+config.AddTagsEndpoint, s.authorized(s.changeTags(addTags)))
+	mux.HandleFunc("/"+config.RemoveTagsEndpoint, s.authorized(s.changeTags(removeTags)))
+	mux.HandleFunc("/"+config.ReplaceTagsEndpoint, s.authorized(s.changeTags(replaceTags)))
+	mux.HandleFunc("/"+config.ListTagsEndpoint, s.authorized(s.listTags))
+	mux.HandleFunc("/"+config.ListColorEndpoint, s.authorized(s.listColors))
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// Client returns a client for the server
+func (s *Server) Client(options ...func(*gopayamgostar.GoPayamgostar)) *gopayamgostar.GoPayamgostar {
+	return gopayamgostar.NewClient(s.URL, options...)
+}
+
+// AddUser registers a user that can authenticate with password. The user is
+// listed by the user endpoints as an active user without roles; use SetUser
+// to change that.
+func (s *Server) AddUser(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[username] = password
+	if _, ok := s.accounts[username]; !ok {
+		s.accounts[username] = gopayamgostar.User{
+			ID:          uuid.NewString(),
+			Username:    username,
+			DisplayName: username,
+			IsActive:    true,
+		}
+	}
+}
+
+// SetUser replaces the details the user endpoints return for user.Username,
+// keeping its id when user.ID is empty. The user must have been added with
+// AddUser to authenticate.
+func (s *Server) SetUser(user gopayamgostar.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user.ID == "" {
+		user.ID = s.accounts[user.Username].ID
+	}
+	if user.ID == "" {
+		user.ID = uuid.NewString()
+	}
+	s.accounts[user.Username] = user
+}
+
+// RevokeTokens invalidates the access tokens issued so far, as when they
+// expire, so that the calls using them are rejected with 401 Unauthorized.
+// Their refresh tokens stay valid.
+func (s *Server) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = map[string]string{}
+}
+
+// AddPerson stores a person and returns its crm id, generated when empty
+func (s *Server) AddPerson(person gopayamgostar.PersonInfo) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if person.CRMID == "" {
+		person.CRMID = uuid.NewString()
+	}
+	s.persons[person.CRMID] = person
+	return person.CRMID
+}
+
+// Person returns the stored person crmId
+func (s *Server) Person(crmId string) (gopayamgostar.PersonInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	person, ok := s.persons[crmId]
+	return person, ok
+}
+
+// Form returns the stored form crmId
+func (s *Server) Form(crmId string) (gopayamgostar.FormInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.forms[crmId]
+	if !ok {
+		return gopayamgostar.FormInfo{}, false
+	}
+	return f.info, true
+}
+
+// Purchase returns the stored purchase crmId
+func (s *Server) Purchase(crmId string) (gopayamgostar.CreatePurchaseRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	purchase, ok := s.purchases[crmId]
+	return purchase, ok
+}
+
+// DefineTags adds tags to the tags defined in the CRM, which ListTags
+// returns. The tag endpoints reject the tags that are not defined.
+func (s *Server) DefineTags(tags ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tag := range tags {
+		s.tags[tag] = true
+	}
+}
+
+// SetColors replaces the colors the CRM defines, which ListColors returns
+func (s *Server) SetColors(colors ...gopayamgostar.Color) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.colors = append([]gopayamgostar.Color{}, colors...)
+}
+
+// Task returns the stored task crmId
+func (s *Server) Task(crmId string) (gopayamgostar.TaskInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, ok := s.tasks[crmId]
+	if !ok {
+		return gopayamgostar.TaskInfo{}, false
+	}
+	return *task, true
+}
+
+// Ticket returns the stored ticket crmId
+func (s *Server) Ticket(crmId string) (gopayamgostar.TicketInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ticket, ok := s.tickets[crmId]
+	if !ok {
+		return gopayamgostar.TicketInfo{}, false
+	}
+	return *ticket, true
+}
+
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.AuthRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if password, ok := s.users[request.Username]; !ok || password != request.Password {
+		writeError(w, http.StatusUnauthorized, "invalid username or password")
+		return
+	}
+
+	writeJSON(w, s.issue(request.Username))
+}
+
+func (s *Server) refresh(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.RefreshTokenRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	username, ok := s.refreshes[request.RefreshToken]
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "invalid refresh token")
+		return
+	}
+	// refresh tokens are used once
+	delete(s.refreshes, request.RefreshToken)
+	writeJSON(w, s.issue(username))
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, token)
+	delete(s.refreshes, s.sessions[token])
+	delete(s.sessions, token)
+	writeJSON(w, struct{}{})
+}
+
+// issue creates the tokens of username, with s.mu held
+func (s *Server) issue(username string) gopayamgostar.JWT {
+	token := gopayamgostar.JWT{
+		AccessToken:  uuid.NewString(),
+		RefreshToken: uuid.NewString(),
+		ExpiresAt:    time.Now().Add(tokenLifetime),
+	}
+	s.tokens[token.AccessToken] = username
+	s.refreshes[token.RefreshToken] = username
+	s.sessions[token.AccessToken] = token.RefreshToken
+	return token
+}
+
+// username returns the user the access token of r was issued to, with s.mu held
+func (s *Server) username(r *http.Request) string {
+	return s.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+}
+
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		s.mu.Lock()
+		_, ok := s.tokens[token]
+		s.mu.Unlock()
+
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "invalid access token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) getPerson(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.GetRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	person, ok := s.persons[request.ID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "person not found")
+		return
+	}
+	writeJSON(w, person)
+}
+
+func (s *Server) findPersons(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.FindRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	var matching []gopayamgostar.PersonRow
+	for _, person := range s.persons {
+		if matches(person, person.ExtendedProperties, request.Queries) {
+			matching = append(matching, person.Row())
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(matching, func(i, j int) bool { return matching[i].CRMID < matching[j].CRMID })
+	from, to := page(len(matching), request.PageNumber, request.PageSize)
+	writeJSON(w, gopayamgostar.FindPersonResponse{Data: project(matching[from:to], request.Fields), Total: int64(len(matching))})
+}
+
+func (s *Server) createPerson(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.CreatePersonRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	person := gopayamgostar.PersonInfo{
+		CRMID:              uuid.NewString(),
+		CRMObjectTypeCode:  request.CRMObjectTypeCode,
+		FirstName:          request.FirstName,
+		LastName:           request.LastName,
+		NationalCode:       request.NationalCode,
+		Email:              request.Email,
+		PhoneContacts:      request.PhoneContacts,
+		ExtendedProperties: request.ExtendedProperties,
+		Description:        request.Description,
+	}
+	for i := range person.PhoneContacts {
+		if person.PhoneContacts[i].ID == "" {
+			person.PhoneContacts[i].ID = uuid.NewString()
+		}
+	}
+
+	s.mu.Lock()
+	s.persons[person.CRMID] = person
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]string{"crmId": person.CRMID})
+}
+
+func (s *Server) deletePerson(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.DeleteRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.persons[request.Id]; !ok {
+		writeError(w, http.StatusNotFound, "person not found")
+		return
+	}
+	delete(s.persons, request.Id)
+	writeJSON(w, gopayamgostar.DeleteResult{CrmId: request.Id})
+}
+
+func (s *Server) createForm(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.CreateFormRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	now := time.Now()
+	f := &form{createDate: now, modifyDate: now}
+	f.info = gopayamgostar.FormInfo{
+		CRMID:              uuid.NewString(),
+		CRMObjectTypeCode:  request.CRMObjectTypeCode,
+		ExtendedProperties: request.ExtendedProperties,
+		IdentityID:         request.IdentityID,
+	}
+	if request.ParentCRMObjectID != nil {
+		f.info.ParentCRMObjectID = *request.ParentCRMObjectID
+	}
+	if request.RefID != nil {
+		f.info.RefID = *request.RefID
+	}
+	if request.StageID != nil {
+		f.info.StageID = *request.StageID
+	}
+	if request.Description != nil {
+		f.info.Description = *request.Description
+	}
+	if request.Subject != nil {
+		f.info.Subject = *request.Subject
+	}
+	for _, tag := range request.Tags {
+		f.info.Tags = append(f.info.Tags, tag)
+	}
+
+	s.mu.Lock()
+	s.forms[f.info.CRMID] = f
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]string{"crmId": f.info.CRMID})
+}
+
+func (s *Server) getForm(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.GetRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.forms[request.ID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "form not found")
+		return
+	}
+	writeJSON(w, f.info)
+}
+
+func (s *Server) updateForm(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.UpdateFormRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.forms[request.CrmId]
+	if !ok {
+		writeError(w, http.StatusNotFound, "form not found")
+		return
+	}
+
+	if request.ParentCrmObjectId != nil {
+		f.info.ParentCRMObjectID = *request.ParentCrmObjectId
+	}
+	if request.StageId != nil {
+		f.info.StageID = *request.StageId
+	}
+	if request.ExtendedProperties != nil {
+		f.info.ExtendedProperties = request.ExtendedProperties
+	}
+	if request.Tags != nil {
+		f.info.Tags = nil
+		for _, tag := range request.Tags {
+			f.info.Tags = append(f.info.Tags, tag)
+		}
+	}
+	f.info.RefID = request.RefId
+	f.info.IdentityID = request.IdentityId
+	f.info.Description = request.Description
+	f.info.Subject = request.Subject
+	f.modifyDate = time.Now()
+
+	writeJSON(w, map[string]string{"crmId": f.info.CRMID})
+}
+
+func (s *Server) findForms(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.FindRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	var matching []gopayamgostar.FormResponse
+	for _, f := range s.forms {
+		if f.info.CRMObjectTypeCode != request.TypeKey {
+			continue
+		}
+		response := gopayamgostar.FormResponse{
+			CRMID:              f.info.CRMID,
+			CRMObjectTypeCode:  f.info.CRMObjectTypeCode,
+			ParentCRMObjectID:  f.info.ParentCRMObjectID,
+			ExtendedProperties: f.info.ExtendedProperties,
+			CreatDate:          gopayamgostar.CustomTime{Time: f.createDate},
+			ModifyDate:         gopayamgostar.CustomTime{Time: f.modifyDate},
+			RefID:              f.info.RefID,
+			StageID:            f.info.StageID,
+			IdentityID:         f.info.IdentityID,
+			Description:        f.info.Description,
+			Subject:            f.info.Subject,
+		}
+		if matches(response, response.ExtendedProperties, request.Queries) {
+			matching = append(matching, response)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(matching, func(i, j int) bool { return matching[i].CRMID < matching[j].CRMID })
+	from, to := page(len(matching), request.PageNumber, request.PageSize)
+	writeJSON(w, gopayamgostar.FindFormResponse{Data: project(matching[from:to], request.Fields), Total: int64(len(matching))})
+}
+
+func (s *Server) deleteForm(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.DeleteRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.forms[request.Id]; !ok {
+		writeError(w, http.StatusNotFound, "form not found")
+		return
+	}
+	delete(s.forms, request.Id)
+	writeJSON(w, gopayamgostar.DeleteResult{CrmId: request.Id})
+}
+
+func (s *Server) createPurchase(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.CreatePurchaseRequest
+	if !decode(w, r, &request) {
+		return
+	}
+	crmId := uuid.NewString()
+
+	s.mu.Lock()
+	s.purchases[crmId] = request
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]string{"crmId": crmId})
+}
+
+func (s *Server) deletePurchase(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.DeleteRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.purchases[request.Id]; !ok {
+		writeError(w, http.StatusNotFound, "purchase not found")
+		return
+	}
+	delete(s.purchases, request.Id)
+	writeJSON(w, gopayamgostar.DeleteResult{CrmId: request.Id})
+}
+
+func (s *Server) createTask(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.CreateTaskRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	now := gopayamgostar.CustomTime{Time: time.Now().UTC()}
+	task := &gopayamgostar.TaskInfo{
+		CRMID:              uuid.NewString(),
+		CRMObjectTypeCode:  request.CRMObjectTypeCode,
+		Subject:            request.Subject,
+		Description:        value(request.Description),
+		StartDate:          customTime(request.StartDate),
+		DueDate:            customTime(request.DueDate),
+		AssignedToUserName: value(request.AssignedToUserName),
+		RelatedCRMObjectID: value(request.RelatedCRMObjectID),
+		IdentityID:         value(request.IdentityID),
+		ExtendedProperties: request.ExtendedProperties,
+		CreatDate:          now,
+		ModifyDate:         now,
+	}
+
+	s.mu.Lock()
+	s.tasks[task.CRMID] = task
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]string{"crmId": task.CRMID})
+}
+
+func (s *Server) getTask(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.GetRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, ok := s.tasks[request.ID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "task not found")
+		return
+	}
+	writeJSON(w, task)
+}
+
+func (s *Server) updateTask(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.UpdateTaskRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, ok := s.tasks[request.CrmId]
+	if !ok {
+		writeError(w, http.StatusNotFound, "task not found")
+		return
+	}
+
+	task.Subject = request.Subject
+	task.Description = value(request.Description)
+	task.StartDate = customTime(request.StartDate)
+	task.DueDate = customTime(request.DueDate)
+	task.AssignedToUserName = value(request.AssignedToUserName)
+	task.RelatedCRMObjectID = value(request.RelatedCRMObjectID)
+	if request.ExtendedProperties != nil {
+		task.ExtendedProperties = request.ExtendedProperties
+	}
+	task.ModifyDate = gopayamgostar.CustomTime{Time: time.Now().UTC()}
+
+	writeJSON(w, map[string]string{"crmId": task.CRMID})
+}
+
+func (s *Server) completeTask(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.CompleteTaskRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, ok := s.tasks[request.CrmId]
+	if !ok {
+		writeError(w, http.StatusNotFound, "task not found")
+		return
+	}
+	if task.IsCompleted {
+		writeError(w, http.StatusConflict, "task is already completed")
+		return
+	}
+
+	now := time.Now()
+	task.IsCompleted = true
+	task.CompletionDate = customTime(&now)
+	if request.Description != nil {
+		task.Description = *request.Description
+	}
+	task.ModifyDate = *task.CompletionDate
+
+	writeJSON(w, struct{}{})
+}
+
+func (s *Server) findTasks(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.FindRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	var matching []gopayamgostar.TaskInfo
+	for _, task := range s.tasks {
+		if matches(task, task.ExtendedProperties, request.Queries) {
+			matching = append(matching, *task)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(matching, func(i, j int) bool { return matching[i].CRMID < matching[j].CRMID })
+	from, to := page(len(matching), request.PageNumber, request.PageSize)
+	writeJSON(w, gopayamgostar.FindTaskResponse{Data: project(matching[from:to], request.Fields), Total: int64(len(matching))})
+}
+
+func (s *Server) createTicket(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.CreateTicketRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	now := gopayamgostar.CustomTime{Time: time.Now().UTC()}
+	ticket := &gopayamgostar.TicketInfo{
+		CRMID:              uuid.NewString(),
+		CRMObjectTypeCode:  request.CRMObjectTypeCode,
+		Subject:            request.Subject,
+		Description:        value(request.Description),
+		Status:             enums.TicketOpen,
+		Priority:           request.Priority,
+		IdentityID:         request.IdentityID,
+		AssignedToUserName: value(request.AssignedToUserName),
+		ExtendedProperties: request.ExtendedProperties,
+		CreatDate:          now,
+		ModifyDate:         now,
+	}
+
+	s.mu.Lock()
+	ticket.Number = fmt.Sprint(1000 + len(s.tickets))
+	s.tickets[ticket.CRMID] = ticket
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]string{"crmId": ticket.CRMID})
+}
+
+func (s *Server) getTicket(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.GetRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ticket, ok := s.tickets[request.ID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "ticket not found")
+		return
+	}
+	writeJSON(w, ticket)
+}
+
+func (s *Server) replyTicket(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.ReplyTicketRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ticket, ok := s.tickets[request.CrmId]
+	if !ok {
+		writeError(w, http.StatusNotFound, "ticket not found")
+		return
+	}
+	if ticket.Status == enums.TicketClosed {
+		writeError(w, http.StatusConflict, "ticket is closed")
+		return
+	}
+
+	reply := gopayamgostar.TicketReply{
+		ID:         uuid.NewString(),
+		Body:       request.Body,
+		IsPrivate:  request.IsPrivate,
+		UserName:   s.username(r),
+		CreateDate: gopayamgostar.CustomTime{Time: time.Now().UTC()},
+	}
+	ticket.Replies = append(ticket.Replies, reply)
+	ticket.Status = enums.TicketWaitingForCustomer
+	ticket.ModifyDate = reply.CreateDate
+
+	writeJSON(w, map[string]string{"crmId": reply.ID})
+}
+
+func (s *Server) closeTicket(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.CloseTicketRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ticket, ok := s.tickets[request.CrmId]
+	if !ok {
+		writeError(w, http.StatusNotFound, "ticket not found")
+		return
+	}
+	if ticket.Status == enums.TicketClosed {
+		writeError(w, http.StatusConflict, "ticket is already closed")
+		return
+	}
+
+	ticket.Status = enums.TicketClosed
+	if request.Description != nil {
+		ticket.Description = *request.Description
+	}
+	ticket.ModifyDate = gopayamgostar.CustomTime{Time: time.Now().UTC()}
+
+	writeJSON(w, struct{}{})
+}
+
+func (s *Server) findTickets(w http.ResponseWriter, r *http.Request) {
+	var request gopayamgostar.FindRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	var matching []gopayamgostar.TicketInfo
+	for _, ticket := range s.tickets {
+		if matches(ticket, ticket.ExtendedProperties, request.Queries) {
+			matching = append(matching, *ticket)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(matching, func(i, j int) bool { return matching[i].CRMID < matching[j].CRMID })
+	from, to := page(len(matching), request.PageNumber, request.PageSize)
+	writeJSON(w, gopayamgostar.FindTicketResponse{Data: project(matching[from:to], request.Fields), Total: int64(len(matching))})
+}
+
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	users := make([]gopayamgostar.User, 0, len(s.accounts))
+	for _, user := range s.accounts {
+		users = append(users, user)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	writeJSON(w, users)
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Username string `json:"username"`
+	}
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.accounts[request.Username]
+	if !ok {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	writeJSON(w, user)
+}
+
+func (s *Server) currentUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, s.accounts[s.username(r)])
+}
+
+// tagsRequest is the body of the tag endpoints
+type tagsRequest struct {
+	CRMObjectID string   `json:"crmObjectId"`
+	Tags        []string `json:"tags"`
+}
+
+// addTags, removeTags and replaceTags return the tags of a form after a change
+func addTags(current, tags []string) []string {
+	for _, tag := range tags {
+		if !slices.Contains(current, tag) {
+			current = append(current, tag)
+		}
+	}
+	return current
+}
+
+func removeTags(current, tags []string) []string {
+	kept := make([]string, 0, len(current))
+	for _, tag := range current {
+		if !slices.Contains(tags, tag) {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
+
+func replaceTags(current, tags []string) []string {
+	return addTags(nil, tags)
+}
+
+func (s *Server) changeTags(change func(current, tags []string) []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request tagsRequest
+		if !decode(w, r, &request) {
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		f, ok := s.forms[request.CRMObjectID]
+		if !ok {
+			writeError(w, http.StatusNotFound, "form not found")
+			return
+		}
+		for _, tag := range request.Tags {
+			if !s.tags[tag] {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("tag %q is not defined", tag))
+				return
+			}
+		}
+
+		current := make([]string, 0, len(f.info.Tags))
+		for _, tag := range f.info.Tags {
+			current = append(current, fmt.Sprint(tag))
+		}
+		f.info.Tags = nil
+		for _, tag := range change(current, request.Tags) {
+			f.info.Tags = append(f.info.Tags, tag)
+		}
+		f.modifyDate = time.Now()
+
+		writeJSON(w, struct{}{})
+	}
+}
+
+func (s *Server) listTags(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	tags := make([]string, 0, len(s.tags))
+	for tag := range s.tags {
+		tags = append(tags, tag)
+	}
+	s.mu.Unlock()
+
+	sort.Strings(tags)
+	writeJSON(w, tags)
+}
+
+func (s *Server) listColors(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, append([]gopayamgostar.Color{}, s.colors...))
+}
+
+// noteRequest is the body of the note endpoints
+type noteRequest struct {
+	CRMObjectID string `json:"crmObjectId"`
+	gopayamgostar.NoteRequest
+}
+
+func (s *Server) createNote(w http.ResponseWriter, r *http.Request) {
+	var request noteRequest
+	if !decode(w, r, &request) {
+		return
+	}
+	if strings.TrimSpace(request.Text) == "" {
+		writeError(w, http.StatusBadRequest, "note text is empty")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	note := gopayamgostar.Note{
+		ID:              uuid.NewString(),
+		CRMObjectID:     request.CRMObjectID,
+		Text:            request.Text,
+		IsPrivate:       request.IsPrivate,
+		CreatorUserName: s.username(r),
+		CreateDate:      gopayamgostar.CustomTime{Time: time.Now().UTC()},
+	}
+	s.notes[note.CRMObjectID] = append(s.notes[note.CRMObjectID], note)
+
+	writeJSON(w, map[string]string{"crmId": note.ID})
+}
+
+func (s *Server) listNotes(w http.ResponseWriter, r *http.Request) {
+	var request noteRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	notes := append([]gopayamgostar.Note{}, s.notes[request.CRMObjectID]...)
+	writeJSON(w, notes)
+}
+
+// matches evaluates queries against the JSON fields of object and its
+// extended properties. Field names are case insensitive.
+func matches(object interface{}, properties []gopayamgostar.ExtendedProperty, queries []gopayamgostar.Query) bool {
+	if len(queries) == 0 {
+		return true
+	}
+
+	data, _ := json.Marshal(object)
+	var raw map[string]interface{}
+	_ = json.Unmarshal(data, &raw)
+
+	fields := make(map[string]string, len(raw)+len(properties))
+	for key, value := range raw {
+		if value != nil {
+			fields[strings.ToLower(key)] = fmt.Sprint(value)
+		}
+	}
+	for _, property := range properties {
+		fields[strings.ToLower(property.UserKey)] = property.Value
+	}
+
+	return matchQueries(fields, queries)
+}
+
+// matchQueries combines the queries matched against fields, evaluating the
+// leaves of groups recursively
+func matchQueries(fields map[string]string, queries []gopayamgostar.Query) bool {
+	result := true
+	for i, query := range queries {
+		var match bool
+		if len(query.Leaves) > 0 {
+			match = matchQueries(fields, query.Leaves) != query.LeafNegate
+		} else {
+			match = matchQuery(fields[strings.ToLower(query.Field)], query)
+		}
+		switch {
+		case i == 0:
+			result = match
+		case query.LogicalOperator == int(enums.Or):
+			result = result || match
+		default:
+			result = result && match
+		}
+	}
+	return result
+}
+
+func matchQuery(value string, query gopayamgostar.Query) bool {
+	switch enums.FieldOperator(query.FieldOperator) {
+	case enums.Equals:
+		return value == query.Value
+	case enums.NotEqual:
+		return value != query.Value
+	case enums.GreateThan:
+		return value > query.Value
+	case enums.GreaterThanOrEqual:
+		return value >= query.Value
+	case enums.LessThan:
+		return value < query.Value
+	case enums.LessThanOrEqual:
+		return value <= query.Value
+	case enums.In, enums.NotIn:
+		in := false
+		for _, candidate := range strings.Split(query.Value, ",") {
+			if value == candidate {
+				in = true
+			}
+		}
+		return in == (enums.FieldOperator(query.FieldOperator) == enums.In)
+	case enums.TextContains:
+		return strings.Contains(value, query.Value)
+	case enums.TextEndsWith:
+		return strings.HasSuffix(value, query.Value)
+	}
+	return false
+}
+
+// project keeps the fields of rows and the extended properties named in
+// fields, case insensitively, like the field projection of find requests
+func project[T any](rows []T, fields []string) []T {
+	if len(fields) == 0 {
+		return rows
+	}
+	keep := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		keep[strings.ToLower(field)] = true
+	}
+
+	projected := make([]T, len(rows))
+	for i, row := range rows {
+		data, _ := json.Marshal(row)
+		var raw map[string]json.RawMessage
+		_ = json.Unmarshal(data, &raw)
+		for key, value := range raw {
+			if strings.EqualFold(key, "extendedProperties") {
+				var properties []gopayamgostar.ExtendedProperty
+				_ = json.Unmarshal(value, &properties)
+				kept := properties[:0]
+				for _, property := range properties {
+					if keep[strings.ToLower(property.UserKey)] {
+						kept = append(kept, property)
+					}
+				}
+				raw[key], _ = json.Marshal(kept)
+			} else if !keep[strings.ToLower(key)] {
+				delete(raw, key)
+			}
+		}
+		data, _ = json.Marshal(raw)
+		_ = json.Unmarshal(data, &projected[i])
+	}
+	return projected
+}
+
+// value returns the value p points to, the zero value for nil
+func value[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
+
+// customTime converts t to the CustomTime the server returns, in UTC
+func customTime(t *time.Time) *gopayamgostar.CustomTime {
+	if t == nil {
+		return nil
+	}
+	return &gopayamgostar.CustomTime{Time: t.UTC()}
+}
+
+func page(total int, pageNumber, pageSize int64) (int, int) {
+	if pageNumber < 1 {
+		pageNumber = 1
+	}
+	if pageSize < 1 {
+		return 0, total
+	}
+	from := int((pageNumber - 1) * pageSize)
+	if from > total {
+		from = total
+	}
+	to := from + int(pageSize)
+	if to > total {
+		to = total
+	}
+	return from, to
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return false
+		}
+		body = reader
+	}
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(gopayamgostar.HTTPErrorResponse{Message: message})
+}
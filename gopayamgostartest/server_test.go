@@ -0,0 +1,119 @@
+package gopayamgostartest_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	personID := server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Ali", LastName: "Rezaei"})
+	server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Sara", LastName: "Ahmadi"})
+
+	ctx := context.Background()
+	client := server.Client()
+
+	_, err := client.AdminAuthenticate(ctx, "admin", "wrong")
+	require.Error(t, err)
+	_, err = client.GetPersonInfoById(ctx, "invalid", personID)
+	var apiErr *gopayamgostar.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusUnauthorized, apiErr.Code)
+
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	accessToken := token.AccessToken
+
+	person, err := client.GetPersonInfoById(ctx, accessToken, personID)
+	require.NoError(t, err)
+	require.Equal(t, "Ali", person.FirstName)
+
+	found, err := client.FindPersonByName(ctx, accessToken, "Person", "Sara", "Ahmadi")
+	require.NoError(t, err)
+	require.Len(t, found.Data, 1)
+	require.Equal(t, int64(1), found.Total)
+
+	formID, err := client.CreateForm(ctx, accessToken, gopayamgostar.CreateFormRequest{
+		CRMObjectTypeCode:  "Order",
+		IdentityID:         personID,
+		Subject:            gopayamgostar.StringP("first order"),
+		ExtendedProperties: []gopayamgostar.ExtendedProperty{{UserKey: "City", Value: "Tehran"}},
+	})
+	require.NoError(t, err)
+
+	form, err := client.GetFormInfoById(ctx, accessToken, formID)
+	require.NoError(t, err)
+	require.Equal(t, "first order", form.Subject)
+
+	_, err = client.UpdateForm(ctx, accessToken, gopayamgostar.UpdateFormRequest{CrmId: formID, Subject: "updated", IdentityId: personID})
+	require.NoError(t, err)
+	stored, ok := server.Form(formID)
+	require.True(t, ok)
+	require.Equal(t, "updated", stored.Subject)
+
+	forms, err := client.FindForm(ctx, accessToken, "Order", []gopayamgostar.Query{
+		{Field: "City", FieldOperator: int(enums.Equals), Value: "Tehran"},
+	})
+	require.NoError(t, err)
+	require.Len(t, forms.Data, 1)
+	require.Equal(t, formID, forms.Data[0].CRMID)
+
+	require.NoError(t, client.DeleteForm(ctx, accessToken, formID))
+	_, ok = server.Form(formID)
+	require.False(t, ok)
+	require.Error(t, client.DeleteForm(ctx, accessToken, formID))
+
+	purchaseID, err := client.CreatePurchaseInvoice(ctx, accessToken, gopayamgostar.CreatePurchaseRequest{CRMObjectTypeCode: "Invoice", IdentityID: personID, FinalValue: gopayamgostar.NewMoney(1000)})
+	require.NoError(t, err)
+	purchase, ok := server.Purchase(purchaseID)
+	require.True(t, ok)
+	require.Equal(t, gopayamgostar.NewMoney(1000), purchase.FinalValue)
+	_, err = client.DeletePurchase(ctx, accessToken, purchaseID, enums.DeleteOnly)
+	require.NoError(t, err)
+	_, ok = server.Purchase(purchaseID)
+	require.False(t, ok)
+
+	createdID, err := client.CreatePerson(ctx, accessToken, gopayamgostar.CreatePersonRequest{
+		CRMObjectTypeCode: "Person",
+		FirstName:         "Reza",
+		LastName:          "Karimi",
+		PhoneContacts:     []gopayamgostar.PhoneContact{{PhoneType: enums.PhoneMobile, PhoneNumber: "09120000000", Default: true}},
+	})
+	require.NoError(t, err)
+	created, ok := server.Person(createdID)
+	require.True(t, ok)
+	require.Equal(t, "Reza", created.FirstName)
+	require.NotEmpty(t, created.PhoneContacts[0].ID)
+	require.NoError(t, client.DeletePerson(ctx, accessToken, createdID))
+	_, ok = server.Person(createdID)
+	require.False(t, ok)
+}
+
+func TestServerRefreshToken(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	personID := server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Ali"})
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	server.RevokeTokens()
+	refreshed, err := client.RefreshToken(ctx, token.RefreshToken)
+	require.NoError(t, err)
+	_, err = client.GetPersonInfoById(ctx, refreshed.AccessToken, personID)
+	require.NoError(t, err)
+
+	_, err = client.RefreshToken(ctx, token.RefreshToken)
+	require.Error(t, err, "refresh tokens are used once")
+}
@@ -0,0 +1,145 @@
+package gopayamgostar
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// TaskError is the error of a task of a Group
+type TaskError struct {
+	// Key identifies the task, as given to Group.Go
+	Key string
+	Err error
+}
+
+func (e *TaskError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Key, e.Err)
+}
+
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError is returned by Group.Wait when tasks failed. errors.Is and
+// errors.As look into the errors of all the failed tasks.
+type MultiError struct {
+	// Errors are the errors of the failed tasks, in the order they failed
+	Errors []*TaskError
+}
+
+func (e *MultiError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("%d tasks failed: %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+func (e *MultiError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// Keys returns the keys of the failed tasks
+func (e *MultiError) Keys() []string {
+	keys := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		keys = append(keys, err.Key)
+	}
+	return keys
+}
+
+// Group runs CRM calls concurrently with one access token, such as hydrating
+// a page of persons with their latest invoices. Unlike errgroup, a failing
+// task does not cancel the others unless CancelOnError is set: Wait returns
+// the errors of all the failed tasks so that the results of the others can be
+// used. The tasks share the rate limiter of the client.
+//
+//	group := client.NewGroup(ctx, accessToken, 8)
+//	persons := make([]*PersonInfo, len(ids))
+//	for i, id := range ids {
+//		group.Go(id, func(ctx context.Context, accessToken string) error {
+//			person, err := client.GetPersonInfoById(ctx, accessToken, id)
+//			persons[i] = person
+//			return err
+//		})
+//	}
+//	err := group.Wait()
+type Group struct {
+	// CancelOnError cancels the context of the tasks and skips the tasks not
+	// started yet once a task fails
+	CancelOnError bool
+
+	accessToken string
+	ctx         context.Context
+	cancel      context.CancelFunc
+	group       errgroup.Group
+
+	mu   sync.Mutex
+	errs []*TaskError
+}
+
+// NewGroup creates a group running the tasks with accessToken, at most limit
+// at once. A limit of 0 or less leaves it unbounded. Tasks must not call Go
+// on their own group when it is limited, as they could wait for themselves.
+func (g *GoPayamgostar) NewGroup(ctx context.Context, accessToken string, limit int) *Group {
+	return newGroup(ctx, accessToken, limit)
+}
+
+func newGroup(ctx context.Context, accessToken string, limit int) *Group {
+	ctx, cancel := context.WithCancel(ctx)
+	group := &Group{accessToken: accessToken, ctx: ctx, cancel: cancel}
+	if limit > 0 {
+		group.group.SetLimit(limit)
+	}
+	return group
+}
+
+// Go runs task in a new goroutine, blocking while the limit of the group is
+// reached. key identifies the task in the errors returned by Wait. Tasks not
+// started when the context of the group is done fail with its error.
+func (gr *Group) Go(key string, task func(ctx context.Context, accessToken string) error) {
+	gr.group.Go(func() error {
+		err := gr.ctx.Err()
+		if err == nil {
+			err = task(gr.ctx, gr.accessToken)
+		}
+		if err != nil {
+			gr.fail(key, err)
+		}
+		return nil
+	})
+}
+
+// Wait waits for the tasks and returns a *MultiError holding the errors of
+// the failed ones, nil when all succeeded
+func (gr *Group) Wait() error {
+	_ = gr.group.Wait()
+	gr.cancel()
+
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	if len(gr.errs) == 0 {
+		return nil
+	}
+	return &MultiError{Errors: append([]*TaskError(nil), gr.errs...)}
+}
+
+func (gr *Group) fail(key string, err error) {
+	gr.mu.Lock()
+	gr.errs = append(gr.errs, &TaskError{Key: key, Err: err})
+	gr.mu.Unlock()
+	if gr.CancelOnError {
+		gr.cancel()
+	}
+}
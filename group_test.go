@@ -0,0 +1,65 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroup(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	ids := []string{
+		server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Ali"}),
+		"missing",
+		server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Sara"}),
+	}
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	group := client.NewGroup(ctx, token.AccessToken, 2)
+	persons := make([]*gopayamgostar.PersonInfo, len(ids))
+	for i, id := range ids {
+		group.Go(id, func(ctx context.Context, accessToken string) error {
+			person, err := client.GetPersonInfoById(ctx, accessToken, id)
+			persons[i] = person
+			return err
+		})
+	}
+	err = group.Wait()
+
+	var multi *gopayamgostar.MultiError
+	require.ErrorAs(t, err, &multi)
+	require.Equal(t, []string{"missing"}, multi.Keys())
+	var apiErr *gopayamgostar.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusNotFound, apiErr.Code)
+	require.Equal(t, "Ali", persons[0].FirstName)
+	require.Equal(t, "Sara", persons[2].FirstName)
+
+	failed := errors.New("failed")
+	var started int32
+	group = client.NewGroup(ctx, token.AccessToken, 1)
+	group.CancelOnError = true
+	for _, key := range []string{"a", "b", "c"} {
+		group.Go(key, func(ctx context.Context, accessToken string) error {
+			atomic.AddInt32(&started, 1)
+			return failed
+		})
+	}
+	err = group.Wait()
+	require.ErrorIs(t, err, failed)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, int32(1), atomic.LoadInt32(&started))
+	require.NoError(t, client.NewGroup(ctx, token.AccessToken, 0).Wait())
+}
@@ -0,0 +1,99 @@
+package gopayamgostar_test
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+)
+
+func TestWithGzipRequests(t *testing.T) {
+	type received struct {
+		encoding string
+		body     string
+	}
+	var (
+		mu       sync.Mutex
+		requests []received
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			reader, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = reader
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, received{encoding: r.Header.Get("Content-Encoding"), body: string(data)})
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"crmId":"form-1"}`))
+	}))
+	defer server.Close()
+
+	large := gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Complaint"}
+	for i := 0; i < 100; i++ {
+		large.ExtendedProperties = append(large.ExtendedProperties, gopayamgostar.ExtendedProperty{UserKey: "Field" + strconv.Itoa(i), Value: "value"})
+	}
+
+	var hookBodies []string
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithGzipRequests())
+	client.RegisterRequestHook(func(info gopayamgostar.HookInfo, req *http.Request) error {
+		data, err := io.ReadAll(req.Body)
+		req.Body = io.NopCloser(strings.NewReader(string(data)))
+		hookBodies = append(hookBodies, string(data))
+		return err
+	})
+	ctx := context.Background()
+	_, err := client.CreateForm(ctx, "token", large)
+	require.NoError(t, err)
+	_, err = client.CreateForm(ctx, "token", gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Complaint"})
+	require.NoError(t, err)
+
+	streaming := gopayamgostar.NewClient(server.URL, gopayamgostar.WithGzipRequests(), gopayamgostar.WithStreamingRequestBodies())
+	_, err = streaming.CreateForm(ctx, "token", large)
+	require.NoError(t, err)
+
+	require.Len(t, requests, 3)
+	require.Equal(t, "gzip", requests[0].encoding)
+	require.Contains(t, requests[0].body, `"userKey":"Field99"`)
+	require.Contains(t, hookBodies[0], `"userKey":"Field99"`, "hooks see the uncompressed body")
+	require.Empty(t, requests[1].encoding, "small bodies are sent as they are")
+	require.Equal(t, "gzip", requests[2].encoding)
+	require.JSONEq(t, requests[0].body, requests[2].body)
+}
+
+func TestWithGzipRequestsFakeServer(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+
+	ctx := context.Background()
+	client := server.Client(gopayamgostar.WithGzipRequests())
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	subject := strings.Repeat("late delivery ", 200)
+	id, err := client.CreateForm(ctx, token.AccessToken, gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Complaint", Subject: &subject})
+	require.NoError(t, err)
+	form, ok := server.Form(id)
+	require.True(t, ok)
+	require.Equal(t, subject, form.Subject)
+}
@@ -0,0 +1,36 @@
+package gopayamgostar
+
+import (
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// redactedHeaderValue replaces the values of static headers in debug logs
+const redactedHeaderValue = "[REDACTED]"
+
+// WithStaticHeaders sends headers with every request of the client, the
+// authentication requests included, such as the license or tenant key some
+// installations require. Their values are redacted in the request logs of
+// WithDebug.
+func WithStaticHeaders(headers map[string]string) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		g.restyClient.SetHeaders(headers)
+
+		if g.redactedHeaders == nil {
+			g.redactedHeaders = map[string]bool{}
+			redacted := g.redactedHeaders
+			g.restyClient.OnRequestLog(func(log *resty.RequestLog) error {
+				for name := range log.Header {
+					if redacted[name] {
+						log.Header.Set(name, redactedHeaderValue)
+					}
+				}
+				return nil
+			})
+		}
+		for name := range headers {
+			g.redactedHeaders[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package gopayamgostar
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// HookInfo describes the attempt a request or response hook runs for
+type HookInfo struct {
+	// Endpoint is the name of the Config endpoint, e.g. "CreateForm", or
+	// "other" for paths that are not a Config endpoint
+	Endpoint string
+	// Attempt is 1 for the first attempt and counts up with every retry
+	Attempt int
+	// Duration is the time the attempt took, set for response hooks only
+	Duration time.Duration
+	// Err is the transport error of the attempt, set for response hooks only
+	// when no response was received
+	Err error
+}
+
+// RequestHook runs before an attempt is sent and may change req, e.g. to add
+// headers or sign it; req.GetBody returns a copy of the body. An error fails
+// the attempt like a transport error.
+type RequestHook func(info HookInfo, req *http.Request) error
+
+// ResponseHook runs after an attempt with its response, or with a nil resp
+// and info.Err set when it failed. The hook must not read or close resp.Body.
+type ResponseHook func(info HookInfo, req *http.Request, resp *http.Response)
+
+var attemptContextKey = contextKey("attempt")
+
+// clientHooks holds the hooks registered on a client
+type clientHooks struct {
+	mu       sync.RWMutex
+	request  []RequestHook
+	response []ResponseHook
+}
+
+func (h *clientHooks) snapshot() ([]RequestHook, []ResponseHook) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.request, h.response
+}
+
+// hooksTransport runs the registered hooks around every attempt sent through
+// next and captures the server version of the responses
+type hooksTransport struct {
+	next   http.RoundTripper
+	client *GoPayamgostar
+}
+
+func (t *hooksTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestHooks, responseHooks := t.client.hooks.snapshot()
+	if len(requestHooks) == 0 && len(responseHooks) == 0 {
+		resp, err := t.send(req)
+		t.client.serverInfo.observe(resp)
+		return resp, err
+	}
+
+	attempt, _ := req.Context().Value(attemptContextKey).(int)
+	info := HookInfo{
+		Endpoint: t.client.endpointName(req.URL.Path),
+		Attempt:  attempt,
+	}
+	for _, hook := range requestHooks {
+		if err := hook(info, req); err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.send(req)
+	info.Duration = time.Since(start)
+	t.client.serverInfo.observe(resp)
+	info.Err = err
+	for _, hook := range responseHooks {
+		hook(info, req, resp)
+	}
+
+	return resp, err
+}
+
+// send sends req through next, compressed with WithGzipRequests, unless
+// it is a change skipped by a dry run
+func (t *hooksTransport) send(req *http.Request) (*http.Response, error) {
+	if t.client.skipped(req) {
+		return t.client.dryRunResponse(req)
+	}
+	if t.client.gzipRequests {
+		compressed, err := gzipRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		req = compressed
+	}
+	return t.next.RoundTrip(req)
+}
+
+// installHooks routes the requests of the client through the registered hooks
+func (g *GoPayamgostar) installHooks() {
+	g.hooks = &clientHooks{}
+
+	httpClient := g.restyClient.GetClient()
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	g.transport = &hooksTransport{next: next, client: g}
+	httpClient.Transport = g.transport
+
+	g.restyClient.OnBeforeRequest(func(c *resty.Client, r *resty.Request) error {
+		r.SetContext(context.WithValue(r.Context(), attemptContextKey, r.Attempt))
+		return nil
+	})
+}
+
+// RegisterRequestHook adds a hook that runs before every attempt the client
+// sends, after the hooks registered before it. Hooks see the request as it
+// goes out, so they also apply to the requests of other client options such
+// as WithRetry. A client set with SetRestyClient does not run hooks.
+func (g *GoPayamgostar) RegisterRequestHook(hook RequestHook) {
+	g.hooks.mu.Lock()
+	defer g.hooks.mu.Unlock()
+	// always copy so that snapshots taken by requests in flight stay intact
+	g.hooks.request = append(g.hooks.request[:len(g.hooks.request):len(g.hooks.request)], hook)
+}
+
+// RegisterResponseHook adds a hook that runs after every attempt the client
+// sends, after the hooks registered before it
+func (g *GoPayamgostar) RegisterResponseHook(hook ResponseHook) {
+	g.hooks.mu.Lock()
+	defer g.hooks.mu.Unlock()
+	g.hooks.response = append(g.hooks.response[:len(g.hooks.response):len(g.hooks.response)], hook)
+}
@@ -0,0 +1,95 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+)
+
+func TestRequestResponseHooks(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Signature") != "signed:"+string(body) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithRetry(1, time.Millisecond, time.Millisecond, nil))
+
+	var (
+		mu        sync.Mutex
+		requests  []gopayamgostar.HookInfo
+		responses []gopayamgostar.HookInfo
+		statuses  []int
+	)
+	client.RegisterRequestHook(func(info gopayamgostar.HookInfo, req *http.Request) error {
+		body, err := req.GetBody()
+		require.NoError(t, err)
+		data, err := io.ReadAll(body)
+		require.NoError(t, err)
+		req.Header.Set("X-Signature", "signed:"+string(data))
+
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, info)
+		return nil
+	})
+	client.RegisterResponseHook(func(info gopayamgostar.HookInfo, req *http.Request, resp *http.Response) {
+		mu.Lock()
+		defer mu.Unlock()
+		responses = append(responses, info)
+		statuses = append(statuses, resp.StatusCode)
+	})
+
+	require.NoError(t, client.DeleteForm(context.Background(), "token", "form-1"))
+
+	require.Len(t, requests, 2)
+	require.Equal(t, "DeleteForm", requests[0].Endpoint)
+	require.Equal(t, 1, requests[0].Attempt)
+	require.Equal(t, 2, requests[1].Attempt)
+	require.Equal(t, []int{http.StatusServiceUnavailable, http.StatusOK}, statuses)
+	require.NoError(t, responses[1].Err)
+	require.Equal(t, 2, responses[1].Attempt)
+}
+
+func TestRequestHookError(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request must not reach the server")
+	}))
+	defer server.Close()
+
+	denied := errors.New("denied by audit")
+	client := gopayamgostar.NewClient(server.URL)
+	client.RegisterRequestHook(func(info gopayamgostar.HookInfo, req *http.Request) error {
+		return denied
+	})
+
+	var hookErr error
+	client.RegisterResponseHook(func(info gopayamgostar.HookInfo, req *http.Request, resp *http.Response) {
+		hookErr = info.Err
+	})
+
+	err := client.DeleteForm(context.Background(), "token", "form-1")
+	require.ErrorIs(t, err, denied)
+	require.NoError(t, hookErr, "response hooks do not run for attempts that were not sent")
+}
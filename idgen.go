@@ -0,0 +1,43 @@
+package gopayamgostar
+
+import (
+	"github.com/google/uuid"
+)
+
+const (
+	// correlationIDHeader carries a fresh ID on every request
+	correlationIDHeader = "X-Correlation-ID"
+	// idempotencyKeyHeader is set once per create call, so every retry
+	// attempt of the call sends the same key
+	idempotencyKeyHeader = "Idempotency-Key"
+)
+
+// IDGenerator creates the identifiers the client sends to the CRM: login
+// DeviceIds, per request correlation IDs and idempotency keys of create calls.
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDGeneratorFunc adapts a function to IDGenerator
+type IDGeneratorFunc func() string
+
+// NewID implements IDGenerator
+func (f IDGeneratorFunc) NewID() string {
+	return f()
+}
+
+// UUIDGenerator generates random UUIDs, it is the default IDGenerator
+type UUIDGenerator struct{}
+
+// NewID implements IDGenerator
+func (UUIDGenerator) NewID() string {
+	return uuid.NewString()
+}
+
+// WithIDGenerator replaces the default UUID generator, e.g. to make IDs
+// deterministic in tests or to embed node identifiers.
+func WithIDGenerator(generator IDGenerator) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		g.idGenerator = generator
+	}
+}
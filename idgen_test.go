@@ -0,0 +1,48 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDGenerator(t *testing.T) {
+	var headers []http.Header
+	var deviceID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Clone())
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/v2/auth/login" {
+			var request gopayamgostar.AuthRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			deviceID = request.DeviceId
+			_, _ = w.Write([]byte(`{"accessToken":"token"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"crmId":"d81d07dd-cdc2-479a-99d5-0270a1f8f07d"}`))
+	}))
+	defer server.Close()
+
+	next := 0
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithIDGenerator(gopayamgostar.IDGeneratorFunc(func() string {
+		next++
+		return fmt.Sprintf("id-%d", next)
+	})))
+
+	_, err := client.AdminAuthenticate(context.Background(), "webservice", "secret")
+	require.NoError(t, err)
+	_, err = client.CreateForm(context.Background(), "token", gopayamgostar.CreateFormRequest{})
+	require.NoError(t, err)
+
+	require.Equal(t, "id-2", deviceID)
+	require.Equal(t, "id-1", headers[0].Get("X-Correlation-ID"))
+	require.Empty(t, headers[0].Get("Idempotency-Key"))
+	require.Equal(t, "id-3", headers[1].Get("X-Correlation-ID"))
+	require.Equal(t, "id-4", headers[1].Get("Idempotency-Key"))
+}
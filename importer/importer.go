@@ -0,0 +1,332 @@
+// Package importer creates persons and forms from the rows of a CSV file,
+// such as a sheet exported from Excel, for migrating contacts and records
+// from another system. A declarative Mapping tells which column fills which
+// request field or extended property:
+//
+//	im := importer.New(client, importer.Mapping{
+//		TypeCode:   "Person",
+//		Fields:     map[string]string{"نام": "FirstName", "نام خانوادگی": "LastName"},
+//		Properties: map[string]string{"شهر": "City"},
+//		Phones:     []string{"موبایل", "تلفن"},
+//		Required:   []string{"نام خانوادگی"},
+//	})
+//	report, err := im.ImportPersons(ctx, accessToken, file)
+//
+// Rows failing validation are reported with their line and not sent; the
+// others are created with the bulk helpers of the client.
+package importer
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/pkg/errors"
+)
+
+// utf8BOM starts the CSV files saved by Excel
+const utf8BOM = "\ufeff"
+
+// Mapping maps the columns of a CSV file, by header, to the requests
+type Mapping struct {
+	// TypeCode is the crm object type code of the created objects
+	TypeCode string
+	// Fields maps headers to the fields of the request, by Go field name,
+	// e.g. "FirstName" of CreatePersonRequest or "Subject" of CreateFormRequest.
+	// String, integer, float and boolean fields are supported.
+	Fields map[string]string
+	// Properties maps headers to the user keys of extended properties
+	Properties map[string]string
+	// Phones are the headers of the phone numbers of a person, the first one
+	// filled being the default
+	Phones []string
+	// Required are the headers of the columns that must not be empty
+	Required []string
+	// Validate checks a row by header after the other checks, optional
+	Validate func(row map[string]string) error
+}
+
+// LineError is the error of a row
+type LineError struct {
+	// Line is the line of the row in the file, the header being line 1
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Created is an object created from a row
+type Created struct {
+	Line  int
+	CrmId string
+}
+
+// Report is the outcome of an import
+type Report struct {
+	Created []Created
+	// Failed holds the rows that failed validation or creation, by line
+	Failed []*LineError
+}
+
+// Importer creates objects from CSV files
+type Importer struct {
+	client  gopayamgostar.GoPayamgostarIface
+	mapping Mapping
+
+	// Bulk configures the concurrency of the creations
+	Bulk gopayamgostar.BulkOptions
+	// Comma is the field delimiter, ',' by default
+	Comma rune
+}
+
+// New creates an importer creating objects through client as mapping says
+func New(client gopayamgostar.GoPayamgostarIface, mapping Mapping) *Importer {
+	return &Importer{client: client, mapping: mapping}
+}
+
+// ImportPersons creates a person per row of r. An error is only returned
+// when the file cannot be read or lacks a mapped column; the rows that
+// failed are in Report.Failed.
+func (im *Importer) ImportPersons(ctx context.Context, accessToken string, r io.Reader) (*Report, error) {
+	return importRows(ctx, im, r, func(row map[string]string) (gopayamgostar.CreatePersonRequest, error) {
+		request := gopayamgostar.CreatePersonRequest{CRMObjectTypeCode: im.mapping.TypeCode}
+		if err := im.fill(&request, row); err != nil {
+			return request, err
+		}
+		request.ExtendedProperties = im.properties(row)
+		for _, header := range im.mapping.Phones {
+			if number := row[header]; number != "" {
+				request.PhoneContacts = append(request.PhoneContacts, gopayamgostar.PhoneContact{
+					PhoneNumber: number,
+					Default:     len(request.PhoneContacts) == 0,
+				})
+			}
+		}
+		return request, nil
+	}, func(requests []gopayamgostar.CreatePersonRequest) ([]gopayamgostar.BulkResult, error) {
+		return im.client.CreatePersons(ctx, accessToken, requests, im.Bulk)
+	})
+}
+
+// ImportForms creates a form per row of r, like ImportPersons
+func (im *Importer) ImportForms(ctx context.Context, accessToken string, r io.Reader) (*Report, error) {
+	if len(im.mapping.Phones) > 0 {
+		return nil, errors.New("forms have no phones")
+	}
+	return importRows(ctx, im, r, func(row map[string]string) (gopayamgostar.CreateFormRequest, error) {
+		request := gopayamgostar.CreateFormRequest{CRMObjectTypeCode: im.mapping.TypeCode}
+		if err := im.fill(&request, row); err != nil {
+			return request, err
+		}
+		request.ExtendedProperties = im.properties(row)
+		return request, nil
+	}, func(requests []gopayamgostar.CreateFormRequest) ([]gopayamgostar.BulkResult, error) {
+		return im.client.BulkCreateForms(ctx, accessToken, requests, im.Bulk)
+	})
+}
+
+func importRows[T any](ctx context.Context, im *Importer, r io.Reader, build func(row map[string]string) (T, error), create func([]T) ([]gopayamgostar.BulkResult, error)) (*Report, error) {
+	reader := csv.NewReader(r)
+	if im.Comma != 0 {
+		reader.Comma = im.Comma
+	}
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the header")
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], utf8BOM)
+	}
+	if err := im.checkHeader(header); err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	var requests []T
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return report, errors.Wrap(err, "could not read the file")
+			}
+			report.Failed = append(report.Failed, &LineError{Line: parseErr.StartLine, Err: parseErr.Err})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		row := make(map[string]string, len(header))
+		for i, value := range record {
+			if i < len(header) {
+				row[header[i]] = strings.TrimSpace(value)
+			}
+		}
+		request, err := checkRow(im, row, build)
+		if err != nil {
+			report.Failed = append(report.Failed, &LineError{Line: line, Err: err})
+			continue
+		}
+		requests = append(requests, request)
+		lines = append(lines, line)
+	}
+
+	if len(requests) == 0 {
+		return report, nil
+	}
+	results, _ := create(requests)
+	for i, result := range results {
+		if result.Err != nil {
+			report.Failed = append(report.Failed, &LineError{Line: lines[i], Err: result.Err})
+			continue
+		}
+		report.Created = append(report.Created, Created{Line: lines[i], CrmId: result.CrmId})
+	}
+	return report, ctx.Err()
+}
+
+// checkHeader verifies that the file has every mapped column
+func (im *Importer) checkHeader(header []string) error {
+	present := map[string]bool{}
+	for _, name := range header {
+		present[name] = true
+	}
+	var missing []string
+	for _, name := range im.headers() {
+		if !present[name] {
+			missing = append(missing, name)
+			present[name] = true
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("missing columns %q", missing)
+	}
+	return nil
+}
+
+// headers returns the headers the mapping refers to
+func (im *Importer) headers() []string {
+	var headers []string
+	headers = append(headers, sortedKeys(im.mapping.Fields)...)
+	headers = append(headers, sortedKeys(im.mapping.Properties)...)
+	headers = append(headers, im.mapping.Phones...)
+	return append(headers, im.mapping.Required...)
+}
+
+// checkRow validates row and builds its request
+func checkRow[T any](im *Importer, row map[string]string, build func(row map[string]string) (T, error)) (T, error) {
+	for _, name := range im.mapping.Required {
+		if row[name] == "" {
+			var zero T
+			return zero, errors.Errorf("%s is empty", name)
+		}
+	}
+	request, err := build(row)
+	if err != nil {
+		return request, err
+	}
+	if person, ok := any(request).(gopayamgostar.CreatePersonRequest); ok {
+		if err := gopayamgostar.ValidatePhoneContacts(person.PhoneContacts); err != nil {
+			return request, err
+		}
+	}
+	if im.mapping.Validate != nil {
+		if err := im.mapping.Validate(row); err != nil {
+			return request, err
+		}
+	}
+	return request, nil
+}
+
+// fill sets the mapped fields of request, a pointer to a struct
+func (im *Importer) fill(request interface{}, row map[string]string) error {
+	target := reflect.ValueOf(request).Elem()
+	for _, header := range sortedKeys(im.mapping.Fields) {
+		value := row[header]
+		if value == "" {
+			continue
+		}
+		name := im.mapping.Fields[header]
+		field := target.FieldByNameFunc(func(n string) bool { return strings.EqualFold(n, name) })
+		if !field.IsValid() {
+			return errors.Errorf("%s has no field %s", target.Type().Name(), name)
+		}
+		if err := set(field, value); err != nil {
+			return errors.Wrapf(err, "%s", header)
+		}
+	}
+	return nil
+}
+
+// set parses value into field
+func set(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Pointer {
+		pointer := reflect.New(field.Type().Elem())
+		if err := set(pointer.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(pointer)
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.Errorf("invalid integer %q", value)
+		}
+		field.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.Errorf("invalid number %q", value)
+		}
+		field.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.Errorf("invalid boolean %q", value)
+		}
+		field.SetBool(b)
+	default:
+		return errors.Errorf("fields of type %s cannot be imported", field.Type())
+	}
+	return nil
+}
+
+// properties returns the mapped extended properties of row that are filled
+func (im *Importer) properties(row map[string]string) []gopayamgostar.ExtendedProperty {
+	var properties []gopayamgostar.ExtendedProperty
+	for _, header := range sortedKeys(im.mapping.Properties) {
+		if value := row[header]; value != "" {
+			properties = append(properties, gopayamgostar.ExtendedProperty{UserKey: im.mapping.Properties[header], Value: value})
+		}
+	}
+	return properties
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,102 @@
+package importer_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/erfandiakoo/gopayamgostar/v2/importer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportPersons(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	im := importer.New(client, importer.Mapping{
+		TypeCode:   "Person",
+		Fields:     map[string]string{"first": "FirstName", "last": "LastName", "mail": "email"},
+		Properties: map[string]string{"city": "City"},
+		Phones:     []string{"mobile", "phone"},
+		Required:   []string{"last"},
+		Validate: func(row map[string]string) error {
+			if row["mail"] != "" && !strings.Contains(row["mail"], "@") {
+				return errors.New("invalid email")
+			}
+			return nil
+		},
+	})
+	im.Bulk.Concurrency = 2
+
+	file := "\ufefffirst,last,mail,city,mobile,phone\n" +
+		"Ali,Rezaei,ali@example.com,Tehran,,02100000000\n" +
+		"Sara,,sara@example.com,,,\n" +
+		"\"Reza\n Karimi\",Karimi,not-an-email,,,\n" +
+		"Mina,Ahmadi,,Shiraz,09120000000,02100000001\n" +
+		"too,few\n"
+	report, err := im.ImportPersons(ctx, token.AccessToken, strings.NewReader(file))
+	require.NoError(t, err)
+
+	require.Len(t, report.Created, 2)
+	require.Equal(t, 2, report.Created[0].Line)
+	ali, ok := server.Person(report.Created[0].CrmId)
+	require.True(t, ok)
+	require.Equal(t, "Ali", ali.FirstName)
+	require.Equal(t, "ali@example.com", ali.Email)
+	require.Equal(t, "Person", ali.CRMObjectTypeCode)
+	require.Equal(t, []gopayamgostar.ExtendedProperty{{UserKey: "City", Value: "Tehran"}}, ali.ExtendedProperties)
+	require.Len(t, ali.PhoneContacts, 1)
+	require.True(t, ali.PhoneContacts[0].Default)
+
+	require.Equal(t, 6, report.Created[1].Line)
+	mina, ok := server.Person(report.Created[1].CrmId)
+	require.True(t, ok)
+	require.Len(t, mina.PhoneContacts, 2)
+	require.Equal(t, "09120000000", mina.PhoneContacts[0].PhoneNumber)
+
+	require.Len(t, report.Failed, 3)
+	require.Equal(t, "line 3: last is empty", report.Failed[0].Error())
+	require.Equal(t, 4, report.Failed[1].Line, "rows spanning lines are reported at their first")
+	require.ErrorContains(t, report.Failed[1], "invalid email")
+	require.Equal(t, 7, report.Failed[2].Line)
+
+	_, err = im.ImportPersons(ctx, token.AccessToken, strings.NewReader("first,last\nAli,Rezaei\n"))
+	require.ErrorContains(t, err, `missing columns ["mail" "city" "mobile" "phone"]`)
+}
+
+func TestImportForms(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	im := importer.New(client, importer.Mapping{
+		TypeCode:   "Complaint",
+		Fields:     map[string]string{"subject": "Subject", "color": "ColorID"},
+		Properties: map[string]string{"amount": "Amount"},
+	})
+	im.Comma = ';'
+
+	report, err := im.ImportForms(ctx, token.AccessToken, strings.NewReader("subject;color;amount\nlate;2;1000\nbroken;red;\n"))
+	require.NoError(t, err)
+	require.Len(t, report.Created, 1)
+	form, ok := server.Form(report.Created[0].CrmId)
+	require.True(t, ok)
+	require.Equal(t, "late", form.Subject)
+	require.Equal(t, "Complaint", form.CRMObjectTypeCode)
+	require.Len(t, report.Failed, 1)
+	require.Equal(t, `line 3: color: invalid integer "red"`, report.Failed[0].Error())
+}
@@ -0,0 +1,237 @@
+// Package indexer feeds CRM objects into a search engine such as
+// Elasticsearch or Meilisearch. Objects are mapped to flat documents, indexed
+// in full once and then kept up to date by polling for modified objects.
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultPageSize = 100
+	defaultInterval = time.Minute
+
+	// modifyDateLayout is the layout the find API expects for date queries
+	modifyDateLayout = "2006-01-02T15:04:05"
+)
+
+// DefaultFields are the object fields kept when ObjectType.Fields is empty
+var DefaultFields = []string{"crmId", "refId", "subject", "description", "crmObjectTypeCode", "identityId", "stageId", "creatDate", "modifyDate"}
+
+// Document is a search document. Its "id" is the crm id of the object.
+type Document map[string]interface{}
+
+// Sink writes documents to a search engine. Upsert must replace documents with
+// the same id so that objects seen more than once are indexed only once.
+type Sink interface {
+	Upsert(ctx context.Context, index string, docs []Document) error
+}
+
+// ObjectType selects an object type to index and how its objects are mapped
+type ObjectType struct {
+	// TypeKey is the crm object type to index
+	TypeKey string
+	// Index is the search index the documents are written to, TypeKey by default
+	Index string
+	// Fields are the JSON names of the object fields to keep, DefaultFields by default
+	Fields []string
+	// Properties are the user keys of the extended properties to keep. Nil keeps
+	// all of them. Extended properties are flattened into top level fields.
+	Properties []string
+}
+
+func (t ObjectType) index() string {
+	if t.Index != "" {
+		return t.Index
+	}
+	return t.TypeKey
+}
+
+// Indexer indexes a set of object types into a Sink
+type Indexer struct {
+	client *gopayamgostar.GoPayamgostar
+	sink   Sink
+	types  []ObjectType
+
+	// PageSize is the number of objects fetched and indexed per request
+	PageSize int64
+	// Interval is the time between two incremental syncs of Run
+	Interval time.Duration
+
+	mu sync.Mutex
+	// checkpoints hold the latest modify date indexed per type key
+	checkpoints map[string]time.Time
+}
+
+// New creates an indexer for types on top of client
+func New(client *gopayamgostar.GoPayamgostar, sink Sink, types ...ObjectType) *Indexer {
+	return &Indexer{
+		client:      client,
+		sink:        sink,
+		types:       types,
+		PageSize:    defaultPageSize,
+		Interval:    defaultInterval,
+		checkpoints: map[string]time.Time{},
+	}
+}
+
+// FullIndex indexes every object of every type
+func (ix *Indexer) FullIndex(ctx context.Context, accessToken string) error {
+	for _, objectType := range ix.types {
+		if err := ix.indexType(ctx, accessToken, objectType, time.Time{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sync indexes the objects modified since the last FullIndex or Sync. Types
+// that were never indexed are indexed in full.
+func (ix *Indexer) Sync(ctx context.Context, accessToken string) error {
+	for _, objectType := range ix.types {
+		if err := ix.indexType(ctx, accessToken, objectType, ix.checkpoint(objectType.TypeKey)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run indexes every object and then syncs modified objects every Interval
+// until ctx is done
+func (ix *Indexer) Run(ctx context.Context, accessToken string) error {
+	if err := ix.FullIndex(ctx, accessToken); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(ix.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := ix.Sync(ctx, accessToken); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Checkpoint returns the latest modify date indexed for typeKey
+func (ix *Indexer) Checkpoint(typeKey string) time.Time {
+	return ix.checkpoint(typeKey)
+}
+
+func (ix *Indexer) checkpoint(typeKey string) time.Time {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.checkpoints[typeKey]
+}
+
+func (ix *Indexer) advance(typeKey string, modified time.Time) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if modified.After(ix.checkpoints[typeKey]) {
+		ix.checkpoints[typeKey] = modified
+	}
+}
+
+func (ix *Indexer) indexType(ctx context.Context, accessToken string, objectType ObjectType, since time.Time) error {
+	var queries []gopayamgostar.Query
+	if !since.IsZero() {
+		// objects modified at the checkpoint itself are fetched again since
+		// the API compares dates with a one second precision
+		queries = append(queries, gopayamgostar.Query{
+			LogicalOperator: int(enums.And),
+			FieldOperator:   int(enums.GreaterThanOrEqual),
+			Field:           "ModifyDate",
+			Value:           since.Format(modifyDateLayout),
+		})
+	}
+
+	var seen int64
+	for page := int64(1); ; page++ {
+		result, err := ix.client.FindFormPage(ctx, accessToken, objectType.TypeKey, queries, page, ix.PageSize)
+		if err != nil {
+			return errors.Wrapf(err, "could not fetch %s", objectType.TypeKey)
+		}
+
+		docs := make([]Document, 0, len(result.Data))
+		var modified time.Time
+		for _, object := range result.Data {
+			doc, err := objectType.Map(object)
+			if err != nil {
+				return err
+			}
+			docs = append(docs, doc)
+			if object.ModifyDate.After(modified) {
+				modified = object.ModifyDate.Time
+			}
+		}
+
+		if len(docs) > 0 {
+			if err := ix.sink.Upsert(ctx, objectType.index(), docs); err != nil {
+				return errors.Wrapf(err, "could not index %s", objectType.TypeKey)
+			}
+			ix.advance(objectType.TypeKey, modified)
+		}
+
+		seen += int64(len(result.Data))
+		if int64(len(result.Data)) < ix.PageSize || seen >= result.Total {
+			return nil
+		}
+	}
+}
+
+// Map converts an object into a document holding the selected fields and the
+// flattened extended properties
+func (t ObjectType) Map(object gopayamgostar.FormResponse) (Document, error) {
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not map object")
+	}
+	var all map[string]interface{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, errors.Wrap(err, "could not map object")
+	}
+
+	fields := t.Fields
+	if len(fields) == 0 {
+		fields = DefaultFields
+	}
+
+	doc := Document{"id": object.CRMID}
+	for _, field := range fields {
+		if value, ok := all[field]; ok {
+			doc[field] = value
+		}
+	}
+
+	var keep map[string]bool
+	if t.Properties != nil {
+		keep = make(map[string]bool, len(t.Properties))
+		for _, key := range t.Properties {
+			keep[key] = true
+		}
+	}
+	for _, property := range object.ExtendedProperties {
+		if keep != nil && !keep[property.UserKey] {
+			continue
+		}
+		if _, ok := doc[property.UserKey]; ok {
+			// never let a property shadow an object field
+			continue
+		}
+		doc[property.UserKey] = property.Value
+	}
+
+	return doc, nil
+}
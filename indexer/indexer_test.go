@@ -0,0 +1,93 @@
+package indexer_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/indexer"
+	"github.com/stretchr/testify/require"
+)
+
+type memorySink map[string]map[string]indexer.Document
+
+func (s memorySink) Upsert(ctx context.Context, index string, docs []indexer.Document) error {
+	if s[index] == nil {
+		s[index] = map[string]indexer.Document{}
+	}
+	for _, doc := range docs {
+		s[index][doc["id"].(string)] = doc
+	}
+	return nil
+}
+
+func TestMap(t *testing.T) {
+	objectType := indexer.ObjectType{
+		TypeKey:    "Order",
+		Fields:     []string{"subject"},
+		Properties: []string{"City", "subject"},
+	}
+
+	doc, err := objectType.Map(gopayamgostar.FormResponse{
+		CRMID:   "1",
+		Subject: "first order",
+		ExtendedProperties: []gopayamgostar.ExtendedProperty{
+			{UserKey: "City", Value: "Tehran"},
+			{UserKey: "Secret", Value: "hidden"},
+			{UserKey: "subject", Value: "shadow"},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, indexer.Document{"id": "1", "subject": "first order", "City": "Tehran"}, doc)
+}
+
+func TestFullIndexAndSync(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	objects := make([]gopayamgostar.FormResponse, 5)
+	for i := range objects {
+		objects[i] = gopayamgostar.FormResponse{
+			CRMID:      fmt.Sprintf("order-%d", i),
+			ModifyDate: gopayamgostar.CustomTime{Time: modified.Add(time.Duration(i) * time.Minute)},
+		}
+	}
+
+	var requests []gopayamgostar.FindRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request gopayamgostar.FindRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		requests = append(requests, request)
+
+		matching := objects
+		if len(request.Queries) > 0 {
+			matching = objects[len(objects)-1:]
+		}
+		start := int((request.PageNumber - 1) * request.PageSize)
+		end := start + int(request.PageSize)
+		if end > len(matching) {
+			end = len(matching)
+		}
+		body, _ := json.Marshal(gopayamgostar.FindFormResponse{Data: matching[start:end], Total: int64(len(matching))})
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	sink := memorySink{}
+	ix := indexer.New(gopayamgostar.NewClient(server.URL), sink, indexer.ObjectType{TypeKey: "Order", Index: "orders"})
+	ix.PageSize = 2
+
+	require.NoError(t, ix.FullIndex(context.Background(), "token"))
+	require.Len(t, sink["orders"], 5)
+	require.Len(t, requests, 3)
+	require.Equal(t, modified.Add(4*time.Minute), ix.Checkpoint("Order"))
+
+	require.NoError(t, ix.Sync(context.Background(), "token"))
+	require.Len(t, requests, 4)
+	require.Equal(t, "ModifyDate", requests[3].Queries[0].Field)
+	require.Equal(t, "2024-05-01T10:04:00", requests[3].Queries[0].Value)
+	require.Len(t, sink["orders"], 5)
+}
@@ -0,0 +1,172 @@
+package gopayamgostar
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ManifestFileName is the name of the manifest GenerateInvoicePDFs writes
+// next to the rendered files
+const ManifestFileName = "manifest.json"
+
+const (
+	invoicePDFAttempts  = 3
+	invoicePDFRetryWait = time.Second
+)
+
+// InvoicePDFResult is the outcome of rendering one invoice
+type InvoicePDFResult struct {
+	CrmId    string `json:"crmId"`
+	Path     string `json:"path,omitempty"`
+	Bytes    int64  `json:"bytes,omitempty"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+}
+
+// InvoicePDFManifest lists the invoices GenerateInvoicePDFs rendered and the
+// ones it gave up on, in the order of the requested crm ids
+type InvoicePDFManifest struct {
+	Succeeded []InvoicePDFResult `json:"succeeded"`
+	Failed    []InvoicePDFResult `json:"failed"`
+}
+
+// GenerateInvoicePDFs renders the invoices crmIds with RenderFormPDF into
+// dir/<crmId>.pdf using up to workers concurrent requests. Each invoice is
+// tried up to three times unless the server rejects it with a 4xx status;
+// files are written to a temporary name first so a failed run never leaves a
+// truncated PDF behind. A failing invoice does not stop the others: it is
+// listed in the Failed part of the manifest, which is also written to
+// dir/manifest.json. An error is only returned when dir or the manifest
+// cannot be written or ctx is done, together with the manifest so far.
+func (g *GoPayamgostar) GenerateInvoicePDFs(ctx context.Context, accessToken string, crmIds []string, dir string, workers int) (*InvoicePDFManifest, error) {
+	const errMessage = "could not generate invoice pdfs"
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrap(err, errMessage)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]InvoicePDFResult, len(crmIds))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				results[index] = g.renderInvoicePDF(ctx, accessToken, crmIds[index], dir)
+			}
+		}()
+	}
+
+feed:
+	for index := range crmIds {
+		select {
+		case jobs <- index:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	manifest := &InvoicePDFManifest{
+		Succeeded: []InvoicePDFResult{},
+		Failed:    []InvoicePDFResult{},
+	}
+	for index, result := range results {
+		if result.CrmId == "" {
+			result = InvoicePDFResult{CrmId: crmIds[index], Error: "not attempted"}
+		}
+		if result.Error != "" {
+			manifest.Failed = append(manifest.Failed, result)
+		} else {
+			manifest.Succeeded = append(manifest.Succeeded, result)
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, errors.Wrap(err, errMessage)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFileName), data, 0o644); err != nil {
+		return manifest, errors.Wrap(err, errMessage)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return manifest, errors.Wrap(err, errMessage)
+	}
+
+	return manifest, nil
+}
+
+// renderInvoicePDF renders one invoice into dir, retrying failures that are
+// not the fault of the request
+func (g *GoPayamgostar) renderInvoicePDF(ctx context.Context, accessToken, crmId, dir string) InvoicePDFResult {
+	result := InvoicePDFResult{CrmId: crmId}
+	path := filepath.Join(dir, crmId+".pdf")
+
+	wait := invoicePDFRetryWait
+	for {
+		result.Attempts++
+		n, err := g.writeInvoicePDF(ctx, accessToken, crmId, path)
+		if err == nil {
+			result.Path = path
+			result.Bytes = n
+			result.Error = ""
+			return result
+		}
+		result.Error = err.Error()
+
+		if result.Attempts >= invoicePDFAttempts || !retryInvoicePDF(err) {
+			return result
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
+
+// writeInvoicePDF renders crmId into a temporary file and moves it to path
+func (g *GoPayamgostar) writeInvoicePDF(ctx context.Context, accessToken, crmId, path string) (int64, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+crmId+"-*.pdf")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(file.Name())
+
+	n, err := g.RenderFormPDF(ctx, accessToken, crmId, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return n, os.Rename(file.Name(), path)
+}
+
+// retryInvoicePDF reports whether rendering may succeed when tried again
+func retryInvoicePDF(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Code >= http.StatusBadRequest && apiErr.Code < http.StatusInternalServerError {
+		return apiErr.Code == http.StatusTooManyRequests
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+)
+
+func TestGenerateInvoicePDFs(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		attempts = map[string]int{}
+		active   int32
+		peak     int32
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+
+		var request gopayamgostar.GetRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		mu.Lock()
+		attempts[request.ID]++
+		attempt := attempts[request.ID]
+		mu.Unlock()
+
+		switch {
+		case request.ID == "missing":
+			w.WriteHeader(http.StatusNotFound)
+		case request.ID == "flaky" && attempt == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Header().Set("Content-Type", "application/pdf")
+			_, _ = w.Write([]byte("%PDF-" + request.ID))
+		}
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "statements")
+	client := gopayamgostar.NewClient(server.URL)
+	manifest, err := client.GenerateInvoicePDFs(context.Background(), "token", []string{"inv-1", "missing", "flaky", "inv-2"}, dir, 2)
+	require.NoError(t, err)
+	require.LessOrEqual(t, atomic.LoadInt32(&peak), int32(2))
+
+	require.Len(t, manifest.Succeeded, 3)
+	require.Equal(t, "inv-1", manifest.Succeeded[0].CrmId)
+	require.Equal(t, "flaky", manifest.Succeeded[1].CrmId)
+	require.Equal(t, 2, manifest.Succeeded[1].Attempts)
+
+	require.Len(t, manifest.Failed, 1)
+	require.Equal(t, "missing", manifest.Failed[0].CrmId)
+	require.Equal(t, 1, manifest.Failed[0].Attempts)
+
+	content, err := os.ReadFile(filepath.Join(dir, "inv-2.pdf"))
+	require.NoError(t, err)
+	require.Equal(t, "%PDF-inv-2", string(content))
+	_, err = os.Stat(filepath.Join(dir, "missing.pdf"))
+	require.True(t, os.IsNotExist(err))
+
+	data, err := os.ReadFile(filepath.Join(dir, gopayamgostar.ManifestFileName))
+	require.NoError(t, err)
+	var written gopayamgostar.InvoicePDFManifest
+	require.NoError(t, json.Unmarshal(data, &written))
+	require.Equal(t, *manifest, written)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 4, "no temporary files are left behind")
+}
@@ -0,0 +1,260 @@
+package gopayamgostar
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	ptime "github.com/yaa110/go-persian-calendar"
+)
+
+// JalaliDateLayout is the layout the CRM uses for Jalali dates, e.g. 1403/12/12.
+// Layouts use the elements of the time package reference date: 2006 is the
+// year, 01 and 1 the month and 02 and 2 the day, with and without padding.
+const JalaliDateLayout = "2006/01/02"
+
+// gregorianYearThreshold separates Gregorian from Jalali years when decoding
+// dates of unknown calendar
+const gregorianYearThreshold = 1700
+
+// JalaliDate is a date of the Solar Hijri (Shamsi) calendar. The zero value
+// is an unset date and is encoded as JSON null.
+type JalaliDate struct {
+	Year  int
+	Month int
+	Day   int
+}
+
+// NewJalaliDate returns the date day/month/year of the Jalali calendar
+func NewJalaliDate(year, month, day int) JalaliDate {
+	return JalaliDate{Year: year, Month: month, Day: day}
+}
+
+// JalaliDateOf returns the Jalali date of t in the Iran time zone
+func JalaliDateOf(t time.Time) JalaliDate {
+	year, month, day := ptime.New(t.In(ptime.Iran())).Date()
+	return JalaliDate{Year: year, Month: int(month), Day: day}
+}
+
+// ParseJalaliDate parses a Jalali date formatted with layout, see
+// JalaliDateLayout. Persian and Arabic digits are accepted.
+func ParseJalaliDate(layout, value string) (JalaliDate, error) {
+	year, month, day, err := parseDateLayout(layout, value)
+	if err != nil {
+		return JalaliDate{}, err
+	}
+	date := JalaliDate{Year: year, Month: month, Day: day}
+	if !date.Valid() {
+		return JalaliDate{}, errors.Errorf("invalid jalali date %q", value)
+	}
+	return date, nil
+}
+
+// IsZero reports whether the date is unset
+func (d JalaliDate) IsZero() bool {
+	return d == JalaliDate{}
+}
+
+// Valid reports whether the date exists in the Jalali calendar
+func (d JalaliDate) Valid() bool {
+	if d.Year < 1 || d.Month < 1 || d.Month > 12 || d.Day < 1 {
+		return false
+	}
+	return d.Day <= d.daysInMonth()
+}
+
+func (d JalaliDate) daysInMonth() int {
+	switch {
+	case d.Month <= 6:
+		return 31
+	case d.Month <= 11:
+		return 30
+	}
+	// Esfand has 30 days in leap years, in other years its 30th is Nowruz
+	if ptime.Date(d.Year, ptime.Esfand, 30, 12, 0, 0, 0, ptime.Iran()).Month() == ptime.Esfand {
+		return 30
+	}
+	return 29
+}
+
+// Time returns the start of the day in the Iran time zone
+func (d JalaliDate) Time() time.Time {
+	return ptime.Date(d.Year, ptime.Month(d.Month), d.Day, 0, 0, 0, 0, ptime.Iran()).Time()
+}
+
+// Format formats the date with layout, see JalaliDateLayout
+func (d JalaliDate) Format(layout string) string {
+	var b strings.Builder
+	for len(layout) > 0 {
+		token := layoutToken(layout)
+		switch token {
+		case "2006":
+			fmt.Fprintf(&b, "%04d", d.Year)
+		case "01":
+			fmt.Fprintf(&b, "%02d", d.Month)
+		case "1":
+			b.WriteString(strconv.Itoa(d.Month))
+		case "02":
+			fmt.Fprintf(&b, "%02d", d.Day)
+		case "2":
+			b.WriteString(strconv.Itoa(d.Day))
+		default:
+			b.WriteString(token)
+		}
+		layout = layout[len(token):]
+	}
+	return b.String()
+}
+
+// String formats the date with JalaliDateLayout, the zero date as ""
+func (d JalaliDate) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format(JalaliDateLayout)
+}
+
+// MarshalJSON encodes the date as a JalaliDateLayout string, the zero date as null
+func (d JalaliDate) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON decodes Jalali dates such as "1403/12/12" or "1403-12-12".
+// Gregorian dates such as "2025-03-02T00:00:00", as some endpoints return
+// them, are converted. null and "" decode to the zero date.
+func (d *JalaliDate) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*d = JalaliDate{}
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	date, err := parseAnyDate(value)
+	if err != nil {
+		return err
+	}
+	*d = date
+	return nil
+}
+
+// parseAnyDate parses a Jalali or Gregorian date, told apart by the year
+func parseAnyDate(value string) (JalaliDate, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return JalaliDate{}, nil
+	}
+	datePart, _, _ := strings.Cut(value, "T")
+	datePart, _, _ = strings.Cut(datePart, " ")
+	datePart = strings.ReplaceAll(datePart, "-", "/")
+
+	year, month, day, err := parseDateLayout(JalaliDateLayout, datePart)
+	if err != nil {
+		return JalaliDate{}, err
+	}
+	if year < gregorianYearThreshold {
+		return ParseJalaliDate(JalaliDateLayout, datePart)
+	}
+
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, ptime.Iran())
+	if t.Day() != day || int(t.Month()) != month {
+		return JalaliDate{}, errors.Errorf("invalid date %q", value)
+	}
+	return JalaliDateOf(t), nil
+}
+
+// parseDateLayout reads the year, month and day of value laid out as layout
+func parseDateLayout(layout, value string) (year, month, day int, err error) {
+	original := value
+	value = normalizeDigits(value)
+	for len(layout) > 0 {
+		token := layoutToken(layout)
+		layout = layout[len(token):]
+
+		var target *int
+		minDigits, maxDigits := 1, 2
+		switch token {
+		case "2006":
+			target, minDigits, maxDigits = &year, 4, 4
+		case "01":
+			target, minDigits = &month, 2
+		case "1":
+			target = &month
+		case "02":
+			target, minDigits = &day, 2
+		case "2":
+			target = &day
+		default:
+			if !strings.HasPrefix(value, token) {
+				return 0, 0, 0, errors.Errorf("could not parse %q as %q", original, token)
+			}
+			value = value[len(token):]
+			continue
+		}
+
+		n := 0
+		for n < maxDigits && n < len(value) && value[n] >= '0' && value[n] <= '9' {
+			n++
+		}
+		if n < minDigits {
+			return 0, 0, 0, errors.Errorf("could not parse %q: expected a number for %q", original, token)
+		}
+		*target, _ = strconv.Atoi(value[:n])
+		value = value[n:]
+	}
+	if value != "" {
+		return 0, 0, 0, errors.Errorf("could not parse %q: extra text %q", original, value)
+	}
+	return year, month, day, nil
+}
+
+// layoutToken returns the layout element or literal at the start of layout
+func layoutToken(layout string) string {
+	for _, token := range []string{"2006", "01", "02", "1", "2"} {
+		if strings.HasPrefix(layout, token) {
+			return token
+		}
+	}
+	return layout[:1]
+}
+
+// normalizeDigits replaces Persian and Arabic digits by ASCII ones
+func normalizeDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '۰' && r <= '۹':
+			return '0' + r - '۰'
+		case r >= '٠' && r <= '٩':
+			return '0' + r - '٠'
+		}
+		return r
+	}, s)
+}
+
+// JalaliDate parses the value of a date property such as "1403/12/12"
+func (p ExtendedProperty) JalaliDate() (JalaliDate, error) {
+	return parseAnyDate(p.Value)
+}
+
+// jalaliDateP parses the deprecated string date field name, nil when unset
+func jalaliDateP(name string, value *string) (*JalaliDate, error) {
+	if value == nil {
+		return nil, nil
+	}
+	date, err := parseAnyDate(*value)
+	if err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+	if date.IsZero() {
+		return nil, nil
+	}
+	return &date, nil
+}
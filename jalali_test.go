@@ -0,0 +1,118 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+)
+
+func TestJalaliDateConversion(t *testing.T) {
+	t.Parallel()
+
+	nowruz := time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, gopayamgostar.NewJalaliDate(1403, 1, 1), gopayamgostar.JalaliDateOf(nowruz))
+
+	date := gopayamgostar.NewJalaliDate(1403, 12, 12)
+	require.Equal(t, "2025-03-02", date.Time().Format("2006-01-02"))
+	require.Equal(t, date, gopayamgostar.JalaliDateOf(date.Time()))
+
+	require.True(t, gopayamgostar.NewJalaliDate(1403, 12, 30).Valid(), "1403 is a leap year")
+	require.False(t, gopayamgostar.NewJalaliDate(1402, 12, 30).Valid())
+	require.True(t, gopayamgostar.NewJalaliDate(1403, 2, 31).Valid())
+	require.False(t, gopayamgostar.NewJalaliDate(1403, 7, 31).Valid())
+}
+
+func TestParseJalaliDate(t *testing.T) {
+	t.Parallel()
+
+	date, err := gopayamgostar.ParseJalaliDate(gopayamgostar.JalaliDateLayout, "1403/02/31")
+	require.NoError(t, err)
+	require.Equal(t, gopayamgostar.NewJalaliDate(1403, 2, 31), date)
+
+	date, err = gopayamgostar.ParseJalaliDate("2/1/2006", "۵/۷/۱۴۰۳")
+	require.NoError(t, err)
+	require.Equal(t, gopayamgostar.NewJalaliDate(1403, 7, 5), date)
+	require.Equal(t, "1403-07-05", date.Format("2006-01-02"))
+	require.Equal(t, "5/7/1403", date.Format("2/1/2006"))
+
+	_, err = gopayamgostar.ParseJalaliDate(gopayamgostar.JalaliDateLayout, "1402/12/30")
+	require.Error(t, err)
+	_, err = gopayamgostar.ParseJalaliDate(gopayamgostar.JalaliDateLayout, "1403/1/1")
+	require.Error(t, err)
+	_, err = gopayamgostar.ParseJalaliDate(gopayamgostar.JalaliDateLayout, "1403/01/01 extra")
+	require.Error(t, err)
+}
+
+func TestJalaliDateJSON(t *testing.T) {
+	t.Parallel()
+
+	var invoice gopayamgostar.InvoiceSummary
+	require.NoError(t, json.Unmarshal([]byte(`{"invoiceDate": "1403/12/12", "expireDate": "2025-03-20T00:00:00"}`), &invoice))
+	require.Equal(t, gopayamgostar.NewJalaliDate(1403, 12, 12), invoice.InvoiceDate)
+	require.Equal(t, gopayamgostar.NewJalaliDate(1403, 12, 30), invoice.ExpireDate)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"invoiceDate": null, "expireDate": ""}`), &invoice))
+	require.True(t, invoice.InvoiceDate.IsZero())
+	require.True(t, invoice.ExpireDate.IsZero())
+
+	date := gopayamgostar.NewJalaliDate(1403, 12, 12)
+	data, err := json.Marshal(gopayamgostar.CreatePurchaseRequest{InvoiceDate: &date})
+	require.NoError(t, err)
+	var sent map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &sent))
+	require.Equal(t, "1403/12/12", sent["invoiceDate"])
+	require.Nil(t, sent["expireDate"])
+
+	legacy := gopayamgostar.CreatePurchase{InvoiceDate: gopayamgostar.StringP("1403/12/12"), ExpireDate: gopayamgostar.StringP("2025-03-02T00:00:00")}
+	request, err := legacy.Request()
+	require.NoError(t, err)
+	require.Equal(t, date, *request.InvoiceDate)
+	require.Equal(t, date, *request.ExpireDate)
+	legacy.ExpireDate = gopayamgostar.StringP("03/02/2025")
+	_, err = legacy.Request()
+	require.ErrorContains(t, err, "expireDate")
+
+	property := gopayamgostar.ExtendedProperty{UserKey: "DepositDate", Value: "1403/12/12"}
+	parsed, err := property.JalaliDate()
+	require.NoError(t, err)
+	require.Equal(t, date, parsed)
+}
+
+func TestCreatePurchaseDates(t *testing.T) {
+	t.Parallel()
+
+	var sent []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		sent = append(sent, body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"crmId":"purchase-1"}`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client := gopayamgostar.NewClient(server.URL)
+
+	_, err := client.CreatePurchase(ctx, "token", gopayamgostar.CreatePurchase{InvoiceDate: gopayamgostar.StringP("2025-03-02T00:00:00")})
+	require.NoError(t, err)
+	require.Len(t, sent, 1)
+	require.Equal(t, "2025-03-02T00:00:00", sent[0]["invoiceDate"])
+	require.Nil(t, sent[0]["expireDate"])
+
+	_, err = client.CreatePurchase(ctx, "token", gopayamgostar.CreatePurchase{InvoiceDate: gopayamgostar.StringP("03/02/2025")})
+	require.ErrorContains(t, err, "invoiceDate")
+	require.Len(t, sent, 1, "the invoice is not sent")
+
+	date := gopayamgostar.NewJalaliDate(1403, 12, 12)
+	_, err = client.CreatePurchaseInvoice(ctx, "token", gopayamgostar.CreatePurchaseRequest{InvoiceDate: &date})
+	require.NoError(t, err)
+	require.Equal(t, "1403/12/12", sent[1]["invoiceDate"])
+}
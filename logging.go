@@ -0,0 +1,85 @@
+package gopayamgostar
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// logBodyLimit is the number of bytes of an error response body logged by WithLogger
+const logBodyLimit = 1024
+
+// slogLogger adapts a slog.Logger to the resty.Logger interface
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l slogLogger) Errorf(format string, v ...interface{}) {
+	l.logger.Error(fmt.Sprintf(format, v...))
+}
+
+func (l slogLogger) Warnf(format string, v ...interface{}) {
+	l.logger.Warn(fmt.Sprintf(format, v...))
+}
+
+func (l slogLogger) Debugf(format string, v ...interface{}) {
+	l.logger.Debug(fmt.Sprintf(format, v...))
+}
+
+// WithLogger logs the requests of the client and the messages of resty to
+// logger. Every attempt is logged with its method, endpoint, status and
+// duration: successful ones at debug level, failed ones at warn level with the
+// start of the response body, and transport errors at error level.
+func WithLogger(logger *slog.Logger) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		g.restyClient.SetLogger(slogLogger{logger: logger})
+
+		g.restyClient.OnAfterResponse(func(c *resty.Client, resp *resty.Response) error {
+			attrs := g.requestLogAttrs(resp.Request)
+			attrs = append(attrs,
+				slog.Int("status", resp.StatusCode()),
+				slog.Duration("duration", resp.Time()),
+			)
+
+			level := slog.LevelDebug
+			if resp.StatusCode() >= http.StatusBadRequest {
+				level = slog.LevelWarn
+				attrs = append(attrs, slog.String("body", truncate(string(resp.Body()), logBodyLimit)))
+			}
+			logger.LogAttrs(resp.Request.Context(), level, "payamgostar request", attrs...)
+			return nil
+		})
+
+		g.restyClient.OnError(func(req *resty.Request, err error) {
+			if respErr, ok := err.(*resty.ResponseError); ok && respErr.Response.RawResponse != nil {
+				// the response was logged when it arrived
+				return
+			}
+			attrs := g.requestLogAttrs(req)
+			if !req.Time.IsZero() {
+				attrs = append(attrs, slog.Duration("duration", time.Since(req.Time)))
+			}
+			attrs = append(attrs, slog.String("error", err.Error()))
+			logger.LogAttrs(req.Context(), slog.LevelError, "payamgostar request failed", attrs...)
+		})
+	}
+}
+
+func (g *GoPayamgostar) requestLogAttrs(req *resty.Request) []slog.Attr {
+	return []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("endpoint", g.endpointName(requestPath(req))),
+		slog.Int("attempt", req.Attempt),
+		slog.String("correlation_id", req.Header.Get(correlationIDHeader)),
+	}
+}
+
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return s[:limit] + "..."
+}
@@ -0,0 +1,60 @@
+package gopayamgostar_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/form/delete") {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errorMessage":"` + strings.Repeat("x", 2000) + `"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"crmId":"1"}`))
+	}))
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithLogger(logger))
+
+	_, err := client.CreateForm(context.Background(), "token", gopayamgostar.CreateFormRequest{})
+	require.NoError(t, err)
+	require.Error(t, client.DeleteForm(context.Background(), "token", "form"))
+	server.Close()
+	require.Error(t, client.DeleteForm(context.Background(), "token", "form"))
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 3)
+
+	require.Equal(t, "DEBUG", records[0]["level"])
+	require.Equal(t, "CreateForm", records[0]["endpoint"])
+	require.Equal(t, "POST", records[0]["method"])
+	require.EqualValues(t, http.StatusOK, records[0]["status"])
+	require.Contains(t, records[0], "duration")
+	require.NotContains(t, records[0], "body")
+
+	require.Equal(t, "WARN", records[1]["level"])
+	require.Equal(t, "DeleteForm", records[1]["endpoint"])
+	require.EqualValues(t, http.StatusBadRequest, records[1]["status"])
+	require.Len(t, records[1]["body"], 1024+len("..."))
+
+	require.Equal(t, "ERROR", records[2]["level"])
+	require.Equal(t, "DeleteForm", records[2]["endpoint"])
+	require.Contains(t, records[2]["error"], "form/delete")
+}
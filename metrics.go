@@ -0,0 +1,119 @@
+package gopayamgostar
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "payamgostar_client"
+
+type clientMetrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+}
+
+func newClientMetrics(registerer prometheus.Registerer) (*clientMetrics, error) {
+	m := &clientMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "requests_total",
+			Help:      "Requests sent to the Payamgostar API, retries included.",
+		}, []string{"endpoint", "method"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "errors_total",
+			Help:      "Requests to the Payamgostar API that failed, by HTTP status or \"transport\".",
+		}, []string{"endpoint", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of the requests to the Payamgostar API.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"endpoint"}),
+	}
+
+	var err error
+	m.requests, err = register(registerer, m.requests)
+	if err != nil {
+		return nil, err
+	}
+	m.errors, err = register(registerer, m.errors)
+	if err != nil {
+		return nil, err
+	}
+	m.latency, err = register(registerer, m.latency)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// register registers c, or returns the collector registered before by
+// another client so that several clients can share a registerer
+func register[C prometheus.Collector](registerer prometheus.Registerer, c C) (C, error) {
+	if err := registerer.Register(c); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if errors.As(err, &already) {
+			if existing, ok := already.ExistingCollector.(C); ok {
+				return existing, nil
+			}
+		}
+		return c, err
+	}
+	return c, nil
+}
+
+// metricsTransport observes every attempt sent through next
+type metricsTransport struct {
+	next    http.RoundTripper
+	metrics *clientMetrics
+	client  *GoPayamgostar
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := t.client.endpointName(req.URL.Path)
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	t.metrics.latency.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	t.metrics.requests.WithLabelValues(endpoint, req.Method).Inc()
+
+	switch {
+	case err != nil:
+		t.metrics.errors.WithLabelValues(endpoint, "transport").Inc()
+	case resp.StatusCode >= http.StatusBadRequest:
+		t.metrics.errors.WithLabelValues(endpoint, strconv.Itoa(resp.StatusCode)).Inc()
+	}
+
+	return resp, err
+}
+
+// WithMetrics registers Prometheus metrics of the requests sent by the client
+// with registerer: requests_total, errors_total by status and a latency
+// histogram, all labelled with the endpoint name. Clients sharing a
+// registerer share the metrics. Registration errors panic as with
+// prometheus.MustRegister.
+func WithMetrics(registerer prometheus.Registerer) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		metrics, err := newClientMetrics(registerer)
+		if err != nil {
+			panic(err)
+		}
+
+		httpClient := g.restyClient.GetClient()
+		next := httpClient.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		httpClient.Transport = &metricsTransport{
+			next:    next,
+			metrics: metrics,
+			client:  g,
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/form/delete") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"crmId":"1"}`))
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	client := gopayamgostar.NewClient(server.URL+"/crm", gopayamgostar.WithMetrics(registry))
+	// a second client shares the collectors of the first one
+	other := gopayamgostar.NewClient(server.URL+"/crm", gopayamgostar.WithMetrics(registry))
+
+	_, err := client.CreateForm(context.Background(), "token", gopayamgostar.CreateFormRequest{})
+	require.NoError(t, err)
+	_, err = other.CreateForm(context.Background(), "token", gopayamgostar.CreateFormRequest{})
+	require.NoError(t, err)
+	require.Error(t, client.DeleteForm(context.Background(), "token", "form"))
+
+	expected := `
+# HELP payamgostar_client_requests_total Requests sent to the Payamgostar API, retries included.
+# TYPE payamgostar_client_requests_total counter
+payamgostar_client_requests_total{endpoint="CreateForm",method="POST"} 2
+payamgostar_client_requests_total{endpoint="DeleteForm",method="POST"} 1
+# HELP payamgostar_client_errors_total Requests to the Payamgostar API that failed, by HTTP status or "transport".
+# TYPE payamgostar_client_errors_total counter
+payamgostar_client_errors_total{endpoint="DeleteForm",status="404"} 1
+`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"payamgostar_client_requests_total", "payamgostar_client_errors_total"))
+	require.Equal(t, 2, testutil.CollectAndCount(registry, "payamgostar_client_request_duration_seconds"))
+}
@@ -0,0 +1,191 @@
+package gopayamgostar
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// staleHeader marks responses served from the mirror with the time they were stored
+const staleHeader = "X-Payamgostar-Stale"
+
+// mirroredEndpoints are the read endpoints served from the mirror
+var mirroredEndpoints = map[string]bool{
+	"GetForm":    true,
+	"FindForm":   true,
+	"GetPerson":  true,
+	"FindPerson": true,
+}
+
+// MirrorEntry is a response kept by a Mirror
+type MirrorEntry struct {
+	Body     []byte
+	StoredAt time.Time
+}
+
+// Mirror keeps the last successful response of every read request so that it
+// can be served while the CRM is unreachable. Load returns nil for keys that
+// were never stored.
+type Mirror interface {
+	Load(ctx context.Context, key string) (*MirrorEntry, error)
+	Store(ctx context.Context, key string, entry MirrorEntry) error
+}
+
+// MemoryMirror is a Mirror kept in memory
+type MemoryMirror struct {
+	mu      sync.RWMutex
+	entries map[string]MirrorEntry
+}
+
+// NewMemoryMirror creates an empty MemoryMirror
+func NewMemoryMirror() *MemoryMirror {
+	return &MemoryMirror{entries: map[string]MirrorEntry{}}
+}
+
+func (m *MemoryMirror) Load(ctx context.Context, key string) (*MirrorEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func (m *MemoryMirror) Store(ctx context.Context, key string, entry MirrorEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = entry
+	return nil
+}
+
+// mirrorTransport stores the responses of the read endpoints in mirror and
+// answers from it when the CRM cannot be reached
+type mirrorTransport struct {
+	next   http.RoundTripper
+	mirror Mirror
+	client *GoPayamgostar
+}
+
+func (t *mirrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key, ok := t.key(req)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err == nil {
+		resp.Header.Del(staleHeader)
+	}
+
+	switch {
+	case err == nil && resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices:
+		body, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if readErr != nil {
+			return nil, readErr
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		// a mirror that cannot store must not fail a request that succeeded
+		_ = t.mirror.Store(req.Context(), key, MirrorEntry{Body: body, StoredAt: time.Now()})
+		return resp, nil
+	case req.Context().Err() != nil || !unreachable(resp, err):
+		return resp, err
+	}
+
+	entry, loadErr := t.mirror.Load(req.Context(), key)
+	if loadErr != nil || entry == nil {
+		return resp, err
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(staleHeader, entry.StoredAt.Format(time.RFC3339Nano))
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         req.Proto,
+		ProtoMajor:    req.ProtoMajor,
+		ProtoMinor:    req.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}, nil
+}
+
+// key identifies a read request by its endpoint, access token and body. The
+// mirror serves while the CRM cannot check the token, so it is matched as a
+// whole rather than by the user its claims name. Requests of other endpoints
+// and requests whose body cannot be read twice are not mirrored.
+func (t *mirrorTransport) key(req *http.Request) (string, bool) {
+	endpoint := t.client.endpointName(req.URL.Path)
+	if !mirroredEndpoints[endpoint] || req.GetBody == nil {
+		return "", false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", false
+	}
+	defer body.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, body); err != nil {
+		return "", false
+	}
+	accessToken := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	return endpoint + ":" + tokenScope(accessToken) + ":" + hex.EncodeToString(hash.Sum(nil)), true
+}
+
+// unreachable reports whether an attempt failed because the CRM is down
+func unreachable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// servedStale returns the age of a response served from the mirror
+func servedStale(resp *resty.Response) (time.Duration, bool) {
+	storedAt, err := time.Parse(time.RFC3339Nano, resp.Header().Get(staleHeader))
+	if err != nil {
+		return 0, false
+	}
+	return time.Since(storedAt), true
+}
+
+// WithMirror keeps the responses of GetFormInfoById, GetPersonInfoById,
+// FindForm and FindPersonsByName in mirror. While the CRM is unreachable or
+// answers 502, 503 or 504 these methods return the last response stored for
+// the same request with the same access token, with its age in the Staleness
+// field of the result, and ErrServedStale. A renewed token is not served the
+// responses mirrored for the token it replaces.
+func WithMirror(mirror Mirror) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		httpClient := g.restyClient.GetClient()
+		next := httpClient.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		httpClient.Transport = &mirrorTransport{
+			next:   next,
+			mirror: mirror,
+			client: g,
+		}
+	}
+}
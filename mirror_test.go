@@ -0,0 +1,106 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+)
+
+func TestWithMirror(t *testing.T) {
+	t.Parallel()
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	server.AddUser("support", "secret")
+	crmId := server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Sara", LastName: "Karimi"})
+
+	var down atomic.Bool
+	client := server.Client(gopayamgostar.WithMirror(gopayamgostar.NewMemoryMirror()))
+	client.RegisterRequestHook(func(info gopayamgostar.HookInfo, req *http.Request) error {
+		if down.Load() && info.Endpoint != "Auth" {
+			return http.ErrServerClosed
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	other, err := client.AdminAuthenticate(ctx, "support", "secret")
+	require.NoError(t, err)
+
+	person, err := client.GetPersonInfoById(ctx, token.AccessToken, crmId)
+	require.NoError(t, err)
+	require.Zero(t, person.Staleness)
+	found, err := client.FindPersonsByName(ctx, token.AccessToken, "Person", "Sara", "Karimi")
+	require.NoError(t, err)
+	require.Len(t, found.Data, 1)
+
+	down.Store(true)
+
+	person, err = client.GetPersonInfoById(ctx, token.AccessToken, crmId)
+	require.ErrorIs(t, err, gopayamgostar.ErrServedStale)
+	require.Equal(t, "Sara", person.FirstName)
+	require.Positive(t, person.Staleness)
+
+	found, err = client.FindPersonsByName(ctx, token.AccessToken, "Person", "Sara", "Karimi")
+	require.ErrorIs(t, err, gopayamgostar.ErrServedStale)
+	require.Len(t, found.Data, 1)
+	require.Positive(t, found.Staleness)
+
+	// requests that were never mirrored still fail
+	_, err = client.FindPersonsByName(ctx, token.AccessToken, "Person", "Ali", "Rezaei")
+	require.ErrorIs(t, err, http.ErrServerClosed)
+	require.NotErrorIs(t, err, gopayamgostar.ErrServedStale)
+
+	// nor are the responses of a user served to another
+	_, err = client.GetPersonInfoById(ctx, other.AccessToken, crmId)
+	require.ErrorIs(t, err, http.ErrServerClosed)
+}
+
+func TestMirrorIsPerToken(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"crmId":"person-1","firstName":"Ali"}`))
+	}))
+	defer server.Close()
+
+	var down atomic.Bool
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithMirror(gopayamgostar.NewMemoryMirror()))
+	client.RegisterRequestHook(func(info gopayamgostar.HookInfo, req *http.Request) error {
+		if down.Load() {
+			return http.ErrServerClosed
+		}
+		return nil
+	})
+
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","iss":"crm"}`))
+	token := "eyJhbGciOiJIUzI1NiJ9." + claims + ".c2lnbmF0dXJl"
+	forged := "eyJhbGciOiJub25lIn0." + claims + "."
+
+	ctx := context.Background()
+	_, err := client.GetPersonInfoById(ctx, token, "person-1")
+	require.NoError(t, err)
+
+	down.Store(true)
+
+	person, err := client.GetPersonInfoById(ctx, token, "person-1")
+	require.ErrorIs(t, err, gopayamgostar.ErrServedStale)
+	require.Equal(t, "Ali", person.FirstName)
+
+	// a token naming the same user is not served while the CRM cannot check it
+	_, err = client.GetPersonInfoById(ctx, forged, "person-1")
+	require.ErrorIs(t, err, http.ErrServerClosed)
+	require.NotErrorIs(t, err, gopayamgostar.ErrServedStale)
+}
@@ -3,8 +3,13 @@ package gopayamgostar
 import (
 	"bytes"
 	"encoding/json"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
 )
 
 // GetQueryParams converts the struct to map[string]string
@@ -100,18 +105,47 @@ const (
 	APIErrTypeInvalidGrant = "oauth: invalid grant"
 )
 
+type errorMatcher struct {
+	pattern *regexp.Regexp
+	errType APIErrType
+}
+
+var (
+	errorMatchersMu sync.RWMutex
+	errorMatchers   = []errorMatcher{
+		{pattern: regexp.MustCompile(`invalid_grant`), errType: APIErrTypeInvalidGrant},
+	}
+)
+
+// RegisterErrorMatcher maps error messages matching pattern to errType.
+// Different Payamgostar versions phrase the same failure differently, so
+// callers can teach ParseAPIErrType about their server. Matchers registered
+// later take precedence over earlier ones and over the built-in ones.
+func RegisterErrorMatcher(pattern *regexp.Regexp, errType APIErrType) {
+	errorMatchersMu.Lock()
+	defer errorMatchersMu.Unlock()
+	errorMatchers = append(errorMatchers, errorMatcher{pattern: pattern, errType: errType})
+}
+
 // ParseAPIErrType is a convenience method for returning strongly
 // typed API errors.
 func ParseAPIErrType(err error) APIErrType {
 	if err == nil {
 		return APIErrTypeUnknown
 	}
-	switch {
-	case strings.Contains(err.Error(), "invalid_grant"):
-		return APIErrTypeInvalidGrant
-	default:
-		return APIErrTypeUnknown
+	return matchAPIErrType(err.Error())
+}
+
+func matchAPIErrType(message string) APIErrType {
+	errorMatchersMu.RLock()
+	defer errorMatchersMu.RUnlock()
+
+	for i := len(errorMatchers) - 1; i >= 0; i-- {
+		if errorMatchers[i].pattern.MatchString(message) {
+			return errorMatchers[i].errType
+		}
 	}
+	return APIErrTypeUnknown
 }
 
 // APIError holds message and statusCode for api errors
@@ -119,6 +153,18 @@ type APIError struct {
 	Code    int        `json:"code"`
 	Message string     `json:"message"`
 	Type    APIErrType `json:"type"`
+	// Endpoint is the method and path of the failed call, e.g.
+	// "POST /api/v2/crmobject/form/get"
+	Endpoint string `json:"endpoint,omitempty"`
+	// RequestID identifies the call in the logs of the server: the
+	// X-Request-Id of the response, else the X-Correlation-ID the client sent
+	RequestID string `json:"requestId,omitempty"`
+	// Body is the raw body of the response
+	Body []byte `json:"-"`
+
+	// err is the error the request failed with when no response was
+	// received, or the sentinel error of the status code, such as ErrNotFound
+	err error
 }
 
 // Error stringifies the APIError
@@ -126,6 +172,14 @@ func (apiError APIError) Error() string {
 	return apiError.Message
 }
 
+// Unwrap returns the error the request failed with when no response was
+// received, or the sentinel error of the status code, so that e.g.
+// errors.Is(err, ErrCircuitOpen), errors.Is(err, context.DeadlineExceeded) or
+// errors.Is(err, ErrNotFound) work
+func (apiError APIError) Unwrap() error {
+	return apiError.err
+}
+
 type AuthRequest struct {
 	Username     string `json:"username"`
 	Password     string `json:"password"`
@@ -133,6 +187,11 @@ type AuthRequest struct {
 	PlatformType int    `json:"platformType"`
 }
 
+// RefreshTokenRequest is the request of RefreshToken
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
 type GetRequest struct {
 	ID                      string `json:"id"`
 	ShowPreviews            bool   `json:"showPreviews"`
@@ -144,53 +203,59 @@ type GetRequest struct {
 }
 
 type PersonInfo struct {
-	FirstName                 string             `json:"firstName"`
-	LastName                  string             `json:"lastName"`
-	BirthDate                 interface{}        `json:"birthDate"`
-	Gender                    string             `json:"gender"`
-	PersonPrefix              string             `json:"personPrefix"`
-	NationalCode              string             `json:"nationalCode"`
-	PreferredContactType      string             `json:"preferredContactType"`
-	FacebookUsername          string             `json:"facebookUsername"`
-	Organizations             []interface{}      `json:"organizations"`
-	NickName                  string             `json:"nickName"`
-	PhoneContacts             []PhoneContact     `json:"phoneContacts"`
-	AddressContacts           []interface{}      `json:"addressContacts"`
-	Email                     string             `json:"email"`
-	AlternativeEmail          string             `json:"alternativeEmail"`
-	Website                   string             `json:"website"`
-	SourceTypeName            string             `json:"sourceTypeName"`
-	CustomerNumber            string             `json:"customerNumber"`
-	ColorName                 string             `json:"colorName"`
-	Classification            string             `json:"classification"`
-	CustomerDate              interface{}        `json:"customerDate"`
-	Balance                   float64            `json:"balance"`
-	IdentityTypeName          string             `json:"identityTypeName"`
-	Categories                []Category         `json:"categories"`
-	SupportUsername           string             `json:"supportUsername"`
-	SaleUsername              string             `json:"saleUsername"`
-	OtherUsername             string             `json:"otherUsername"`
-	CRMID                     string             `json:"crmId"`
-	CRMObjectTypeName         interface{}        `json:"crmObjectTypeName"`
-	CRMObjectTypeCode         string             `json:"crmObjectTypeCode"`
-	CRMObjectTypeIndex        int64              `json:"crmObjectTypeIndex"`
-	CRMObjectTypeID           string             `json:"crmObjectTypeId"`
-	ParentCRMObjectID         interface{}        `json:"parentCrmObjectId"`
-	ExtendedProperties        []ExtendedProperty `json:"extendedProperties"`
-	ProcessLifePaths          []interface{}      `json:"processLifePaths"`
-	CreatDate                 CustomTime         `json:"creatDate"`
-	ModifyDate                CustomTime         `json:"modifyDate"`
-	RefID                     string             `json:"refId"`
-	StageID                   interface{}        `json:"stageId"`
-	IdentityID                string             `json:"identityId"`
-	Description               string             `json:"description"`
-	Subject                   string             `json:"subject"`
-	ModifierIDPreview         interface{}        `json:"modifierIdPreview"`
-	CreatorIDPreview          interface{}        `json:"creatorIdPreview"`
-	CRMObjectTypeIndexPreview interface{}        `json:"crmObjectTypeIndexPreview"`
-	IdentityIDPreview         interface{}        `json:"identityIdPreview"`
-	AssignedToIDPreview       interface{}        `json:"assignedToIdPreview"`
-	IncludedFields            IncludedFields     `json:"includedFields"`
+	FirstName                 string                   `json:"firstName"`
+	LastName                  string                   `json:"lastName"`
+	BirthDate                 DateTime                 `json:"birthDate"`
+	Gender                    string                   `json:"gender"`
+	PersonPrefix              string                   `json:"personPrefix"`
+	NationalCode              string                   `json:"nationalCode"`
+	PreferredContactType      string                   `json:"preferredContactType"`
+	FacebookUsername          string                   `json:"facebookUsername"`
+	Organizations             []OrganizationMembership `json:"organizations"`
+	NickName                  string                   `json:"nickName"`
+	PhoneContacts             []PhoneContact           `json:"phoneContacts"`
+	AddressContacts           []interface{}            `json:"addressContacts"`
+	Email                     string                   `json:"email"`
+	AlternativeEmail          string                   `json:"alternativeEmail"`
+	Website                   string                   `json:"website"`
+	SourceTypeName            string                   `json:"sourceTypeName"`
+	CustomerNumber            string                   `json:"customerNumber"`
+	ColorName                 string                   `json:"colorName"`
+	Classification            string                   `json:"classification"`
+	CustomerDate              DateTime                 `json:"customerDate"`
+	Balance                   float64                  `json:"balance"`
+	IdentityTypeName          string                   `json:"identityTypeName"`
+	Categories                []Category               `json:"categories"`
+	SupportUsername           string                   `json:"supportUsername"`
+	SaleUsername              string                   `json:"saleUsername"`
+	OtherUsername             string                   `json:"otherUsername"`
+	CRMID                     string                   `json:"crmId"`
+	CRMObjectTypeName         interface{}              `json:"crmObjectTypeName"`
+	CRMObjectTypeCode         string                   `json:"crmObjectTypeCode"`
+	CRMObjectTypeIndex        int64                    `json:"crmObjectTypeIndex"`
+	CRMObjectTypeID           string                   `json:"crmObjectTypeId"`
+	ParentCRMObjectID         interface{}              `json:"parentCrmObjectId"`
+	ExtendedProperties        []ExtendedProperty       `json:"extendedProperties"`
+	ProcessLifePaths          []ProcessLifePath        `json:"processLifePaths"`
+	CreatDate                 CustomTime               `json:"creatDate"`
+	ModifyDate                CustomTime               `json:"modifyDate"`
+	RefID                     string                   `json:"refId"`
+	StageID                   interface{}              `json:"stageId"`
+	IdentityID                string                   `json:"identityId"`
+	Description               string                   `json:"description"`
+	Subject                   string                   `json:"subject"`
+	ModifierIDPreview         Preview                  `json:"modifierIdPreview"`
+	CreatorIDPreview          Preview                  `json:"creatorIdPreview"`
+	CRMObjectTypeIndexPreview interface{}              `json:"crmObjectTypeIndexPreview"`
+	IdentityIDPreview         Preview                  `json:"identityIdPreview"`
+	AssignedToIDPreview       Preview                  `json:"assignedToIdPreview"`
+	IncludedFields            IncludedFields           `json:"includedFields"`
+	// Extra holds the fields returned by newer servers this version of the
+	// client does not know about
+	Extra map[string]json.RawMessage `json:"-"`
+	// Staleness is the age of the data when it was served from the mirror
+	// during an outage, see WithMirror
+	Staleness time.Duration `json:"-"`
 }
 
 type AreasOfInterest struct {
@@ -198,35 +263,46 @@ type AreasOfInterest struct {
 }
 
 type FormInfo struct {
-	CRMID                     string              `json:"CrmId"`
-	CRMObjectTypeIndexPreview AssignedToIDPreview `json:"CrmObjectTypeIndexPreview"`
-	CRMObjectTypeIndex        int64               `json:"CrmObjectTypeIndex"`
-	CRMObjectTypeName         AssignedToIDPreview `json:"CrmObjectTypeName"`
-	CRMObjectTypeID           string              `json:"CrmObjectTypeId"`
-	CRMObjectTypeCode         string              `json:"CrmObjectTypeCode"`
-	ParentCRMObjectID         interface{}         `json:"ParentCrmObjectId"`
-	ExtendedProperties        []ExtendedProperty  `json:"ExtendedProperties"`
-	Tags                      []interface{}       `json:"Tags"`
-	RefID                     string              `json:"RefId"`
-	StageID                   interface{}         `json:"StageId"`
-	IdentityIDPreview         AssignedToIDPreview `json:"IdentityIdPreview"`
-	IdentityID                string              `json:"IdentityId"`
-	Description               string              `json:"Description"`
-	Subject                   string              `json:"Subject"`
-	ProcessLifePaths          []interface{}       `json:"ProcessLifePaths"`
-	Color                     interface{}         `json:"Color"`
-	ModifierIDPreview         AssignedToIDPreview `json:"ModifierIdPreview"`
-	ModifierID                string              `json:"ModifierId"`
-	CreatorIDPreview          AssignedToIDPreview `json:"CreatorIdPreview"`
-	CreatorID                 string              `json:"CreatorId"`
-	AssignedToIDPreview       AssignedToIDPreview `json:"AssignedToIdPreview"`
-	AssignedToID              interface{}         `json:"AssignedToId"`
-}
-
-type AssignedToIDPreview struct {
-	Name string `json:"Name"`
+	CRMID                     string             `json:"CrmId"`
+	CRMObjectTypeIndexPreview Preview            `json:"CrmObjectTypeIndexPreview"`
+	CRMObjectTypeIndex        int64              `json:"CrmObjectTypeIndex"`
+	CRMObjectTypeName         Preview            `json:"CrmObjectTypeName"`
+	CRMObjectTypeID           string             `json:"CrmObjectTypeId"`
+	CRMObjectTypeCode         string             `json:"CrmObjectTypeCode"`
+	ParentCRMObjectID         interface{}        `json:"ParentCrmObjectId"`
+	ExtendedProperties        []ExtendedProperty `json:"ExtendedProperties"`
+	Tags                      []interface{}      `json:"Tags"`
+	RefID                     string             `json:"RefId"`
+	StageID                   interface{}        `json:"StageId"`
+	IdentityIDPreview         Preview            `json:"IdentityIdPreview"`
+	IdentityID                string             `json:"IdentityId"`
+	Description               string             `json:"Description"`
+	Subject                   string             `json:"Subject"`
+	ProcessLifePaths          []ProcessLifePath  `json:"ProcessLifePaths"`
+	Color                     interface{}        `json:"Color"`
+	ModifierIDPreview         Preview            `json:"ModifierIdPreview"`
+	ModifierID                string             `json:"ModifierId"`
+	CreatorIDPreview          Preview            `json:"CreatorIdPreview"`
+	CreatorID                 string             `json:"CreatorId"`
+	AssignedToIDPreview       Preview            `json:"AssignedToIdPreview"`
+	AssignedToID              interface{}        `json:"AssignedToId"`
+	// Extra holds the fields returned by newer servers this version of the
+	// client does not know about
+	Extra map[string]json.RawMessage `json:"-"`
+	// Staleness is the age of the data when it was served from the mirror
+	// during an outage, see WithMirror
+	Staleness time.Duration `json:"-"`
 }
 
+// AssignedToIDPreview is the preview of a reference field of FormInfo.
+//
+// Deprecated: use Preview.
+type AssignedToIDPreview = Preview
+
+// CreatePurchase is the request of the CreatePurchase method.
+//
+// Deprecated: CrmId is assigned by the server and ignored on create. Use
+// CreatePurchaseRequest with CreatePurchaseInvoice.
 type CreatePurchase struct {
 	CrmId              string             `json:"crmId,omitempty"`
 	CRMObjectTypeCode  string             `json:"crmObjectTypeCode"`
@@ -255,16 +331,93 @@ type CreatePurchase struct {
 	RelatedQuoteID     *string            `json:"relatedQuoteId"`
 }
 
+// Request converts the deprecated model into a CreatePurchaseRequest. The
+// dates are parsed as Jalali or Gregorian dates, an error being returned for
+// the ones that are neither.
+func (p CreatePurchase) Request() (CreatePurchaseRequest, error) {
+	invoiceDate, err := jalaliDateP("invoiceDate", p.InvoiceDate)
+	if err != nil {
+		return CreatePurchaseRequest{}, err
+	}
+	expireDate, err := jalaliDateP("expireDate", p.ExpireDate)
+	if err != nil {
+		return CreatePurchaseRequest{}, err
+	}
+
+	return CreatePurchaseRequest{
+		CRMObjectTypeCode:  p.CRMObjectTypeCode,
+		Details:            p.Details,
+		Discount:           NewMoney(p.Discount),
+		FinalValue:         NewMoney(p.FinalValue),
+		Toll:               NewMoney(p.Toll),
+		TotalValue:         NewMoney(p.TotalValue),
+		Vat:                NewMoney(p.Vat),
+		ParentCRMObjectID:  p.ParentCRMObjectID,
+		ExtendedProperties: p.ExtendedProperties,
+		Tags:               p.Tags,
+		RefID:              p.RefID,
+		StageID:            p.StageID,
+		ColorID:            p.ColorID,
+		IdentityID:         p.IdentityID,
+		Description:        p.Description,
+		Subject:            p.Subject,
+		AssignedToUserName: p.AssignedToUserName,
+		Number:             p.Number,
+		PriceListName:      p.PriceListName,
+		AdditionalCosts:    p.AdditionalCosts,
+		InvoiceDate:        invoiceDate,
+		ExpireDate:         expireDate,
+		DiscountPercent:    p.DiscountPercent,
+		RelatedQuoteID:     p.RelatedQuoteID,
+	}, nil
+}
+
+// legacyPurchaseRequest is the body of the deprecated CreatePurchase, whose
+// dates shadow the ones of the request to be sent in the format of the caller
+type legacyPurchaseRequest struct {
+	CreatePurchaseRequest
+	InvoiceDate *string `json:"invoiceDate"`
+	ExpireDate  *string `json:"expireDate"`
+}
+
+// CreatePurchaseRequest is the request of CreatePurchaseInvoice
+type CreatePurchaseRequest struct {
+	CRMObjectTypeCode  string             `json:"crmObjectTypeCode"`
+	Details            []Detail           `json:"details"`
+	Discount           Money              `json:"discount"`
+	FinalValue         Money              `json:"finalValue"`
+	Toll               Money              `json:"toll"`
+	TotalValue         Money              `json:"totalValue"`
+	Vat                Money              `json:"vat"`
+	ParentCRMObjectID  *string            `json:"parentCrmObjectId"`
+	ExtendedProperties []ExtendedProperty `json:"extendedProperties"`
+	Tags               *[]string          `json:"tags"`
+	RefID              *string            `json:"refId"`
+	StageID            *string            `json:"stageId"`
+	ColorID            int64              `json:"colorId"`
+	IdentityID         string             `json:"identityId"`
+	Description        *string            `json:"description"`
+	Subject            *string            `json:"subject"`
+	AssignedToUserName *string            `json:"assignedToUserName"`
+	Number             *string            `json:"number"`
+	PriceListName      *string            `json:"priceListName"`
+	AdditionalCosts    *string            `json:"additionalCosts"`
+	InvoiceDate        *JalaliDate        `json:"invoiceDate"`
+	ExpireDate         *JalaliDate        `json:"expireDate"`
+	DiscountPercent    *string            `json:"discountPercent"`
+	RelatedQuoteID     *string            `json:"relatedQuoteId"`
+}
+
 type Detail struct {
 	IsService           bool   `json:"isService"`
-	BaseUnitPrice       int64  `json:"baseUnitPrice"`
-	FinalUnitPrice      int64  `json:"finalUnitPrice"`
+	BaseUnitPrice       Money  `json:"baseUnitPrice"`
+	FinalUnitPrice      Money  `json:"finalUnitPrice"`
 	Count               int64  `json:"count"`
 	ReturnedCount       int64  `json:"returnedCount"`
-	TotalUnitPrice      int64  `json:"totalUnitPrice"`
-	TotalDiscount       int64  `json:"totalDiscount"`
-	TotalVat            int64  `json:"totalVat"`
-	TotalToll           int64  `json:"totalToll"`
+	TotalUnitPrice      Money  `json:"totalUnitPrice"`
+	TotalDiscount       Money  `json:"totalDiscount"`
+	TotalVat            Money  `json:"totalVat"`
+	TotalToll           Money  `json:"totalToll"`
 	ProductCode         string `json:"productCode"`
 	ProductID           string `json:"productId"`
 	ProductName         string `json:"productName"`
@@ -274,20 +427,96 @@ type Detail struct {
 }
 
 type DeleteRequest struct {
-	Id     string `json:"id"`
-	Option int    `json:"option"`
+	Id     string             `json:"id"`
+	Option enums.DeleteOption `json:"option"`
 }
 
+type DeleteResult struct {
+	CrmId                  string   `json:"crmId"`
+	AffectedRelatedRecords []string `json:"affectedRelatedRecords"`
+	Warnings               []string `json:"warnings"`
+}
+
+// FindResponse is a page of persons.
+//
+// Deprecated: find rows only carry a subset of the person fields. Use
+// FindPersonResponse and GetPersonInfoById for the full person.
 type FindResponse struct {
 	Data  []PersonInfo `json:"data"`
 	Total int64        `json:"total"`
 }
 
+// FindPersonResponse is a page of persons returned by a find
+type FindPersonResponse struct {
+	Data  []PersonRow `json:"data"`
+	Total int64       `json:"total"`
+	// Staleness is the age of the data when it was served from the mirror
+	// during an outage, see WithMirror
+	Staleness time.Duration `json:"-"`
+}
+
+// PersonRow is a person as returned by a find. Use GetPersonInfoById with
+// its CRMID to get the full PersonInfo.
+type PersonRow struct {
+	CRMID              string             `json:"crmId"`
+	CRMObjectTypeCode  string             `json:"crmObjectTypeCode"`
+	RefID              string             `json:"refId"`
+	FirstName          string             `json:"firstName"`
+	LastName           string             `json:"lastName"`
+	NickName           string             `json:"nickName"`
+	NationalCode       string             `json:"nationalCode"`
+	Email              string             `json:"email"`
+	CustomerNumber     string             `json:"customerNumber"`
+	IdentityTypeName   string             `json:"identityTypeName"`
+	PhoneContacts      []PhoneContact     `json:"phoneContacts"`
+	Categories         []Category         `json:"categories"`
+	Subject            string             `json:"subject"`
+	Description        string             `json:"description"`
+	CreatDate          CustomTime         `json:"creatDate"`
+	ModifyDate         CustomTime         `json:"modifyDate"`
+	ExtendedProperties []ExtendedProperty `json:"extendedProperties"`
+}
+
+// Row returns the fields of the person a find returns
+func (p PersonInfo) Row() PersonRow {
+	return PersonRow{
+		CRMID:              p.CRMID,
+		CRMObjectTypeCode:  p.CRMObjectTypeCode,
+		RefID:              p.RefID,
+		FirstName:          p.FirstName,
+		LastName:           p.LastName,
+		NickName:           p.NickName,
+		NationalCode:       p.NationalCode,
+		Email:              p.Email,
+		CustomerNumber:     p.CustomerNumber,
+		IdentityTypeName:   p.IdentityTypeName,
+		PhoneContacts:      p.PhoneContacts,
+		Categories:         p.Categories,
+		Subject:            p.Subject,
+		Description:        p.Description,
+		CreatDate:          p.CreatDate,
+		ModifyDate:         p.ModifyDate,
+		ExtendedProperties: p.ExtendedProperties,
+	}
+}
+
 type FindRequest struct {
-	TypeKey    string  `json:"typeKey"`
-	Queries    []Query `json:"queries"`
-	PageNumber int64   `json:"pageNumber"`
-	PageSize   int64   `json:"pageSize"`
+	TypeKey string  `json:"typeKey"`
+	Queries []Query `json:"queries"`
+	Sorts   []Sort  `json:"sorts,omitempty"`
+	// Fields are the fields and extended properties returned, all when empty
+	Fields     []string `json:"fields,omitempty"`
+	PageNumber int64    `json:"pageNumber"`
+	PageSize   int64    `json:"pageSize"`
+}
+
+// crmIdSortField is the field WithStableFindSort sorts on
+const crmIdSortField = "CrmId"
+
+// Sort orders the results of a find request on Field
+type Sort struct {
+	Field      string `json:"field"`
+	Descending bool   `json:"descending"`
 }
 
 type Query struct {
@@ -298,6 +527,44 @@ type Query struct {
 	FieldOperator       int    `json:"fieldOperator,omitempty"`
 	Value               string `json:"value"`
 	LeafLogicalOperator int    `json:"leafLogicalOperator,omitempty"`
+	// Leaves are the queries of a group, see QueryGroup
+	Leaves []Query `json:"leaves,omitempty"`
+}
+
+// OrganizationMembership is an organization a person works for
+type OrganizationMembership struct {
+	// CRMID is the crm id of the organization
+	CRMID      string `json:"crmId"`
+	Name       string `json:"name"`
+	JobTitle   string `json:"jobTitle"`
+	Department string `json:"department"`
+	// Default marks the primary employer of the person
+	Default bool `json:"default"`
+}
+
+// PrimaryOrganization returns the primary employer of the person: the
+// membership marked Default, or the first one when none is. It returns false
+// when the person has no organization.
+func (p PersonInfo) PrimaryOrganization() (OrganizationMembership, bool) {
+	for _, membership := range p.Organizations {
+		if membership.Default {
+			return membership, true
+		}
+	}
+	if len(p.Organizations) > 0 {
+		return p.Organizations[0], true
+	}
+	return OrganizationMembership{}, false
+}
+
+// Organization returns the membership of the person in the organization crmId
+func (p PersonInfo) Organization(crmId string) (OrganizationMembership, bool) {
+	for _, membership := range p.Organizations {
+		if membership.CRMID == crmId {
+			return membership, true
+		}
+	}
+	return OrganizationMembership{}, false
 }
 
 type Category struct {
@@ -308,23 +575,50 @@ type Category struct {
 }
 
 type ExtendedProperty struct {
-	Value   string      `json:"value"`
-	UserKey string      `json:"userKey"`
-	Preview interface{} `json:"preview"`
+	Value   string       `json:"value"`
+	UserKey string       `json:"userKey"`
+	Preview PreviewValue `json:"preview"`
 }
 
 type PhoneContact struct {
-	PhoneType       string `json:"phoneType"`
-	PhoneNumber     string `json:"phoneNumber"`
-	ContinuedNumber string `json:"continuedNumber"`
-	Extension       string `json:"extension"`
-	ID              string `json:"id"`
-	Default         bool   `json:"default"`
+	PhoneType       enums.PhoneType `json:"phoneType"`
+	PhoneNumber     string          `json:"phoneNumber"`
+	ContinuedNumber string          `json:"continuedNumber"`
+	Extension       string          `json:"extension"`
+	ID              string          `json:"id"`
+	Default         bool            `json:"default"`
+}
+
+// ValidatePhoneContacts checks that a non-empty list of phone contacts has
+// exactly one default contact
+func ValidatePhoneContacts(contacts []PhoneContact) error {
+	if len(contacts) == 0 {
+		return nil
+	}
+
+	defaults := 0
+	for _, contact := range contacts {
+		if contact.Default {
+			defaults++
+		}
+	}
+
+	switch {
+	case defaults == 0:
+		return ErrNoDefaultPhone
+	case defaults > 1:
+		return ErrMultipleDefaultPhones
+	default:
+		return nil
+	}
 }
 
 type FindFormResponse struct {
 	Data  []FormResponse `json:"data"`
 	Total int64          `json:"total"`
+	// Staleness is the age of the data when it was served from the mirror
+	// during an outage, see WithMirror
+	Staleness time.Duration `json:"-"`
 }
 
 type FormResponse struct {
@@ -343,38 +637,123 @@ type FormResponse struct {
 	IdentityID                interface{}        `json:"identityId"`
 	Description               string             `json:"description"`
 	Subject                   string             `json:"subject"`
-	ModifierIDPreview         interface{}        `json:"modifierIdPreview"`
-	CreatorIDPreview          interface{}        `json:"creatorIdPreview"`
+	ModifierIDPreview         Preview            `json:"modifierIdPreview"`
+	CreatorIDPreview          Preview            `json:"creatorIdPreview"`
 	CRMObjectTypeIndexPreview interface{}        `json:"crmObjectTypeIndexPreview"`
-	IdentityIDPreview         interface{}        `json:"identityIdPreview"`
-	AssignedToIDPreview       interface{}        `json:"assignedToIdPreview"`
+	IdentityIDPreview         Preview            `json:"identityIdPreview"`
+	AssignedToIDPreview       Preview            `json:"assignedToIdPreview"`
 	IncludedFields            IncludedFields     `json:"includedFields"`
+	// Extra holds the fields returned by newer servers this version of the
+	// client does not know about
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
+// ProcessLifePath is a stage a BPMS process went through on an object
 type ProcessLifePath struct {
-	ID                 string     `json:"id"`
-	ProcessInstanceID  int64      `json:"processInstanceId"`
-	ProcessTypeStateID string     `json:"processTypeStateId"`
-	Index              int64      `json:"index"`
-	Name               string     `json:"name"`
-	CreateDate         CustomTime `json:"createDate"`
+	ID                 string `json:"id"`
+	ProcessInstanceID  int64  `json:"processInstanceId"`
+	ProcessTypeStateID string `json:"processTypeStateId"`
+	Index              int64  `json:"index"`
+	// Name is the name of the stage
+	Name string `json:"name"`
+	// CreateDate is the date the object entered the stage
+	CreateDate CustomTime `json:"createDate"`
+	// UserID and UserName identify the user who moved the object to the stage
+	UserID   string `json:"userId"`
+	UserName string `json:"userName"`
+}
+
+type startProcessRequest struct {
+	CRMObjectID string `json:"crmObjectId"`
+	ProcessID   string `json:"processId"`
+}
+
+// IncludedFields holds the list fields of an object by their key, as returned
+// when GetRequest.IncludeListFields is set, see WithListFields. Values are
+// kept undecoded since their shape depends on the field; use the accessors to
+// read them.
+type IncludedFields map[string]json.RawMessage
+
+// Has reports whether the field key was returned and is not null
+func (f IncludedFields) Has(key string) bool {
+	value, ok := f[key]
+	return ok && !bytes.Equal(value, []byte("null"))
+}
+
+// Keys returns the keys of the fields, sorted
+func (f IncludedFields) Keys() []string {
+	keys := make([]string, 0, len(f))
+	for key := range f {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Decode decodes the field key into v. A missing field leaves v unchanged.
+func (f IncludedFields) Decode(key string, v any) error {
+	value, ok := f[key]
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(value, v)
+}
+
+// String returns the field key as a string, numbers and booleans formatted.
+// It returns "" for missing, null, list and object fields.
+func (f IncludedFields) String(key string) string {
+	value := bytes.TrimSpace(f[key])
+	if len(value) == 0 {
+		return ""
+	}
+	switch value[0] {
+	case '"':
+		var s string
+		if json.Unmarshal(value, &s) == nil {
+			return s
+		}
+	case '[', '{', 'n':
+	default:
+		return string(value)
+	}
+	return ""
 }
 
-type IncludedFields struct {
+// Strings returns the items of the list field key as strings, formatted as
+// String does. A field holding a single value is returned as a list of one.
+func (f IncludedFields) Strings(key string) []string {
+	value := bytes.TrimSpace(f[key])
+	if len(value) == 0 || value[0] != '[' {
+		if s := f.String(key); s != "" {
+			return []string{s}
+		}
+		return nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(value, &items); err != nil {
+		return nil
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		list = append(list, IncludedFields{key: item}.String(key))
+	}
+	return list
 }
 
 type UpdateFormRequest struct {
-	CrmId              string   `json:"CrmId"`
-	ParentCrmObjectId  *string  `json:"ParentCrmObjectId"`
-	ExtendedProperties []string `json:"ExtendedProperties"`
-	Tags               []string `json:"Tags"`
-	RefId              string   `json:"RefId"`
-	StageId            *string  `json:"StageId"`
-	ColorId            int      `json:"ColorId"`
-	IdentityId         string   `json:"IdentityId"`
-	Description        string   `json:"Description"`
-	Subject            string   `json:"Subject"`
-	AssignedToUserName string   `json:"AssignedToUserName"`
+	CrmId              string             `json:"CrmId"`
+	ParentCrmObjectId  *string            `json:"ParentCrmObjectId"`
+	ExtendedProperties []ExtendedProperty `json:"ExtendedProperties"`
+	Tags               []string           `json:"Tags"`
+	RefId              string             `json:"RefId"`
+	StageId            *string            `json:"StageId"`
+	ColorId            int                `json:"ColorId"`
+	IdentityId         string             `json:"IdentityId"`
+	Description        string             `json:"Description"`
+	Subject            string             `json:"Subject"`
+	AssignedToUserName string             `json:"AssignedToUserName"`
+	// Extra holds fields to send along the known ones, see FormInfo.UpdateRequest
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 // CustomTime handles the time format without the timezone information.
@@ -382,12 +761,17 @@ type CustomTime struct {
 	time.Time
 }
 
+const customTimeLayout = "2006-01-02T15:04:05.999"
+
 // UnmarshalJSON handles the custom parsing logic for the time field.
 func (ct *CustomTime) UnmarshalJSON(b []byte) error {
-	const layout = "2006-01-02T15:04:05.999"
+	if string(b) == "null" {
+		ct.Time = time.Time{}
+		return nil
+	}
 	// Remove quotes from the JSON string
 	s := strings.Trim(string(b), "\"")
-	parsedTime, err := time.Parse(layout, s)
+	parsedTime, err := time.Parse(customTimeLayout, s)
 	if err != nil {
 		return err
 	}
@@ -395,6 +779,11 @@ func (ct *CustomTime) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// MarshalJSON writes the time in the same layout UnmarshalJSON reads.
+func (ct CustomTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ct.Time.Format(customTimeLayout))
+}
+
 type CreateFormRequest struct {
 	CRMObjectTypeCode  string             `json:"CrmObjectTypeCode"`
 	ParentCRMObjectID  *string            `json:"ParentCrmObjectId"`
@@ -408,3 +797,331 @@ type CreateFormRequest struct {
 	Subject            *string            `json:"Subject"`
 	AssignedToUserName *string            `json:"AssignedToUserName"`
 }
+
+type CreateTaskRequest struct {
+	CRMObjectTypeCode  string             `json:"crmObjectTypeCode"`
+	Subject            string             `json:"subject"`
+	Description        *string            `json:"description"`
+	StartDate          *time.Time         `json:"startDate"`
+	DueDate            *time.Time         `json:"dueDate"`
+	AssignedToUserName *string            `json:"assignedToUserName"`
+	RelatedCRMObjectID *string            `json:"relatedCrmObjectId"`
+	IdentityID         *string            `json:"identityId"`
+	ExtendedProperties []ExtendedProperty `json:"extendedProperties"`
+}
+
+type UpdateTaskRequest struct {
+	CrmId              string             `json:"crmId"`
+	Subject            string             `json:"subject"`
+	Description        *string            `json:"description"`
+	StartDate          *time.Time         `json:"startDate"`
+	DueDate            *time.Time         `json:"dueDate"`
+	AssignedToUserName *string            `json:"assignedToUserName"`
+	RelatedCRMObjectID *string            `json:"relatedCrmObjectId"`
+	ExtendedProperties []ExtendedProperty `json:"extendedProperties"`
+}
+
+type CompleteTaskRequest struct {
+	CrmId       string  `json:"crmId"`
+	Description *string `json:"description"`
+}
+
+type TaskInfo struct {
+	CRMID              string             `json:"crmId"`
+	CRMObjectTypeCode  string             `json:"crmObjectTypeCode"`
+	Subject            string             `json:"subject"`
+	Description        string             `json:"description"`
+	StartDate          *CustomTime        `json:"startDate"`
+	DueDate            *CustomTime        `json:"dueDate"`
+	CompletionDate     *CustomTime        `json:"completionDate"`
+	IsCompleted        bool               `json:"isCompleted"`
+	AssignedToUserName string             `json:"assignedToUserName"`
+	RelatedCRMObjectID string             `json:"relatedCrmObjectId"`
+	IdentityID         string             `json:"identityId"`
+	ExtendedProperties []ExtendedProperty `json:"extendedProperties"`
+	CreatDate          CustomTime         `json:"creatDate"`
+	ModifyDate         CustomTime         `json:"modifyDate"`
+}
+
+type FindTaskResponse struct {
+	Data  []TaskInfo `json:"data"`
+	Total int64      `json:"total"`
+}
+
+type CreateTicketRequest struct {
+	CRMObjectTypeCode  string               `json:"crmObjectTypeCode"`
+	Subject            string               `json:"subject"`
+	Description        *string              `json:"description"`
+	IdentityID         string               `json:"identityId"`
+	Priority           enums.TicketPriority `json:"priority"`
+	AssignedToUserName *string              `json:"assignedToUserName"`
+	ExtendedProperties []ExtendedProperty   `json:"extendedProperties"`
+}
+
+type ReplyTicketRequest struct {
+	CrmId     string `json:"crmId"`
+	Body      string `json:"body"`
+	IsPrivate bool   `json:"isPrivate"`
+}
+
+type CloseTicketRequest struct {
+	CrmId       string  `json:"crmId"`
+	Description *string `json:"description"`
+}
+
+type TicketInfo struct {
+	CRMID              string               `json:"crmId"`
+	CRMObjectTypeCode  string               `json:"crmObjectTypeCode"`
+	Number             string               `json:"number"`
+	Subject            string               `json:"subject"`
+	Description        string               `json:"description"`
+	Status             enums.TicketStatus   `json:"status"`
+	Priority           enums.TicketPriority `json:"priority"`
+	IdentityID         string               `json:"identityId"`
+	AssignedToUserName string               `json:"assignedToUserName"`
+	Replies            []TicketReply        `json:"replies"`
+	ExtendedProperties []ExtendedProperty   `json:"extendedProperties"`
+	CreatDate          CustomTime           `json:"creatDate"`
+	ModifyDate         CustomTime           `json:"modifyDate"`
+}
+
+type TicketReply struct {
+	ID           string     `json:"id"`
+	Body         string     `json:"body"`
+	IsPrivate    bool       `json:"isPrivate"`
+	FromCustomer bool       `json:"fromCustomer"`
+	UserName     string     `json:"userName"`
+	CreateDate   CustomTime `json:"createDate"`
+}
+
+type FindTicketResponse struct {
+	Data  []TicketInfo `json:"data"`
+	Total int64        `json:"total"`
+}
+
+type NoteRequest struct {
+	Text      string `json:"text"`
+	IsPrivate bool   `json:"isPrivate"`
+}
+
+type createNoteRequest struct {
+	CRMObjectID string `json:"crmObjectId"`
+	NoteRequest
+}
+
+type listNotesRequest struct {
+	CRMObjectID string `json:"crmObjectId"`
+}
+
+type Note struct {
+	ID              string     `json:"id"`
+	CRMObjectID     string     `json:"crmObjectId"`
+	Text            string     `json:"text"`
+	IsPrivate       bool       `json:"isPrivate"`
+	CreatorUserName string     `json:"creatorUserName"`
+	CreateDate      CustomTime `json:"createDate"`
+}
+
+const invoiceDateLayout = "2006-01-02T15:04:05"
+
+// DateRange bounds a query by date, a zero From or To leaves that side open
+type DateRange struct {
+	From time.Time
+	To   time.Time
+}
+
+type InvoiceSummary struct {
+	CRMID             string      `json:"crmId"`
+	CRMObjectTypeCode string      `json:"crmObjectTypeCode"`
+	Number            string      `json:"number"`
+	Subject           string      `json:"subject"`
+	IdentityID        string      `json:"identityId"`
+	StageID           interface{} `json:"stageId"`
+	InvoiceDate       JalaliDate  `json:"invoiceDate"`
+	ExpireDate        JalaliDate  `json:"expireDate"`
+	TotalValue        Money       `json:"totalValue"`
+	Discount          Money       `json:"discount"`
+	Vat               Money       `json:"vat"`
+	Toll              Money       `json:"toll"`
+	FinalValue        Money       `json:"finalValue"`
+	CreatDate         CustomTime  `json:"creatDate"`
+	ModifyDate        CustomTime  `json:"modifyDate"`
+}
+
+type FindInvoiceResponse struct {
+	Data  []InvoiceSummary `json:"data"`
+	Total int64            `json:"total"`
+}
+
+type CreateReceiptRequest struct {
+	CRMObjectTypeCode  string             `json:"crmObjectTypeCode"`
+	IdentityID         string             `json:"identityId"`
+	Amount             int64              `json:"amount"`
+	ReceiptDate        *JalaliDate        `json:"receiptDate"`
+	TrackingNumber     *string            `json:"trackingNumber"`
+	RelatedInvoiceID   *string            `json:"relatedInvoiceId"`
+	Description        *string            `json:"description"`
+	ExtendedProperties []ExtendedProperty `json:"extendedProperties"`
+}
+
+type SendEmailRequest struct {
+	To                 []string          `json:"to"`
+	Cc                 []string          `json:"cc,omitempty"`
+	Bcc                []string          `json:"bcc,omitempty"`
+	Subject            string            `json:"subject"`
+	HTMLBody           string            `json:"htmlBody"`
+	Attachments        []EmailAttachment `json:"attachments,omitempty"`
+	RelatedCRMObjectID *string           `json:"relatedCrmObjectId"`
+}
+
+// EmailAttachment is a file sent with an email, Content is base64 encoded on the wire
+type EmailAttachment struct {
+	FileName    string `json:"fileName"`
+	ContentType string `json:"contentType"`
+	Content     []byte `json:"content"`
+}
+
+type EmailStatus struct {
+	ID           string      `json:"id"`
+	Status       string      `json:"status"`
+	ErrorMessage string      `json:"errorMessage"`
+	SentDate     *CustomTime `json:"sentDate"`
+}
+
+type CreateOpportunityRequest struct {
+	CRMObjectTypeCode  string             `json:"crmObjectTypeCode"`
+	Subject            string             `json:"subject"`
+	Description        *string            `json:"description"`
+	IdentityID         string             `json:"identityId"`
+	Amount             int64              `json:"amount"`
+	ExpectedCloseDate  *time.Time         `json:"expectedCloseDate"`
+	StageID            *string            `json:"stageId"`
+	Probability        int                `json:"probability"`
+	AssignedToUserName *string            `json:"assignedToUserName"`
+	ExtendedProperties []ExtendedProperty `json:"extendedProperties"`
+}
+
+type UpdateOpportunityStageRequest struct {
+	CrmId       string  `json:"crmId"`
+	StageID     string  `json:"stageId"`
+	Probability *int    `json:"probability"`
+	Description *string `json:"description"`
+}
+
+type OpportunityInfo struct {
+	CRMID              string             `json:"crmId"`
+	CRMObjectTypeCode  string             `json:"crmObjectTypeCode"`
+	Subject            string             `json:"subject"`
+	Description        string             `json:"description"`
+	IdentityID         string             `json:"identityId"`
+	Amount             int64              `json:"amount"`
+	ExpectedCloseDate  *CustomTime        `json:"expectedCloseDate"`
+	StageID            string             `json:"stageId"`
+	StageName          string             `json:"stageName"`
+	Probability        int                `json:"probability"`
+	AssignedToUserName string             `json:"assignedToUserName"`
+	ExtendedProperties []ExtendedProperty `json:"extendedProperties"`
+	CreatDate          CustomTime         `json:"creatDate"`
+	ModifyDate         CustomTime         `json:"modifyDate"`
+}
+
+type FindOpportunityResponse struct {
+	Data  []OpportunityInfo `json:"data"`
+	Total int64             `json:"total"`
+}
+
+type getObjectTypeRequest struct {
+	Code string `json:"code"`
+}
+
+type ObjectType struct {
+	ID         string     `json:"id"`
+	Code       string     `json:"code"`
+	Name       string     `json:"name"`
+	ParentID   string     `json:"parentId"`
+	ModifyDate CustomTime `json:"modifyDate"`
+}
+
+type getUserRequest struct {
+	Username string `json:"username"`
+}
+
+type User struct {
+	ID          string   `json:"id"`
+	Username    string   `json:"username"`
+	DisplayName string   `json:"displayName"`
+	Email       string   `json:"email"`
+	IsActive    bool     `json:"isActive"`
+	Roles       []string `json:"roles"`
+}
+
+type AttachmentInfo struct {
+	ID          string     `json:"id"`
+	CRMObjectID string     `json:"crmObjectId"`
+	FileName    string     `json:"fileName"`
+	ContentType string     `json:"contentType"`
+	Size        int64      `json:"size"`
+	CreateDate  CustomTime `json:"createDate"`
+}
+
+type listAttachmentsRequest struct {
+	CRMObjectID string `json:"crmObjectId"`
+}
+
+type getPicklistRequest struct {
+	Name string `json:"name"`
+}
+
+type PicklistItem struct {
+	ID    string `json:"id"`
+	Key   string `json:"key"`
+	Name  string `json:"name"`
+	Index int    `json:"index"`
+}
+
+type tagsRequest struct {
+	CRMObjectID string   `json:"crmObjectId"`
+	Tags        []string `json:"tags"`
+}
+
+// CreatePersonRequest is the request of CreatePerson
+type CreatePersonRequest struct {
+	CRMObjectTypeCode  string             `json:"crmObjectTypeCode"`
+	FirstName          string             `json:"firstName"`
+	LastName           string             `json:"lastName"`
+	NationalCode       string             `json:"nationalCode,omitempty"`
+	Email              string             `json:"email,omitempty"`
+	PhoneContacts      []PhoneContact     `json:"phoneContacts,omitempty"`
+	ExtendedProperties []ExtendedProperty `json:"extendedProperties,omitempty"`
+	Description        string             `json:"description,omitempty"`
+}
+
+type UpdatePersonRequest struct {
+	CrmId              string             `json:"crmId"`
+	FirstName          *string            `json:"firstName,omitempty"`
+	LastName           *string            `json:"lastName,omitempty"`
+	NationalCode       *string            `json:"nationalCode,omitempty"`
+	Email              *string            `json:"email,omitempty"`
+	PhoneContacts      []PhoneContact     `json:"phoneContacts,omitempty"`
+	ExtendedProperties []ExtendedProperty `json:"extendedProperties,omitempty"`
+	Description        *string            `json:"description,omitempty"`
+}
+
+type Color struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// Product is an item of the product catalog used in invoice details
+type Product struct {
+	ID           string `json:"id"`
+	Code         string `json:"code"`
+	Name         string `json:"name"`
+	UnitTypeName string `json:"unitTypeName"`
+	UnitPrice    Money  `json:"unitPrice"`
+	IsService    bool   `json:"isService"`
+}
+
+type getProductRequest struct {
+	Code string `json:"code"`
+}
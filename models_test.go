@@ -0,0 +1,202 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAPIErrType(t *testing.T) {
+	require.Equal(t, gopayamgostar.APIErrTypeUnknown, gopayamgostar.ParseAPIErrType(nil))
+	require.Equal(t, gopayamgostar.APIErrTypeUnknown, gopayamgostar.ParseAPIErrType(errors.New("boom")))
+	require.Equal(t,
+		gopayamgostar.APIErrType(gopayamgostar.APIErrTypeInvalidGrant),
+		gopayamgostar.ParseAPIErrType(errors.New(`{"error":"invalid_grant"}`)),
+	)
+}
+
+func TestRegisterErrorMatcher(t *testing.T) {
+	const errTypeSessionExpired gopayamgostar.APIErrType = "test: session expired"
+	gopayamgostar.RegisterErrorMatcher(regexp.MustCompile(`(?i)session (has )?expired`), errTypeSessionExpired)
+
+	require.Equal(t, errTypeSessionExpired, gopayamgostar.ParseAPIErrType(errors.New("Session has expired")))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"message":"Session expired, please login again"}`))
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL)
+	_, err := client.CreateForm(context.Background(), "token", gopayamgostar.CreateFormRequest{})
+	require.Error(t, err)
+
+	var apiErr *gopayamgostar.APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusUnauthorized, apiErr.Code)
+	require.Equal(t, errTypeSessionExpired, apiErr.Type)
+}
+
+func TestValidatePhoneContacts(t *testing.T) {
+	require.NoError(t, gopayamgostar.ValidatePhoneContacts(nil))
+	require.NoError(t, gopayamgostar.ValidatePhoneContacts([]gopayamgostar.PhoneContact{
+		{ID: "1", PhoneType: enums.PhoneMobile, Default: true},
+		{ID: "2", PhoneType: enums.PhoneWork},
+	}))
+	require.ErrorIs(t, gopayamgostar.ValidatePhoneContacts([]gopayamgostar.PhoneContact{
+		{ID: "1", PhoneType: enums.PhoneMobile},
+	}), gopayamgostar.ErrNoDefaultPhone)
+	require.ErrorIs(t, gopayamgostar.ValidatePhoneContacts([]gopayamgostar.PhoneContact{
+		{ID: "1", PhoneType: enums.PhoneMobile, Default: true},
+		{ID: "2", PhoneType: enums.PhoneHome, Default: true},
+	}), gopayamgostar.ErrMultipleDefaultPhones)
+}
+
+func TestFormInfoExtra(t *testing.T) {
+	data := []byte(`{
+		"CrmId": "form-1",
+		"subject": "first order",
+		"StageId": "approved",
+		"ExtendedProperties": [{"userKey": "City", "value": "Tehran"}],
+		"WorkflowMetadata": {"version": 3, "state": "waiting"},
+		"Priority": 2
+	}`)
+
+	var form gopayamgostar.FormInfo
+	require.NoError(t, json.Unmarshal(data, &form))
+	require.Equal(t, "form-1", form.CRMID)
+	require.Equal(t, "first order", form.Subject)
+	require.Equal(t, map[string]json.RawMessage{
+		"WorkflowMetadata": json.RawMessage(`{"version": 3, "state": "waiting"}`),
+		"Priority":         json.RawMessage(`2`),
+	}, form.Extra)
+
+	encoded, err := json.Marshal(form)
+	require.NoError(t, err)
+	var again gopayamgostar.FormInfo
+	require.NoError(t, json.Unmarshal(encoded, &again))
+	require.Equal(t, form.CRMID, again.CRMID)
+	require.JSONEq(t, string(form.Extra["WorkflowMetadata"]), string(again.Extra["WorkflowMetadata"]))
+
+	request := form.UpdateRequest()
+	require.Equal(t, "approved", *request.StageId)
+	request.Subject = "updated"
+	encoded, err = json.Marshal(request)
+	require.NoError(t, err)
+
+	var sent map[string]interface{}
+	require.NoError(t, json.Unmarshal(encoded, &sent))
+	require.Equal(t, "updated", sent["Subject"])
+	require.Equal(t, float64(2), sent["Priority"])
+	require.Equal(t, map[string]interface{}{"version": float64(3), "state": "waiting"}, sent["WorkflowMetadata"])
+	require.Equal(t, "Tehran", sent["ExtendedProperties"].([]interface{})[0].(map[string]interface{})["value"])
+}
+
+func TestRequestResponseConversions(t *testing.T) {
+	t.Parallel()
+
+	deprecated := gopayamgostar.CreatePurchase{
+		CrmId:             "ignored",
+		CRMObjectTypeCode: "PurchaseInvoice",
+		IdentityID:        "person-1",
+		FinalValue:        1000,
+	}
+	request, err := deprecated.Request()
+	require.NoError(t, err)
+	require.Equal(t, "PurchaseInvoice", request.CRMObjectTypeCode)
+	require.Equal(t, "person-1", request.IdentityID)
+	require.Equal(t, gopayamgostar.NewMoney(1000), request.FinalValue)
+
+	encoded, err := json.Marshal(request)
+	require.NoError(t, err)
+	require.NotContains(t, string(encoded), "ignored")
+
+	person := gopayamgostar.PersonInfo{CRMID: "person-1", FirstName: "Ali", LastName: "Rezaei", Email: "ali@example.com"}
+	row := person.Row()
+	require.Equal(t, "person-1", row.CRMID)
+	require.Equal(t, "Ali", row.FirstName)
+	require.Equal(t, "ali@example.com", row.Email)
+}
+
+func TestIncludedFields(t *testing.T) {
+	var request gopayamgostar.GetRequest
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"firstName":"Ali","includedFields":{
+			"Branch":"Tehran",
+			"Score":42,
+			"Products":["A",7,true],
+			"Manager":{"id":"m1"},
+			"Removed":null
+		}}`))
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithCache(gopayamgostar.NewMemoryCache(), time.Minute))
+	_, err := client.GetPersonInfoById(context.Background(), "token", "p1")
+	require.NoError(t, err)
+	require.False(t, request.IncludeListFields)
+
+	// the cached person was read without the list fields
+	person, err := client.GetPersonInfoById(gopayamgostar.WithListFields(context.Background()), "token", "p1")
+	require.NoError(t, err)
+	require.True(t, request.IncludeListFields)
+	require.Equal(t, 2, requests)
+
+	fields := person.IncludedFields
+	require.Equal(t, []string{"Branch", "Manager", "Products", "Removed", "Score"}, fields.Keys())
+	require.True(t, fields.Has("Branch"))
+	require.False(t, fields.Has("Removed"))
+	require.False(t, fields.Has("Missing"))
+	require.Equal(t, "Tehran", fields.String("Branch"))
+	require.Equal(t, "42", fields.String("Score"))
+	require.Equal(t, "", fields.String("Manager"))
+	require.Equal(t, "", fields.String("Removed"))
+	require.Equal(t, []string{"A", "7", "true"}, fields.Strings("Products"))
+	require.Equal(t, []string{"Tehran"}, fields.Strings("Branch"))
+	require.Nil(t, fields.Strings("Missing"))
+
+	var manager struct {
+		ID string `json:"id"`
+	}
+	require.NoError(t, fields.Decode("Manager", &manager))
+	require.Equal(t, "m1", manager.ID)
+	require.NoError(t, fields.Decode("Missing", &manager))
+	require.Equal(t, "m1", manager.ID)
+}
+
+func TestPersonOrganizations(t *testing.T) {
+	var person gopayamgostar.PersonInfo
+	require.NoError(t, json.Unmarshal([]byte(`{"organizations":[
+		{"crmId":"o1","name":"Acme","jobTitle":"Engineer","department":"R&D"},
+		{"crmId":"o2","name":"Globex","jobTitle":"CTO","default":true}
+	]}`), &person))
+
+	primary, ok := person.PrimaryOrganization()
+	require.True(t, ok)
+	require.Equal(t, "Globex", primary.Name)
+	membership, ok := person.Organization("o1")
+	require.True(t, ok)
+	require.Equal(t, gopayamgostar.OrganizationMembership{CRMID: "o1", Name: "Acme", JobTitle: "Engineer", Department: "R&D"}, membership)
+	_, ok = person.Organization("o3")
+	require.False(t, ok)
+
+	person.Organizations[1].Default = false
+	primary, _ = person.PrimaryOrganization()
+	require.Equal(t, "Acme", primary.Name)
+	_, ok = gopayamgostar.PersonInfo{}.PrimaryOrganization()
+	require.False(t, ok)
+}
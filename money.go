@@ -0,0 +1,227 @@
+package gopayamgostar
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MoneyDecimals is the number of decimal places a Money amount keeps
+const MoneyDecimals = 4
+
+// moneyScale is the number of units per whole currency unit
+const moneyScale = 10000
+
+// ErrMoneyDivideByZero is returned by Money.Div for a zero divisor
+var ErrMoneyDivideByZero = errors.New("money divided by zero")
+
+// Money is a currency amount with MoneyDecimals decimal places, kept as an
+// exact fixed point number so that fractional currencies add up without
+// float rounding errors. It is encoded as a JSON number, e.g. 1000 or 12.5.
+//
+// Money holds amounts between MinMoney and MaxMoney, about ±922 trillion
+// whole units. Arithmetic whose result falls outside that range saturates at
+// the nearest bound instead of wrapping around; ParseMoney and UnmarshalJSON
+// reject such amounts.
+type Money struct {
+	units int64
+}
+
+var (
+	// MaxMoney is the largest amount a Money holds, 922337203685477.5807
+	MaxMoney = Money{units: math.MaxInt64}
+	// MinMoney is the smallest amount a Money holds, -922337203685477.5808
+	MinMoney = Money{units: math.MinInt64}
+)
+
+// NewMoney returns amount whole currency units, saturated to the range of Money
+func NewMoney(amount int64) Money {
+	return Money{units: saturate(new(big.Int).Mul(big.NewInt(amount), big.NewInt(moneyScale)))}
+}
+
+// ParseMoney parses a decimal amount such as "1000", "-12.5" or "0.0025"
+func ParseMoney(s string) (Money, error) {
+	value := strings.TrimSpace(s)
+	negative := strings.HasPrefix(value, "-")
+	if negative || strings.HasPrefix(value, "+") {
+		value = value[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(value, ".")
+	if whole == "" && fraction == "" || len(fraction) > MoneyDecimals {
+		return Money{}, errors.Errorf("invalid money amount %q", s)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	fraction += strings.Repeat("0", MoneyDecimals-len(fraction))
+
+	digits := whole + fraction
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return Money{}, errors.Errorf("invalid money amount %q", s)
+		}
+	}
+	if negative {
+		digits = "-" + digits
+	}
+	units, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Money{}, errors.Errorf("money amount %q out of range", s)
+	}
+	return Money{units: units}, nil
+}
+
+// Add returns m + o
+func (m Money) Add(o Money) Money {
+	return Money{units: saturate(new(big.Int).Add(big.NewInt(m.units), big.NewInt(o.units)))}
+}
+
+// Sub returns m - o
+func (m Money) Sub(o Money) Money {
+	return Money{units: saturate(new(big.Int).Sub(big.NewInt(m.units), big.NewInt(o.units)))}
+}
+
+// Neg returns -m
+func (m Money) Neg() Money {
+	return Money{units: saturate(new(big.Int).Neg(big.NewInt(m.units)))}
+}
+
+// Mul returns m multiplied by n, e.g. a unit price by a count
+func (m Money) Mul(n int64) Money {
+	return Money{units: saturate(new(big.Int).Mul(big.NewInt(m.units), big.NewInt(n)))}
+}
+
+// Div returns m divided by n, rounded half away from zero. It returns
+// ErrMoneyDivideByZero when n is zero.
+func (m Money) Div(n int64) (Money, error) {
+	if n == 0 {
+		return Money{}, ErrMoneyDivideByZero
+	}
+	return Money{units: divRound(big.NewInt(m.units), big.NewInt(n))}, nil
+}
+
+// Percent returns percent percent of m, rounded half away from zero, e.g.
+// the discount of a DiscountPercent or the VAT of a rate
+func (m Money) Percent(percent Money) Money {
+	product := new(big.Int).Mul(big.NewInt(m.units), big.NewInt(percent.units))
+	return Money{units: divRound(product, big.NewInt(100*moneyScale))}
+}
+
+// Round rounds m to decimals decimal places, half away from zero
+func (m Money) Round(decimals int) Money {
+	if decimals >= MoneyDecimals {
+		return m
+	}
+	step := int64(1)
+	for i := decimals; i < MoneyDecimals; i++ {
+		step *= 10
+	}
+	rounded := new(big.Int).Mul(big.NewInt(divRound(big.NewInt(m.units), big.NewInt(step))), big.NewInt(step))
+	return Money{units: saturate(rounded)}
+}
+
+// Cmp returns -1, 0 or 1 when m is less than, equal to or greater than o
+func (m Money) Cmp(o Money) int {
+	switch {
+	case m.units < o.units:
+		return -1
+	case m.units > o.units:
+		return 1
+	}
+	return 0
+}
+
+// IsZero reports whether m is zero
+func (m Money) IsZero() bool {
+	return m.units == 0
+}
+
+// Int64 returns the whole currency units of m, truncating the fraction
+func (m Money) Int64() int64 {
+	return m.units / moneyScale
+}
+
+// String formats m without trailing zeros, e.g. "1000" or "-12.5"
+func (m Money) String() string {
+	units := m.units
+	sign := ""
+	if units < 0 {
+		sign = "-"
+	}
+	abs := new(big.Int).Abs(big.NewInt(units)).String()
+	if len(abs) <= MoneyDecimals {
+		abs = strings.Repeat("0", MoneyDecimals-len(abs)+1) + abs
+	}
+	whole, fraction := abs[:len(abs)-MoneyDecimals], strings.TrimRight(abs[len(abs)-MoneyDecimals:], "0")
+	if fraction == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + fraction
+}
+
+// MarshalJSON encodes m as a JSON number
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON decodes a JSON number or a string holding a number. null
+// decodes to zero.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*m = Money{}
+		return nil
+	}
+	value := string(data)
+	if strings.HasPrefix(value, `"`) {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+	}
+	parsed, err := ParseMoney(value)
+	if err != nil {
+		// amounts with exponents or more than MoneyDecimals decimals, as
+		// servers encoding amounts as floats send them, are rounded
+		f, _, floatErr := big.ParseFloat(strings.TrimSpace(value), 10, 128, big.ToNearestAway)
+		if floatErr != nil {
+			return err
+		}
+		if parsed, err = ParseMoney(f.Text('f', MoneyDecimals)); err != nil {
+			return err
+		}
+	}
+	*m = parsed
+	return nil
+}
+
+// saturate returns n, or the int64 bound nearest to it when it is out of range
+func saturate(n *big.Int) int64 {
+	switch {
+	case n.IsInt64():
+		return n.Int64()
+	case n.Sign() < 0:
+		return math.MinInt64
+	}
+	return math.MaxInt64
+}
+
+// divRound divides a by b rounding half away from zero, saturated to the
+// range of int64
+func divRound(a, b *big.Int) int64 {
+	quotient, remainder := new(big.Int).QuoRem(a, b, new(big.Int))
+	twice := new(big.Int).Abs(remainder)
+	twice.Lsh(twice, 1)
+	if twice.Cmp(new(big.Int).Abs(b)) >= 0 {
+		if (a.Sign() < 0) != (b.Sign() < 0) {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return saturate(quotient)
+}
@@ -0,0 +1,102 @@
+package gopayamgostar_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+)
+
+func mustMoney(t *testing.T, s string) gopayamgostar.Money {
+	t.Helper()
+	m, err := gopayamgostar.ParseMoney(s)
+	require.NoError(t, err)
+	return m
+}
+
+func TestParseMoney(t *testing.T) {
+	t.Parallel()
+
+	for input, want := range map[string]string{
+		"1000":    "1000",
+		"-12.50":  "-12.5",
+		"0.0025":  "0.0025",
+		".5":      "0.5",
+		"+7":      "7",
+		"-0.0001": "-0.0001",
+	} {
+		require.Equal(t, want, mustMoney(t, input).String(), input)
+	}
+
+	require.Equal(t, gopayamgostar.MinMoney, mustMoney(t, "-922337203685477.5808"))
+	require.Equal(t, gopayamgostar.MaxMoney, mustMoney(t, "922337203685477.5807"))
+
+	for _, input := range []string{"", "-", "1.23456", "1,5", "12a", "99999999999999999999", "--5", "+-5", "-+5", "++5", "922337203685477.5808"} {
+		_, err := gopayamgostar.ParseMoney(input)
+		require.Error(t, err, input)
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	t.Parallel()
+
+	price := mustMoney(t, "19.99")
+	total := price.Mul(3)
+	require.Equal(t, "59.97", total.String())
+	require.Equal(t, "4.7976", total.Percent(mustMoney(t, "8")).String())
+	require.Equal(t, "4.8", total.Percent(mustMoney(t, "8")).Round(2).String())
+	third, err := mustMoney(t, "19.99").Div(3)
+	require.NoError(t, err)
+	require.Equal(t, "6.6633", third.String())
+	third, err = mustMoney(t, "-0.0005").Div(3)
+	require.NoError(t, err)
+	require.Equal(t, "-0.0002", third.String())
+	_, err = price.Div(0)
+	require.ErrorIs(t, err, gopayamgostar.ErrMoneyDivideByZero)
+	require.Equal(t, "-3", mustMoney(t, "-2.5").Round(0).String())
+	require.Equal(t, "0", price.Sub(price).String())
+	require.Equal(t, "-19.99", price.Neg().String())
+	require.Equal(t, 1, total.Cmp(price))
+	require.Equal(t, int64(59), total.Int64())
+	require.Equal(t, gopayamgostar.NewMoney(60), total.Add(mustMoney(t, "0.03")))
+}
+
+func TestMoneySaturates(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, gopayamgostar.MaxMoney, gopayamgostar.NewMoney(1e15))
+	require.Equal(t, gopayamgostar.MinMoney, gopayamgostar.NewMoney(-1e15))
+	require.Equal(t, gopayamgostar.MaxMoney, gopayamgostar.MaxMoney.Add(gopayamgostar.NewMoney(1)))
+	require.Equal(t, gopayamgostar.MinMoney, gopayamgostar.MinMoney.Sub(gopayamgostar.NewMoney(1)))
+	require.Equal(t, gopayamgostar.MaxMoney, gopayamgostar.MinMoney.Neg())
+	require.Equal(t, gopayamgostar.MaxMoney, gopayamgostar.NewMoney(1e14).Mul(100))
+	require.Equal(t, gopayamgostar.MinMoney, gopayamgostar.NewMoney(1e14).Mul(-100))
+	require.Equal(t, gopayamgostar.MaxMoney, gopayamgostar.NewMoney(1e14).Percent(gopayamgostar.NewMoney(1000)))
+	require.Equal(t, gopayamgostar.MaxMoney, gopayamgostar.MaxMoney.Round(0))
+	require.Equal(t, "922337203685477.5807", gopayamgostar.MaxMoney.String())
+	require.Equal(t, "-922337203685477.5808", gopayamgostar.MinMoney.String())
+}
+
+func TestMoneyJSON(t *testing.T) {
+	t.Parallel()
+
+	data, err := json.Marshal(gopayamgostar.Detail{BaseUnitPrice: mustMoney(t, "12.5"), Count: 2, TotalUnitPrice: gopayamgostar.NewMoney(25)})
+	require.NoError(t, err)
+	var sent map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &sent))
+	require.Equal(t, 12.5, sent["baseUnitPrice"])
+	require.Equal(t, float64(25), sent["totalUnitPrice"])
+	require.Equal(t, float64(0), sent["totalVat"])
+
+	var invoice gopayamgostar.InvoiceSummary
+	require.NoError(t, json.Unmarshal([]byte(`{"totalValue": 1.5e6, "discount": "2.25", "vat": 0.123456, "toll": null, "finalValue": 1000}`), &invoice))
+	require.Equal(t, "1500000", invoice.TotalValue.String())
+	require.Equal(t, "2.25", invoice.Discount.String())
+	require.Equal(t, "0.1235", invoice.Vat.String())
+	require.True(t, invoice.Toll.IsZero())
+	require.Equal(t, gopayamgostar.NewMoney(1000), invoice.FinalValue)
+
+	require.Error(t, json.Unmarshal([]byte(`{"totalValue": "lots"}`), &invoice))
+}
@@ -0,0 +1,353 @@
+// Package offline queues form mutations locally while the CRM cannot be
+// reached, as in branch offices with flaky connectivity, and sends them in
+// order once it can. Queued operations are kept in a Store so they survive
+// restarts, and updates and deletes are checked for conflicting changes made
+// on the server in the meantime before they are applied.
+package offline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// LocalIDPrefix starts the ids CreateForm returns for forms not created yet
+const LocalIDPrefix = "local-"
+
+// ErrConflict is wrapped by the ConflictError of an operation whose object
+// was changed or deleted on the server after the operation was queued
+var ErrConflict = errors.New("object changed since the operation was queued")
+
+// Kind is the mutation an Operation applies
+type Kind string
+
+const (
+	KindCreateForm Kind = "CreateForm"
+	KindUpdateForm Kind = "UpdateForm"
+	KindDeleteForm Kind = "DeleteForm"
+)
+
+// Operation is a queued mutation
+type Operation struct {
+	// Seq orders the operations of a queue
+	Seq  uint64 `json:"seq"`
+	Kind Kind   `json:"kind"`
+	// LocalID is the id CreateForm returned for the form it creates
+	LocalID string `json:"localId,omitempty"`
+	// CrmId is the object updated or deleted, which may be a local id. For a
+	// create it is set to the id of the created form once it is applied.
+	CrmId string `json:"crmId,omitempty"`
+	// Request is the JSON encoded request of the mutation
+	Request json.RawMessage `json:"request,omitempty"`
+	// Base is the fingerprint of the object the mutation was made on, empty
+	// when conflicts are not checked
+	Base     string    `json:"base,omitempty"`
+	QueuedAt time.Time `json:"queuedAt"`
+}
+
+// Store persists the operations of a queue. Pending returns the stored
+// operations ordered by Seq, Update replaces the operation with the same Seq
+// and Remove deletes it.
+type Store interface {
+	Append(op Operation) error
+	Pending() ([]Operation, error)
+	Update(op Operation) error
+	Remove(seq uint64) error
+}
+
+// Resolution tells Flush what to do with a conflicting operation
+type Resolution int
+
+const (
+	// Stop ends the flush with a ConflictError and keeps the operation queued
+	Stop Resolution = iota
+	// Skip drops the operation and goes on with the next one
+	Skip
+	// Overwrite applies the operation anyway
+	Overwrite
+)
+
+// ConflictError is returned by Flush for an operation whose object was
+// changed on the server after it was queued. Current is nil when the object
+// was deleted.
+type ConflictError struct {
+	Op      Operation
+	Current *gopayamgostar.FormInfo
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Op.Kind, e.Op.CrmId, ErrConflict)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+// Queue accepts form mutations locally and applies them with Flush
+type Queue struct {
+	client *gopayamgostar.GoPayamgostar
+	store  Store
+
+	// OnConflict decides what to do with an operation whose object changed
+	// since it was queued; nil stops the flush
+	OnConflict func(ctx context.Context, err *ConflictError) Resolution
+
+	mu      sync.Mutex
+	seq     uint64
+	flushMu sync.Mutex
+}
+
+// New creates a queue applying the operations of store with client, after
+// the ones already in the store
+func New(client *gopayamgostar.GoPayamgostar, store Store) (*Queue, error) {
+	pending, err := store.Pending()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the queue")
+	}
+	q := &Queue{client: client, store: store}
+	if len(pending) > 0 {
+		q.seq = pending[len(pending)-1].Seq
+	}
+	return q, nil
+}
+
+// Fingerprint identifies the state of a form, so that changes made to it can
+// be detected
+func Fingerprint(form *gopayamgostar.FormInfo) string {
+	data, _ := json.Marshal(form)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateForm queues the creation of a form and returns a local id standing
+// for it. The local id can be used as the CrmId, parent or any other id of
+// later operations; it is replaced by the id of the form once it is created.
+func (q *Queue) CreateForm(request gopayamgostar.CreateFormRequest) (string, error) {
+	localID := LocalIDPrefix + uuid.NewString()
+	err := q.append(Operation{Kind: KindCreateForm, LocalID: localID}, request)
+	if err != nil {
+		return "", err
+	}
+	return localID, nil
+}
+
+// UpdateForm queues an update. base is the form as read before making the
+// change; when it is set, Flush checks that the form has not been changed on
+// the server since. Build the request with base.UpdateRequest to keep the
+// fields not changed.
+func (q *Queue) UpdateForm(base *gopayamgostar.FormInfo, request gopayamgostar.UpdateFormRequest) error {
+	op := Operation{Kind: KindUpdateForm, CrmId: request.CrmId}
+	if base != nil {
+		op.Base = Fingerprint(base)
+	}
+	return q.append(op, request)
+}
+
+// DeleteForm queues the deletion of the form crmId. base is the form as read
+// before deciding to delete it; when it is set, Flush checks that the form
+// has not been changed on the server since.
+func (q *Queue) DeleteForm(base *gopayamgostar.FormInfo, crmId string) error {
+	op := Operation{Kind: KindDeleteForm, CrmId: crmId}
+	if base != nil {
+		op.Base = Fingerprint(base)
+	}
+	return q.append(op, nil)
+}
+
+// Pending returns the operations not applied yet, in order
+func (q *Queue) Pending() ([]Operation, error) {
+	return q.store.Pending()
+}
+
+func (q *Queue) append(op Operation, request any) error {
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return errors.Wrapf(err, "could not encode %s", op.Kind)
+		}
+		op.Request = data
+	}
+	op.QueuedAt = time.Now()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	op.Seq = q.seq + 1
+	if err := q.store.Append(op); err != nil {
+		return errors.Wrapf(err, "could not queue %s", op.Kind)
+	}
+	q.seq = op.Seq
+	return nil
+}
+
+// Flush applies the queued operations in order and returns how many it
+// applied. It stops at the first operation that fails, which stays queued
+// with the ones after it so that a later Flush resumes from there; call it
+// again once the CRM can be reached. Conflicts are resolved by OnConflict.
+func (q *Queue) Flush(ctx context.Context, accessToken string) (int, error) {
+	q.flushMu.Lock()
+	defer q.flushMu.Unlock()
+
+	pending, err := q.store.Pending()
+	if err != nil {
+		return 0, errors.Wrap(err, "could not read the queue")
+	}
+
+	applied := 0
+	for i, op := range pending {
+		if err := ctx.Err(); err != nil {
+			return applied, err
+		}
+		if strings.HasPrefix(op.CrmId, LocalIDPrefix) {
+			// the create of the form failed to resolve it, which cannot be fixed by retrying
+			return applied, errors.Errorf("%s %s: the form was not created", op.Kind, op.CrmId)
+		}
+
+		skip, err := q.checkConflict(ctx, accessToken, op)
+		if err != nil {
+			return applied, err
+		}
+		if !skip {
+			if err := q.apply(ctx, accessToken, &op, pending[i+1:]); err != nil {
+				return applied, err
+			}
+			applied++
+		}
+		if err := q.store.Remove(op.Seq); err != nil {
+			return applied, errors.Wrapf(err, "could not dequeue %s %s", op.Kind, op.CrmId)
+		}
+	}
+	return applied, nil
+}
+
+// Run flushes the queue every interval until ctx is done, getting a token
+// with accessToken before each flush. Failing flushes are retried at the next
+// interval and reported to onError when set.
+func (q *Queue) Run(ctx context.Context, interval time.Duration, accessToken func(ctx context.Context) (string, error), onError func(error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		token, err := accessToken(ctx)
+		if err == nil {
+			_, err = q.Flush(ctx, token)
+		}
+		if err != nil && ctx.Err() == nil && onError != nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkConflict compares the object of op with its base and returns whether
+// op is to be skipped
+func (q *Queue) checkConflict(ctx context.Context, accessToken string, op Operation) (bool, error) {
+	if op.Base == "" {
+		return false, nil
+	}
+
+	current, err := q.client.GetFormInfoById(gopayamgostar.WithoutCache(ctx), accessToken, op.CrmId)
+	switch {
+	case errors.Is(err, gopayamgostar.ErrNotFound):
+		if op.Kind == KindDeleteForm {
+			// deleted on the server as well
+			return true, nil
+		}
+		current = nil
+	case err != nil:
+		return false, errors.Wrapf(err, "could not check %s for conflicts", op.CrmId)
+	case Fingerprint(current) == op.Base:
+		return false, nil
+	}
+
+	conflict := &ConflictError{Op: op, Current: current}
+	resolution := Stop
+	if q.OnConflict != nil {
+		resolution = q.OnConflict(ctx, conflict)
+	}
+	switch resolution {
+	case Skip:
+		return true, nil
+	case Overwrite:
+		if current == nil {
+			// nothing left to overwrite
+			return true, nil
+		}
+		return false, nil
+	}
+	return false, conflict
+}
+
+// apply sends op and, for a create, replaces its local id in the operations
+// after it
+func (q *Queue) apply(ctx context.Context, accessToken string, op *Operation, later []Operation) error {
+	switch op.Kind {
+	case KindCreateForm:
+		// a create applied by an interrupted flush only has its id left to resolve
+		if op.CrmId == "" {
+			var request gopayamgostar.CreateFormRequest
+			if err := json.Unmarshal(op.Request, &request); err != nil {
+				return errors.Wrapf(err, "could not decode %s", op.Kind)
+			}
+			crmId, err := q.client.CreateForm(ctx, accessToken, request)
+			if err != nil {
+				return errors.Wrapf(err, "could not create %s", op.LocalID)
+			}
+			op.CrmId = crmId
+			if err := q.store.Update(*op); err != nil {
+				return errors.Wrapf(err, "could not record the id of %s", op.LocalID)
+			}
+		}
+		return q.resolve(op.LocalID, op.CrmId, later)
+
+	case KindUpdateForm:
+		var request gopayamgostar.UpdateFormRequest
+		if err := json.Unmarshal(op.Request, &request); err != nil {
+			return errors.Wrapf(err, "could not decode %s", op.Kind)
+		}
+		if _, err := q.client.UpdateForm(ctx, accessToken, request); err != nil {
+			return errors.Wrapf(err, "could not update %s", op.CrmId)
+		}
+		return nil
+
+	case KindDeleteForm:
+		err := q.client.DeleteForm(ctx, accessToken, op.CrmId)
+		if errors.Is(err, gopayamgostar.ErrNotFound) {
+			return nil
+		}
+		return errors.Wrapf(err, "could not delete %s", op.CrmId)
+	}
+	return errors.Errorf("unknown operation %q", op.Kind)
+}
+
+// resolve replaces localID by crmId in the operations later, in place
+func (q *Queue) resolve(localID, crmId string, later []Operation) error {
+	for i, op := range later {
+		request := strings.ReplaceAll(string(op.Request), localID, crmId)
+		if op.CrmId != localID && request == string(op.Request) {
+			continue
+		}
+		if op.CrmId == localID {
+			op.CrmId = crmId
+		}
+		op.Request = json.RawMessage(request)
+		if err := q.store.Update(op); err != nil {
+			return errors.Wrapf(err, "could not resolve %s", localID)
+		}
+		later[i] = op
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package offline_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/erfandiakoo/gopayamgostar/v2/offline"
+	"github.com/stretchr/testify/require"
+)
+
+func setup(t *testing.T) (*gopayamgostartest.Server, *gopayamgostar.GoPayamgostar, string) {
+	server := gopayamgostartest.NewServer()
+	t.Cleanup(server.Close)
+	server.AddUser("admin", "secret")
+	client := server.Client()
+	token, err := client.AdminAuthenticate(context.Background(), "admin", "secret")
+	require.NoError(t, err)
+	return server, client, token.AccessToken
+}
+
+func TestFlushInOrder(t *testing.T) {
+	server, client, accessToken := setup(t)
+	ctx := context.Background()
+	store, err := offline.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	// queued while the CRM cannot be reached
+	down := gopayamgostar.NewClient("http://127.0.0.1:1")
+	queue, err := offline.New(down, store)
+	require.NoError(t, err)
+	parentID, err := queue.CreateForm(gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Order", Subject: gopayamgostar.StringP("parent")})
+	require.NoError(t, err)
+	require.NoError(t, queue.UpdateForm(nil, gopayamgostar.UpdateFormRequest{CrmId: parentID, Subject: "renamed"}))
+	_, err = queue.CreateForm(gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Order", ParentCRMObjectID: &parentID, Subject: gopayamgostar.StringP("child")})
+	require.NoError(t, err)
+
+	applied, err := queue.Flush(ctx, accessToken)
+	require.Error(t, err)
+	require.Zero(t, applied)
+	pending, err := queue.Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 3)
+
+	// flushed after a restart once the CRM is back
+	queue, err = offline.New(client, store)
+	require.NoError(t, err)
+	applied, err = queue.Flush(ctx, accessToken)
+	require.NoError(t, err)
+	require.Equal(t, 3, applied)
+	pending, err = queue.Pending()
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	forms, err := client.FindForm(ctx, accessToken, "Order", nil)
+	require.NoError(t, err)
+	require.Len(t, forms.Data, 2)
+	var parent, child gopayamgostar.FormResponse
+	for _, form := range forms.Data {
+		if form.ParentCRMObjectID == nil {
+			parent = form
+		} else {
+			child = form
+		}
+	}
+	require.Equal(t, "renamed", parent.Subject)
+	require.Equal(t, parent.CRMID, child.ParentCRMObjectID)
+	_, ok := server.Form(parent.CRMID)
+	require.True(t, ok)
+}
+
+func TestFlushConflicts(t *testing.T) {
+	_, client, accessToken := setup(t)
+	ctx := context.Background()
+	crmId, err := client.CreateForm(ctx, accessToken, gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Order", Subject: gopayamgostar.StringP("first")})
+	require.NoError(t, err)
+	base, err := client.GetFormInfoById(ctx, accessToken, crmId)
+	require.NoError(t, err)
+
+	queue, err := offline.New(client, offline.NewMemoryStore())
+	require.NoError(t, err)
+	request := base.UpdateRequest()
+	request.Subject = "local"
+	require.NoError(t, queue.UpdateForm(base, request))
+
+	// changed on the server in the meantime
+	remote := base.UpdateRequest()
+	remote.Subject = "remote"
+	_, err = client.UpdateForm(ctx, accessToken, remote)
+	require.NoError(t, err)
+
+	_, err = queue.Flush(ctx, accessToken)
+	var conflict *offline.ConflictError
+	require.ErrorAs(t, err, &conflict)
+	require.ErrorIs(t, err, offline.ErrConflict)
+	require.Equal(t, "remote", conflict.Current.Subject)
+
+	queue.OnConflict = func(context.Context, *offline.ConflictError) offline.Resolution { return offline.Overwrite }
+	applied, err := queue.Flush(ctx, accessToken)
+	require.NoError(t, err)
+	require.Equal(t, 1, applied)
+	current, err := client.GetFormInfoById(gopayamgostar.WithoutCache(ctx), accessToken, crmId)
+	require.NoError(t, err)
+	require.Equal(t, "local", current.Subject)
+
+	// deleting a form deleted on the server as well is not a conflict
+	require.NoError(t, queue.DeleteForm(current, crmId))
+	require.NoError(t, client.DeleteForm(ctx, accessToken, crmId))
+	applied, err = queue.Flush(ctx, accessToken)
+	require.NoError(t, err)
+	require.Zero(t, applied)
+}
+
+func TestFileStoreCodec(t *testing.T) {
+	dir := t.TempDir()
+	store, err := offline.NewFileStore(dir)
+	require.NoError(t, err)
+	key := func() ([]byte, error) {
+		return bytes.Repeat([]byte{7}, 32), nil
+	}
+	store.Codec = gopayamgostar.StateCodec{Key: key}
+
+	down := gopayamgostar.NewClient("http://127.0.0.1:1")
+	queue, err := offline.New(down, store)
+	require.NoError(t, err)
+	_, err = queue.CreateForm(gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Order", Subject: gopayamgostar.StringP("confidential")})
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	data, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "confidential")
+
+	reopened, err := offline.NewFileStore(dir)
+	require.NoError(t, err)
+	reopened.Codec = gopayamgostar.StateCodec{Key: key}
+	pending, err := reopened.Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Contains(t, string(pending[0].Request), "confidential")
+
+	plain, err := offline.NewFileStore(dir)
+	require.NoError(t, err)
+	_, err = plain.Pending()
+	require.Error(t, err, "encrypted operations are not read without the key")
+}
@@ -0,0 +1,166 @@
+package offline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/pkg/errors"
+)
+
+// MemoryStore is a Store keeping the operations in memory, for tests and
+// clients that do not need the queue to survive restarts
+type MemoryStore struct {
+	mu  sync.Mutex
+	ops []Operation
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) Append(op Operation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ops = append(s.ops, op)
+	return nil
+}
+
+func (s *MemoryStore) Pending() ([]Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Operation(nil), s.ops...), nil
+}
+
+func (s *MemoryStore) Update(op Operation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.ops {
+		if s.ops[i].Seq == op.Seq {
+			s.ops[i] = op
+			return nil
+		}
+	}
+	return errors.Errorf("operation %d is not queued", op.Seq)
+}
+
+func (s *MemoryStore) Remove(seq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.ops {
+		if s.ops[i].Seq == seq {
+			s.ops = append(s.ops[:i], s.ops[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// FileStore is a Store keeping every operation in a JSON file of a
+// directory. Files are replaced atomically, so the queue survives crashes.
+type FileStore struct {
+	dir string
+
+	// Codec encrypts or compresses the files, which are plain JSON by
+	// default. Set it before the first write: files written with another
+	// codec cannot be read.
+	Codec gopayamgostar.StateCodec
+}
+
+// NewFileStore creates a FileStore in dir, creating the directory if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, errors.Wrap(err, "could not create the queue directory")
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(seq uint64) string {
+	// zero padded so that the names sort by seq
+	return filepath.Join(s.dir, fmt.Sprintf("%020d.json", seq))
+}
+
+func (s *FileStore) Append(op Operation) error {
+	return s.write(op)
+}
+
+func (s *FileStore) Pending() ([]Operation, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []Operation
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if !s.Codec.IsZero() {
+			if data, err = s.Codec.Decode(data); err != nil {
+				return nil, errors.Wrapf(err, "could not decode %s", entry.Name())
+			}
+		}
+		var op Operation
+		if err := json.Unmarshal(data, &op); err != nil {
+			return nil, errors.Wrapf(err, "could not decode %s", entry.Name())
+		}
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Seq < ops[j].Seq })
+	return ops, nil
+}
+
+func (s *FileStore) Update(op Operation) error {
+	if _, err := os.Stat(s.path(op.Seq)); err != nil {
+		return err
+	}
+	return s.write(op)
+}
+
+func (s *FileStore) Remove(seq uint64) error {
+	err := os.Remove(s.path(seq))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *FileStore) write(op Operation) error {
+	data, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	if !s.Codec.IsZero() {
+		if data, err = s.Codec.Encode(data); err != nil {
+			return err
+		}
+	}
+	file, err := os.CreateTemp(s.dir, "op-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), s.path(op.Seq))
+}
@@ -0,0 +1,298 @@
+package gopayamgostar
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+)
+
+// Names of the profiles of DefaultPolicies
+const (
+	// PolicyInteractive fails fast for calls a user is waiting on. It is used
+	// by the calls without a policy.
+	PolicyInteractive = "interactive"
+	// PolicyBatch retries patiently in the low priority lane, for exports and syncs
+	PolicyBatch = "batch"
+	// PolicyCritical retries hard in the high priority lane, for writes that
+	// must not be lost such as invoices
+	PolicyCritical = "critical"
+)
+
+var policyContextKey = contextKey("policy")
+
+// Policy bundles the retry, timeout, rate limit and priority settings of a
+// call, see WithPolicies
+type Policy struct {
+	// Retries is the number of times a failed attempt is retried
+	Retries int
+	// RetryWait is the wait before the first retry, doubled for every retry
+	// up to RetryMaxWait
+	RetryWait    time.Duration
+	RetryMaxWait time.Duration
+	// RetryOn decides whether a response or error is retried; nil uses
+	// RetryOnServerErrors
+	RetryOn func(*resty.Response, error) bool
+	// Timeout bounds the whole call, retries included; 0 leaves it to the
+	// context of the call
+	Timeout time.Duration
+	// Priority is the rate limiter lane of the call unless the context sets
+	// one with WithPriority
+	Priority Priority
+	// RateLimiter, when set, is waited for by every attempt of the call on
+	// top of the limiter of the client
+	RateLimiter *RateLimiter
+}
+
+// DefaultPolicies returns the interactive, batch and critical profiles. Change
+// the returned map to tune them before passing it to WithPolicies.
+func DefaultPolicies() map[string]Policy {
+	return map[string]Policy{
+		PolicyInteractive: {
+			Retries:      1,
+			RetryWait:    100 * time.Millisecond,
+			RetryMaxWait: 500 * time.Millisecond,
+			Timeout:      10 * time.Second,
+			Priority:     PriorityHigh,
+		},
+		PolicyBatch: {
+			Retries:      5,
+			RetryWait:    time.Second,
+			RetryMaxWait: 30 * time.Second,
+			Timeout:      5 * time.Minute,
+			Priority:     PriorityLow,
+		},
+		PolicyCritical: {
+			Retries:      8,
+			RetryWait:    500 * time.Millisecond,
+			RetryMaxWait: 10 * time.Second,
+			Timeout:      2 * time.Minute,
+			Priority:     PriorityHigh,
+		},
+	}
+}
+
+// WithPolicy returns a context that makes the calls using it follow the
+// profile name of the client, see WithPolicies. Calls fail when the client
+// has no such profile.
+func WithPolicy(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, policyContextKey, name)
+}
+
+// WithPolicies configures retries, timeouts, rate limits and priorities by
+// named profiles, selected per call with WithPolicy. Calls without a policy
+// use the PolicyInteractive profile when there is one. The retries of the
+// profiles replace the ones set with WithRetry, WithRetryBudget and
+// WithAdaptiveRetry, whatever the order of the options, and the waits
+// Retry-After asks for are capped at the RetryMaxWait of the profile.
+func WithPolicies(policies map[string]Policy) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		g.policies = make(map[string]Policy, len(policies))
+		for name, policy := range policies {
+			if policy.RetryOn == nil {
+				policy.RetryOn = RetryOnServerErrors
+			}
+			g.policies[name] = policy
+		}
+
+		g.restyClient.OnBeforeRequest(func(c *resty.Client, r *resty.Request) error {
+			policy, err := g.policy(r.Context())
+			if err != nil || policy.RateLimiter == nil {
+				return err
+			}
+			ctx := r.Context()
+			return policy.RateLimiter.Wait(ctx, PriorityFromContext(ctx))
+		})
+
+		httpClient := g.restyClient.GetClient()
+		next := httpClient.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		httpClient.Transport = &policyTransport{next: next}
+		g.restyClient.OnSuccess(func(c *resty.Client, resp *resty.Response) {
+			releasePolicy(resp.Request)
+		})
+		g.restyClient.OnError(func(r *resty.Request, err error) {
+			releasePolicy(r)
+		})
+	}
+}
+
+// installPolicyRetries makes the policies the only retry decision of the
+// client. It runs after the options, for the retry options passed after
+// WithPolicies not to add their own.
+func (g *GoPayamgostar) installPolicyRetries() {
+	if g.policies == nil {
+		return
+	}
+	maxRetries := 0
+	var maxWait time.Duration
+	for _, policy := range g.policies {
+		if policy.Retries > maxRetries {
+			maxRetries = policy.Retries
+		}
+		if policy.RetryMaxWait > maxWait {
+			maxWait = policy.RetryMaxWait
+		}
+	}
+
+	// resty clamps the wait to the minimum wait time, so it is set to the
+	// smallest wait and the policies apply their own
+	g.restyClient.
+		SetRetryCount(maxRetries).
+		SetRetryWaitTime(time.Nanosecond).
+		SetRetryMaxWaitTime(maxWait)
+	g.restyClient.RetryConditions = []resty.RetryConditionFunc{func(resp *resty.Response, err error) bool {
+		if resp == nil || resp.Request == nil {
+			return false
+		}
+		policy, perr := g.policy(resp.Request.Context())
+		return perr == nil && resp.Request.Attempt <= policy.Retries && policy.RetryOn(resp, err)
+	}}
+	g.backoff = func(c *resty.Client, resp *resty.Response) (time.Duration, error) {
+		policy, _ := g.policy(resp.Request.Context())
+		return policy.wait(resp.Request.Attempt), nil
+	}
+}
+
+var policyCallContextKey = contextKey("policy-call")
+
+// policyCall holds the timeout of a call with a policy
+type policyCall struct {
+	cancel context.CancelFunc
+
+	mu sync.Mutex
+	// body is the response body of the last attempt
+	body *policyBody
+}
+
+// withPolicy applies the timeout and priority of the policy of ctx. The
+// timeout is released when the call returns, or when the body of a streamed
+// response is closed.
+func (g *GoPayamgostar) withPolicy(ctx context.Context) context.Context {
+	if g.policies == nil {
+		return ctx
+	}
+	policy, err := g.policy(ctx)
+	if err != nil {
+		// reported by the request
+		return ctx
+	}
+
+	if ctx.Value(priorityContextKey) == nil {
+		ctx = WithPriority(ctx, policy.Priority)
+	}
+	if policy.Timeout > 0 {
+		call := &policyCall{}
+		ctx, call.cancel = context.WithTimeout(ctx, policy.Timeout)
+		ctx = context.WithValue(ctx, policyCallContextKey, call)
+	}
+	return ctx
+}
+
+// releasePolicy cancels the timeout of the policy of a call once it returns.
+// The bodies of streamed responses, left open by resty, cancel it when they
+// are closed instead.
+func releasePolicy(r *resty.Request) {
+	call, ok := r.Context().Value(policyCallContextKey).(*policyCall)
+	if !ok {
+		return
+	}
+	call.mu.Lock()
+	body := call.body
+	call.mu.Unlock()
+	if body != nil && body.cancelOnClose(call.cancel) {
+		return
+	}
+	call.cancel()
+}
+
+// policyTransport tracks the response bodies of the calls with a policy
+// timeout, to tell the streamed ones that are still open when they return
+type policyTransport struct {
+	next http.RoundTripper
+}
+
+func (t *policyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	call, ok := req.Context().Value(policyCallContextKey).(*policyCall)
+	if err != nil || !ok {
+		return resp, err
+	}
+	body := &policyBody{ReadCloser: resp.Body}
+	resp.Body = body
+	call.mu.Lock()
+	call.body = body
+	call.mu.Unlock()
+	return resp, nil
+}
+
+// policyBody is a response body calling cancel when it is closed, if it was
+// still open when cancelOnClose was called
+type policyBody struct {
+	io.ReadCloser
+
+	mu     sync.Mutex
+	closed bool
+	cancel context.CancelFunc
+}
+
+// cancelOnClose reports whether the body is still open, in which case cancel
+// is called when it is closed
+func (b *policyBody) cancelOnClose(cancel context.CancelFunc) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+	b.cancel = cancel
+	return true
+}
+
+func (b *policyBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.mu.Lock()
+	b.closed = true
+	cancel := b.cancel
+	b.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	return err
+}
+
+// policy returns the profile selected by ctx
+func (g *GoPayamgostar) policy(ctx context.Context) (Policy, error) {
+	name, ok := ctx.Value(policyContextKey).(string)
+	if !ok {
+		name = PolicyInteractive
+	}
+	policy, found := g.policies[name]
+	if !found && ok {
+		return Policy{}, errors.Errorf("unknown policy %q", name)
+	}
+	return policy, nil
+}
+
+// wait returns the time to wait after the given attempt
+func (p Policy) wait(attempt int) time.Duration {
+	return nonZeroWait(backoff(p.RetryWait, p.RetryMaxWait, attempt))
+}
+
+// capRetryWait caps the wait Retry-After asks for at the RetryMaxWait of the
+// policy of the call
+func (g *GoPayamgostar) capRetryWait(resp *resty.Response, wait time.Duration) time.Duration {
+	if g.policies == nil || resp.Request == nil {
+		return wait
+	}
+	policy, err := g.policy(resp.Request.Context())
+	if err == nil && wait > policy.RetryMaxWait {
+		return policy.RetryMaxWait
+	}
+	return wait
+}
@@ -0,0 +1,132 @@
+package gopayamgostar_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithPolicies(t *testing.T) {
+	var attempts int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		if r.Header.Get("X-Hang") != "" {
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	policies := gopayamgostar.DefaultPolicies()
+	policies[gopayamgostar.PolicyInteractive] = gopayamgostar.Policy{Priority: gopayamgostar.PriorityHigh}
+	policies[gopayamgostar.PolicyBatch] = gopayamgostar.Policy{Retries: 3, RetryWait: time.Millisecond, RetryMaxWait: time.Millisecond}
+	policies["slow"] = gopayamgostar.Policy{Timeout: 100 * time.Millisecond}
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithPolicies(policies))
+
+	var priorities []gopayamgostar.Priority
+	client.RegisterRequestHook(func(info gopayamgostar.HookInfo, req *http.Request) error {
+		priorities = append(priorities, gopayamgostar.PriorityFromContext(req.Context()))
+		if _, ok := req.Context().Deadline(); ok {
+			req.Header.Set("X-Hang", "1")
+		}
+		return nil
+	})
+	ctx := context.Background()
+
+	// calls without a policy use the interactive profile
+	require.Error(t, client.DeleteForm(ctx, "token", "form"))
+	require.Equal(t, int32(1), atomic.SwapInt32(&attempts, 0))
+	require.Equal(t, []gopayamgostar.Priority{gopayamgostar.PriorityHigh}, priorities)
+
+	priorities = nil
+	require.Error(t, client.DeleteForm(gopayamgostar.WithPolicy(ctx, gopayamgostar.PolicyBatch), "token", "form"))
+	require.Equal(t, int32(4), atomic.SwapInt32(&attempts, 0))
+	require.Equal(t, gopayamgostar.PriorityLow, priorities[0])
+
+	err := client.DeleteForm(gopayamgostar.WithPolicy(ctx, "unknown"), "token", "form")
+	require.ErrorContains(t, err, `unknown policy "unknown"`)
+	require.Zero(t, atomic.SwapInt32(&attempts, 0))
+
+	start := time.Now()
+	err = client.DeleteForm(gopayamgostar.WithPolicy(ctx, "slow"), "token", "form")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestPoliciesReplaceRetries(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	policies := gopayamgostar.DefaultPolicies()
+	policies[gopayamgostar.PolicyInteractive] = gopayamgostar.Policy{Retries: 1, RetryWait: time.Millisecond, RetryMaxWait: time.Millisecond}
+	policies[gopayamgostar.PolicyBatch] = gopayamgostar.Policy{Retries: 3, RetryWait: time.Millisecond, RetryMaxWait: 10 * time.Millisecond}
+	for name, options := range map[string][]func(*gopayamgostar.GoPayamgostar){
+		"before": {gopayamgostar.WithRetry(6, time.Millisecond, time.Millisecond, nil), gopayamgostar.WithPolicies(policies)},
+		"after":  {gopayamgostar.WithPolicies(policies), gopayamgostar.WithRetry(6, time.Millisecond, time.Millisecond, nil), gopayamgostar.WithAdaptiveRetry(6, 4)},
+	} {
+		client := gopayamgostar.NewClient(server.URL, options...)
+		ctx := context.Background()
+
+		start := time.Now()
+		require.Error(t, client.DeleteForm(ctx, "token", "form"))
+		require.Equal(t, int32(2), atomic.SwapInt32(&attempts, 0), name)
+		require.Error(t, client.DeleteForm(gopayamgostar.WithPolicy(ctx, gopayamgostar.PolicyBatch), "token", "form"))
+		require.Equal(t, int32(4), atomic.SwapInt32(&attempts, 0), name)
+		require.Less(t, time.Since(start), 5*time.Second, "Retry-After is capped at RetryMaxWait")
+	}
+}
+
+func TestPolicyTimeoutIsReleased(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/download") {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+			_, _ = w.Write([]byte("attachment"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	policies := gopayamgostar.DefaultPolicies()
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithPolicies(policies))
+	var calls []context.Context
+	client.RegisterRequestHook(func(info gopayamgostar.HookInfo, req *http.Request) error {
+		calls = append(calls, req.Context())
+		return nil
+	})
+	ctx := context.Background()
+
+	require.NoError(t, client.DeleteForm(ctx, "token", "form"))
+	require.Len(t, calls, 1)
+	require.ErrorIs(t, calls[0].Err(), context.Canceled, "the timeout is released when the call returns")
+
+	var out bytes.Buffer
+	n, err := client.DownloadAttachment(gopayamgostar.WithPolicy(ctx, gopayamgostar.PolicyBatch), "token", "attachment", &out)
+	require.NoError(t, err, "streamed responses are read under the timeout")
+	require.Equal(t, int64(10), n)
+	require.Equal(t, "attachment", out.String())
+	require.Len(t, calls, 2)
+	require.ErrorIs(t, calls[1].Err(), context.Canceled, "the timeout is released when the body is closed")
+}
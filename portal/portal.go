@@ -0,0 +1,156 @@
+// Package portal composes the low level gopayamgostar calls into the flows a
+// customer self-service portal needs. Every flow runs with a customer Session,
+// and data belonging to other identities is never returned.
+package portal
+
+import (
+	"context"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
+	"github.com/pkg/errors"
+)
+
+// ticketsPageSize is the number of tickets read per request by Tickets
+const ticketsPageSize = 100
+
+var (
+	// ErrNotCustomerSession is returned when a flow is called with a session that
+	// was not created by Portal.Login
+	ErrNotCustomerSession = errors.New("portal: not a customer session")
+	// ErrForeignObject is returned when a request refers to another identity
+	ErrForeignObject = errors.New("portal: object does not belong to the customer")
+)
+
+// IdentityResolver returns the CrmId of the person a customer account belongs to
+type IdentityResolver func(ctx context.Context, client *gopayamgostar.GoPayamgostar, token *gopayamgostar.JWT, username string) (string, error)
+
+// Portal exposes customer-facing flows
+type Portal struct {
+	client          *gopayamgostar.GoPayamgostar
+	resolveIdentity IdentityResolver
+}
+
+// Session is an authenticated customer
+type Session struct {
+	Username   string
+	IdentityID string
+	token      *gopayamgostar.JWT
+}
+
+// Token returns the customer token of the session
+func (s *Session) Token() *gopayamgostar.JWT {
+	return s.token
+}
+
+// New creates a portal on top of client
+func New(client *gopayamgostar.GoPayamgostar, resolveIdentity IdentityResolver) *Portal {
+	return &Portal{
+		client:          client,
+		resolveIdentity: resolveIdentity,
+	}
+}
+
+// Login authenticates a customer and resolves the identity the account belongs to
+func (p *Portal) Login(ctx context.Context, username, password string) (*Session, error) {
+	token, err := p.client.UserAuthenticate(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	identityID, err := p.resolveIdentity(ctx, p.client, token, username)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not resolve customer identity")
+	}
+	if identityID == "" {
+		return nil, errors.New("could not resolve customer identity")
+	}
+
+	return &Session{
+		Username:   username,
+		IdentityID: identityID,
+		token:      token,
+	}, nil
+}
+
+// Profile returns the person record of the customer
+func (p *Portal) Profile(ctx context.Context, session *Session) (*gopayamgostar.PersonInfo, error) {
+	if err := checkSession(session); err != nil {
+		return nil, err
+	}
+	return p.client.GetPersonInfoById(ctx, session.token.AccessToken, session.IdentityID)
+}
+
+// Invoices lists the invoices of the customer issued within dateRange
+func (p *Portal) Invoices(ctx context.Context, session *Session, dateRange gopayamgostar.DateRange) ([]gopayamgostar.InvoiceSummary, error) {
+	if err := checkSession(session); err != nil {
+		return nil, err
+	}
+
+	invoices, err := p.client.GetInvoicesForIdentity(ctx, session.token.AccessToken, session.IdentityID, dateRange, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	own := invoices[:0]
+	for _, invoice := range invoices {
+		if invoice.IdentityID == session.IdentityID {
+			own = append(own, invoice)
+		}
+	}
+	return own, nil
+}
+
+// Tickets lists all the tickets of the customer, reading them page by page
+func (p *Portal) Tickets(ctx context.Context, session *Session) ([]gopayamgostar.TicketInfo, error) {
+	if err := checkSession(session); err != nil {
+		return nil, err
+	}
+
+	queries := []gopayamgostar.Query{
+		{
+			LogicalOperator: int(enums.And),
+			FieldOperator:   int(enums.Equals),
+			Field:           "IdentityId",
+			Value:           session.IdentityID,
+		},
+	}
+
+	own := make([]gopayamgostar.TicketInfo, 0)
+	for page, read := int64(1), int64(0); ; page++ {
+		result, err := p.client.FindTicketsPage(ctx, session.token.AccessToken, queries, page, ticketsPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, ticket := range result.Data {
+			if ticket.IdentityID == session.IdentityID {
+				own = append(own, ticket)
+			}
+		}
+		read += int64(len(result.Data))
+		if len(result.Data) < ticketsPageSize || read >= result.Total {
+			return own, nil
+		}
+	}
+}
+
+// SubmitForm creates a form on behalf of the customer. The form is always bound
+// to the customer identity.
+func (p *Portal) SubmitForm(ctx context.Context, session *Session, request gopayamgostar.CreateFormRequest) (string, error) {
+	if err := checkSession(session); err != nil {
+		return "", err
+	}
+	if request.IdentityID != "" && request.IdentityID != session.IdentityID {
+		return "", ErrForeignObject
+	}
+
+	request.IdentityID = session.IdentityID
+	return p.client.CreateForm(ctx, session.token.AccessToken, request)
+}
+
+func checkSession(session *Session) error {
+	if session == nil || session.token == nil || session.IdentityID == "" {
+		return ErrNotCustomerSession
+	}
+	return nil
+}
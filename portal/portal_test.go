@@ -0,0 +1,160 @@
+package portal_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/faultinject"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/erfandiakoo/gopayamgostar/v2/portal"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// identities maps the customer accounts to the persons they belong to
+func identities(accounts map[string]string) portal.IdentityResolver {
+	return func(ctx context.Context, client *gopayamgostar.GoPayamgostar, token *gopayamgostar.JWT, username string) (string, error) {
+		return accounts[username], nil
+	}
+}
+
+func newPortal(t *testing.T) (*gopayamgostartest.Server, *gopayamgostar.GoPayamgostar, *portal.Portal) {
+	server := gopayamgostartest.NewServer()
+	t.Cleanup(server.Close)
+	server.AddUser("customer", "secret")
+	server.AddUser("stranger", "secret")
+	server.AddPerson(gopayamgostar.PersonInfo{CRMID: "person-1", FirstName: "Sara", LastName: "Ahmadi"})
+	client := server.Client()
+
+	return server, client, portal.New(client, identities(map[string]string{"customer": "person-1"}))
+}
+
+func TestLogin(t *testing.T) {
+	_, client, p := newPortal(t)
+	ctx := context.Background()
+
+	session, err := p.Login(ctx, "customer", "secret")
+	require.NoError(t, err)
+	require.Equal(t, "customer", session.Username)
+	require.Equal(t, "person-1", session.IdentityID)
+	require.NotEmpty(t, session.Token().AccessToken)
+
+	_, err = p.Login(ctx, "customer", "wrong")
+	require.Error(t, err)
+
+	_, err = p.Login(ctx, "stranger", "secret")
+	require.ErrorContains(t, err, "could not resolve customer identity")
+
+	failing := portal.New(client, func(ctx context.Context, client *gopayamgostar.GoPayamgostar, token *gopayamgostar.JWT, username string) (string, error) {
+		return "", errors.New("directory unavailable")
+	})
+	_, err = failing.Login(ctx, "customer", "secret")
+	require.ErrorContains(t, err, "could not resolve customer identity")
+	require.ErrorContains(t, err, "directory unavailable")
+}
+
+func TestProfile(t *testing.T) {
+	_, _, p := newPortal(t)
+	ctx := context.Background()
+
+	session, err := p.Login(ctx, "customer", "secret")
+	require.NoError(t, err)
+
+	person, err := p.Profile(ctx, session)
+	require.NoError(t, err)
+	require.Equal(t, "person-1", person.CRMID)
+	require.Equal(t, "Sara", person.FirstName)
+}
+
+func TestInvoicesAndTicketsAreOwn(t *testing.T) {
+	_, client, p := newPortal(t)
+	faultinject.Install(client).
+		Add(faultinject.Rule{
+			Operation:  "FindInvoice",
+			StatusCode: http.StatusOK,
+			Body:       `{"data":[{"crmId":"invoice-1","identityId":"person-1"},{"crmId":"invoice-2","identityId":"person-2"}],"total":2}`,
+		}).
+		Add(faultinject.Rule{
+			Operation:  "FindTicket",
+			StatusCode: http.StatusOK,
+			Body:       `{"data":[{"crmId":"ticket-1","identityId":"person-2"},{"crmId":"ticket-2","identityId":"person-1"}],"total":2}`,
+		})
+	ctx := context.Background()
+
+	session, err := p.Login(ctx, "customer", "secret")
+	require.NoError(t, err)
+
+	invoices, err := p.Invoices(ctx, session, gopayamgostar.DateRange{})
+	require.NoError(t, err)
+	require.Len(t, invoices, 1)
+	require.Equal(t, "invoice-1", invoices[0].CRMID)
+
+	tickets, err := p.Tickets(ctx, session)
+	require.NoError(t, err)
+	require.Len(t, tickets, 1)
+	require.Equal(t, "ticket-2", tickets[0].CRMID)
+}
+
+func TestTicketsArePaged(t *testing.T) {
+	_, client, p := newPortal(t)
+
+	page := func(from, to, total int) string {
+		response := gopayamgostar.FindTicketResponse{Total: int64(total)}
+		for i := from; i < to; i++ {
+			response.Data = append(response.Data, gopayamgostar.TicketInfo{CRMID: fmt.Sprintf("ticket-%d", i), IdentityID: "person-1"})
+		}
+		body, err := json.Marshal(response)
+		require.NoError(t, err)
+		return string(body)
+	}
+	injector := faultinject.Install(client).
+		Add(faultinject.Rule{Operation: "FindTicket", Times: 1, StatusCode: http.StatusOK, Body: page(0, 100, 130)}).
+		Add(faultinject.Rule{Operation: "FindTicket", Times: 1, StatusCode: http.StatusOK, Body: page(100, 130, 130)})
+	ctx := context.Background()
+
+	session, err := p.Login(ctx, "customer", "secret")
+	require.NoError(t, err)
+
+	tickets, err := p.Tickets(ctx, session)
+	require.NoError(t, err)
+	require.Len(t, tickets, 130)
+	require.Equal(t, "ticket-129", tickets[129].CRMID)
+	require.Equal(t, 2, injector.Applied())
+}
+
+func TestSubmitForm(t *testing.T) {
+	server, _, p := newPortal(t)
+	ctx := context.Background()
+
+	session, err := p.Login(ctx, "customer", "secret")
+	require.NoError(t, err)
+
+	id, err := p.SubmitForm(ctx, session, gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "SupportRequest", Subject: gopayamgostar.StringP("Refund")})
+	require.NoError(t, err)
+	form, ok := server.Form(id)
+	require.True(t, ok)
+	require.Equal(t, "person-1", form.IdentityID)
+
+	_, err = p.SubmitForm(ctx, session, gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "SupportRequest", IdentityID: "person-2"})
+	require.ErrorIs(t, err, portal.ErrForeignObject)
+}
+
+func TestNotCustomerSession(t *testing.T) {
+	_, _, p := newPortal(t)
+	ctx := context.Background()
+
+	for _, session := range []*portal.Session{nil, {Username: "customer", IdentityID: "person-1"}} {
+		_, err := p.Profile(ctx, session)
+		require.ErrorIs(t, err, portal.ErrNotCustomerSession)
+		_, err = p.Invoices(ctx, session, gopayamgostar.DateRange{})
+		require.ErrorIs(t, err, portal.ErrNotCustomerSession)
+		_, err = p.Tickets(ctx, session)
+		require.ErrorIs(t, err, portal.ErrNotCustomerSession)
+		_, err = p.SubmitForm(ctx, session, gopayamgostar.CreateFormRequest{})
+		require.ErrorIs(t, err, portal.ErrNotCustomerSession)
+	}
+}
@@ -0,0 +1,226 @@
+package gopayamgostar
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PreviewKind is the JSON kind of a PreviewValue
+type PreviewKind int
+
+const (
+	PreviewNull PreviewKind = iota
+	PreviewString
+	PreviewNumber
+	PreviewBool
+	PreviewObject
+	PreviewArray
+)
+
+// previewTextKeys are the object keys Text looks for, in order
+var previewTextKeys = []string{"name", "title", "text", "value", "Name", "Title", "Text", "Value"}
+
+// PreviewValue is the preview of an extended property. Its shape depends on
+// the field type: a string for plain fields, an object for lookups and an
+// array for multi-select fields. The raw JSON is kept so the value can be
+// decoded into any type with Decode.
+type PreviewValue struct {
+	kind PreviewKind
+	raw  json.RawMessage
+}
+
+// NewPreviewValue creates a preview holding v
+func NewPreviewValue(v interface{}) (PreviewValue, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return PreviewValue{}, err
+	}
+	var p PreviewValue
+	err = p.UnmarshalJSON(data)
+	return p, err
+}
+
+// UnmarshalJSON keeps the raw preview and detects its kind
+func (p *PreviewValue) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		*p = PreviewValue{}
+		return nil
+	}
+
+	switch data[0] {
+	case 'n':
+		*p = PreviewValue{}
+		return nil
+	case '"':
+		p.kind = PreviewString
+	case 't', 'f':
+		p.kind = PreviewBool
+	case '{':
+		p.kind = PreviewObject
+	case '[':
+		p.kind = PreviewArray
+	default:
+		p.kind = PreviewNumber
+	}
+	if !json.Valid(data) {
+		*p = PreviewValue{}
+		return errors.New("invalid preview JSON")
+	}
+	p.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// MarshalJSON writes the preview as it was received
+func (p PreviewValue) MarshalJSON() ([]byte, error) {
+	if p.kind == PreviewNull {
+		return []byte("null"), nil
+	}
+	return p.raw, nil
+}
+
+// Kind returns the JSON kind of the preview
+func (p PreviewValue) Kind() PreviewKind {
+	return p.kind
+}
+
+// IsNull reports whether the preview is missing or null
+func (p PreviewValue) IsNull() bool {
+	return p.kind == PreviewNull
+}
+
+// Raw returns the JSON of the preview
+func (p PreviewValue) Raw() json.RawMessage {
+	return p.raw
+}
+
+// Decode unmarshals the preview into v
+func (p PreviewValue) Decode(v interface{}) error {
+	data, _ := p.MarshalJSON()
+	return json.Unmarshal(data, v)
+}
+
+// String returns the preview of a string field
+func (p PreviewValue) String() (string, bool) {
+	var s string
+	if p.kind != PreviewString || p.Decode(&s) != nil {
+		return "", false
+	}
+	return s, true
+}
+
+// Number returns the preview of a numeric field
+func (p PreviewValue) Number() (float64, bool) {
+	var n float64
+	if p.kind != PreviewNumber || p.Decode(&n) != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// Bool returns the preview of a boolean field
+func (p PreviewValue) Bool() (bool, bool) {
+	var b bool
+	if p.kind != PreviewBool || p.Decode(&b) != nil {
+		return false, false
+	}
+	return b, true
+}
+
+// Object returns the members of an object preview
+func (p PreviewValue) Object() (map[string]PreviewValue, bool) {
+	var m map[string]PreviewValue
+	if p.kind != PreviewObject || p.Decode(&m) != nil {
+		return nil, false
+	}
+	return m, true
+}
+
+// Array returns the items of an array preview
+func (p PreviewValue) Array() ([]PreviewValue, bool) {
+	var a []PreviewValue
+	if p.kind != PreviewArray || p.Decode(&a) != nil {
+		return nil, false
+	}
+	return a, true
+}
+
+// Text returns a human readable form of the preview: strings as-is, objects
+// by their name, title, text or value member and arrays as the comma
+// separated text of their items.
+func (p PreviewValue) Text() string {
+	switch p.kind {
+	case PreviewString:
+		s, _ := p.String()
+		return s
+	case PreviewNumber:
+		n, _ := p.Number()
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	case PreviewBool:
+		b, _ := p.Bool()
+		return strconv.FormatBool(b)
+	case PreviewObject:
+		m, _ := p.Object()
+		for _, key := range previewTextKeys {
+			if v, ok := m[key]; ok && !v.IsNull() {
+				return v.Text()
+			}
+		}
+		return string(p.raw)
+	case PreviewArray:
+		a, _ := p.Array()
+		texts := make([]string, 0, len(a))
+		for _, item := range a {
+			if text := item.Text(); text != "" {
+				texts = append(texts, text)
+			}
+		}
+		return strings.Join(texts, ", ")
+	}
+	return ""
+}
+
+// Preview is the preview of a reference field such as the creator or the
+// identity of an object, returned when GetRequest.ShowPreviews is set. It is
+// the zero Preview when the field is null or previews were not requested.
+type Preview struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+// IsZero reports whether the preview is missing or null
+func (p Preview) IsZero() bool {
+	return p == Preview{}
+}
+
+// UnmarshalJSON decodes object previews such as {"id": "...", "name": "..."}
+// and previews given as their name only. null decodes to the zero Preview.
+func (p *Preview) UnmarshalJSON(data []byte) error {
+	var value PreviewValue
+	if err := value.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	*p = Preview{}
+	switch value.Kind() {
+	case PreviewNull:
+		return nil
+	case PreviewObject:
+		type plain Preview
+		var object plain
+		if err := value.Decode(&object); err != nil {
+			return err
+		}
+		*p = Preview(object)
+		if p.Name == "" {
+			p.Name = value.Text()
+		}
+		return nil
+	}
+	p.Name = value.Text()
+	return nil
+}
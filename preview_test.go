@@ -0,0 +1,108 @@
+package gopayamgostar_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewValue(t *testing.T) {
+	data, err := os.ReadFile("testdata/extended_properties.json")
+	require.NoError(t, err)
+
+	var properties []gopayamgostar.ExtendedProperty
+	require.NoError(t, json.Unmarshal(data, &properties))
+
+	previews := map[string]gopayamgostar.PreviewValue{}
+	for _, property := range properties {
+		previews[property.UserKey] = property.Preview
+	}
+
+	for key, expected := range map[string]struct {
+		kind gopayamgostar.PreviewKind
+		text string
+	}{
+		"Description": {gopayamgostar.PreviewString, "hello"},
+		"Amount":      {gopayamgostar.PreviewNumber, "1500000"},
+		"IsVip":       {gopayamgostar.PreviewBool, "true"},
+		"City":        {gopayamgostar.PreviewObject, "Tehran"},
+		"Owner":       {gopayamgostar.PreviewObject, "Ali Rezaei"},
+		"Channels":    {gopayamgostar.PreviewArray, "Email, SMS"},
+		"Labels":      {gopayamgostar.PreviewArray, "Gold, Partner"},
+		"Birthday":    {gopayamgostar.PreviewNull, ""},
+		"Legacy":      {gopayamgostar.PreviewNull, ""},
+	} {
+		require.Equal(t, expected.kind, previews[key].Kind(), key)
+		require.Equal(t, expected.text, previews[key].Text(), key)
+	}
+
+	s, ok := previews["Description"].String()
+	require.True(t, ok)
+	require.Equal(t, "hello", s)
+	_, ok = previews["Amount"].String()
+	require.False(t, ok)
+
+	n, ok := previews["Amount"].Number()
+	require.True(t, ok)
+	require.Equal(t, float64(1500000), n)
+
+	city, ok := previews["City"].Object()
+	require.True(t, ok)
+	id, _ := city["id"].String()
+	require.Equal(t, "5b0f1c2e-2d3b-4c5d-9e8f-0a1b2c3d4e5f", id)
+
+	labels, ok := previews["Labels"].Array()
+	require.True(t, ok)
+	require.Len(t, labels, 2)
+
+	var owner struct {
+		Name string
+		Code int
+	}
+	require.NoError(t, previews["Owner"].Decode(&owner))
+	require.Equal(t, 12, owner.Code)
+
+	out, err := json.Marshal(properties)
+	require.NoError(t, err)
+	var roundTrip []gopayamgostar.ExtendedProperty
+	require.NoError(t, json.Unmarshal(out, &roundTrip))
+	require.Len(t, roundTrip, len(properties))
+	for i := range properties {
+		require.Equal(t, properties[i].Preview.Kind(), roundTrip[i].Preview.Kind())
+		require.Equal(t, properties[i].Preview.Text(), roundTrip[i].Preview.Text())
+	}
+}
+
+func TestNewPreviewValue(t *testing.T) {
+	preview, err := gopayamgostar.NewPreviewValue(map[string]string{"name": "Tehran"})
+	require.NoError(t, err)
+	require.Equal(t, gopayamgostar.PreviewObject, preview.Kind())
+	require.Equal(t, "Tehran", preview.Text())
+
+	require.Error(t, new(gopayamgostar.PreviewValue).UnmarshalJSON([]byte(`{"broken`)))
+}
+
+func TestPreview(t *testing.T) {
+	var person gopayamgostar.PersonInfo
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"creatorIdPreview": {"id": "u1", "name": "Admin"},
+		"modifierIdPreview": "Sara Ahmadi",
+		"identityIdPreview": {"Name": "Ali Rezaei"},
+		"assignedToIdPreview": null
+	}`), &person))
+	require.Equal(t, gopayamgostar.Preview{ID: "u1", Name: "Admin"}, person.CreatorIDPreview)
+	require.Equal(t, gopayamgostar.Preview{Name: "Sara Ahmadi"}, person.ModifierIDPreview)
+	require.Equal(t, "Ali Rezaei", person.IdentityIDPreview.Name)
+	require.True(t, person.AssignedToIDPreview.IsZero())
+
+	var form gopayamgostar.FormInfo
+	require.NoError(t, json.Unmarshal([]byte(`{"CrmObjectTypeName": {"Name": "Order"}, "CreatorIdPreview": {"title": "Admin"}}`), &form))
+	require.Equal(t, "Order", form.CRMObjectTypeName.Name)
+	require.Equal(t, "Admin", form.CreatorIDPreview.Name)
+
+	var preview gopayamgostar.Preview
+	require.Error(t, json.Unmarshal([]byte(`{"broken`), &preview))
+}
@@ -0,0 +1,162 @@
+package gopayamgostar
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
+)
+
+// MaxPageSize is the largest page size the find endpoints accept
+const MaxPageSize = 1000
+
+// QueryGroup combines queries and nested groups with a single logical
+// operator, for conditions a flat []Query cannot express such as
+// (A AND B) OR (C AND D):
+//
+//	group := AnyOf().
+//		AddGroup(AllOf(a, b), AllOf(c, d))
+//	forms, err := client.FindForm(ctx, accessToken, "Order", group.Queries())
+//
+// It is sent as a query whose leaves are its items and groups.
+type QueryGroup struct {
+	// Or combines the items and groups with OR instead of AND
+	Or bool
+	// Negate matches the objects the group does not match
+	Negate bool
+	Items  []Query
+	Groups []QueryGroup
+}
+
+// AllOf returns a group matching the objects that match every query
+func AllOf(queries ...Query) *QueryGroup {
+	return &QueryGroup{Items: queries}
+}
+
+// AnyOf returns a group matching the objects that match any of the queries
+func AnyOf(queries ...Query) *QueryGroup {
+	return &QueryGroup{Or: true, Items: queries}
+}
+
+// Add adds queries to the group
+func (q *QueryGroup) Add(queries ...Query) *QueryGroup {
+	q.Items = append(q.Items, queries...)
+	return q
+}
+
+// AddGroup nests groups in the group
+func (q *QueryGroup) AddGroup(groups ...*QueryGroup) *QueryGroup {
+	for _, group := range groups {
+		q.Groups = append(q.Groups, *group)
+	}
+	return q
+}
+
+// Not negates the group
+func (q *QueryGroup) Not() *QueryGroup {
+	q.Negate = !q.Negate
+	return q
+}
+
+// Query returns the group as a query whose leaves are its items and groups,
+// each joined to the previous one with the operator of the group
+func (q QueryGroup) Query() Query {
+	operator := int(enums.And)
+	if q.Or {
+		operator = int(enums.Or)
+	}
+	leaves := make([]Query, 0, len(q.Items)+len(q.Groups))
+	leaves = append(leaves, q.Items...)
+	for _, group := range q.Groups {
+		leaves = append(leaves, group.Query())
+	}
+	for i := range leaves {
+		leaves[i].LogicalOperator = operator
+	}
+	return Query{
+		LeafNegate:          q.Negate,
+		LeafLogicalOperator: operator,
+		Leaves:              leaves,
+	}
+}
+
+// Queries returns the group as the queries of a find request
+func (q QueryGroup) Queries() []Query {
+	return []Query{q.Query()}
+}
+
+// Validate checks the paging and queries of the request, so that mistakes are
+// reported with the query at fault instead of by a failed round trip. The
+// errors wrap ErrInvalidQuery.
+func (r FindRequest) Validate() error {
+	if r.PageNumber < 1 {
+		return fmt.Errorf("%w: page number %d, pages start at 1", ErrInvalidQuery, r.PageNumber)
+	}
+	if r.PageSize < 1 || r.PageSize > MaxPageSize {
+		return fmt.Errorf("%w: page size %d, expected 1 to %d", ErrInvalidQuery, r.PageSize, MaxPageSize)
+	}
+	for i, query := range r.Queries {
+		if err := query.validate(fmt.Sprintf("queries[%d]", i)); err != nil {
+			return err
+		}
+	}
+	for i, sort := range r.Sorts {
+		if strings.TrimSpace(sort.Field) == "" {
+			return fmt.Errorf("%w: sorts[%d]: field is empty", ErrInvalidQuery, i)
+		}
+	}
+	return nil
+}
+
+// Validate checks the query and, for a group, its leaves: a leaf needs a
+// field and an operator that fits its value, and a group no field of its own.
+// The errors wrap ErrInvalidQuery.
+func (q Query) Validate() error {
+	return q.validate("query")
+}
+
+func (q Query) validate(path string) error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidQuery, path, fmt.Sprintf(format, args...))
+	}
+
+	if q.LogicalOperator < int(enums.And) || q.LogicalOperator > int(enums.OrNot) {
+		return invalid("unknown logical operator %d", q.LogicalOperator)
+	}
+	if len(q.Leaves) > 0 {
+		if q.Field != "" || q.Value != "" || q.FieldOperator != int(enums.Equals) {
+			return invalid("a group cannot have a field, operator or value")
+		}
+		if q.LeafLogicalOperator < int(enums.And) || q.LeafLogicalOperator > int(enums.OrNot) {
+			return invalid("unknown leaf logical operator %d", q.LeafLogicalOperator)
+		}
+		for i, leaf := range q.Leaves {
+			if err := leaf.validate(fmt.Sprintf("%s.leaves[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// the leaf operators of a query without leaves are passed on as they were
+	// before groups, and regular expressions are left to the server, whose
+	// syntax Go does not fully support
+	if strings.TrimSpace(q.Field) == "" {
+		return invalid("field is empty")
+	}
+	switch enums.FieldOperator(q.FieldOperator) {
+	case enums.In, enums.NotIn:
+		if q.Value == "" {
+			return invalid("%s needs a comma separated list of values", q.Field)
+		}
+	case enums.Regex, enums.Modulo, enums.Lenght:
+		if q.Value == "" {
+			return invalid("%s needs a value", q.Field)
+		}
+	default:
+		if q.FieldOperator < int(enums.Equals) || q.FieldOperator > int(enums.Lenght) {
+			return invalid("%s has unknown operator %d", q.Field, q.FieldOperator)
+		}
+	}
+	return nil
+}
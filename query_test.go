@@ -0,0 +1,145 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
+)
+
+func TestQueryGroup(t *testing.T) {
+	t.Parallel()
+
+	equals := func(field, value string) gopayamgostar.Query {
+		return gopayamgostar.Query{Field: field, Value: value, FieldOperator: int(enums.Equals)}
+	}
+	// (City = Tehran AND Status = open) OR (City = Shiraz AND Status = closed)
+	group := gopayamgostar.AnyOf().AddGroup(
+		gopayamgostar.AllOf(equals("City", "Tehran"), equals("Status", "open")),
+		gopayamgostar.AllOf(equals("City", "Shiraz"), equals("Status", "closed")),
+	)
+
+	data, err := json.Marshal(group.Queries())
+	require.NoError(t, err)
+	require.JSONEq(t, `[{
+		"logicalOperator": 0, "operator": 0, "field": "", "value": "", "leafLogicalOperator": 1,
+		"leaves": [
+			{"logicalOperator": 1, "operator": 0, "field": "", "value": "", "leaves": [
+				{"logicalOperator": 0, "operator": 0, "field": "City", "value": "Tehran"},
+				{"logicalOperator": 0, "operator": 0, "field": "Status", "value": "open"}
+			]},
+			{"logicalOperator": 1, "operator": 0, "field": "", "value": "", "leaves": [
+				{"logicalOperator": 0, "operator": 0, "field": "City", "value": "Shiraz"},
+				{"logicalOperator": 0, "operator": 0, "field": "Status", "value": "closed"}
+			]}
+		]
+	}]`, string(data))
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	ids := map[string]string{}
+	for _, form := range []struct{ name, city, status string }{
+		{"tehran-open", "Tehran", "open"},
+		{"tehran-closed", "Tehran", "closed"},
+		{"shiraz-open", "Shiraz", "open"},
+		{"shiraz-closed", "Shiraz", "closed"},
+	} {
+		id, err := client.CreateForm(ctx, token.AccessToken, gopayamgostar.CreateFormRequest{
+			CRMObjectTypeCode: "Complaint",
+			ExtendedProperties: []gopayamgostar.ExtendedProperty{
+				{UserKey: "City", Value: form.city},
+				{UserKey: "Status", Value: form.status},
+			},
+		})
+		require.NoError(t, err)
+		ids[id] = form.name
+	}
+
+	find := func(queries []gopayamgostar.Query) []string {
+		forms, err := client.FindForm(ctx, token.AccessToken, "Complaint", queries)
+		require.NoError(t, err)
+		var names []string
+		for _, form := range forms.Data {
+			names = append(names, ids[form.CRMID])
+		}
+		sort.Strings(names)
+		return names
+	}
+	require.Equal(t, []string{"shiraz-closed", "tehran-open"}, find(group.Queries()))
+	require.Equal(t, []string{"shiraz-open", "tehran-closed"}, find(group.Not().Queries()))
+	require.Equal(t, []string{"shiraz-closed", "shiraz-open", "tehran-open"},
+		find(gopayamgostar.AnyOf(equals("City", "Shiraz")).AddGroup(gopayamgostar.AllOf(equals("Status", "open")).Add(equals("City", "Tehran"))).Queries()))
+}
+
+func TestFindRequestValidate(t *testing.T) {
+	t.Parallel()
+
+	valid := gopayamgostar.FindRequest{
+		TypeKey:    "Complaint",
+		Queries:    gopayamgostar.AnyOf(gopayamgostar.Query{Field: "City", Value: "Tehran"}).Queries(),
+		PageNumber: 1,
+		PageSize:   gopayamgostar.MaxPageSize,
+	}
+	require.NoError(t, valid.Validate())
+
+	for name, test := range map[string]struct {
+		change func(*gopayamgostar.FindRequest)
+		err    string
+	}{
+		"page number": {func(r *gopayamgostar.FindRequest) { r.PageNumber = 0 }, "page number 0, pages start at 1"},
+		"page size":   {func(r *gopayamgostar.FindRequest) { r.PageSize = gopayamgostar.MaxPageSize + 1 }, "page size 1001, expected 1 to 1000"},
+		"empty field": {func(r *gopayamgostar.FindRequest) { r.Queries[0].Leaves[0].Field = " " }, "queries[0].leaves[0]: field is empty"},
+		"in without values": {func(r *gopayamgostar.FindRequest) {
+			r.Queries[0].Leaves[0].FieldOperator = int(enums.In)
+			r.Queries[0].Leaves[0].Value = ""
+		}, "queries[0].leaves[0]: City needs a comma separated list of values"},
+		"empty regex": {func(r *gopayamgostar.FindRequest) {
+			r.Queries[0].Leaves[0].FieldOperator = int(enums.Regex)
+			r.Queries[0].Leaves[0].Value = ""
+		}, "queries[0].leaves[0]: City needs a value"},
+		"unknown operator":   {func(r *gopayamgostar.FindRequest) { r.Queries[0].Leaves[0].FieldOperator = 42 }, "City has unknown operator 42"},
+		"group with a field": {func(r *gopayamgostar.FindRequest) { r.Queries[0].Field = "City" }, "queries[0]: a group cannot have a field"},
+		"sort":               {func(r *gopayamgostar.FindRequest) { r.Sorts = []gopayamgostar.Sort{{}} }, "sorts[0]: field is empty"},
+	} {
+		request := valid
+		request.Queries = gopayamgostar.AnyOf(gopayamgostar.Query{Field: "City", Value: "Tehran"}).Queries()
+		test.change(&request)
+		err := request.Validate()
+		require.ErrorIs(t, err, gopayamgostar.ErrInvalidQuery, name)
+		require.ErrorContains(t, err, test.err, name)
+	}
+
+	// flat queries keep accepting the leaf operators, and patterns Go cannot
+	// compile are left to the server
+	flat := valid
+	flat.Queries = []gopayamgostar.Query{
+		{Field: "City", Value: "Tehran", LeafNegate: true, LeafLogicalOperator: int(enums.Or)},
+		{Field: "Phone", FieldOperator: int(enums.Regex), Value: `^(?!021)\d+$`},
+	}
+	require.NoError(t, flat.Validate())
+
+	// invalid requests are not sent
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+	_, err := gopayamgostar.NewClient(server.URL).FindFormPage(context.Background(), "token", "Complaint", []gopayamgostar.Query{{Value: "Tehran"}}, 1, 10)
+	require.EqualError(t, err, "invalid query: queries[0]: field is empty")
+	require.Zero(t, atomic.LoadInt32(&calls))
+}
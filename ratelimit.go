@@ -0,0 +1,169 @@
+package gopayamgostar
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Priority selects the rate limiter lane a request waits in
+type Priority int
+
+const (
+	// PriorityLow is the batch lane used by default, e.g. for exports and syncs
+	PriorityLow Priority = iota
+	// PriorityHigh is the interactive lane for latency sensitive lookups. Its
+	// requests are always let through before the waiting batch requests.
+	PriorityHigh
+)
+
+var priorityContextKey = contextKey("priority")
+
+// WithPriority returns a context that makes the requests using it wait in the
+// lane of priority
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityContextKey, priority)
+}
+
+// PriorityFromContext returns the priority set with WithPriority, PriorityLow
+// when none was set
+func PriorityFromContext(ctx context.Context) Priority {
+	priority, _ := ctx.Value(priorityContextKey).(Priority)
+	return priority
+}
+
+// RateLimiter is a token bucket shared by the requests of one or more
+// clients. Requests of the high priority lane take tokens first so that
+// background batch work sharing the limiter never delays interactive calls.
+type RateLimiter struct {
+	mu          sync.Mutex
+	rate        float64
+	burst       float64
+	tokens      float64
+	last        time.Time
+	highWaiting int
+	pausedUntil time.Time
+}
+
+// NewRateLimiter creates a limiter allowing rps requests per second on
+// average and bursts of up to burst requests
+func NewRateLimiter(rps float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a request of priority may be sent or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context, priority Priority) error {
+	high := priority >= PriorityHigh
+	registered := false
+	defer func() {
+		if registered {
+			l.mu.Lock()
+			l.highWaiting--
+			l.mu.Unlock()
+		}
+	}()
+
+	for {
+		l.mu.Lock()
+		l.refill()
+		paused := time.Until(l.pausedUntil)
+		if paused <= 0 && l.tokens >= 1 && (high || l.highWaiting == 0) {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		if high && !registered {
+			registered = true
+			l.highWaiting++
+		}
+		delay := l.delay()
+		if paused > 0 {
+			delay = paused
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Pause holds every request waiting for the limiter for d, such as the
+// Retry-After of a 429 response. Pauses only ever extend.
+func (l *RateLimiter) Pause(d time.Duration) {
+	until := time.Now().Add(d)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// refill adds the tokens accumulated since the last call, l.mu must be held
+func (l *RateLimiter) refill() {
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+}
+
+// delay estimates the time until the next token is available, l.mu must be held
+func (l *RateLimiter) delay() time.Duration {
+	if l.rate <= 0 {
+		return time.Second
+	}
+	missing := math.Max(1-l.tokens, 0)
+	delay := time.Duration(missing / l.rate * float64(time.Second))
+	if delay < time.Millisecond {
+		delay = time.Millisecond
+	}
+	return delay
+}
+
+// WithRateLimit makes the client send at most rps requests per second on
+// average with bursts of up to burst requests. Pass the limiter returned by
+// RateLimiter to WithRateLimiter to share the budget with other clients.
+func WithRateLimit(rps float64, burst int) func(*GoPayamgostar) {
+	return WithRateLimiter(NewRateLimiter(rps, burst))
+}
+
+// WithRateLimiter makes every request of the client wait for limiter in the
+// lane of its context priority, see WithPriority. A 429 or 503 response with
+// a Retry-After header pauses the limiter for that long, up to the maximum
+// retry wait of the client, so that concurrent requests such as those of bulk
+// operations back off together.
+func WithRateLimiter(limiter *RateLimiter) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		g.rateLimiter = limiter
+		g.restyClient.OnBeforeRequest(func(c *resty.Client, r *resty.Request) error {
+			ctx := r.Context()
+			return limiter.Wait(ctx, PriorityFromContext(ctx))
+		})
+		g.restyClient.OnAfterResponse(func(c *resty.Client, resp *resty.Response) error {
+			if wait, ok := retryAfterHeader(resp); ok {
+				limiter.Pause(g.capRetryAfter(c, resp, wait))
+			}
+			return nil
+		})
+	}
+}
+
+// RateLimiter returns the limiter installed with WithRateLimit or
+// WithRateLimiter, nil when the client is not rate limited
+func (g *GoPayamgostar) RateLimiter() *RateLimiter {
+	return g.rateLimiter
+}
@@ -0,0 +1,112 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiterPriority(t *testing.T) {
+	limiter := gopayamgostar.NewRateLimiter(50, 1)
+	require.NoError(t, limiter.Wait(context.Background(), gopayamgostar.PriorityLow))
+
+	var (
+		mu    sync.Mutex
+		order []gopayamgostar.Priority
+		wg    sync.WaitGroup
+	)
+	wait := func(priority gopayamgostar.Priority) {
+		defer wg.Done()
+		require.NoError(t, limiter.Wait(context.Background(), priority))
+		mu.Lock()
+		order = append(order, priority)
+		mu.Unlock()
+	}
+
+	wg.Add(3)
+	go wait(gopayamgostar.PriorityLow)
+	go wait(gopayamgostar.PriorityLow)
+	time.Sleep(5 * time.Millisecond)
+	go wait(gopayamgostar.PriorityHigh)
+	wg.Wait()
+
+	require.Equal(t, []gopayamgostar.Priority{
+		gopayamgostar.PriorityHigh,
+		gopayamgostar.PriorityLow,
+		gopayamgostar.PriorityLow,
+	}, order)
+}
+
+func TestWithRateLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	limiter := gopayamgostar.NewRateLimiter(1, 1)
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithRateLimiter(limiter))
+	require.NoError(t, client.DeleteForm(context.Background(), "token", "form"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := client.DeleteForm(gopayamgostar.WithPriority(ctx, gopayamgostar.PriorityHigh), "token", "form")
+	require.ErrorContains(t, err, context.DeadlineExceeded.Error())
+}
+
+func TestWithRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithRateLimit(1, 1))
+	require.NotNil(t, client.RateLimiter())
+	require.Nil(t, gopayamgostar.NewClient(server.URL).RateLimiter())
+
+	// a second client sharing the limiter spends the same budget
+	shared := gopayamgostar.NewClient(server.URL, gopayamgostar.WithRateLimiter(client.RateLimiter()))
+	require.NoError(t, client.DeleteForm(context.Background(), "token", "form"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := shared.DeleteForm(ctx, "token", "form")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestRateLimiterPause(t *testing.T) {
+	var attempts int32
+	retryAfter := "1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	limiter := gopayamgostar.NewRateLimiter(1000, 10)
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithRateLimiter(limiter))
+	require.Error(t, client.DeleteForm(context.Background(), "token", "form"))
+
+	// the other requests of the limiter wait for the Retry-After too
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, limiter.Wait(ctx, gopayamgostar.PriorityHigh), context.DeadlineExceeded)
+
+	start := time.Now()
+	require.NoError(t, client.DeleteForm(context.Background(), "token", "form"))
+	require.Greater(t, time.Since(start), 500*time.Millisecond)
+
+	// the pause is capped at the maximum retry wait
+	atomic.StoreInt32(&attempts, 0)
+	retryAfter = "86400"
+	limiter = gopayamgostar.NewRateLimiter(1000, 10)
+	client = gopayamgostar.NewClient(server.URL, gopayamgostar.WithRateLimiter(limiter), gopayamgostar.WithRetry(0, time.Millisecond, 100*time.Millisecond, nil))
+	require.Error(t, client.DeleteForm(context.Background(), "token", "form"))
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, limiter.Wait(ctx, gopayamgostar.PriorityHigh))
+}
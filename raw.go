@@ -0,0 +1,42 @@
+package gopayamgostar
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Do calls an endpoint the SDK does not wrap yet, with the authentication,
+// retries, rate limiting, tracing and hooks of the wrapped calls. path is
+// relative to the base path of the client, e.g. "api/v2/crmobject/quote/get",
+// and may carry a query string. body, when not nil, is sent as JSON and the
+// response is decoded into result when it is not nil. Error responses are
+// returned as *APIError like the ones of the other methods.
+func (g *GoPayamgostar) Do(ctx context.Context, accessToken string, method, path string, body, result interface{}) error {
+	errMessage := "could not call " + method + " " + path
+
+	if strings.Contains(path, "://") {
+		return errors.Errorf("%s: path must be relative to the base path", errMessage)
+	}
+
+	request := g.GetRequestWithBearerAuthNoCache(ctx, accessToken)
+	if body != nil {
+		request.SetBody(body)
+	}
+
+	resp, err := request.Execute(strings.ToUpper(method), g.basePath+"/"+strings.TrimLeft(path, urlSeparator))
+	if err := g.checkForError(resp, err, errMessage); err != nil {
+		return err
+	}
+
+	if result == nil || resp.StatusCode() == http.StatusNoContent {
+		return nil
+	}
+	if err := g.unmarshalBody(resp.Body(), result); err != nil {
+		return fmt.Errorf("%s: %w", errMessage, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+)
+
+func TestDo(t *testing.T) {
+	t.Parallel()
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	personID := server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Ali", LastName: "Rezaei"})
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	var person gopayamgostar.PersonInfo
+	err = client.Do(ctx, token.AccessToken, "post", "/api/v2/crmobject/person/get", gopayamgostar.GetRequest{ID: personID}, &person)
+	require.NoError(t, err)
+	require.Equal(t, "Rezaei", person.LastName)
+
+	var raw map[string]interface{}
+	err = client.Session(token.AccessToken).Do(ctx, http.MethodPost, "api/v2/crmobject/person/get", gopayamgostar.GetRequest{ID: personID}, &raw)
+	require.NoError(t, err)
+	require.Equal(t, "Ali", raw["firstName"])
+
+	err = client.Do(ctx, token.AccessToken, http.MethodPost, "api/v2/crmobject/person/get", gopayamgostar.GetRequest{ID: "missing"}, nil)
+	var apiErr *gopayamgostar.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusNotFound, apiErr.Code)
+
+	err = client.Do(ctx, "revoked", http.MethodPost, "api/v2/crmobject/person/get", gopayamgostar.GetRequest{ID: personID}, nil)
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusUnauthorized, apiErr.Code)
+
+	err = client.Do(ctx, token.AccessToken, http.MethodGet, "https://example.com/api", nil, nil)
+	require.ErrorContains(t, err, "path must be relative to the base path")
+}
@@ -0,0 +1,183 @@
+package gopayamgostar
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// DefaultRefTTL is how long RefData keeps reference data by default
+	DefaultRefTTL = 10 * time.Minute
+	// DefaultRefMissInterval is the minimum time between two reloads of a
+	// list caused by lookups of unknown names
+	DefaultRefMissInterval = time.Minute
+)
+
+// ErrColorNotFound is returned by RefData.ColorByName for unknown colors
+var ErrColorNotFound = errors.New("color not found")
+
+// RefData caches the reference data bulk jobs look up over and over: colors,
+// users and products. Entries are loaded on first use and kept for TTL. The
+// cache is shared by all the users of the client.
+type RefData struct {
+	client *GoPayamgostar
+
+	// TTL is how long loaded entries are kept, DefaultRefTTL by default.
+	// Change it before the first lookup.
+	TTL time.Duration
+	// MissInterval limits how often a lookup of an unknown color reloads
+	// the color list, DefaultRefMissInterval by default
+	MissInterval time.Duration
+
+	colors   refCache[map[string]Color]
+	users    refCache[User]
+	products refCache[Product]
+}
+
+func newRefData(client *GoPayamgostar) *RefData {
+	return &RefData{
+		client:       client,
+		TTL:          DefaultRefTTL,
+		MissInterval: DefaultRefMissInterval,
+	}
+}
+
+// Ref returns the reference data cache of the client
+func (g *GoPayamgostar) Ref() *RefData {
+	return g.ref
+}
+
+// ColorByName returns the color with name, compared case insensitively. A
+// name missing from the cached list reloads the list at most once every
+// MissInterval before ErrColorNotFound is returned.
+func (r *RefData) ColorByName(ctx context.Context, accessToken, name string) (*Color, error) {
+	load := func(ctx context.Context) (map[string]Color, error) {
+		colors, err := r.client.ListColors(WithoutCache(ctx), accessToken)
+		if err != nil {
+			return nil, err
+		}
+		byName := make(map[string]Color, len(colors))
+		for _, color := range colors {
+			byName[strings.ToLower(color.Name)] = color
+		}
+		return byName, nil
+	}
+
+	colors, loadedAt, err := r.colors.get(ctx, "", r.TTL, load)
+	if err != nil {
+		return nil, err
+	}
+	if color, ok := colors[strings.ToLower(name)]; ok {
+		return &color, nil
+	}
+	if time.Since(loadedAt) < r.MissInterval {
+		return nil, errors.Wrap(ErrColorNotFound, name)
+	}
+
+	r.colors.invalidate("")
+	if colors, _, err = r.colors.get(ctx, "", r.TTL, load); err != nil {
+		return nil, err
+	}
+	if color, ok := colors[strings.ToLower(name)]; ok {
+		return &color, nil
+	}
+	return nil, errors.Wrap(ErrColorNotFound, name)
+}
+
+// UserByUsername returns the user with username
+func (r *RefData) UserByUsername(ctx context.Context, accessToken, username string) (*User, error) {
+	user, _, err := r.users.get(ctx, username, r.TTL, func(ctx context.Context) (User, error) {
+		user, err := r.client.GetUserByUsername(ctx, accessToken, username)
+		if err != nil {
+			return User{}, err
+		}
+		return *user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ProductByCode returns the product with the catalog code
+func (r *RefData) ProductByCode(ctx context.Context, accessToken, code string) (*Product, error) {
+	product, _, err := r.products.get(ctx, code, r.TTL, func(ctx context.Context) (Product, error) {
+		product, err := r.client.GetProductByCode(ctx, accessToken, code)
+		if err != nil {
+			return Product{}, err
+		}
+		return *product, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// Invalidate drops all cached reference data
+func (r *RefData) Invalidate() {
+	r.colors.clear()
+	r.users.clear()
+	r.products.clear()
+}
+
+// refCache is a TTL cache whose concurrent misses of a key share one load
+type refCache[V any] struct {
+	mu      sync.Mutex
+	entries map[string]refEntry[V]
+	group   singleflight.Group
+}
+
+type refEntry[V any] struct {
+	value    V
+	loadedAt time.Time
+}
+
+// get returns the entry of key and when it was loaded, loading it when it is
+// missing or older than ttl
+func (c *refCache[V]) get(ctx context.Context, key string, ttl time.Duration, load func(context.Context) (V, error)) (V, time.Time, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Since(entry.loadedAt) < ttl {
+		return entry.value, entry.loadedAt, nil
+	}
+
+	result, err, _ := c.group.Do(key, func() (interface{}, error) {
+		value, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		entry := refEntry[V]{value: value, loadedAt: time.Now()}
+		c.mu.Lock()
+		if c.entries == nil {
+			c.entries = map[string]refEntry[V]{}
+		}
+		c.entries[key] = entry
+		c.mu.Unlock()
+		return entry, nil
+	})
+	if err != nil {
+		var zero V
+		return zero, time.Time{}, err
+	}
+	entry = result.(refEntry[V])
+	return entry.value, entry.loadedAt, nil
+}
+
+func (c *refCache[V]) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+func (c *refCache[V]) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
@@ -0,0 +1,99 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+)
+
+func TestRefData(t *testing.T) {
+	t.Parallel()
+
+	config := gopayamgostar.NewClient("").Config
+	var (
+		mu       sync.Mutex
+		requests = map[string]int{}
+		colors   = []gopayamgostar.Color{{ID: 1, Name: "Red"}}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests[r.URL.Path]++
+
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/" + config.ListColorEndpoint:
+			_ = json.NewEncoder(w).Encode(colors)
+		case "/" + config.GetUserEndpoint:
+			_ = json.NewEncoder(w).Encode(gopayamgostar.User{ID: "u-" + body["username"], Username: body["username"]})
+		case "/" + config.GetProductEndpoint:
+			if body["code"] == "missing" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(gopayamgostar.Product{Code: body["code"], UnitPrice: gopayamgostar.NewMoney(250)})
+		}
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL)
+	ref := client.Ref()
+	ref.MissInterval = 0
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			color, err := ref.ColorByName(ctx, "token", "red")
+			require.NoError(t, err)
+			require.Equal(t, int64(1), color.ID)
+
+			user, err := ref.UserByUsername(ctx, "token", "sara")
+			require.NoError(t, err)
+			require.Equal(t, "u-sara", user.ID)
+
+			product, err := ref.ProductByCode(ctx, "token", "product-2")
+			require.NoError(t, err)
+			require.Equal(t, gopayamgostar.NewMoney(250), product.UnitPrice)
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	require.Equal(t, 1, requests["/"+config.ListColorEndpoint])
+	require.Equal(t, 1, requests["/"+config.GetUserEndpoint])
+	require.Equal(t, 1, requests["/"+config.GetProductEndpoint])
+	colors = append(colors, gopayamgostar.Color{ID: 2, Name: "Blue"})
+	mu.Unlock()
+
+	// an unknown name reloads the list
+	color, err := ref.ColorByName(ctx, "token", "Blue")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), color.ID)
+
+	_, err = ref.ColorByName(ctx, "token", "Green")
+	require.ErrorIs(t, err, gopayamgostar.ErrColorNotFound)
+
+	_, err = ref.ProductByCode(ctx, "token", "missing")
+	require.Error(t, err)
+
+	ref.Invalidate()
+	_, err = ref.UserByUsername(ctx, "token", "sara")
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, 3, requests["/"+config.ListColorEndpoint])
+	require.Equal(t, 2, requests["/"+config.GetUserEndpoint])
+}
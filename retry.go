@@ -0,0 +1,206 @@
+package gopayamgostar
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// RetryOnServerErrors retries transport errors, 429 Too Many Requests and 5xx
+// responses. It is the retry condition of WithRetry when none is given.
+func RetryOnServerErrors(resp *resty.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	if resp == nil {
+		return false
+	}
+	return resp.StatusCode() == http.StatusTooManyRequests || resp.StatusCode() >= http.StatusInternalServerError
+}
+
+// WithRetry retries failed requests up to count times, waiting between
+// waitTime and maxWait with an exponential backoff. retryOn decides whether a
+// response or error is retried; nil uses RetryOnServerErrors. Responses with
+// a Retry-After header are retried after the time it asks for, capped at
+// maxWait.
+func WithRetry(count int, waitTime, maxWait time.Duration, retryOn func(*resty.Response, error) bool) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		if retryOn == nil {
+			retryOn = RetryOnServerErrors
+		}
+		g.restyClient.
+			SetRetryCount(count).
+			SetRetryWaitTime(waitTime).
+			SetRetryMaxWaitTime(maxWait).
+			AddRetryCondition(retryOn)
+	}
+}
+
+// WithRetryBudget retries failed requests like WithRetry, but splits the
+// remaining deadline of the request context across the attempts left instead
+// of waiting fixed times that overshoot it. Every attempt gets at most an even
+// share of the remaining time, and the wait before a retry is capped so that
+// the attempts after it still get half of their share. Requests without a
+// deadline retry exactly like WithRetry.
+func WithRetryBudget(count int, waitTime, maxWait time.Duration, retryOn func(*resty.Response, error) bool) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		if retryOn == nil {
+			retryOn = RetryOnServerErrors
+		}
+		budget := &retryBudget{attempts: count + 1, waitTime: waitTime, maxWait: maxWait}
+
+		httpClient := g.restyClient.GetClient()
+		next := httpClient.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		httpClient.Transport = &budgetTransport{next: next, budget: budget}
+
+		// resty clamps the wait to the minimum wait time, so it is set to the
+		// smallest wait and the budget applies waitTime itself
+		g.restyClient.
+			SetRetryCount(count).
+			SetRetryWaitTime(time.Nanosecond).
+			SetRetryMaxWaitTime(maxWait).
+			AddRetryCondition(retryOn)
+		g.backoff = budget.wait
+	}
+}
+
+// retryAfter is the resty.RetryAfterFunc of the client: it waits for the
+// Retry-After of the response when there is one and uses the backoff of the
+// retry options otherwise
+func (g *GoPayamgostar) retryAfter(c *resty.Client, resp *resty.Response) (time.Duration, error) {
+	if wait, ok := retryAfterHeader(resp); ok {
+		return nonZeroWait(g.capRetryAfter(c, resp, wait)), nil
+	}
+	if g.backoff == nil {
+		// the default backoff of resty
+		return 0, nil
+	}
+	return g.backoff(c, resp)
+}
+
+// capRetryAfter caps the wait a Retry-After header asks for at the maximum
+// retry wait of the client, and of the policy of the call
+func (g *GoPayamgostar) capRetryAfter(c *resty.Client, resp *resty.Response, wait time.Duration) time.Duration {
+	if max := c.RetryMaxWaitTime; max >= 0 && wait > max {
+		wait = max
+	}
+	return g.capRetryWait(resp, wait)
+}
+
+// retryAfterHeader returns the wait the Retry-After header of a 429 or 503
+// response asks for, given in seconds or as an HTTP date
+func retryAfterHeader(resp *resty.Response) (time.Duration, bool) {
+	if resp == nil || (resp.StatusCode() != http.StatusTooManyRequests && resp.StatusCode() != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+	value := resp.Header().Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date), true
+	}
+	return 0, false
+}
+
+// retryBudget divides the deadline of a request across its attempts
+type retryBudget struct {
+	attempts int
+	waitTime time.Duration
+	maxWait  time.Duration
+}
+
+// left returns how many attempts remain including the given one
+func (b *retryBudget) left(attempt int) int {
+	if attempt < 1 {
+		attempt = 1
+	}
+	if left := b.attempts - attempt + 1; left > 1 {
+		return left
+	}
+	return 1
+}
+
+// wait returns the time to wait after the given attempt
+func (b *retryBudget) wait(c *resty.Client, resp *resty.Response) (time.Duration, error) {
+	attempt := resp.Request.Attempt
+	wait := backoff(b.waitTime, b.maxWait, attempt)
+	if deadline, ok := resp.Request.Context().Deadline(); ok {
+		share := time.Until(deadline) / time.Duration(2*b.left(attempt+1))
+		if share < wait {
+			wait = share
+		}
+	}
+	return nonZeroWait(wait), nil
+}
+
+// backoff returns waitTime doubled for every attempt after the first, capped
+// at maxWait
+func backoff(waitTime, maxWait time.Duration, attempt int) time.Duration {
+	wait := maxWait
+	if shift := attempt - 1; shift >= 0 && shift < 32 {
+		if doubled := waitTime << shift; doubled > 0 && doubled < wait {
+			wait = doubled
+		}
+	}
+	return wait
+}
+
+// nonZeroWait returns wait, at least 1ns since resty treats a zero wait as
+// "use the default backoff"
+func nonZeroWait(wait time.Duration) time.Duration {
+	if wait <= 0 {
+		return time.Nanosecond
+	}
+	return wait
+}
+
+// budgetTransport limits every attempt to its share of the request deadline
+type budgetTransport struct {
+	next   http.RoundTripper
+	budget *retryBudget
+}
+
+func (t *budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	attempt, _ := req.Context().Value(attemptContextKey).(int)
+	timeout := time.Until(deadline) / time.Duration(t.budget.left(attempt))
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// the body is read under the attempt timeout too
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases the context of an attempt once its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
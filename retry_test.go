@@ -0,0 +1,108 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRetry(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&attempts, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithRetry(5, time.Millisecond, time.Millisecond, nil))
+	require.Error(t, client.DeleteForm(context.Background(), "token", "form"))
+	// 400 is not retried by RetryOnServerErrors
+	require.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+
+	atomic.StoreInt32(&attempts, 0)
+	never := func(*resty.Response, error) bool { return false }
+	client = gopayamgostar.NewClient(server.URL, gopayamgostar.WithRetry(5, time.Millisecond, time.Millisecond, never))
+	require.Error(t, client.DeleteForm(context.Background(), "token", "form"))
+	require.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestWithRetryBudget(t *testing.T) {
+	var attempts int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			// hang past the whole deadline
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	// the long waits would overshoot the deadline without the budget
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithRetryBudget(2, 10*time.Second, 10*time.Second, nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 900*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	require.NoError(t, client.DeleteForm(ctx, "token", "form"))
+	require.Less(t, time.Since(start), 900*time.Millisecond)
+	require.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+
+	// without a deadline it retries like WithRetry
+	atomic.StoreInt32(&attempts, 1)
+	client = gopayamgostar.NewClient(server.URL, gopayamgostar.WithRetryBudget(2, time.Millisecond, time.Millisecond, nil))
+	require.NoError(t, client.DeleteForm(context.Background(), "token", "form"))
+	require.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+}
+
+func TestRetryAfter(t *testing.T) {
+	var attempts int32
+	var retryAfter atomic.Value
+	retryAfter.Store("1")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1)%2 == 1 {
+			w.Header().Set("Retry-After", retryAfter.Load().(string))
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithRetry(1, time.Millisecond, 5*time.Second, nil))
+	start := time.Now()
+	require.NoError(t, client.DeleteForm(context.Background(), "token", "form"))
+	require.GreaterOrEqual(t, time.Since(start), time.Second)
+	require.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+
+	// and with a resty client of the caller
+	atomic.StoreInt32(&attempts, 0)
+	client = gopayamgostar.NewClient(server.URL, gopayamgostar.WithRestyClient(resty.New()), gopayamgostar.WithRetry(1, time.Millisecond, 5*time.Second, nil))
+	start = time.Now()
+	require.NoError(t, client.DeleteForm(context.Background(), "token", "form"))
+	require.GreaterOrEqual(t, time.Since(start), time.Second)
+	require.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+
+	// the wait is capped at the maximum wait of the retry options
+	retryAfter.Store("60")
+	client = gopayamgostar.NewClient(server.URL, gopayamgostar.WithRetry(1, time.Millisecond, 10*time.Millisecond, nil))
+	start = time.Now()
+	require.NoError(t, client.DeleteForm(context.Background(), "token", "form"))
+	require.Less(t, time.Since(start), time.Second)
+}
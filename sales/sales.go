@@ -0,0 +1,148 @@
+// Package sales exposes the sales pipeline operations CRM-driven dashboards need:
+// moving opportunities between stages, closing them as won or lost, and
+// per-stage pipeline metrics.
+package sales
+
+import (
+	"context"
+	"sort"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/pkg/errors"
+)
+
+const summaryPageSize = 100
+
+// Service runs sales pipeline operations. WonStageID and LostStageID must be set
+// to the stage ids configured for closed opportunities before MarkWon/MarkLost
+// are used.
+type Service struct {
+	client *gopayamgostar.GoPayamgostar
+
+	WonStageID  string
+	LostStageID string
+}
+
+// New creates a sales service on top of client
+func New(client *gopayamgostar.GoPayamgostar, wonStageID, lostStageID string) *Service {
+	return &Service{
+		client:      client,
+		WonStageID:  wonStageID,
+		LostStageID: lostStageID,
+	}
+}
+
+// StageSummary aggregates the opportunities of one stage
+type StageSummary struct {
+	StageID   string
+	StageName string
+	Count     int
+	// Amount is the sum of the opportunity amounts
+	Amount int64
+	// WeightedAmount is the sum of the amounts weighted by their probability
+	WeightedAmount int64
+}
+
+// PipelineSummary aggregates a set of opportunities per stage
+type PipelineSummary struct {
+	Stages []StageSummary
+	Total  StageSummary
+	Won    StageSummary
+	Lost   StageSummary
+}
+
+// MoveOpportunityToStage moves an opportunity to stageID
+func (s *Service) MoveOpportunityToStage(ctx context.Context, accessToken, crmID, stageID string) error {
+	return s.client.UpdateOpportunityStage(ctx, accessToken, gopayamgostar.UpdateOpportunityStageRequest{
+		CrmId:   crmID,
+		StageID: stageID,
+	})
+}
+
+// MarkWon closes an opportunity as won
+func (s *Service) MarkWon(ctx context.Context, accessToken, crmID string) error {
+	if s.WonStageID == "" {
+		return errors.New("sales: won stage is not configured")
+	}
+	return s.client.UpdateOpportunityStage(ctx, accessToken, gopayamgostar.UpdateOpportunityStageRequest{
+		CrmId:       crmID,
+		StageID:     s.WonStageID,
+		Probability: gopayamgostar.IntP(100),
+	})
+}
+
+// MarkLost closes an opportunity as lost with an optional reason
+func (s *Service) MarkLost(ctx context.Context, accessToken, crmID, reason string) error {
+	if s.LostStageID == "" {
+		return errors.New("sales: lost stage is not configured")
+	}
+	request := gopayamgostar.UpdateOpportunityStageRequest{
+		CrmId:       crmID,
+		StageID:     s.LostStageID,
+		Probability: gopayamgostar.IntP(0),
+	}
+	if reason != "" {
+		request.Description = gopayamgostar.StringP(reason)
+	}
+	return s.client.UpdateOpportunityStage(ctx, accessToken, request)
+}
+
+// GetPipelineSummary loads every opportunity matching queries and aggregates them per stage
+func (s *Service) GetPipelineSummary(ctx context.Context, accessToken string, queries []gopayamgostar.Query) (*PipelineSummary, error) {
+	var opportunities []gopayamgostar.OpportunityInfo
+
+	for page := int64(1); ; page++ {
+		result, err := s.client.FindOpportunitiesPage(ctx, accessToken, queries, page, summaryPageSize)
+		if err != nil {
+			return nil, err
+		}
+		opportunities = append(opportunities, result.Data...)
+		if len(result.Data) < summaryPageSize || int64(len(opportunities)) >= result.Total {
+			break
+		}
+	}
+
+	summary := s.Summarize(opportunities)
+	return &summary, nil
+}
+
+// Summarize aggregates opportunities per stage. Stages are ordered by
+// descending amount; won and lost opportunities are reported separately and
+// are not part of Stages or Total.
+func (s *Service) Summarize(opportunities []gopayamgostar.OpportunityInfo) PipelineSummary {
+	var summary PipelineSummary
+	index := map[string]int{}
+
+	for _, opportunity := range opportunities {
+		switch {
+		case s.WonStageID != "" && opportunity.StageID == s.WonStageID:
+			add(&summary.Won, opportunity)
+		case s.LostStageID != "" && opportunity.StageID == s.LostStageID:
+			add(&summary.Lost, opportunity)
+		default:
+			i, ok := index[opportunity.StageID]
+			if !ok {
+				i = len(summary.Stages)
+				index[opportunity.StageID] = i
+				summary.Stages = append(summary.Stages, StageSummary{
+					StageID:   opportunity.StageID,
+					StageName: opportunity.StageName,
+				})
+			}
+			add(&summary.Stages[i], opportunity)
+			add(&summary.Total, opportunity)
+		}
+	}
+
+	sort.SliceStable(summary.Stages, func(i, j int) bool {
+		return summary.Stages[i].Amount > summary.Stages[j].Amount
+	})
+
+	return summary
+}
+
+func add(stage *StageSummary, opportunity gopayamgostar.OpportunityInfo) {
+	stage.Count++
+	stage.Amount += opportunity.Amount
+	stage.WeightedAmount += opportunity.Amount * int64(opportunity.Probability) / 100
+}
@@ -0,0 +1,29 @@
+package sales_test
+
+import (
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/sales"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarize(t *testing.T) {
+	service := sales.New(gopayamgostar.NewClient("http://localhost"), "won", "lost")
+
+	summary := service.Summarize([]gopayamgostar.OpportunityInfo{
+		{StageID: "lead", StageName: "Lead", Amount: 1000, Probability: 10},
+		{StageID: "proposal", StageName: "Proposal", Amount: 5000, Probability: 50},
+		{StageID: "lead", StageName: "Lead", Amount: 3000, Probability: 20},
+		{StageID: "won", Amount: 7000, Probability: 100},
+		{StageID: "lost", Amount: 2000},
+	})
+
+	require.Equal(t, []sales.StageSummary{
+		{StageID: "proposal", StageName: "Proposal", Count: 1, Amount: 5000, WeightedAmount: 2500},
+		{StageID: "lead", StageName: "Lead", Count: 2, Amount: 4000, WeightedAmount: 700},
+	}, summary.Stages)
+	require.Equal(t, sales.StageSummary{Count: 3, Amount: 9000, WeightedAmount: 3200}, summary.Total)
+	require.Equal(t, sales.StageSummary{Count: 1, Amount: 7000, WeightedAmount: 7000}, summary.Won)
+	require.Equal(t, sales.StageSummary{Count: 1, Amount: 2000}, summary.Lost)
+}
@@ -0,0 +1,58 @@
+package gopayamgostar
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// schemaPins holds the CRMObjectTypeID expected per type code and the type
+// codes that were already verified against the server.
+type schemaPins struct {
+	mu       sync.Mutex
+	expected map[string]string
+	verified map[string]bool
+}
+
+// WithSchemaPin pins the type code to the CRMObjectTypeID the integration was
+// written against. The first call using the type code verifies it against the
+// server and fails with ErrSchemaVersionMismatch when administrators have
+// replaced the form, instead of writing mis-mapped data.
+func WithSchemaPin(typeCode, crmObjectTypeID string) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		g.schema.mu.Lock()
+		defer g.schema.mu.Unlock()
+
+		if g.schema.expected == nil {
+			g.schema.expected = map[string]string{}
+			g.schema.verified = map[string]bool{}
+		}
+		g.schema.expected[typeCode] = crmObjectTypeID
+	}
+}
+
+func (g *GoPayamgostar) verifySchema(ctx context.Context, accessToken, typeCode string) error {
+	g.schema.mu.Lock()
+	expected, pinned := g.schema.expected[typeCode]
+	verified := g.schema.verified[typeCode]
+	g.schema.mu.Unlock()
+
+	if !pinned || verified {
+		return nil
+	}
+
+	objectType, err := g.GetObjectType(ctx, accessToken, typeCode)
+	if err != nil {
+		return errors.Wrapf(err, "could not verify schema of %s", typeCode)
+	}
+	if objectType.ID != expected {
+		return errors.Wrapf(ErrSchemaVersionMismatch, "%s is %s, pinned to %s", typeCode, objectType.ID, expected)
+	}
+
+	g.schema.mu.Lock()
+	g.schema.verified[typeCode] = true
+	g.schema.mu.Unlock()
+
+	return nil
+}
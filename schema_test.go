@@ -0,0 +1,54 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func newSchemaServer(t *testing.T, typeLookups *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/type/get") {
+			*typeLookups++
+			_, _ = w.Write([]byte(`{"id":"3fa4b3c1-type-v2","code":"SettlementRequest"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"crmId":"d81d07dd-cdc2-479a-99d5-0270a1f8f07d"}`))
+	}))
+}
+
+func TestSchemaPin(t *testing.T) {
+	typeLookups := 0
+	server := newSchemaServer(t, &typeLookups)
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithSchemaPin("SettlementRequest", "3fa4b3c1-type-v2"))
+	request := gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "SettlementRequest"}
+
+	for i := 0; i < 2; i++ {
+		_, err := client.CreateForm(context.Background(), "token", request)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, typeLookups, "schema must be verified only once")
+
+	_, err := client.CreateForm(context.Background(), "token", gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Unpinned"})
+	require.NoError(t, err)
+	require.Equal(t, 1, typeLookups, "unpinned types must not be verified")
+}
+
+func TestSchemaPinMismatch(t *testing.T) {
+	typeLookups := 0
+	server := newSchemaServer(t, &typeLookups)
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithSchemaPin("SettlementRequest", "3fa4b3c1-type-v1"))
+	_, err := client.CreateForm(context.Background(), "token", gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "SettlementRequest"})
+	require.True(t, errors.Is(err, gopayamgostar.ErrSchemaVersionMismatch), "unexpected error: %v", err)
+}
@@ -0,0 +1,395 @@
+// Package seed creates coherent graphs of test data in a CRM, such as persons
+// with phones, their invoices and the forms related to them, from a compact
+// spec. Create returns the created objects as a Graph queried by the refs of
+// the spec, and a teardown deleting them again.
+//
+//	graph := seed.MustCreate(t, ctx, client, accessToken, seed.Spec{
+//		Persons: []seed.Person{{Ref: "ali", FirstName: "Ali", LastName: "Rezaei", Phones: []string{"09120000000"}}},
+//		Invoices: []seed.Invoice{{Ref: "order", Person: "ali", Lines: []seed.Line{
+//			{ProductCode: "product-2", Count: 2, UnitPrice: gopayamgostar.NewMoney(1000)},
+//		}}},
+//		Forms: []seed.Form{{TypeCode: "Complaint", Person: "ali", Parent: "order", Subject: "late delivery"}},
+//	})
+//	invoiceID := graph.ID("order")
+package seed
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
+	"github.com/pkg/errors"
+)
+
+// Type codes used when a spec leaves them empty
+const (
+	DefaultPersonTypeCode  = "Person"
+	DefaultInvoiceTypeCode = "PurchaseInvoice"
+)
+
+// Kind is the kind of object of a Node
+type Kind string
+
+const (
+	KindPerson  Kind = "person"
+	KindInvoice Kind = "invoice"
+	KindForm    Kind = "form"
+)
+
+// Spec describes the objects to create. Objects refer to each other by their
+// Ref; a Ref left empty is set to the kind and position of the object, such
+// as "person-1". Persons are created first, then invoices, then forms in the
+// order given, so a form can only have a form listed before it as parent.
+type Spec struct {
+	Persons  []Person
+	Invoices []Invoice
+	Forms    []Form
+}
+
+// Person is a person of a Spec
+type Person struct {
+	Ref       string
+	TypeCode  string
+	FirstName string
+	LastName  string
+	Email     string
+	// Phones are mobile numbers, the first one being the default
+	Phones []string
+	Fields map[string]string
+}
+
+// Invoice is a purchase invoice of a Spec, its totals are the sum of its lines
+type Invoice struct {
+	Ref      string
+	TypeCode string
+	// Person is the ref of the person the invoice is issued to
+	Person string
+	Lines  []Line
+	Fields map[string]string
+}
+
+// Line is a detail of an Invoice
+type Line struct {
+	ProductCode string
+	Count       int64
+	UnitPrice   gopayamgostar.Money
+	Service     bool
+}
+
+// Form is a form of a Spec
+type Form struct {
+	Ref      string
+	TypeCode string
+	// Person is the ref of the person of the form, if any
+	Person string
+	// Parent is the ref of the invoice or form the form belongs to, if any
+	Parent  string
+	Subject string
+	Fields  map[string]string
+}
+
+// Node is a created object
+type Node struct {
+	Ref   string
+	Kind  Kind
+	CrmId string
+	// Person and Parent are the refs the object was created with
+	Person string
+	Parent string
+}
+
+// Graph holds the created objects in the order they were created
+type Graph struct {
+	nodes []Node
+	byRef map[string]int
+}
+
+// ID returns the crm id of the object ref, empty when there is none
+func (g *Graph) ID(ref string) string {
+	node, _ := g.Node(ref)
+	return node.CrmId
+}
+
+// Node returns the object ref
+func (g *Graph) Node(ref string) (Node, bool) {
+	i, ok := g.byRef[ref]
+	if !ok {
+		return Node{}, false
+	}
+	return g.nodes[i], true
+}
+
+// Nodes returns all the objects in the order they were created
+func (g *Graph) Nodes() []Node {
+	return append([]Node(nil), g.nodes...)
+}
+
+// Where returns the objects matching predicate in the order they were created
+func (g *Graph) Where(predicate func(Node) bool) []Node {
+	var nodes []Node
+	for _, node := range g.nodes {
+		if predicate(node) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Of returns the objects of kind
+func (g *Graph) Of(kind Kind) []Node {
+	return g.Where(func(node Node) bool { return node.Kind == kind })
+}
+
+// OwnedBy returns the invoices and forms of the person personRef
+func (g *Graph) OwnedBy(personRef string) []Node {
+	return g.Where(func(node Node) bool { return node.Person == personRef })
+}
+
+// Children returns the forms whose parent is ref
+func (g *Graph) Children(ref string) []Node {
+	return g.Where(func(node Node) bool { return node.Parent == ref })
+}
+
+func (g *Graph) add(node Node) {
+	g.byRef[node.Ref] = len(g.nodes)
+	g.nodes = append(g.nodes, node)
+}
+
+// Create creates the objects of spec with client and returns them with a
+// teardown deleting them in reverse order. When an object cannot be created,
+// the ones created before it are deleted and the error is returned. The
+// teardown keeps going when a deletion fails and returns a
+// *gopayamgostar.MultiError keyed by ref; objects already deleted are skipped.
+func Create(ctx context.Context, client gopayamgostar.GoPayamgostarIface, accessToken string, spec Spec) (*Graph, func() error, error) {
+	if err := spec.normalize(); err != nil {
+		return nil, nil, err
+	}
+
+	graph := &Graph{byRef: map[string]int{}}
+	teardown := func() error {
+		return deleteAll(context.WithoutCancel(ctx), client, accessToken, graph)
+	}
+
+	err := create(ctx, client, accessToken, spec, graph)
+	if err != nil {
+		if terr := teardown(); terr != nil {
+			return nil, nil, fmt.Errorf("%w (could not delete the objects created: %v)", err, terr)
+		}
+		return nil, nil, err
+	}
+	return graph, teardown, nil
+}
+
+// MustCreate creates the objects of spec like Create, failing the test when
+// it cannot, and deletes them when the test ends
+func MustCreate(t testing.TB, ctx context.Context, client gopayamgostar.GoPayamgostarIface, accessToken string, spec Spec) *Graph {
+	t.Helper()
+	graph, teardown, err := Create(ctx, client, accessToken, spec)
+	if err != nil {
+		t.Fatalf("could not seed: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := teardown(); err != nil {
+			t.Errorf("could not tear down the seeded objects: %v", err)
+		}
+	})
+	return graph
+}
+
+func create(ctx context.Context, client gopayamgostar.GoPayamgostarIface, accessToken string, spec Spec, graph *Graph) error {
+	for _, person := range spec.Persons {
+		crmId, err := client.CreatePerson(ctx, accessToken, person.request())
+		if err != nil {
+			return errors.Wrapf(err, "could not create %s", person.Ref)
+		}
+		graph.add(Node{Ref: person.Ref, Kind: KindPerson, CrmId: crmId})
+	}
+
+	for _, invoice := range spec.Invoices {
+		crmId, err := client.CreatePurchaseInvoice(ctx, accessToken, invoice.request(graph))
+		if err != nil {
+			return errors.Wrapf(err, "could not create %s", invoice.Ref)
+		}
+		graph.add(Node{Ref: invoice.Ref, Kind: KindInvoice, CrmId: crmId, Person: invoice.Person})
+	}
+
+	for _, form := range spec.Forms {
+		crmId, err := client.CreateForm(ctx, accessToken, form.request(graph))
+		if err != nil {
+			return errors.Wrapf(err, "could not create %s", form.Ref)
+		}
+		graph.add(Node{Ref: form.Ref, Kind: KindForm, CrmId: crmId, Person: form.Person, Parent: form.Parent})
+	}
+	return nil
+}
+
+func deleteAll(ctx context.Context, client gopayamgostar.GoPayamgostarIface, accessToken string, graph *Graph) error {
+	var failed []*gopayamgostar.TaskError
+	for i := len(graph.nodes) - 1; i >= 0; i-- {
+		node := graph.nodes[i]
+		var err error
+		switch node.Kind {
+		case KindPerson:
+			err = client.DeletePerson(ctx, accessToken, node.CrmId)
+		case KindInvoice:
+			_, err = client.DeletePurchase(ctx, accessToken, node.CrmId, enums.DeleteOnly)
+		case KindForm:
+			err = client.DeleteForm(ctx, accessToken, node.CrmId)
+		}
+		if err != nil && !errors.Is(err, gopayamgostar.ErrNotFound) {
+			failed = append(failed, &gopayamgostar.TaskError{Key: node.Ref, Err: err})
+		}
+	}
+
+	if len(failed) > 0 {
+		return &gopayamgostar.MultiError{Errors: failed}
+	}
+	return nil
+}
+
+// normalize sets the default refs and type codes and checks the references
+func (s *Spec) normalize() error {
+	kinds := map[string]Kind{}
+	define := func(ref *string, kind Kind, i int) error {
+		if *ref == "" {
+			*ref = fmt.Sprintf("%s-%d", kind, i+1)
+		}
+		if _, ok := kinds[*ref]; ok {
+			return errors.Errorf("duplicate ref %q", *ref)
+		}
+		kinds[*ref] = kind
+		return nil
+	}
+	refer := func(from, ref string, allowed ...Kind) error {
+		kind, ok := kinds[ref]
+		if !ok {
+			return errors.Errorf("%s: unknown ref %q", from, ref)
+		}
+		for _, k := range allowed {
+			if kind == k {
+				return nil
+			}
+		}
+		return errors.Errorf("%s: %q is a %s", from, ref, kind)
+	}
+
+	for i := range s.Persons {
+		person := &s.Persons[i]
+		if err := define(&person.Ref, KindPerson, i); err != nil {
+			return err
+		}
+		if person.TypeCode == "" {
+			person.TypeCode = DefaultPersonTypeCode
+		}
+	}
+	for i := range s.Invoices {
+		invoice := &s.Invoices[i]
+		if err := define(&invoice.Ref, KindInvoice, i); err != nil {
+			return err
+		}
+		if invoice.TypeCode == "" {
+			invoice.TypeCode = DefaultInvoiceTypeCode
+		}
+		if err := refer(invoice.Ref, invoice.Person, KindPerson); err != nil {
+			return err
+		}
+	}
+	for i := range s.Forms {
+		form := &s.Forms[i]
+		if form.Ref == "" {
+			form.Ref = fmt.Sprintf("%s-%d", KindForm, i+1)
+		}
+		if form.TypeCode == "" {
+			return errors.Errorf("%s: no type code", form.Ref)
+		}
+		// checked before defining the form so that it cannot be its own parent
+		// or the parent of a form listed before it
+		if form.Person != "" {
+			if err := refer(form.Ref, form.Person, KindPerson); err != nil {
+				return err
+			}
+		}
+		if form.Parent != "" {
+			if err := refer(form.Ref, form.Parent, KindInvoice, KindForm); err != nil {
+				return err
+			}
+		}
+		if err := define(&form.Ref, KindForm, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p Person) request() gopayamgostar.CreatePersonRequest {
+	request := gopayamgostar.CreatePersonRequest{
+		CRMObjectTypeCode:  p.TypeCode,
+		FirstName:          p.FirstName,
+		LastName:           p.LastName,
+		Email:              p.Email,
+		ExtendedProperties: properties(p.Fields),
+	}
+	for i, phone := range p.Phones {
+		request.PhoneContacts = append(request.PhoneContacts, gopayamgostar.PhoneContact{
+			PhoneType:   enums.PhoneMobile,
+			PhoneNumber: phone,
+			Default:     i == 0,
+		})
+	}
+	return request
+}
+
+func (inv Invoice) request(graph *Graph) gopayamgostar.CreatePurchaseRequest {
+	request := gopayamgostar.CreatePurchaseRequest{
+		CRMObjectTypeCode:  inv.TypeCode,
+		IdentityID:         graph.ID(inv.Person),
+		ExtendedProperties: properties(inv.Fields),
+	}
+	for _, line := range inv.Lines {
+		total := line.UnitPrice.Mul(line.Count)
+		request.Details = append(request.Details, gopayamgostar.Detail{
+			IsService:      line.Service,
+			BaseUnitPrice:  line.UnitPrice,
+			FinalUnitPrice: line.UnitPrice,
+			TotalUnitPrice: total,
+			Count:          line.Count,
+			ProductCode:    line.ProductCode,
+		})
+		request.TotalValue = request.TotalValue.Add(total)
+	}
+	request.FinalValue = request.TotalValue
+	return request
+}
+
+func (f Form) request(graph *Graph) gopayamgostar.CreateFormRequest {
+	request := gopayamgostar.CreateFormRequest{
+		CRMObjectTypeCode:  f.TypeCode,
+		IdentityID:         graph.ID(f.Person),
+		ExtendedProperties: properties(f.Fields),
+	}
+	if f.Parent != "" {
+		request.ParentCRMObjectID = gopayamgostar.StringP(graph.ID(f.Parent))
+	}
+	if f.Subject != "" {
+		request.Subject = gopayamgostar.StringP(f.Subject)
+	}
+	return request
+}
+
+// properties returns fields as extended properties sorted by key
+func properties(fields map[string]string) []gopayamgostar.ExtendedProperty {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var extended []gopayamgostar.ExtendedProperty
+	for _, key := range keys {
+		extended = append(extended, gopayamgostar.ExtendedProperty{UserKey: key, Value: fields[key]})
+	}
+	return extended
+}
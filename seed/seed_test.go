@@ -0,0 +1,126 @@
+package seed_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/faultinject"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/erfandiakoo/gopayamgostar/v2/seed"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreate(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	graph, teardown, err := seed.Create(ctx, client, token.AccessToken, seed.Spec{
+		Persons: []seed.Person{
+			{Ref: "ali", FirstName: "Ali", LastName: "Rezaei", Phones: []string{"09120000000", "09350000000"}},
+			{FirstName: "Sara", LastName: "Ahmadi"},
+		},
+		Invoices: []seed.Invoice{{Ref: "order", Person: "ali", Lines: []seed.Line{
+			{ProductCode: "product-1", Count: 2, UnitPrice: gopayamgostar.NewMoney(1000)},
+			{ProductCode: "product-2", Count: 1, UnitPrice: gopayamgostar.NewMoney(500), Service: true},
+		}}},
+		Forms: []seed.Form{
+			{Ref: "complaint", TypeCode: "Complaint", Person: "ali", Parent: "order", Subject: "late delivery", Fields: map[string]string{"City": "Tehran"}},
+			{Ref: "followup", TypeCode: "Followup", Parent: "complaint"},
+		},
+	})
+	require.NoError(t, err)
+
+	person, ok := server.Person(graph.ID("ali"))
+	require.True(t, ok)
+	require.Equal(t, seed.DefaultPersonTypeCode, person.CRMObjectTypeCode)
+	require.Len(t, person.PhoneContacts, 2)
+	require.True(t, person.PhoneContacts[0].Default)
+	require.False(t, person.PhoneContacts[1].Default)
+	_, ok = server.Person(graph.ID("person-2"))
+	require.True(t, ok)
+
+	purchase, ok := server.Purchase(graph.ID("order"))
+	require.True(t, ok)
+	require.Equal(t, graph.ID("ali"), purchase.IdentityID)
+	require.Equal(t, gopayamgostar.NewMoney(2500), purchase.FinalValue)
+	require.Equal(t, gopayamgostar.NewMoney(2000), purchase.Details[0].TotalUnitPrice)
+
+	complaint, ok := server.Form(graph.ID("complaint"))
+	require.True(t, ok)
+	require.Equal(t, graph.ID("order"), complaint.ParentCRMObjectID)
+	require.Equal(t, graph.ID("ali"), complaint.IdentityID)
+	require.Equal(t, "late delivery", complaint.Subject)
+	followup, ok := server.Form(graph.ID("followup"))
+	require.True(t, ok)
+	require.Equal(t, graph.ID("complaint"), followup.ParentCRMObjectID)
+
+	refs := func(nodes []seed.Node) []string {
+		var refs []string
+		for _, node := range nodes {
+			refs = append(refs, node.Ref)
+		}
+		return refs
+	}
+	require.Equal(t, []string{"ali", "person-2"}, refs(graph.Of(seed.KindPerson)))
+	require.Equal(t, []string{"order", "complaint"}, refs(graph.OwnedBy("ali")))
+	require.Equal(t, []string{"complaint"}, refs(graph.Children("order")))
+	require.Empty(t, graph.ID("unknown"))
+
+	// deleted by the test itself
+	require.NoError(t, client.DeleteForm(ctx, token.AccessToken, graph.ID("followup")))
+
+	require.NoError(t, teardown())
+	for _, node := range graph.Nodes() {
+		_, isPerson := server.Person(node.CrmId)
+		_, isPurchase := server.Purchase(node.CrmId)
+		_, isForm := server.Form(node.CrmId)
+		require.False(t, isPerson || isPurchase || isForm, node.Ref)
+	}
+}
+
+func TestCreateFailure(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	for name, spec := range map[string]seed.Spec{
+		"unknown person": {Invoices: []seed.Invoice{{Person: "nobody"}}},
+		"duplicate ref":  {Persons: []seed.Person{{Ref: "a"}, {Ref: "a"}}},
+		"later parent":   {Forms: []seed.Form{{TypeCode: "Complaint", Parent: "form-2"}, {TypeCode: "Complaint"}}},
+		"person parent":  {Persons: []seed.Person{{Ref: "a"}}, Forms: []seed.Form{{TypeCode: "Complaint", Parent: "a"}}},
+		"no type code":   {Forms: []seed.Form{{}}},
+	} {
+		_, _, err := seed.Create(ctx, client, token.AccessToken, spec)
+		require.Error(t, err, name)
+	}
+
+	// the invoice fails after the persons are created, which are deleted again
+	faultinject.Install(client).Add(faultinject.Rule{Operation: "CreatePurchase", StatusCode: http.StatusInternalServerError, Body: `{"message":"boom"}`})
+	_, _, err = seed.Create(ctx, client, token.AccessToken, seed.Spec{
+		Persons:  []seed.Person{{FirstName: "Ali", LastName: "Rezaei"}, {FirstName: "Sara", LastName: "Ahmadi"}},
+		Invoices: []seed.Invoice{{Person: "person-1"}},
+	})
+	require.ErrorContains(t, err, "could not create invoice-1")
+	for _, name := range [][2]string{{"Ali", "Rezaei"}, {"Sara", "Ahmadi"}} {
+		found, err := client.FindPersonByName(ctx, token.AccessToken, seed.DefaultPersonTypeCode, name[0], name[1])
+		require.NoError(t, err)
+		require.Zero(t, found.Total, name[0])
+	}
+
+	_, _, err = seed.Create(ctx, client, "expired", seed.Spec{Persons: []seed.Person{{FirstName: "Ali"}}})
+	var apiErr *gopayamgostar.APIError
+	require.ErrorAs(t, err, &apiErr)
+}
@@ -0,0 +1,121 @@
+package gopayamgostar
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers the server version is read from
+const (
+	ServerVersionHeader = "X-Payamgostar-Version"
+	SchemaVersionHeader = "X-Payamgostar-Schema-Version"
+)
+
+// TestedServerVersions are the server versions this version of the client
+// was tested against
+var TestedServerVersions = VersionRange{Min: "8", Max: "9"}
+
+// VersionRange is a range of dotted versions such as 8.2.1. Versions are
+// compared on as many parts as the bound has, so a Max of "9" includes every
+// 9.x version. An empty bound leaves that side open.
+type VersionRange struct {
+	Min string
+	Max string
+}
+
+// Contains reports whether version is within the range
+func (r VersionRange) Contains(version string) bool {
+	if r.Min != "" && compareVersions(version, r.Min) < 0 {
+		return false
+	}
+	if r.Max != "" && compareVersions(version, r.Max) > 0 {
+		return false
+	}
+	return true
+}
+
+// compareVersions compares the first parts of version with bound, as many as
+// bound has. Numeric parts compare as numbers, others as strings.
+func compareVersions(version, bound string) int {
+	versionParts := strings.Split(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".")
+	boundParts := strings.Split(strings.TrimPrefix(strings.TrimSpace(bound), "v"), ".")
+	for i, b := range boundParts {
+		v := "0"
+		if i < len(versionParts) {
+			v = versionParts[i]
+		}
+		vn, verr := strconv.Atoi(v)
+		bn, berr := strconv.Atoi(b)
+		switch {
+		case verr == nil && berr == nil && vn != bn:
+			if vn < bn {
+				return -1
+			}
+			return 1
+		case (verr != nil || berr != nil) && v != b:
+			return strings.Compare(v, b)
+		}
+	}
+	return 0
+}
+
+// ServerInfo is the version of the server as returned in the headers of its
+// last response. Its fields are empty until a response carried them.
+type ServerInfo struct {
+	Version       string
+	SchemaVersion string
+	// SeenAt is the time of the last response carrying a version
+	SeenAt time.Time
+}
+
+// serverInfo captures the version headers of the responses of a client
+type serverInfo struct {
+	mu   sync.RWMutex
+	info ServerInfo
+
+	warn     func(info ServerInfo, tested VersionRange)
+	warnOnce sync.Once
+}
+
+func (s *serverInfo) observe(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	version := resp.Header.Get(ServerVersionHeader)
+	schemaVersion := resp.Header.Get(SchemaVersionHeader)
+	if version == "" && schemaVersion == "" {
+		return
+	}
+
+	s.mu.Lock()
+	s.info = ServerInfo{Version: version, SchemaVersion: schemaVersion, SeenAt: time.Now()}
+	info := s.info
+	warn := s.warn
+	s.mu.Unlock()
+
+	if warn != nil && version != "" && !TestedServerVersions.Contains(version) {
+		s.warnOnce.Do(func() { warn(info, TestedServerVersions) })
+	}
+}
+
+// ServerInfo returns the server version captured from the last response that
+// carried one
+func (g *GoPayamgostar) ServerInfo() ServerInfo {
+	g.serverInfo.mu.RLock()
+	defer g.serverInfo.mu.RUnlock()
+	return g.serverInfo.info
+}
+
+// WithCompatibilityWarning calls warn once, with the first response from a
+// server whose version is outside TestedServerVersions, so that deployments
+// can flag an upgrade of the CRM the client was not tested against
+func WithCompatibilityWarning(warn func(info ServerInfo, tested VersionRange)) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		g.serverInfo.mu.Lock()
+		defer g.serverInfo.mu.Unlock()
+		g.serverInfo.warn = warn
+	}
+}
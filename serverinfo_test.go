@@ -0,0 +1,56 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionRange(t *testing.T) {
+	tested := gopayamgostar.VersionRange{Min: "8.2", Max: "9"}
+	require.True(t, tested.Contains("8.2"))
+	require.True(t, tested.Contains("8.10.1"))
+	require.True(t, tested.Contains("9.4.7"))
+	require.True(t, tested.Contains("v9"))
+	require.False(t, tested.Contains("8.1.9"))
+	require.False(t, tested.Contains("10.0"))
+	require.True(t, gopayamgostar.VersionRange{}.Contains("1"))
+}
+
+func TestServerInfo(t *testing.T) {
+	var version atomic.Value
+	version.Store("9.1.0")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(gopayamgostar.ServerVersionHeader, version.Load().(string))
+		w.Header().Set(gopayamgostar.SchemaVersionHeader, "42")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var warnings []gopayamgostar.ServerInfo
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithCompatibilityWarning(func(info gopayamgostar.ServerInfo, tested gopayamgostar.VersionRange) {
+		require.Equal(t, gopayamgostar.TestedServerVersions, tested)
+		warnings = append(warnings, info)
+	}))
+	require.Empty(t, client.ServerInfo().Version)
+
+	ctx := context.Background()
+	require.NoError(t, client.DeleteForm(ctx, "token", "form"))
+	info := client.ServerInfo()
+	require.Equal(t, "9.1.0", info.Version)
+	require.Equal(t, "42", info.SchemaVersion)
+	require.False(t, info.SeenAt.IsZero())
+	require.Empty(t, warnings)
+
+	version.Store("11.0.0")
+	require.NoError(t, client.DeleteForm(ctx, "token", "form"))
+	require.NoError(t, client.DeleteForm(ctx, "token", "form"))
+	require.Len(t, warnings, 1)
+	require.Equal(t, "11.0.0", warnings[0].Version)
+	require.Equal(t, "11.0.0", client.ServerInfo().Version)
+}
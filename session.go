@@ -0,0 +1,373 @@
+package gopayamgostar
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
+)
+
+// Session binds a client to an access token, so that services authenticating
+// once do not have to pass the token to every call. Its methods are the ones
+// of GoPayamgostarIface taking an access token, without it.
+type Session struct {
+	client      GoPayamgostarIface
+	accessToken string
+}
+
+// NewSession returns a session calling client with accessToken. An empty
+// accessToken uses the token set with SetAccessToken at the time of each call.
+func NewSession(client GoPayamgostarIface, accessToken string) *Session {
+	return &Session{client: client, accessToken: accessToken}
+}
+
+// Session returns a session calling the client with accessToken, see NewSession
+func (g *GoPayamgostar) Session(accessToken string) *Session {
+	return NewSession(g, accessToken)
+}
+
+// Client returns the client of the session
+func (s *Session) Client() GoPayamgostarIface {
+	return s.client
+}
+
+// NewGroup calls GoPayamgostarIface.NewGroup with the token of the session
+func (s *Session) NewGroup(ctx context.Context, limit int) *Group {
+	return s.client.NewGroup(ctx, s.accessToken, limit)
+}
+
+// Do calls GoPayamgostarIface.Do with the token of the session
+func (s *Session) Do(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	return s.client.Do(ctx, s.accessToken, method, path, body, result)
+}
+
+// Logout calls GoPayamgostarIface.Logout with the token of the session
+func (s *Session) Logout(ctx context.Context) error {
+	return s.client.Logout(ctx, s.accessToken)
+}
+
+// GetPersonInfoById calls GoPayamgostarIface.GetPersonInfoById with the token of the session
+func (s *Session) GetPersonInfoById(ctx context.Context, crmId string) (*PersonInfo, error) {
+	return s.client.GetPersonInfoById(ctx, s.accessToken, crmId)
+}
+
+// FindPersonByName calls GoPayamgostarIface.FindPersonByName with the token of the session
+func (s *Session) FindPersonByName(ctx context.Context, typeKey string, firstName string, lastName string, options ...FindPersonOption) (*FindResponse, error) {
+	return s.client.FindPersonByName(ctx, s.accessToken, typeKey, firstName, lastName, options...)
+}
+
+// FindPersonsByName calls GoPayamgostarIface.FindPersonsByName with the token of the session
+func (s *Session) FindPersonsByName(ctx context.Context, typeKey string, firstName string, lastName string, options ...FindPersonOption) (*FindPersonResponse, error) {
+	return s.client.FindPersonsByName(ctx, s.accessToken, typeKey, firstName, lastName, options...)
+}
+
+// FindPersonsPage calls GoPayamgostarIface.FindPersonsPage with the token of the session
+func (s *Session) FindPersonsPage(ctx context.Context, typeKey string, queries []Query, pageNumber int64, pageSize int64) (*FindPersonResponse, error) {
+	return s.client.FindPersonsPage(ctx, s.accessToken, typeKey, queries, pageNumber, pageSize)
+}
+
+// FindPersonsStream calls GoPayamgostarIface.FindPersonsStream with the token of the session
+func (s *Session) FindPersonsStream(ctx context.Context, typeKey string, queries []Query) (<-chan PersonRow, <-chan error) {
+	return s.client.FindPersonsStream(ctx, s.accessToken, typeKey, queries)
+}
+
+// CreatePerson calls GoPayamgostarIface.CreatePerson with the token of the session
+func (s *Session) CreatePerson(ctx context.Context, request CreatePersonRequest) (string, error) {
+	return s.client.CreatePerson(ctx, s.accessToken, request)
+}
+
+// CreatePersons calls GoPayamgostarIface.CreatePersons with the token of the session
+func (s *Session) CreatePersons(ctx context.Context, requests []CreatePersonRequest, options BulkOptions) ([]BulkResult, error) {
+	return s.client.CreatePersons(ctx, s.accessToken, requests, options)
+}
+
+// UpdatePerson calls GoPayamgostarIface.UpdatePerson with the token of the session
+func (s *Session) UpdatePerson(ctx context.Context, request UpdatePersonRequest) (string, error) {
+	return s.client.UpdatePerson(ctx, s.accessToken, request)
+}
+
+// DeletePerson calls GoPayamgostarIface.DeletePerson with the token of the session
+func (s *Session) DeletePerson(ctx context.Context, crmId string) error {
+	return s.client.DeletePerson(ctx, s.accessToken, crmId)
+}
+
+// SetDefaultPhone calls GoPayamgostarIface.SetDefaultPhone with the token of the session
+func (s *Session) SetDefaultPhone(ctx context.Context, identityId string, phoneId string) error {
+	return s.client.SetDefaultPhone(ctx, s.accessToken, identityId, phoneId)
+}
+
+// GetIdentityTimeline calls GoPayamgostarIface.GetIdentityTimeline with the token of the session
+func (s *Session) GetIdentityTimeline(ctx context.Context, identityId string, formTypes ...string) ([]TimelineEntry, error) {
+	return s.client.GetIdentityTimeline(ctx, s.accessToken, identityId, formTypes...)
+}
+
+// GetFormInfoById calls GoPayamgostarIface.GetFormInfoById with the token of the session
+func (s *Session) GetFormInfoById(ctx context.Context, crmId string) (*FormInfo, error) {
+	return s.client.GetFormInfoById(ctx, s.accessToken, crmId)
+}
+
+// FindForm calls GoPayamgostarIface.FindForm with the token of the session
+func (s *Session) FindForm(ctx context.Context, typeKey string, queries []Query) (*FindFormResponse, error) {
+	return s.client.FindForm(ctx, s.accessToken, typeKey, queries)
+}
+
+// FindFormPage calls GoPayamgostarIface.FindFormPage with the token of the session
+func (s *Session) FindFormPage(ctx context.Context, typeKey string, queries []Query, pageNumber int64, pageSize int64) (*FindFormResponse, error) {
+	return s.client.FindFormPage(ctx, s.accessToken, typeKey, queries, pageNumber, pageSize)
+}
+
+// FindFormsStream calls GoPayamgostarIface.FindFormsStream with the token of the session
+func (s *Session) FindFormsStream(ctx context.Context, typeKey string, queries []Query) (<-chan FormResponse, <-chan error) {
+	return s.client.FindFormsStream(ctx, s.accessToken, typeKey, queries)
+}
+
+// CreateForm calls GoPayamgostarIface.CreateForm with the token of the session
+func (s *Session) CreateForm(ctx context.Context, request CreateFormRequest) (string, error) {
+	return s.client.CreateForm(ctx, s.accessToken, request)
+}
+
+// BulkCreateForms calls GoPayamgostarIface.BulkCreateForms with the token of the session
+func (s *Session) BulkCreateForms(ctx context.Context, requests []CreateFormRequest, options BulkOptions) ([]BulkResult, error) {
+	return s.client.BulkCreateForms(ctx, s.accessToken, requests, options)
+}
+
+// UpdateForm calls GoPayamgostarIface.UpdateForm with the token of the session
+func (s *Session) UpdateForm(ctx context.Context, request UpdateFormRequest) (string, error) {
+	return s.client.UpdateForm(ctx, s.accessToken, request)
+}
+
+// DeleteForm calls GoPayamgostarIface.DeleteForm with the token of the session
+func (s *Session) DeleteForm(ctx context.Context, formID string) error {
+	return s.client.DeleteForm(ctx, s.accessToken, formID)
+}
+
+// WaitForObjectCondition calls GoPayamgostarIface.WaitForObjectCondition with the token of the session
+func (s *Session) WaitForObjectCondition(ctx context.Context, crmId string, predicate func(*FormInfo) bool, pollInterval time.Duration) (*FormInfo, error) {
+	return s.client.WaitForObjectCondition(ctx, s.accessToken, crmId, predicate, pollInterval)
+}
+
+// GetObjectType calls GoPayamgostarIface.GetObjectType with the token of the session
+func (s *Session) GetObjectType(ctx context.Context, typeCode string) (*ObjectType, error) {
+	return s.client.GetObjectType(ctx, s.accessToken, typeCode)
+}
+
+// StartProcess calls GoPayamgostarIface.StartProcess with the token of the session
+func (s *Session) StartProcess(ctx context.Context, crmId string, processId string) error {
+	return s.client.StartProcess(ctx, s.accessToken, crmId, processId)
+}
+
+// CreatePurchase calls GoPayamgostarIface.CreatePurchase with the token of the session
+func (s *Session) CreatePurchase(ctx context.Context, purchase CreatePurchase) (string, error) {
+	return s.client.CreatePurchase(ctx, s.accessToken, purchase)
+}
+
+// CreatePurchaseInvoice calls GoPayamgostarIface.CreatePurchaseInvoice with the token of the session
+func (s *Session) CreatePurchaseInvoice(ctx context.Context, request CreatePurchaseRequest) (string, error) {
+	return s.client.CreatePurchaseInvoice(ctx, s.accessToken, request)
+}
+
+// DeletePurchase calls GoPayamgostarIface.DeletePurchase with the token of the session
+func (s *Session) DeletePurchase(ctx context.Context, purchaseID string, option enums.DeleteOption) (*DeleteResult, error) {
+	return s.client.DeletePurchase(ctx, s.accessToken, purchaseID, option)
+}
+
+// DeletePurchaseBulk calls GoPayamgostarIface.DeletePurchaseBulk with the token of the session
+func (s *Session) DeletePurchaseBulk(ctx context.Context, purchaseIDs []string, option enums.DeleteOption) ([]DeleteResult, error) {
+	return s.client.DeletePurchaseBulk(ctx, s.accessToken, purchaseIDs, option)
+}
+
+// GetInvoicesForIdentity calls GoPayamgostarIface.GetInvoicesForIdentity with the token of the session
+func (s *Session) GetInvoicesForIdentity(ctx context.Context, identityId string, dateRange DateRange, states []string) ([]InvoiceSummary, error) {
+	return s.client.GetInvoicesForIdentity(ctx, s.accessToken, identityId, dateRange, states)
+}
+
+// CreateReceipt calls GoPayamgostarIface.CreateReceipt with the token of the session
+func (s *Session) CreateReceipt(ctx context.Context, request CreateReceiptRequest) (string, error) {
+	return s.client.CreateReceipt(ctx, s.accessToken, request)
+}
+
+// DeleteReceipt calls GoPayamgostarIface.DeleteReceipt with the token of the session
+func (s *Session) DeleteReceipt(ctx context.Context, receiptID string) error {
+	return s.client.DeleteReceipt(ctx, s.accessToken, receiptID)
+}
+
+// RenderFormPDF calls GoPayamgostarIface.RenderFormPDF with the token of the session
+func (s *Session) RenderFormPDF(ctx context.Context, crmId string, w io.Writer) (int64, error) {
+	return s.client.RenderFormPDF(ctx, s.accessToken, crmId, w)
+}
+
+// GenerateInvoicePDFs calls GoPayamgostarIface.GenerateInvoicePDFs with the token of the session
+func (s *Session) GenerateInvoicePDFs(ctx context.Context, crmIds []string, dir string, workers int) (*InvoicePDFManifest, error) {
+	return s.client.GenerateInvoicePDFs(ctx, s.accessToken, crmIds, dir, workers)
+}
+
+// CreateTask calls GoPayamgostarIface.CreateTask with the token of the session
+func (s *Session) CreateTask(ctx context.Context, request CreateTaskRequest) (string, error) {
+	return s.client.CreateTask(ctx, s.accessToken, request)
+}
+
+// GetTask calls GoPayamgostarIface.GetTask with the token of the session
+func (s *Session) GetTask(ctx context.Context, crmId string) (*TaskInfo, error) {
+	return s.client.GetTask(ctx, s.accessToken, crmId)
+}
+
+// UpdateTask calls GoPayamgostarIface.UpdateTask with the token of the session
+func (s *Session) UpdateTask(ctx context.Context, request UpdateTaskRequest) (string, error) {
+	return s.client.UpdateTask(ctx, s.accessToken, request)
+}
+
+// CompleteTask calls GoPayamgostarIface.CompleteTask with the token of the session
+func (s *Session) CompleteTask(ctx context.Context, request CompleteTaskRequest) error {
+	return s.client.CompleteTask(ctx, s.accessToken, request)
+}
+
+// FindTasks calls GoPayamgostarIface.FindTasks with the token of the session
+func (s *Session) FindTasks(ctx context.Context, queries []Query) (*FindTaskResponse, error) {
+	return s.client.FindTasks(ctx, s.accessToken, queries)
+}
+
+// CreateTicket calls GoPayamgostarIface.CreateTicket with the token of the session
+func (s *Session) CreateTicket(ctx context.Context, request CreateTicketRequest) (string, error) {
+	return s.client.CreateTicket(ctx, s.accessToken, request)
+}
+
+// GetTicket calls GoPayamgostarIface.GetTicket with the token of the session
+func (s *Session) GetTicket(ctx context.Context, crmId string) (*TicketInfo, error) {
+	return s.client.GetTicket(ctx, s.accessToken, crmId)
+}
+
+// ReplyToTicket calls GoPayamgostarIface.ReplyToTicket with the token of the session
+func (s *Session) ReplyToTicket(ctx context.Context, request ReplyTicketRequest) (string, error) {
+	return s.client.ReplyToTicket(ctx, s.accessToken, request)
+}
+
+// CloseTicket calls GoPayamgostarIface.CloseTicket with the token of the session
+func (s *Session) CloseTicket(ctx context.Context, request CloseTicketRequest) error {
+	return s.client.CloseTicket(ctx, s.accessToken, request)
+}
+
+// FindTickets calls GoPayamgostarIface.FindTickets with the token of the session
+func (s *Session) FindTickets(ctx context.Context, queries []Query) (*FindTicketResponse, error) {
+	return s.client.FindTickets(ctx, s.accessToken, queries)
+}
+
+// FindTicketsPage calls GoPayamgostarIface.FindTicketsPage with the token of the session
+func (s *Session) FindTicketsPage(ctx context.Context, queries []Query, pageNumber int64, pageSize int64) (*FindTicketResponse, error) {
+	return s.client.FindTicketsPage(ctx, s.accessToken, queries, pageNumber, pageSize)
+}
+
+// CreateOpportunity calls GoPayamgostarIface.CreateOpportunity with the token of the session
+func (s *Session) CreateOpportunity(ctx context.Context, request CreateOpportunityRequest) (string, error) {
+	return s.client.CreateOpportunity(ctx, s.accessToken, request)
+}
+
+// GetOpportunity calls GoPayamgostarIface.GetOpportunity with the token of the session
+func (s *Session) GetOpportunity(ctx context.Context, crmId string) (*OpportunityInfo, error) {
+	return s.client.GetOpportunity(ctx, s.accessToken, crmId)
+}
+
+// UpdateOpportunityStage calls GoPayamgostarIface.UpdateOpportunityStage with the token of the session
+func (s *Session) UpdateOpportunityStage(ctx context.Context, request UpdateOpportunityStageRequest) error {
+	return s.client.UpdateOpportunityStage(ctx, s.accessToken, request)
+}
+
+// FindOpportunities calls GoPayamgostarIface.FindOpportunities with the token of the session
+func (s *Session) FindOpportunities(ctx context.Context, queries []Query) (*FindOpportunityResponse, error) {
+	return s.client.FindOpportunities(ctx, s.accessToken, queries)
+}
+
+// FindOpportunitiesPage calls GoPayamgostarIface.FindOpportunitiesPage with the token of the session
+func (s *Session) FindOpportunitiesPage(ctx context.Context, queries []Query, pageNumber int64, pageSize int64) (*FindOpportunityResponse, error) {
+	return s.client.FindOpportunitiesPage(ctx, s.accessToken, queries, pageNumber, pageSize)
+}
+
+// AddNote calls GoPayamgostarIface.AddNote with the token of the session
+func (s *Session) AddNote(ctx context.Context, crmId string, note NoteRequest) (string, error) {
+	return s.client.AddNote(ctx, s.accessToken, crmId, note)
+}
+
+// ListNotes calls GoPayamgostarIface.ListNotes with the token of the session
+func (s *Session) ListNotes(ctx context.Context, crmId string) ([]Note, error) {
+	return s.client.ListNotes(ctx, s.accessToken, crmId)
+}
+
+// UploadAttachment calls GoPayamgostarIface.UploadAttachment with the token of the session
+func (s *Session) UploadAttachment(ctx context.Context, crmId string, filename string, content io.Reader) (string, error) {
+	return s.client.UploadAttachment(ctx, s.accessToken, crmId, filename, content)
+}
+
+// ListAttachments calls GoPayamgostarIface.ListAttachments with the token of the session
+func (s *Session) ListAttachments(ctx context.Context, crmId string) ([]AttachmentInfo, error) {
+	return s.client.ListAttachments(ctx, s.accessToken, crmId)
+}
+
+// DownloadAttachment calls GoPayamgostarIface.DownloadAttachment with the token of the session
+func (s *Session) DownloadAttachment(ctx context.Context, attachmentId string, w io.Writer) (int64, error) {
+	return s.client.DownloadAttachment(ctx, s.accessToken, attachmentId, w)
+}
+
+// DeleteAttachment calls GoPayamgostarIface.DeleteAttachment with the token of the session
+func (s *Session) DeleteAttachment(ctx context.Context, attachmentId string) error {
+	return s.client.DeleteAttachment(ctx, s.accessToken, attachmentId)
+}
+
+// AddTags calls GoPayamgostarIface.AddTags with the token of the session
+func (s *Session) AddTags(ctx context.Context, crmId string, tags []string) error {
+	return s.client.AddTags(ctx, s.accessToken, crmId, tags)
+}
+
+// RemoveTags calls GoPayamgostarIface.RemoveTags with the token of the session
+func (s *Session) RemoveTags(ctx context.Context, crmId string, tags []string) error {
+	return s.client.RemoveTags(ctx, s.accessToken, crmId, tags)
+}
+
+// ReplaceTags calls GoPayamgostarIface.ReplaceTags with the token of the session
+func (s *Session) ReplaceTags(ctx context.Context, crmId string, tags []string) error {
+	return s.client.ReplaceTags(ctx, s.accessToken, crmId, tags)
+}
+
+// ListTags calls GoPayamgostarIface.ListTags with the token of the session
+func (s *Session) ListTags(ctx context.Context) ([]string, error) {
+	return s.client.ListTags(ctx, s.accessToken)
+}
+
+// SendEmail calls GoPayamgostarIface.SendEmail with the token of the session
+func (s *Session) SendEmail(ctx context.Context, request SendEmailRequest) (string, error) {
+	return s.client.SendEmail(ctx, s.accessToken, request)
+}
+
+// GetEmailStatus calls GoPayamgostarIface.GetEmailStatus with the token of the session
+func (s *Session) GetEmailStatus(ctx context.Context, emailId string) (*EmailStatus, error) {
+	return s.client.GetEmailStatus(ctx, s.accessToken, emailId)
+}
+
+// ListUsers calls GoPayamgostarIface.ListUsers with the token of the session
+func (s *Session) ListUsers(ctx context.Context) ([]User, error) {
+	return s.client.ListUsers(ctx, s.accessToken)
+}
+
+// GetUserByUsername calls GoPayamgostarIface.GetUserByUsername with the token of the session
+func (s *Session) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	return s.client.GetUserByUsername(ctx, s.accessToken, username)
+}
+
+// GetCurrentUser calls GoPayamgostarIface.GetCurrentUser with the token of the session
+func (s *Session) GetCurrentUser(ctx context.Context) (*User, error) {
+	return s.client.GetCurrentUser(ctx, s.accessToken)
+}
+
+// GetPicklist calls GoPayamgostarIface.GetPicklist with the token of the session
+func (s *Session) GetPicklist(ctx context.Context, name string) ([]PicklistItem, error) {
+	return s.client.GetPicklist(ctx, s.accessToken, name)
+}
+
+// ListColors calls GoPayamgostarIface.ListColors with the token of the session
+func (s *Session) ListColors(ctx context.Context) ([]Color, error) {
+	return s.client.ListColors(ctx, s.accessToken)
+}
+
+// GetProductByCode calls GoPayamgostarIface.GetProductByCode with the token of the session
+func (s *Session) GetProductByCode(ctx context.Context, code string) (*Product, error) {
+	return s.client.GetProductByCode(ctx, s.accessToken, code)
+}
@@ -0,0 +1,65 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	personID := server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Ali"})
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	session := client.Session(token.AccessToken)
+	person, err := session.GetPersonInfoById(ctx, personID)
+	require.NoError(t, err)
+	require.Equal(t, "Ali", person.FirstName)
+
+	held := client.Session("")
+	_, err = held.GetPersonInfoById(ctx, personID)
+	require.Error(t, err)
+
+	client.SetAccessToken(token.AccessToken)
+	require.Equal(t, token.AccessToken, client.AccessToken())
+	person, err = held.GetPersonInfoById(ctx, personID)
+	require.NoError(t, err)
+	require.Equal(t, personID, person.CRMID)
+	_, err = client.GetPersonInfoById(ctx, "", personID)
+	require.NoError(t, err)
+}
+
+func TestSessionCoversClient(t *testing.T) {
+	notBound := map[string]bool{
+		"GetRequestWithBearerAuth":        true,
+		"GetRequestWithBearerAuthNoCache": true,
+		"AdminAuthenticate":               true,
+		"UserAuthenticate":                true,
+		"RefreshToken":                    true,
+		"Session":                         true,
+		"SetAccessToken":                  true,
+	}
+	iface := reflect.TypeOf((*gopayamgostar.GoPayamgostarIface)(nil)).Elem()
+	session := reflect.TypeOf(&gopayamgostar.Session{})
+	for i := 0; i < iface.NumMethod(); i++ {
+		method := iface.Method(i)
+		if notBound[method.Name] || method.Type.NumIn() < 2 || method.Type.In(1).Kind() != reflect.String {
+			continue
+		}
+		bound, ok := session.MethodByName(method.Name)
+		require.True(t, ok, "Session has no %s", method.Name)
+		// the receiver comes first and the access token is left out
+		require.Equal(t, method.Type.NumIn(), bound.Type.NumIn(), method.Name)
+		require.Equal(t, method.Type.NumOut(), bound.Type.NumOut(), method.Name)
+	}
+}
@@ -0,0 +1,10 @@
+package enums
+
+type DeleteOption int
+
+const (
+	// DeleteOnly removes the object and keeps records related to it
+	DeleteOnly DeleteOption = iota
+	// DeleteWithRelated removes the object together with its related records
+	DeleteWithRelated
+)
@@ -0,0 +1,11 @@
+package enums
+
+type PhoneType string
+
+const (
+	PhoneMobile PhoneType = "Mobile"
+	PhoneHome   PhoneType = "Home"
+	PhoneWork   PhoneType = "Work"
+	PhoneFax    PhoneType = "Fax"
+	PhoneOther  PhoneType = "Other"
+)
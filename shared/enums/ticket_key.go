@@ -0,0 +1,20 @@
+package enums
+
+type TicketStatus int
+
+const (
+	TicketOpen TicketStatus = iota
+	TicketInProgress
+	TicketWaitingForCustomer
+	TicketResolved
+	TicketClosed
+)
+
+type TicketPriority int
+
+const (
+	TicketPriorityLow TicketPriority = iota
+	TicketPriorityNormal
+	TicketPriorityHigh
+	TicketPriorityUrgent
+)
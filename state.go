@@ -0,0 +1,139 @@
+package gopayamgostar
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const stateFormatVersion byte = 1
+
+const (
+	stateFlagCompressed byte = 1 << iota
+	stateFlagEncrypted
+)
+
+// StateKeyProvider returns the AES key (16, 24 or 32 bytes) used to encrypt
+// state the client keeps on disk. It is called on every Encode/Decode so that
+// the key can be read from a secret store and rotated.
+type StateKeyProvider func() ([]byte, error)
+
+// StateCodec compresses and/or encrypts state persisted on disk, such as the
+// tokens of FileTokenStore and the operations of offline.FileStore. The zero
+// value stores data as-is.
+type StateCodec struct {
+	// Compress gzips the data before it is (optionally) encrypted
+	Compress bool
+	// Key enables AES-GCM encryption when set
+	Key StateKeyProvider
+}
+
+// IsZero reports whether the codec stores data as-is, without the header
+// Encode adds
+func (c StateCodec) IsZero() bool {
+	return !c.Compress && c.Key == nil
+}
+
+// Encode converts plain state into its on-disk representation. The data is
+// prefixed with a header of the format version and flags; when encrypted the
+// header is authenticated with the data.
+func (c StateCodec) Encode(plain []byte) ([]byte, error) {
+	var flags byte
+	if c.Compress {
+		flags |= stateFlagCompressed
+	}
+	if c.Key != nil {
+		flags |= stateFlagEncrypted
+	}
+	header := []byte{stateFormatVersion, flags}
+	data := plain
+
+	if c.Compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, errors.Wrap(err, "could not compress state")
+		}
+		if err := zw.Close(); err != nil {
+			return nil, errors.Wrap(err, "could not compress state")
+		}
+		data = buf.Bytes()
+	}
+
+	if c.Key != nil {
+		aead, err := c.aead()
+		if err != nil {
+			return nil, err
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, errors.Wrap(err, "could not generate nonce")
+		}
+		data = aead.Seal(nonce, nonce, data, header)
+	}
+
+	return append(header, data...), nil
+}
+
+// Decode converts state produced by Encode back into plain data. With a Key
+// set it only accepts encrypted state, so that state written by someone not
+// holding the key is rejected.
+func (c StateCodec) Decode(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != stateFormatVersion {
+		return nil, errors.New("unknown state format")
+	}
+	header, flags := data[:2], data[1]
+	data = data[2:]
+
+	if flags&stateFlagEncrypted == 0 && c.Key != nil {
+		return nil, errors.New("state is not encrypted but a key is configured")
+	}
+	if flags&stateFlagEncrypted != 0 {
+		if c.Key == nil {
+			return nil, errors.New("state is encrypted but no key is configured")
+		}
+		aead, err := c.aead()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) < aead.NonceSize() {
+			return nil, errors.New("state is truncated")
+		}
+		nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+		data, err = aead.Open(nil, nonce, sealed, header)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not decrypt state")
+		}
+	}
+
+	if flags&stateFlagCompressed != 0 {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrap(err, "could not decompress state")
+		}
+		defer zr.Close()
+		data, err = io.ReadAll(zr)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not decompress state")
+		}
+	}
+
+	return data, nil
+}
+
+func (c StateCodec) aead() (cipher.AEAD, error) {
+	key, err := c.Key()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get state key")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid state key")
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,70 @@
+package gopayamgostar_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func testStateKey() ([]byte, error) {
+	return bytes.Repeat([]byte{7}, 32), nil
+}
+
+func TestStateCodecRoundTrip(t *testing.T) {
+	plain := []byte(`{"accessToken":"abc","refreshToken":"def"}`)
+
+	for name, codec := range map[string]gopayamgostar.StateCodec{
+		"plain":      {},
+		"compressed": {Compress: true},
+		"encrypted":  {Key: testStateKey},
+		"both":       {Compress: true, Key: testStateKey},
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := codec.Encode(plain)
+			require.NoError(t, err)
+			if codec.Key != nil {
+				require.False(t, bytes.Contains(data, []byte("accessToken")), "state is not encrypted")
+			}
+			got, err := codec.Decode(data)
+			require.NoError(t, err)
+			require.Equal(t, plain, got)
+		})
+	}
+}
+
+func TestStateCodecWrongKey(t *testing.T) {
+	data, err := gopayamgostar.StateCodec{Key: testStateKey}.Encode([]byte("secret"))
+	require.NoError(t, err)
+
+	_, err = gopayamgostar.StateCodec{}.Decode(data)
+	require.Error(t, err)
+
+	other := gopayamgostar.StateCodec{Key: func() ([]byte, error) {
+		return bytes.Repeat([]byte{8}, 32), nil
+	}}
+	_, err = other.Decode(data)
+	require.Error(t, err)
+}
+
+func TestStateCodecRejectsTampering(t *testing.T) {
+	codec := gopayamgostar.StateCodec{Compress: true, Key: testStateKey}
+	data, err := codec.Encode([]byte("secret"))
+	require.NoError(t, err)
+
+	// the flags are authenticated with the data
+	flipped := append([]byte(nil), data...)
+	flipped[1] &^= 1
+	_, err = codec.Decode(flipped)
+	require.ErrorContains(t, err, "could not decrypt state")
+
+	// state written without the key is not accepted when a key is configured
+	injected, err := gopayamgostar.StateCodec{Compress: true}.Encode([]byte(`{"accessToken":"injected"}`))
+	require.NoError(t, err)
+	_, err = codec.Decode(injected)
+	require.ErrorContains(t, err, "not encrypted")
+	injected[1] |= 2
+	_, err = codec.Decode(injected)
+	require.Error(t, err)
+}
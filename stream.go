@@ -0,0 +1,80 @@
+package gopayamgostar
+
+import "context"
+
+// streamPageSize is the number of rows a stream fetches per page
+const streamPageSize = 100
+
+// FindPersonsStream sends the persons of typeKey matching queries on the
+// returned channel as their pages arrive, fetching the next page while the
+// current one is processed, so that pipelines do not hold every page in
+// memory:
+//
+//	rows, errs := client.FindPersonsStream(ctx, accessToken, "Person", queries)
+//	for row := range rows {
+//		load(row)
+//	}
+//	if err := <-errs; err != nil {
+//		return err
+//	}
+//
+// Once rows is closed, errs yields the error that stopped the stream, such as
+// the one of a page or of ctx, or nil. Pages served stale by the mirror stop
+// it with ErrServedStale. Callers stopping early must cancel ctx.
+func (g *GoPayamgostar) FindPersonsStream(ctx context.Context, accessToken string, typeKey string, queries []Query) (<-chan PersonRow, <-chan error) {
+	return stream(ctx, func(ctx context.Context, pageNumber int64) ([]PersonRow, int64, error) {
+		result, err := g.FindPersonsPage(ctx, accessToken, typeKey, queries, pageNumber, streamPageSize)
+		if err != nil {
+			return nil, 0, err
+		}
+		return result.Data, result.Total, nil
+	})
+}
+
+// FindFormsStream sends the forms of typeKey matching queries on the returned
+// channel as their pages arrive, like FindPersonsStream
+func (g *GoPayamgostar) FindFormsStream(ctx context.Context, accessToken string, typeKey string, queries []Query) (<-chan FormResponse, <-chan error) {
+	return stream(ctx, func(ctx context.Context, pageNumber int64) ([]FormResponse, int64, error) {
+		result, err := g.FindFormPage(ctx, accessToken, typeKey, queries, pageNumber, streamPageSize)
+		if err != nil {
+			return nil, 0, err
+		}
+		return result.Data, result.Total, nil
+	})
+}
+
+// stream sends the rows of the pages fetch returns until the last one. The
+// rows channel buffers a page, so that the next one is fetched meanwhile.
+func stream[T any](ctx context.Context, fetch func(ctx context.Context, pageNumber int64) ([]T, int64, error)) (<-chan T, <-chan error) {
+	rows := make(chan T, streamPageSize)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(rows)
+
+		var seen int64
+		for pageNumber := int64(1); ; pageNumber++ {
+			data, total, err := fetch(ctx, pageNumber)
+			if err != nil {
+				errs <- err
+				return
+			}
+			for _, row := range data {
+				select {
+				case rows <- row:
+				case <-ctx.Done():
+					errs <- ctx.Err()
+					return
+				}
+			}
+
+			seen += int64(len(data))
+			if len(data) < streamPageSize || seen >= total {
+				return
+			}
+		}
+	}()
+
+	return rows, errs
+}
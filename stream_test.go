@@ -0,0 +1,82 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+)
+
+func TestFindPersonsStream(t *testing.T) {
+	t.Parallel()
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	for i := 0; i < 250; i++ {
+		server.AddPerson(gopayamgostar.PersonInfo{CRMID: "person-" + strconv.Itoa(1000+i), FirstName: "Ali"})
+	}
+	server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Sara"})
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	queries := []gopayamgostar.Query{{Field: "FirstName", Value: "Ali"}}
+	rows, errs := client.FindPersonsStream(ctx, token.AccessToken, "Person", queries)
+	var ids []string
+	for row := range rows {
+		ids = append(ids, row.CRMID)
+	}
+	require.NoError(t, <-errs)
+	require.Len(t, ids, 250)
+	for i, id := range ids {
+		require.Equal(t, "person-"+strconv.Itoa(1000+i), id)
+	}
+
+	// stopping early
+	ctx, cancel := context.WithCancel(ctx)
+	rows, errs = client.FindPersonsStream(ctx, token.AccessToken, "Person", queries)
+	<-rows
+	cancel()
+	for range rows {
+	}
+	require.ErrorIs(t, <-errs, context.Canceled)
+}
+
+func TestFindFormsStream(t *testing.T) {
+	t.Parallel()
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	created := map[string]bool{}
+	for i := 0; i < 120; i++ {
+		id, err := client.CreateForm(ctx, token.AccessToken, gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Complaint"})
+		require.NoError(t, err)
+		created[id] = true
+	}
+
+	rows, errs := client.Session(token.AccessToken).FindFormsStream(ctx, "Complaint", nil)
+	streamed := map[string]bool{}
+	for row := range rows {
+		streamed[row.CRMID] = true
+	}
+	require.NoError(t, <-errs)
+	require.Equal(t, created, streamed)
+
+	rows, errs = client.FindFormsStream(ctx, "revoked", "Complaint", nil)
+	_, ok := <-rows
+	require.False(t, ok)
+	require.Error(t, <-errs)
+}
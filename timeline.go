@@ -0,0 +1,144 @@
+package gopayamgostar
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
+)
+
+// TimelineKind is the kind of object a TimelineEntry refers to
+type TimelineKind string
+
+const (
+	TimelineNote    TimelineKind = "note"
+	TimelineTask    TimelineKind = "task"
+	TimelineInvoice TimelineKind = "invoice"
+	TimelineTicket  TimelineKind = "ticket"
+	TimelineForm    TimelineKind = "form"
+)
+
+// TimelineEntry is one item of an identity timeline. Item holds the typed
+// object: Note, TaskInfo, InvoiceSummary, TicketInfo or FormResponse.
+type TimelineEntry struct {
+	Kind    TimelineKind
+	CrmId   string
+	Date    time.Time
+	Subject string
+	Item    interface{}
+}
+
+// GetIdentityTimeline returns the notes, tasks, invoices, tickets and the forms
+// of formTypes related to a person as a single feed sorted from oldest to
+// newest. The sources are fetched concurrently; the first failure cancels the
+// remaining fetches and is returned.
+func (g *GoPayamgostar) GetIdentityTimeline(ctx context.Context, accessToken, identityId string, formTypes ...string) ([]TimelineEntry, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	identityQuery := []Query{
+		{
+			LogicalOperator: int(enums.And),
+			FieldOperator:   int(enums.Equals),
+			Field:           "IdentityId",
+			Value:           identityId,
+		},
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		entries  []TimelineEntry
+		firstErr error
+	)
+	fetch := func(f func() ([]TimelineEntry, error)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := f()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				return
+			}
+			entries = append(entries, result...)
+		}()
+	}
+
+	fetch(func() ([]TimelineEntry, error) {
+		notes, err := g.ListNotes(ctx, accessToken, identityId)
+		if err != nil {
+			return nil, err
+		}
+		result := make([]TimelineEntry, 0, len(notes))
+		for _, note := range notes {
+			result = append(result, TimelineEntry{Kind: TimelineNote, CrmId: note.ID, Date: note.CreateDate.Time, Subject: note.Text, Item: note})
+		}
+		return result, nil
+	})
+	fetch(func() ([]TimelineEntry, error) {
+		tasks, err := g.FindTasks(ctx, accessToken, identityQuery)
+		if err != nil {
+			return nil, err
+		}
+		result := make([]TimelineEntry, 0, len(tasks.Data))
+		for _, task := range tasks.Data {
+			result = append(result, TimelineEntry{Kind: TimelineTask, CrmId: task.CRMID, Date: task.CreatDate.Time, Subject: task.Subject, Item: task})
+		}
+		return result, nil
+	})
+	fetch(func() ([]TimelineEntry, error) {
+		invoices, err := g.GetInvoicesForIdentity(ctx, accessToken, identityId, DateRange{}, nil)
+		if err != nil {
+			return nil, err
+		}
+		result := make([]TimelineEntry, 0, len(invoices))
+		for _, invoice := range invoices {
+			result = append(result, TimelineEntry{Kind: TimelineInvoice, CrmId: invoice.CRMID, Date: invoice.CreatDate.Time, Subject: invoice.Subject, Item: invoice})
+		}
+		return result, nil
+	})
+	fetch(func() ([]TimelineEntry, error) {
+		tickets, err := g.FindTickets(ctx, accessToken, identityQuery)
+		if err != nil {
+			return nil, err
+		}
+		result := make([]TimelineEntry, 0, len(tickets.Data))
+		for _, ticket := range tickets.Data {
+			result = append(result, TimelineEntry{Kind: TimelineTicket, CrmId: ticket.CRMID, Date: ticket.CreatDate.Time, Subject: ticket.Subject, Item: ticket})
+		}
+		return result, nil
+	})
+	for _, formType := range formTypes {
+		formType := formType
+		fetch(func() ([]TimelineEntry, error) {
+			forms, err := g.FindForm(ctx, accessToken, formType, identityQuery)
+			if err != nil {
+				return nil, err
+			}
+			result := make([]TimelineEntry, 0, len(forms.Data))
+			for _, form := range forms.Data {
+				result = append(result, TimelineEntry{Kind: TimelineForm, CrmId: form.CRMID, Date: form.CreatDate.Time, Subject: form.Subject, Item: form})
+			}
+			return result, nil
+		})
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Date.Before(entries[j].Date)
+	})
+
+	return entries, nil
+}
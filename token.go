@@ -1,9 +1,357 @@
 package gopayamgostar
 
-import "time"
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+)
+
+// tokenExpirySkew is how long before its expiry a cached token is renewed
+const tokenExpirySkew = 30 * time.Second
 
 type JWT struct {
 	AccessToken  string    `json:"accessToken"`
 	RefreshToken string    `json:"refreshToken"`
 	ExpiresAt    time.Time `json:"expiresAt"`
 }
+
+// tokenCache shares the logins of a client: concurrent logins with the same
+// credentials are sent once and their token is kept in store and reused until
+// it expires, then renewed with its refresh token when it has one
+type tokenCache struct {
+	group singleflight.Group
+	store TokenStore
+	// scope separates the tokens of clients of different servers sharing store
+	scope string
+	// refresh exchanges a refresh token for a new token
+	refresh func(ctx context.Context, refreshToken string) (*JWT, error)
+
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+func newTokenCache(scope string) *tokenCache {
+	return &tokenCache{store: NewMemoryTokenStore(), scope: scope, keys: map[string]bool{}}
+}
+
+// login returns the cached token of the credentials or calls authenticate
+// once for all concurrent callers. A caller whose ctx is done stops waiting
+// without cancelling the login of the others.
+func (c *tokenCache) login(ctx context.Context, kind, username, password string, authenticate func(context.Context) (*JWT, error)) (*JWT, error) {
+	key := c.key(kind, username, password)
+	c.mu.Lock()
+	c.keys[key] = true
+	c.mu.Unlock()
+	if token, ok := c.valid(ctx, key); ok {
+		return &token, nil
+	}
+
+	result := c.group.DoChan(key, func() (interface{}, error) {
+		ctx := context.WithoutCancel(ctx)
+		// another process sharing the store may have logged in meanwhile
+		if token, ok := c.valid(ctx, key); ok {
+			return token, nil
+		}
+		token, err := c.renew(ctx, key)
+		if err != nil {
+			token, err = authenticate(ctx)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !token.ExpiresAt.IsZero() {
+			// not fatal, the next login authenticates again
+			_ = c.store.Put(ctx, key, *token)
+		}
+		return *token, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-result:
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		token := r.Val.(JWT)
+		return &token, nil
+	}
+}
+
+// valid returns the stored token of key when it is not about to expire
+func (c *tokenCache) valid(ctx context.Context, key string) (JWT, bool) {
+	token, ok, err := c.store.Get(ctx, key)
+	if err != nil || !ok || !time.Now().Add(tokenExpirySkew).Before(token.ExpiresAt) {
+		return JWT{}, false
+	}
+	return token, true
+}
+
+// renew refreshes the stored token of key
+func (c *tokenCache) renew(ctx context.Context, key string) (*JWT, error) {
+	stored, ok, err := c.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || stored.RefreshToken == "" || c.refresh == nil {
+		return nil, errors.New("no refresh token")
+	}
+	return c.refresh(ctx, stored.RefreshToken)
+}
+
+// forget drops the cached token of the credentials when it is accessToken, so
+// that the next login gets a new one. A token already renewed is kept.
+func (c *tokenCache) forget(ctx context.Context, kind, username, password, accessToken string) {
+	key := c.key(kind, username, password)
+	if token, ok, err := c.store.Get(ctx, key); err == nil && ok && token.AccessToken == accessToken {
+		_ = c.store.Delete(ctx, key)
+	}
+}
+
+// revoke drops accessToken from the store, whichever login of the client it
+// was cached for
+func (c *tokenCache) revoke(ctx context.Context, accessToken string) {
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.keys))
+	for key := range c.keys {
+		keys = append(keys, key)
+	}
+	c.mu.Unlock()
+
+	for _, key := range keys {
+		if token, ok, err := c.store.Get(ctx, key); err == nil && ok && token.AccessToken == accessToken {
+			_ = c.store.Delete(ctx, key)
+		}
+	}
+}
+
+// clear drops the tokens of the logins of the client from the store
+func (c *tokenCache) clear() {
+	c.mu.Lock()
+	keys := c.keys
+	c.keys = map[string]bool{}
+	c.mu.Unlock()
+
+	for key := range keys {
+		_ = c.store.Delete(context.Background(), key)
+	}
+}
+
+func (c *tokenCache) key(kind, username, password string) string {
+	sum := sha256.Sum256([]byte(c.scope + "\x00" + kind + "\x00" + username + "\x00" + password))
+	return hex.EncodeToString(sum[:])
+}
+
+// ClearTokenCache makes the next AdminAuthenticate and UserAuthenticate calls
+// log in again, e.g. after a token was revoked. It drops the tokens of the
+// logins made by the client from its TokenStore.
+func (g *GoPayamgostar) ClearTokenCache() {
+	g.tokens.clear()
+}
+
+// SetAccessToken holds token in the client for the calls given an empty
+// access token, so that services authenticating once can use Session or pass
+// "" instead of threading the token through their code. Set it again when
+// the token is refreshed.
+func (g *GoPayamgostar) SetAccessToken(token string) {
+	g.heldToken.Store(token)
+}
+
+// AccessToken returns the token set with SetAccessToken
+func (g *GoPayamgostar) AccessToken() string {
+	token, _ := g.heldToken.Load().(string)
+	return token
+}
+
+// tokenOrHeld returns token, or the held token when token is empty
+func (g *GoPayamgostar) tokenOrHeld(token string) string {
+	if token == "" {
+		return g.AccessToken()
+	}
+	return token
+}
+
+// APIKeyHeader is the header WithAPIKey sends the key in
+const APIKeyHeader = "X-API-Key"
+
+// WithAPIKey authenticates every request of the client with the static API
+// key some tenants issue instead of user credentials. AdminAuthenticate and
+// UserAuthenticate then return an empty token without logging in, so code
+// passing their token on works unchanged. The key is redacted in the request
+// logs of WithDebug.
+func WithAPIKey(key string) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		WithStaticHeaders(map[string]string{APIKeyHeader: key})(g)
+		g.apiKey = key
+	}
+}
+
+// WithAutoReauth logs in again with AdminAuthenticate when a call is rejected
+// with 401 Unauthorized, for instance because its token expired, and retries
+// it once with the new token. When the rejected token is the one held with
+// SetAccessToken, the new token replaces it so that the following calls use
+// it directly. Calls whose body cannot be sent again are not retried.
+func WithAutoReauth(username, password string) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		httpClient := g.restyClient.GetClient()
+		next := httpClient.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		httpClient.Transport = &reauthTransport{next: next, client: g, username: username, password: password}
+	}
+}
+
+// reauthTransport retries the requests rejected for their token with a new one
+type reauthTransport struct {
+	next     http.RoundTripper
+	client   *GoPayamgostar
+	username string
+	password string
+}
+
+func (t *reauthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rejected, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	t.client.tokens.forget(req.Context(), "admin", t.username, t.password, rejected)
+	token, aerr := t.client.AdminAuthenticate(req.Context(), t.username, t.password)
+	if aerr != nil {
+		// the rejection is more useful to the caller than the failed login
+		return resp, nil
+	}
+	t.client.heldToken.CompareAndSwap(rejected, token.AccessToken)
+
+	retry := req.Clone(req.Context())
+	retry.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	if req.GetBody != nil {
+		body, berr := req.GetBody()
+		if berr != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return t.next.RoundTrip(retry)
+}
+
+// Claim names of ASP.NET identity tokens, used when the short names are absent
+const (
+	nameIdentifierClaim = "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/nameidentifier"
+	nameClaim           = "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name"
+	roleClaim           = "http://schemas.microsoft.com/ws/2008/06/identity/claims/role"
+)
+
+// TokenClaims are the claims of an access token, see DecodeAccessToken
+type TokenClaims struct {
+	// UserID is the sub, userId or nameidentifier claim
+	UserID string
+	// Username is the unique_name, name or username claim
+	Username  string
+	Roles     []string
+	Issuer    string
+	IssuedAt  time.Time
+	NotBefore time.Time
+	ExpiresAt time.Time
+	// Raw holds all the claims of the token
+	Raw map[string]json.RawMessage
+}
+
+// Expired reports whether the token has expired, false when it has no expiry
+func (c *TokenClaims) Expired() bool {
+	return !c.ExpiresAt.IsZero() && !time.Now().Before(c.ExpiresAt)
+}
+
+// DecodeAccessToken returns the claims of a JWT access token, such as the
+// one returned by AdminAuthenticate, to inspect its lifetime and identity.
+// The signature is not verified: do not use the claims for authorization.
+func DecodeAccessToken(token string) (*TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("could not decode access token: not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode access token")
+	}
+
+	claims := &TokenClaims{}
+	if err := json.Unmarshal(payload, &claims.Raw); err != nil {
+		return nil, errors.Wrap(err, "could not decode access token claims")
+	}
+
+	claims.UserID = claims.stringClaim("sub", "userId", "user_id", "nameid", nameIdentifierClaim)
+	claims.Username = claims.stringClaim("unique_name", "username", "preferred_username", "name", nameClaim)
+	claims.Issuer = claims.stringClaim("iss")
+	claims.Roles = claims.stringsClaim("role", "roles", roleClaim)
+	claims.IssuedAt = claims.timeClaim("iat")
+	claims.NotBefore = claims.timeClaim("nbf")
+	claims.ExpiresAt = claims.timeClaim("exp")
+	return claims, nil
+}
+
+// stringClaim returns the first of names holding a string or number claim
+func (c *TokenClaims) stringClaim(names ...string) string {
+	for _, name := range names {
+		var value interface{}
+		if json.Unmarshal(c.Raw[name], &value) != nil {
+			continue
+		}
+		switch value := value.(type) {
+		case string:
+			return value
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+// stringsClaim returns the values of the claims names, which hold a string or a
+// list of strings
+func (c *TokenClaims) stringsClaim(names ...string) []string {
+	var values []string
+	for _, name := range names {
+		var list []string
+		if err := json.Unmarshal(c.Raw[name], &list); err == nil {
+			values = append(values, list...)
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(c.Raw[name], &value); err == nil {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// timeClaim returns the NumericDate claim name
+func (c *TokenClaims) timeClaim(name string) time.Time {
+	var seconds float64
+	if err := json.Unmarshal(c.Raw[name], &seconds); err != nil {
+		return time.Time{}
+	}
+	return time.Unix(int64(seconds), 0)
+}
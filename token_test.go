@@ -0,0 +1,289 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+)
+
+func TestAuthenticateSharesLogins(t *testing.T) {
+	t.Parallel()
+
+	var logins int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&logins, 1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(gopayamgostar.JWT{
+			AccessToken: "token-" + string(rune('0'+n)),
+			ExpiresAt:   time.Now().Add(time.Hour),
+		})
+	}))
+	defer server.Close()
+
+	client := gopayamgostar.NewClient(server.URL)
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 10)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			token, err := client.AdminAuthenticate(context.Background(), "admin", "secret")
+			require.NoError(t, err)
+			tokens[i] = token.AccessToken
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&logins))
+	for _, token := range tokens {
+		require.Equal(t, "token-1", token)
+	}
+
+	token, err := client.AdminAuthenticate(context.Background(), "admin", "secret")
+	require.NoError(t, err)
+	require.Equal(t, "token-1", token.AccessToken)
+	require.Equal(t, int32(1), atomic.LoadInt32(&logins), "the cached token is reused")
+
+	token, err = client.UserAuthenticate(context.Background(), "admin", "secret")
+	require.NoError(t, err)
+	require.Equal(t, "token-2", token.AccessToken, "user logins are cached separately")
+
+	client.ClearTokenCache()
+	token, err = client.AdminAuthenticate(context.Background(), "admin", "secret")
+	require.NoError(t, err)
+	require.Equal(t, "token-3", token.AccessToken)
+}
+
+func TestAuthenticateWaiterCancel(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(gopayamgostar.JWT{AccessToken: "token", ExpiresAt: time.Now().Add(time.Hour)})
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := gopayamgostar.NewClient(server.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWithAutoReauth(t *testing.T) {
+	t.Parallel()
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	personID := server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Ali"})
+
+	ctx := context.Background()
+	client := server.Client(gopayamgostar.WithAutoReauth("admin", "secret"))
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	client.SetAccessToken(token.AccessToken)
+
+	server.RevokeTokens()
+	formID, err := client.CreateForm(ctx, "", gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Order", IdentityID: personID})
+	require.NoError(t, err, "the body is sent again")
+	_, ok := server.Form(formID)
+	require.True(t, ok)
+	require.NotEqual(t, token.AccessToken, client.AccessToken(), "the held token is replaced")
+
+	renewed, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	require.Equal(t, client.AccessToken(), renewed.AccessToken, "the new token is cached")
+
+	// a token passed explicitly is retried without replacing the held one
+	client.SetAccessToken("held")
+	server.RevokeTokens()
+	person, err := client.GetPersonInfoById(gopayamgostar.WithoutCache(ctx), renewed.AccessToken, personID)
+	require.NoError(t, err)
+	require.Equal(t, "Ali", person.FirstName)
+	require.Equal(t, "held", client.AccessToken())
+
+	// the rejection is returned when logging in again fails
+	server.RevokeTokens()
+	server.AddUser("admin", "changed")
+	_, err = client.GetPersonInfoById(gopayamgostar.WithoutCache(ctx), "stale", personID)
+	var apiErr *gopayamgostar.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusUnauthorized, apiErr.Code)
+
+	// without the option, calls fail with the expired token
+	plain := server.Client()
+	_, err = plain.GetPersonInfoById(ctx, "stale", personID)
+	require.ErrorAs(t, err, &apiErr)
+}
+
+func TestDecodeAccessToken(t *testing.T) {
+	encode := func(claims string) string {
+		return "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".c2lnbmF0dXJl"
+	}
+
+	claims, err := gopayamgostar.DecodeAccessToken(encode(`{
+		"sub": "f845cf77",
+		"unique_name": "admin",
+		"role": ["Admin", "Sales"],
+		"iss": "payamgostar",
+		"iat": 1700000000,
+		"exp": 1700003600
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, "f845cf77", claims.UserID)
+	require.Equal(t, "admin", claims.Username)
+	require.Equal(t, []string{"Admin", "Sales"}, claims.Roles)
+	require.Equal(t, "payamgostar", claims.Issuer)
+	require.Equal(t, time.Unix(1700000000, 0), claims.IssuedAt)
+	require.Equal(t, time.Unix(1700003600, 0), claims.ExpiresAt)
+	require.True(t, claims.NotBefore.IsZero())
+	require.True(t, claims.Expired())
+	require.Contains(t, claims.Raw, "iss")
+
+	claims, err = gopayamgostar.DecodeAccessToken(encode(`{
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/nameidentifier": 42,
+		"http://schemas.microsoft.com/ws/2008/06/identity/claims/role": "Admin"
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, "42", claims.UserID)
+	require.Equal(t, []string{"Admin"}, claims.Roles)
+	require.False(t, claims.Expired(), "no expiry")
+
+	for _, token := range []string{"", "opaque-token", "a.!!!.c", encode("[]")} {
+		_, err := gopayamgostar.DecodeAccessToken(token)
+		require.Error(t, err, token)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	t.Parallel()
+
+	var logins, refreshes int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&logins, 1)
+		w.Header().Set("Content-Type", "application/json")
+		// expires within the renewal skew, so the next login renews it
+		_ = json.NewEncoder(w).Encode(gopayamgostar.JWT{AccessToken: "login", RefreshToken: "refresh-1", ExpiresAt: time.Now().Add(time.Second)})
+	})
+	mux.HandleFunc("/api/v2/auth/token/refresh", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&refreshes, 1)
+		var request gopayamgostar.RefreshTokenRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Header().Set("Content-Type", "application/json")
+		if request.RefreshToken != "refresh-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(gopayamgostar.JWT{AccessToken: "refreshed-" + string(rune('0'+n)), RefreshToken: "refresh-2", ExpiresAt: time.Now().Add(time.Second)})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := gopayamgostar.NewClient(server.URL)
+
+	token, err := client.RefreshToken(ctx, "refresh-1")
+	require.NoError(t, err)
+	require.Equal(t, "refreshed-1", token.AccessToken)
+	_, err = client.RefreshToken(ctx, "revoked")
+	require.Error(t, err)
+
+	token, err = client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	require.Equal(t, "login", token.AccessToken)
+
+	token, err = client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	require.Equal(t, "refreshed-3", token.AccessToken, "the expiring token is refreshed")
+	require.Equal(t, int32(1), atomic.LoadInt32(&logins))
+
+	// refresh-2 is rejected, so the client logs in again
+	token, err = client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	require.Equal(t, "login", token.AccessToken)
+	require.Equal(t, int32(2), atomic.LoadInt32(&logins))
+}
+
+func TestLogout(t *testing.T) {
+	t.Parallel()
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	personID := server.AddPerson(gopayamgostar.PersonInfo{FirstName: "Ali"})
+
+	ctx := context.Background()
+	client := server.Client()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	client.SetAccessToken(token.AccessToken)
+
+	require.NoError(t, client.Logout(ctx, ""))
+	require.Empty(t, client.AccessToken(), "the held token is released")
+	_, err = client.GetPersonInfoById(ctx, token.AccessToken, personID)
+	require.Error(t, err)
+	_, err = client.RefreshToken(ctx, token.RefreshToken)
+	require.Error(t, err, "the refresh token of the session is revoked")
+	require.Error(t, client.Logout(ctx, token.AccessToken))
+
+	renewed, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	require.NotEqual(t, token.AccessToken, renewed.AccessToken, "the token is dropped from the cache")
+	_, err = client.GetPersonInfoById(ctx, renewed.AccessToken, personID)
+	require.NoError(t, err)
+}
+
+func TestWithAPIKey(t *testing.T) {
+	t.Parallel()
+
+	var logins int32
+	var header http.Header
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&logins, 1)
+	})
+	mux.HandleFunc("/api/v2/crmobject/person/get", func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"crmId":"person-1"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := gopayamgostar.NewClient(server.URL, gopayamgostar.WithAPIKey("key-1"))
+	token, err := client.AdminAuthenticate(ctx, "", "")
+	require.NoError(t, err)
+	require.Empty(t, token.AccessToken)
+	_, err = client.UserAuthenticate(ctx, "", "")
+	require.NoError(t, err)
+	require.Zero(t, atomic.LoadInt32(&logins), "no login is sent")
+
+	_, err = client.GetPersonInfoById(ctx, token.AccessToken, "person-1")
+	require.NoError(t, err)
+	require.Equal(t, "key-1", header.Get(gopayamgostar.APIKeyHeader))
+	require.Empty(t, header.Get("Authorization"))
+}
@@ -0,0 +1,143 @@
+package gopayamgostar
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// TokenStore keeps the tokens AdminAuthenticate and UserAuthenticate reuse,
+// see WithTokenStore. Keys are hashes of the base path and credentials of a
+// login, safe to use as file names. Get reports false for keys it does not
+// hold.
+type TokenStore interface {
+	Get(ctx context.Context, key string) (JWT, bool, error)
+	Put(ctx context.Context, key string, token JWT) error
+	Delete(ctx context.Context, key string) error
+}
+
+// WithTokenStore keeps the tokens of the client in store instead of memory,
+// so that processes or replicas sharing store share their logins instead of
+// logging in separately and exhausting the session limit of the user. The
+// store is a cache: when it fails, the client logs in.
+func WithTokenStore(store TokenStore) func(*GoPayamgostar) {
+	return func(g *GoPayamgostar) {
+		g.tokens.store = store
+	}
+}
+
+// MemoryTokenStore is a TokenStore kept in memory, the default of a client
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]JWT
+}
+
+// NewMemoryTokenStore creates an empty MemoryTokenStore. Pass the same one to
+// several clients for them to share their logins.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: map[string]JWT{}}
+}
+
+func (s *MemoryTokenStore) Get(ctx context.Context, key string) (JWT, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[key]
+	return token, ok, nil
+}
+
+func (s *MemoryTokenStore) Put(ctx context.Context, key string, token JWT) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[key] = token
+	return nil
+}
+
+func (s *MemoryTokenStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, key)
+	return nil
+}
+
+// FileTokenStore is a TokenStore keeping every token in a JSON file of a
+// directory, readable by its owner only. Files are replaced atomically, so
+// processes on the same host can share the directory.
+type FileTokenStore struct {
+	dir string
+
+	// Codec encrypts or compresses the files, which are plain JSON by
+	// default. Files written with another codec are not read, and the
+	// client logs in again.
+	Codec StateCodec
+}
+
+// NewFileTokenStore creates a FileTokenStore in dir, creating the directory if
+// needed
+func NewFileTokenStore(dir string) (*FileTokenStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, errors.Wrap(err, "could not create the token directory")
+	}
+	return &FileTokenStore{dir: dir}, nil
+}
+
+func (s *FileTokenStore) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+func (s *FileTokenStore) Get(ctx context.Context, key string) (JWT, bool, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return JWT{}, false, nil
+	}
+	if err != nil {
+		return JWT{}, false, err
+	}
+	if !s.Codec.IsZero() {
+		if data, err = s.Codec.Decode(data); err != nil {
+			return JWT{}, false, errors.Wrapf(err, "could not decode token %s", key)
+		}
+	}
+	var token JWT
+	if err := json.Unmarshal(data, &token); err != nil {
+		return JWT{}, false, errors.Wrapf(err, "could not decode token %s", key)
+	}
+	return token, true, nil
+}
+
+func (s *FileTokenStore) Put(ctx context.Context, key string, token JWT) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	if !s.Codec.IsZero() {
+		if data, err = s.Codec.Encode(data); err != nil {
+			return err
+		}
+	}
+	file, err := os.CreateTemp(s.dir, "token-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), s.path(key))
+}
+
+func (s *FileTokenStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
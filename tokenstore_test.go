@@ -0,0 +1,113 @@
+package gopayamgostar_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+)
+
+func TestWithTokenStore(t *testing.T) {
+	t.Parallel()
+
+	var logins int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&logins, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(gopayamgostar.JWT{
+			AccessToken: "token-" + string(rune('0'+n)),
+			ExpiresAt:   time.Now().Add(time.Hour),
+		})
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	store, err := gopayamgostar.NewFileTokenStore(dir)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	first := gopayamgostar.NewClient(server.URL, gopayamgostar.WithTokenStore(store))
+	token, err := first.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	require.Equal(t, "token-1", token.AccessToken)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	info, err := entries[0].Info()
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	require.NotContains(t, entries[0].Name(), "admin")
+
+	// another replica reuses the token of the store
+	reopened, err := gopayamgostar.NewFileTokenStore(dir)
+	require.NoError(t, err)
+	second := gopayamgostar.NewClient(server.URL, gopayamgostar.WithTokenStore(reopened))
+	token, err = second.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	require.Equal(t, "token-1", token.AccessToken)
+	require.Equal(t, int32(1), atomic.LoadInt32(&logins))
+
+	// a client of another server does not
+	other := gopayamgostar.NewClient(server.URL+"/other", gopayamgostar.WithTokenStore(reopened))
+	token, err = other.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	require.Equal(t, "token-2", token.AccessToken)
+
+	second.ClearTokenCache()
+	token, err = first.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	require.Equal(t, "token-3", token.AccessToken, "the shared token was dropped")
+}
+
+func TestMemoryTokenStore(t *testing.T) {
+	ctx := context.Background()
+	store := gopayamgostar.NewMemoryTokenStore()
+
+	_, ok, err := store.Get(ctx, "key")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, store.Put(ctx, "key", gopayamgostar.JWT{AccessToken: "token"}))
+	token, ok, err := store.Get(ctx, "key")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "token", token.AccessToken)
+
+	require.NoError(t, store.Delete(ctx, "key"))
+	_, ok, _ = store.Get(ctx, "key")
+	require.False(t, ok)
+}
+
+func TestFileTokenStoreCodec(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := gopayamgostar.NewFileTokenStore(dir)
+	require.NoError(t, err)
+	store.Codec = gopayamgostar.StateCodec{Compress: true, Key: testStateKey}
+
+	require.NoError(t, store.Put(ctx, "key", gopayamgostar.JWT{AccessToken: "access-secret", RefreshToken: "refresh-secret"}))
+	data, err := os.ReadFile(filepath.Join(dir, "key.json"))
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "secret")
+
+	token, ok, err := store.Get(ctx, "key")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "access-secret", token.AccessToken)
+	require.Equal(t, "refresh-secret", token.RefreshToken)
+
+	plain, err := gopayamgostar.NewFileTokenStore(dir)
+	require.NoError(t, err)
+	_, _, err = plain.Get(ctx, "key")
+	require.Error(t, err, "encrypted tokens are not read without the key")
+}
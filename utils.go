@@ -2,10 +2,13 @@ package gopayamgostar
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
@@ -160,3 +163,45 @@ func GregorianToShamsi(gDate string) string {
 
 	return fmt.Sprintf("%d/%02d/%02d", persianYear, persianMonth, persianDay)
 }
+
+// jsonStream is a request body that encodes its value through an io.Pipe while
+// it is being read. Encoding starts on the first Read and restarts after Close,
+// so a retried request sends the payload again.
+type jsonStream struct {
+	mu    sync.Mutex
+	value interface{}
+	pr    *io.PipeReader
+}
+
+func newJSONStream(v interface{}) *jsonStream {
+	return &jsonStream{value: v}
+}
+
+// Read implements io.Reader
+func (s *jsonStream) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	if s.pr == nil {
+		pr, pw := io.Pipe()
+		go func(v interface{}) {
+			pw.CloseWithError(json.NewEncoder(pw).Encode(v))
+		}(s.value)
+		s.pr = pr
+	}
+	pr := s.pr
+	s.mu.Unlock()
+
+	return pr.Read(p)
+}
+
+// Close implements io.Closer
+func (s *jsonStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pr == nil {
+		return nil
+	}
+	err := s.pr.Close()
+	s.pr = nil
+	return err
+}
@@ -0,0 +1,53 @@
+package gopayamgostar
+
+import (
+	"context"
+	"time"
+)
+
+// maxWaitPollInterval caps the backoff of WaitForObjectCondition
+const maxWaitPollInterval = time.Minute
+
+// WaitForObjectCondition gets the object crmId until predicate returns true,
+// e.g. until a person on the CRM side approves it by moving it to a stage. The
+// first poll is immediate, the delay between polls starts at pollInterval and
+// doubles up to a minute. When ctx is done the last object read is returned
+// with the context error.
+func (g *GoPayamgostar) WaitForObjectCondition(ctx context.Context, accessToken, crmId string, predicate func(*FormInfo) bool, pollInterval time.Duration) (*FormInfo, error) {
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+	maxInterval := maxWaitPollInterval
+	if pollInterval > maxInterval {
+		maxInterval = pollInterval
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	var last *FormInfo
+	for interval := pollInterval; ; {
+		select {
+		case <-ctx.Done():
+			return last, ctx.Err()
+		case <-timer.C:
+		}
+
+		object, err := g.GetFormInfoById(WithoutCache(ctx), accessToken, crmId)
+		if err != nil {
+			if ctx.Err() != nil {
+				return last, ctx.Err()
+			}
+			return last, err
+		}
+		last = object
+		if predicate(object) {
+			return object, nil
+		}
+
+		timer.Reset(interval)
+		if interval *= 2; interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Package webhooks decodes the webhook notifications Payamgostar sends when
+// objects are created, updated, deleted or moved to another stage.
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/pkg/errors"
+)
+
+// maxPayloadSize bounds the webhook bodies Handler reads
+const maxPayloadSize = 1 << 20
+
+// EventType is the kind of a webhook event
+type EventType string
+
+const (
+	TypeObjectCreated EventType = "ObjectCreated"
+	TypeObjectUpdated EventType = "ObjectUpdated"
+	TypeObjectDeleted EventType = "ObjectDeleted"
+	TypeStageChanged  EventType = "StageChanged"
+)
+
+// ErrUnknownEventType is returned by ParseEvent for event types this package
+// does not know
+var ErrUnknownEventType = errors.New("unknown webhook event type")
+
+// Header holds the fields every event carries
+type Header struct {
+	ID                string                   `json:"eventId"`
+	Type              EventType                `json:"eventType"`
+	OccurredAt        gopayamgostar.CustomTime `json:"occurredAt"`
+	CrmId             string                   `json:"crmId"`
+	CRMObjectTypeCode string                   `json:"crmObjectTypeCode"`
+}
+
+// EventHeader returns the common fields of the event
+func (h Header) EventHeader() Header {
+	return h
+}
+
+// Event is one of ObjectCreated, ObjectUpdated, ObjectDeleted or StageChanged
+type Event interface {
+	EventHeader() Header
+}
+
+// ObjectCreated is sent when an object is created
+type ObjectCreated struct {
+	Header
+	Subject            string                           `json:"subject"`
+	IdentityID         string                           `json:"identityId"`
+	ExtendedProperties []gopayamgostar.ExtendedProperty `json:"extendedProperties"`
+}
+
+// ObjectUpdated is sent when fields of an object are updated
+type ObjectUpdated struct {
+	Header
+	// ChangedFields are the names of the updated fields and extended properties
+	ChangedFields      []string                         `json:"changedFields"`
+	ExtendedProperties []gopayamgostar.ExtendedProperty `json:"extendedProperties"`
+}
+
+// ObjectDeleted is sent when an object is deleted
+type ObjectDeleted struct {
+	Header
+}
+
+// StageChanged is sent when an object moves to another stage
+type StageChanged struct {
+	Header
+	PreviousStageID string `json:"previousStageId"`
+	StageID         string `json:"stageId"`
+	StageName       string `json:"stageName"`
+}
+
+// ParseEvent decodes a webhook payload into its typed event
+func ParseEvent(data []byte) (Event, error) {
+	var header Header
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, errors.Wrap(err, "could not parse webhook event")
+	}
+
+	var event Event
+	switch header.Type {
+	case TypeObjectCreated:
+		event = &ObjectCreated{}
+	case TypeObjectUpdated:
+		event = &ObjectUpdated{}
+	case TypeObjectDeleted:
+		event = &ObjectDeleted{}
+	case TypeStageChanged:
+		event = &StageChanged{}
+	default:
+		return nil, errors.Wrapf(ErrUnknownEventType, "%q", header.Type)
+	}
+
+	if err := json.Unmarshal(data, event); err != nil {
+		return nil, errors.Wrapf(err, "could not parse %s event", header.Type)
+	}
+	return event, nil
+}
+
+// HandlerFunc processes a webhook event
+type HandlerFunc func(ctx context.Context, event Event) error
+
+// Handler returns an http.Handler that decodes webhook requests and passes the
+// events to fn. It answers 400 to payloads that cannot be parsed, 500 when fn
+// fails so that the webhook is delivered again, and 204 otherwise.
+func Handler(fn HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		event, err := ParseEvent(data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := fn(r.Context(), event); err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,75 @@
+package webhooks_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erfandiakoo/gopayamgostar/v2/webhooks"
+	"github.com/stretchr/testify/require"
+)
+
+const stageChangedPayload = `{
+	"eventId": "evt-1",
+	"eventType": "StageChanged",
+	"occurredAt": "2024-05-01T10:30:00",
+	"crmId": "order-1",
+	"crmObjectTypeCode": "Order",
+	"previousStageId": "lead",
+	"stageId": "won",
+	"stageName": "Won"
+}`
+
+func TestParseEvent(t *testing.T) {
+	event, err := webhooks.ParseEvent([]byte(stageChangedPayload))
+	require.NoError(t, err)
+
+	changed, ok := event.(*webhooks.StageChanged)
+	require.True(t, ok)
+	require.Equal(t, "order-1", changed.CrmId)
+	require.Equal(t, "won", changed.StageID)
+	require.Equal(t, time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC), changed.OccurredAt.Time)
+	require.Equal(t, webhooks.TypeStageChanged, event.EventHeader().Type)
+
+	event, err = webhooks.ParseEvent([]byte(`{"eventType":"ObjectUpdated","crmId":"1","changedFields":["Subject"],"extendedProperties":[{"userKey":"City","value":"Tehran"}]}`))
+	require.NoError(t, err)
+	updated := event.(*webhooks.ObjectUpdated)
+	require.Equal(t, []string{"Subject"}, updated.ChangedFields)
+	require.Equal(t, "Tehran", updated.ExtendedProperties[0].Value)
+
+	_, err = webhooks.ParseEvent([]byte(`{"eventType":"Unknown"}`))
+	require.ErrorIs(t, err, webhooks.ErrUnknownEventType)
+
+	_, err = webhooks.ParseEvent([]byte(`not json`))
+	require.Error(t, err)
+}
+
+func TestHandler(t *testing.T) {
+	var received []webhooks.Event
+	fail := false
+	handler := webhooks.Handler(func(ctx context.Context, event webhooks.Event) error {
+		if fail {
+			return errors.New("boom")
+		}
+		received = append(received, event)
+		return nil
+	})
+
+	serve := func(method, body string) int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, "/webhook", strings.NewReader(body)))
+		return recorder.Code
+	}
+
+	require.Equal(t, http.StatusNoContent, serve(http.MethodPost, stageChangedPayload))
+	require.Len(t, received, 1)
+	require.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodGet, ""))
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, `{"eventType":"Unknown"}`))
+
+	fail = true
+	require.Equal(t, http.StatusInternalServerError, serve(http.MethodPost, stageChangedPayload))
+}
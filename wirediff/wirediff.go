@@ -0,0 +1,451 @@
+// Package wirediff compares the HTTP requests clients send for the same
+// operations, so that an upgrade of the SDK or a change of its configuration
+// can be validated against the CRM version in use before it is deployed. Each
+// side runs against its own fake server of package gopayamgostartest and the
+// requests it sends are recorded, then compared one by one:
+//
+//	operations := func(ctx context.Context, client gopayamgostar.GoPayamgostarIface) error {
+//		token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+//		if err != nil {
+//			return err
+//		}
+//		_, err = client.CreateForm(ctx, token.AccessToken, request)
+//		return err
+//	}
+//	differences, err := wirediff.Compare(ctx,
+//		wirediff.Profile(operations),
+//		wirediff.Profile(operations, gopayamgostar.WithEndpointPrefix("crm")),
+//		wirediff.WithSetup(func(s *gopayamgostartest.Server) { s.AddUser("admin", "secret") }))
+//
+// A module builds a single version of the SDK, so to compare two versions,
+// Record the requests of the old one and Save them, then Load them after the
+// upgrade and Diff them with the requests of the new one.
+//
+// UUIDs, such as crm ids, tokens and correlation ids, are replaced by
+// placeholders numbered in order of appearance, so that the ids generated by
+// each run do not show as differences. Operations must run sequentially for
+// their requests to be recorded in the same order.
+package wirediff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/pkg/errors"
+)
+
+// defaultIgnoredHeaders are set by the HTTP stack or change with every
+// release rather than with the behavior of the client
+var defaultIgnoredHeaders = []string{"Accept-Encoding", "Content-Length", "User-Agent"}
+
+var uuidPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+
+// Request is a request sent by a client, normalized for comparison
+type Request struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Query  url.Values  `json:"query,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	// Body is the decoded JSON body, or the body as a string when it is not JSON
+	Body interface{} `json:"body,omitempty"`
+}
+
+// String returns the method and URL of the request
+func (r Request) String() string {
+	if len(r.Query) == 0 {
+		return r.Method + " " + r.Path
+	}
+	return r.Method + " " + r.Path + "?" + r.Query.Encode()
+}
+
+// Run performs operations with a client sending its requests to baseURL
+type Run func(ctx context.Context, baseURL string) error
+
+// Operations performs the operations to compare with client
+type Operations func(ctx context.Context, client gopayamgostar.GoPayamgostarIface) error
+
+// Profile returns a Run performing operations with a client of this version
+// of the SDK configured with options
+func Profile(operations Operations, options ...func(*gopayamgostar.GoPayamgostar)) Run {
+	return func(ctx context.Context, baseURL string) error {
+		return operations(ctx, gopayamgostar.NewClient(baseURL, options...))
+	}
+}
+
+// Option changes how requests are recorded
+type Option func(*recordOptions)
+
+type recordOptions struct {
+	setup          []func(*gopayamgostartest.Server)
+	ignoredHeaders map[string]bool
+	ignoredFields  map[string]bool
+}
+
+// WithSetup prepares the fake server before the run, e.g. adds the users and
+// persons the operations need
+func WithSetup(setup func(*gopayamgostartest.Server)) Option {
+	return func(o *recordOptions) {
+		o.setup = append(o.setup, setup)
+	}
+}
+
+// IgnoreHeaders leaves headers out of the recorded requests, in addition to
+// Accept-Encoding, Content-Length and User-Agent
+func IgnoreHeaders(names ...string) Option {
+	return func(o *recordOptions) {
+		for _, name := range names {
+			o.ignoredHeaders[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// IgnoreFields leaves the JSON fields named names out of the recorded bodies,
+// at any depth, e.g. fields holding the current time
+func IgnoreFields(names ...string) Option {
+	return func(o *recordOptions) {
+		for _, name := range names {
+			o.ignoredFields[name] = true
+		}
+	}
+}
+
+// Record performs run against a new fake server and returns the requests it
+// sent, with the error of run
+func Record(ctx context.Context, run Run, options ...Option) ([]Request, error) {
+	o := recordOptions{ignoredHeaders: map[string]bool{}, ignoredFields: map[string]bool{}}
+	IgnoreHeaders(defaultIgnoredHeaders...)(&o)
+	for _, option := range options {
+		option(&o)
+	}
+
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	for _, setup := range o.setup {
+		setup(server)
+	}
+
+	var mu sync.Mutex
+	var requests []Request
+	n := normalizer{options: o, ids: map[string]string{}}
+	recorder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		mu.Lock()
+		requests = append(requests, n.request(r, body))
+		mu.Unlock()
+		server.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer recorder.Close()
+
+	err := run(ctx, recorder.URL)
+
+	mu.Lock()
+	defer mu.Unlock()
+	return requests, err
+}
+
+// Compare records the requests of before and after with the same options and
+// returns their differences
+func Compare(ctx context.Context, before, after Run, options ...Option) ([]Difference, error) {
+	beforeRequests, err := Record(ctx, before, options...)
+	if err != nil {
+		return nil, errors.Wrap(err, "the run before failed")
+	}
+	afterRequests, err := Record(ctx, after, options...)
+	if err != nil {
+		return nil, errors.Wrap(err, "the run after failed")
+	}
+	return Diff(beforeRequests, afterRequests), nil
+}
+
+// Save writes requests to the JSON file path, to Diff them after an upgrade
+func Save(path string, requests []Request) error {
+	data, err := json.MarshalIndent(requests, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Load reads the requests written by Save
+func Load(path string) ([]Request, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var requests []Request
+	if err := json.Unmarshal(data, &requests); err != nil {
+		return nil, errors.Wrapf(err, "could not decode %s", path)
+	}
+	return requests, nil
+}
+
+// normalizer turns the requests of a run into comparable Requests
+type normalizer struct {
+	options recordOptions
+	// ids maps the UUIDs seen to their placeholders
+	ids map[string]string
+}
+
+func (n *normalizer) request(r *http.Request, body []byte) Request {
+	request := Request{Method: r.Method, Path: n.replace(r.URL.Path)}
+
+	query := r.URL.Query()
+	for _, key := range sortedKeys(query) {
+		for _, value := range query[key] {
+			if request.Query == nil {
+				request.Query = url.Values{}
+			}
+			request.Query.Add(key, n.replace(value))
+		}
+	}
+
+	for _, name := range sortedKeys(r.Header) {
+		if n.options.ignoredHeaders[name] {
+			continue
+		}
+		for _, value := range r.Header[name] {
+			if request.Header == nil {
+				request.Header = http.Header{}
+			}
+			request.Header.Add(name, n.replace(value))
+		}
+	}
+
+	var decoded interface{}
+	switch {
+	case len(bytes.TrimSpace(body)) == 0:
+	case json.Unmarshal(body, &decoded) == nil:
+		request.Body = n.value(decoded)
+	default:
+		request.Body = n.replace(string(body))
+	}
+	return request
+}
+
+// value replaces the UUIDs of a decoded JSON value and drops ignored fields
+func (n *normalizer) value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			if n.options.ignoredFields[key] {
+				delete(v, key)
+				continue
+			}
+			v[key] = n.value(v[key])
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = n.value(v[i])
+		}
+	case string:
+		return n.replace(v)
+	}
+	return v
+}
+
+func (n *normalizer) replace(s string) string {
+	return uuidPattern.ReplaceAllStringFunc(s, func(id string) string {
+		id = strings.ToLower(id)
+		placeholder, ok := n.ids[id]
+		if !ok {
+			placeholder = fmt.Sprintf("<uuid-%d>", len(n.ids)+1)
+			n.ids[id] = placeholder
+		}
+		return placeholder
+	})
+}
+
+// Difference is a request sent differently after than before
+type Difference struct {
+	// Before is the request sent before, nil when it is only sent after
+	Before *Request
+	// After is the request sent after, nil when it is only sent before
+	After *Request
+	// Changes describes how the request changed when it is sent by both
+	Changes []string
+}
+
+func (d Difference) String() string {
+	switch {
+	case d.After == nil:
+		return "only sent before: " + d.Before.String()
+	case d.Before == nil:
+		return "only sent after: " + d.After.String()
+	}
+	return d.Before.String() + ":\n\t" + strings.Join(d.Changes, "\n\t")
+}
+
+// Diff returns the differences between the requests sent before and after.
+// Requests are paired by method and path in the order they were sent, so
+// that a request added or removed does not shift the others.
+func Diff(before, after []Request) []Difference {
+	var differences []Difference
+	i, j := 0, 0
+	for _, pair := range align(before, after) {
+		for ; i < pair[0]; i++ {
+			differences = append(differences, Difference{Before: &before[i]})
+		}
+		for ; j < pair[1]; j++ {
+			differences = append(differences, Difference{After: &after[j]})
+		}
+		if changes := compare(before[i], after[j]); len(changes) > 0 {
+			differences = append(differences, Difference{Before: &before[i], After: &after[j], Changes: changes})
+		}
+		i, j = i+1, j+1
+	}
+	for ; i < len(before); i++ {
+		differences = append(differences, Difference{Before: &before[i]})
+	}
+	for ; j < len(after); j++ {
+		differences = append(differences, Difference{After: &after[j]})
+	}
+	return differences
+}
+
+// align returns the indexes of the longest common subsequence of requests
+// with the same method and path
+func align(before, after []Request) [][2]int {
+	key := func(r Request) string { return r.Method + " " + r.Path }
+
+	// lengths[i][j] is the length of the subsequence of before[i:] and after[j:]
+	lengths := make([][]int, len(before)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if key(before[i]) == key(after[j]) {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	var pairs [][2]int
+	for i, j := 0, 0; i < len(before) && j < len(after); {
+		switch {
+		case key(before[i]) == key(after[j]):
+			pairs = append(pairs, [2]int{i, j})
+			i, j = i+1, j+1
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
+
+// compare describes the changes between two requests with the same method and path
+func compare(before, after Request) []string {
+	var changes []string
+	for _, key := range unionKeys(before.Query, after.Query) {
+		changes = appendChange(changes, "query "+key, before.Query[key], after.Query[key])
+	}
+	for _, name := range unionKeys(before.Header, after.Header) {
+		changes = appendChange(changes, "header "+name, before.Header[name], after.Header[name])
+	}
+	diffValues(&changes, "body", before.Body, after.Body)
+	return changes
+}
+
+func appendChange(changes []string, name string, before, after []string) []string {
+	switch {
+	case after == nil:
+		return append(changes, fmt.Sprintf("%s removed: %q", name, before))
+	case before == nil:
+		return append(changes, fmt.Sprintf("%s added: %q", name, after))
+	case !reflect.DeepEqual(before, after):
+		return append(changes, fmt.Sprintf("%s changed from %q to %q", name, before, after))
+	}
+	return changes
+}
+
+// diffValues describes the changes between two decoded JSON values
+func diffValues(changes *[]string, path string, before, after interface{}) {
+	switch b := before.(type) {
+	case map[string]interface{}:
+		if a, ok := after.(map[string]interface{}); ok {
+			for _, key := range unionKeys(b, a) {
+				bv, inBefore := b[key]
+				av, inAfter := a[key]
+				switch {
+				case !inAfter:
+					*changes = append(*changes, fmt.Sprintf("%s.%s removed: %s", path, key, format(bv)))
+				case !inBefore:
+					*changes = append(*changes, fmt.Sprintf("%s.%s added: %s", path, key, format(av)))
+				default:
+					diffValues(changes, path+"."+key, bv, av)
+				}
+			}
+			return
+		}
+	case []interface{}:
+		if a, ok := after.([]interface{}); ok {
+			for i := 0; i < max(len(b), len(a)); i++ {
+				elementPath := fmt.Sprintf("%s[%d]", path, i)
+				switch {
+				case i >= len(a):
+					*changes = append(*changes, fmt.Sprintf("%s removed: %s", elementPath, format(b[i])))
+				case i >= len(b):
+					*changes = append(*changes, fmt.Sprintf("%s added: %s", elementPath, format(a[i])))
+				default:
+					diffValues(changes, elementPath, b[i], a[i])
+				}
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(before, after) {
+		*changes = append(*changes, fmt.Sprintf("%s changed from %s to %s", path, format(before), format(after)))
+	}
+}
+
+func format(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := sortedKeys(a)
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,97 @@
+package wirediff_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/erfandiakoo/gopayamgostar/v2/wirediff"
+	"github.com/stretchr/testify/require"
+)
+
+// operations creates a person and a form of the person with subject
+func operations(subject string) wirediff.Operations {
+	return func(ctx context.Context, client gopayamgostar.GoPayamgostarIface) error {
+		token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+		if err != nil {
+			return err
+		}
+		personID, err := client.CreatePerson(ctx, token.AccessToken, gopayamgostar.CreatePersonRequest{CRMObjectTypeCode: "Person", FirstName: "Ali"})
+		if err != nil {
+			return err
+		}
+		_, err = client.CreateForm(ctx, token.AccessToken, gopayamgostar.CreateFormRequest{CRMObjectTypeCode: "Complaint", IdentityID: personID, Subject: &subject})
+		return err
+	}
+}
+
+var setup = wirediff.WithSetup(func(s *gopayamgostartest.Server) {
+	s.AddUser("admin", "secret")
+})
+
+func TestCompare(t *testing.T) {
+	ctx := context.Background()
+
+	differences, err := wirediff.Compare(ctx, wirediff.Profile(operations("late")), wirediff.Profile(operations("late")), setup)
+	require.NoError(t, err)
+	require.Empty(t, differences, "generated ids and tokens are not differences")
+
+	differences, err = wirediff.Compare(ctx,
+		wirediff.Profile(operations("late")),
+		wirediff.Profile(operations("broken"), gopayamgostar.WithStaticHeaders(map[string]string{"X-Tenant": "shop"})),
+		setup)
+	require.NoError(t, err)
+	require.Len(t, differences, 3)
+	require.Equal(t, []string{`header X-Tenant added: ["shop"]`}, differences[0].Changes)
+	require.Equal(t, []string{`header X-Tenant added: ["shop"]`}, differences[1].Changes)
+	require.Equal(t, []string{
+		`header X-Tenant added: ["shop"]`,
+		`body.Subject changed from "late" to "broken"`,
+	}, differences[2].Changes)
+
+	_, err = wirediff.Compare(ctx, wirediff.Profile(operations("late")), wirediff.Profile(operations("late")))
+	require.Error(t, err, "the user is missing")
+}
+
+func TestDiff(t *testing.T) {
+	get := wirediff.Request{Method: "POST", Path: "/api/v2/crmobject/form/get", Body: map[string]interface{}{"id": "<uuid-1>"}}
+	find := wirediff.Request{Method: "POST", Path: "/api/v2/crmobject/form/find", Body: map[string]interface{}{
+		"pageSize": 10.0,
+		"queries":  []interface{}{"a", "b"},
+	}}
+	moved := wirediff.Request{Method: "POST", Path: "/api/v3/crmobject/form/get", Body: get.Body}
+	paged := wirediff.Request{Method: "POST", Path: find.Path, Body: map[string]interface{}{
+		"pageNumber": 1.0,
+		"queries":    []interface{}{"a"},
+	}}
+
+	differences := wirediff.Diff([]wirediff.Request{get, find}, []wirediff.Request{moved, paged})
+	require.Len(t, differences, 3)
+	require.Equal(t, "only sent before: POST /api/v2/crmobject/form/get", differences[0].String())
+	require.Equal(t, "only sent after: POST /api/v3/crmobject/form/get", differences[1].String())
+	require.Equal(t, []string{
+		"body.pageNumber added: 1",
+		"body.pageSize removed: 10",
+		`body.queries[1] removed: "b"`,
+	}, differences[2].Changes)
+
+	require.Empty(t, wirediff.Diff([]wirediff.Request{get, find}, []wirediff.Request{get, find}))
+}
+
+func TestSaveLoad(t *testing.T) {
+	ctx := context.Background()
+	requests, err := wirediff.Record(ctx, wirediff.Profile(operations("late")), setup, wirediff.IgnoreHeaders("X-Correlation-ID"), wirediff.IgnoreFields("deviceId"))
+	require.NoError(t, err)
+	require.Len(t, requests, 3)
+	require.Equal(t, "Bearer <uuid-1>", requests[1].Header.Get("Authorization"))
+	require.Empty(t, requests[1].Header.Get("X-Correlation-ID"))
+	require.NotContains(t, requests[0].Body, "deviceId")
+
+	path := filepath.Join(t.TempDir(), "requests.json")
+	require.NoError(t, wirediff.Save(path, requests))
+	loaded, err := wirediff.Load(path)
+	require.NoError(t, err)
+	require.Empty(t, wirediff.Diff(requests, loaded))
+}
@@ -0,0 +1,280 @@
+// Package wizard fills in a form over several user steps, as settlement and
+// onboarding forms are filled in practice: a draft form is created first,
+// every step validates and saves its answers, and finalizing moves the form
+// to its final stage. The progress is kept in the form itself, so a wizard
+// interrupted at any point is resumed from the RefID of its form.
+package wizard
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/shared/enums"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is returned by Resume when no form has the ref id
+	ErrNotFound = errors.New("wizard: no form with this ref id")
+	// ErrCompleted is returned by Submit when every step was submitted already
+	ErrCompleted = errors.New("wizard: all steps are completed")
+	// ErrIncomplete is returned by Finalize while steps are left
+	ErrIncomplete = errors.New("wizard: not all steps are completed")
+	// ErrFinalized is returned by Submit once the form was finalized
+	ErrFinalized = errors.New("wizard: form is finalized")
+)
+
+// StepError is returned by Submit when the answers of a step are rejected
+type StepError struct {
+	Step string
+	// Key is the user key of the missing property, empty when Validate failed
+	Key string
+	Err error
+}
+
+func (e *StepError) Error() string {
+	if e.Key != "" {
+		return fmt.Sprintf("wizard: step %s: %s: %v", e.Step, e.Key, e.Err)
+	}
+	return fmt.Sprintf("wizard: step %s: %v", e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// ErrRequired is the error of a StepError for a missing required property
+var ErrRequired = errors.New("value is required")
+
+// Step is one page of the wizard
+type Step struct {
+	Name string
+	// Required are the user keys of the properties the step must fill in
+	Required []string
+	// Validate checks the answers collected up to and including the step
+	Validate func(answers map[string]string) error
+}
+
+// check returns a StepError when answers do not complete the step
+func (s Step) check(answers map[string]string) error {
+	for _, key := range s.Required {
+		if answers[key] == "" {
+			return &StepError{Step: s.Name, Key: key, Err: ErrRequired}
+		}
+	}
+	if s.Validate != nil {
+		if err := s.Validate(answers); err != nil {
+			return &StepError{Step: s.Name, Err: err}
+		}
+	}
+	return nil
+}
+
+// State is the progress of one form through the wizard
+type State struct {
+	RefID string
+	CrmId string
+	// Step is the index of the next step to submit, len(Steps) once every
+	// step is completed
+	Step int
+	// Answers are the extended property values of the form by user key
+	Answers map[string]string
+	// Finalized is set once the form reached the final stage
+	Finalized bool
+}
+
+// Wizard fills in forms of one type
+type Wizard struct {
+	client *gopayamgostar.GoPayamgostar
+
+	// TypeCode is the object type of the forms
+	TypeCode string
+	// Steps are the pages of the wizard in order
+	Steps []Step
+	// DraftStageID is the stage drafts are created in, the default stage of
+	// the type when empty
+	DraftStageID string
+	// FinalStageID is the stage Finalize moves the form to
+	FinalStageID string
+}
+
+// New creates a wizard filling in forms of typeCode with steps, finalized by
+// moving them to finalStageID
+func New(client *gopayamgostar.GoPayamgostar, typeCode, finalStageID string, steps ...Step) *Wizard {
+	return &Wizard{
+		client:       client,
+		TypeCode:     typeCode,
+		Steps:        steps,
+		FinalStageID: finalStageID,
+	}
+}
+
+// Start creates a draft form of identityID with refID, or resumes the form
+// that already has refID
+func (w *Wizard) Start(ctx context.Context, accessToken, identityID, refID string) (*State, error) {
+	state, err := w.Resume(ctx, accessToken, refID)
+	if !errors.Is(err, ErrNotFound) {
+		return state, err
+	}
+
+	request := gopayamgostar.CreateFormRequest{
+		CRMObjectTypeCode: w.TypeCode,
+		IdentityID:        identityID,
+		RefID:             gopayamgostar.StringP(refID),
+	}
+	if w.DraftStageID != "" {
+		request.StageID = gopayamgostar.StringP(w.DraftStageID)
+	}
+	crmId, err := w.client.CreateForm(ctx, accessToken, request)
+	if err != nil {
+		return nil, errors.Wrap(err, "wizard: could not create draft")
+	}
+
+	answers := map[string]string{}
+	return &State{
+		RefID:   refID,
+		CrmId:   crmId,
+		Step:    w.progress(answers),
+		Answers: answers,
+	}, nil
+}
+
+// Resume loads the state of the form with refID from the CRM
+func (w *Wizard) Resume(ctx context.Context, accessToken, refID string) (*State, error) {
+	result, err := w.client.FindFormPage(ctx, accessToken, w.TypeCode, []gopayamgostar.Query{
+		{
+			LogicalOperator: int(enums.And),
+			FieldOperator:   int(enums.Equals),
+			Field:           "RefId",
+			Value:           refID,
+		},
+	}, 1, 1)
+	if err != nil {
+		return nil, errors.Wrap(err, "wizard: could not find draft")
+	}
+	if len(result.Data) == 0 {
+		return nil, ErrNotFound
+	}
+
+	form := result.Data[0]
+	answers := make(map[string]string, len(form.ExtendedProperties))
+	for _, property := range form.ExtendedProperties {
+		answers[property.UserKey] = property.Value
+	}
+	stage, _ := form.StageID.(string)
+
+	return &State{
+		RefID:     refID,
+		CrmId:     form.CRMID,
+		Step:      w.progress(answers),
+		Answers:   answers,
+		Finalized: w.FinalStageID != "" && stage == w.FinalStageID,
+	}, nil
+}
+
+// Submit validates answers for the current step of state and saves them in
+// the form. Answers of earlier steps may be changed as well; they are
+// validated again by the steps they belong to.
+func (w *Wizard) Submit(ctx context.Context, accessToken string, state *State, answers map[string]string) error {
+	if state.Finalized {
+		return ErrFinalized
+	}
+	if state.Step >= len(w.Steps) {
+		return ErrCompleted
+	}
+
+	merged := make(map[string]string, len(state.Answers)+len(answers))
+	for key, value := range state.Answers {
+		merged[key] = value
+	}
+	for key, value := range answers {
+		merged[key] = value
+	}
+	for _, step := range w.Steps[:state.Step+1] {
+		if err := step.check(merged); err != nil {
+			return err
+		}
+	}
+
+	err := w.update(ctx, accessToken, state, func(request *gopayamgostar.UpdateFormRequest) {
+		request.ExtendedProperties = setProperties(request.ExtendedProperties, answers)
+	})
+	if err != nil {
+		return errors.Wrap(err, "wizard: could not save step")
+	}
+
+	state.Answers = merged
+	state.Step = w.progress(merged)
+	return nil
+}
+
+// Finalize moves the form to FinalStageID once every step is completed.
+// Finalizing a finalized form does nothing.
+func (w *Wizard) Finalize(ctx context.Context, accessToken string, state *State) error {
+	if state.Finalized {
+		return nil
+	}
+	if state.Step < len(w.Steps) {
+		return ErrIncomplete
+	}
+
+	err := w.update(ctx, accessToken, state, func(request *gopayamgostar.UpdateFormRequest) {
+		request.StageId = gopayamgostar.StringP(w.FinalStageID)
+	})
+	if err != nil {
+		return errors.Wrap(err, "wizard: could not finalize")
+	}
+
+	state.Finalized = true
+	return nil
+}
+
+// update reads the current form and saves it changed by change, so that
+// fields the wizard does not manage are kept
+func (w *Wizard) update(ctx context.Context, accessToken string, state *State, change func(*gopayamgostar.UpdateFormRequest)) error {
+	form, err := w.client.GetFormInfoById(gopayamgostar.WithoutCache(ctx), accessToken, state.CrmId)
+	if err != nil {
+		return err
+	}
+	request := form.UpdateRequest()
+	change(&request)
+	_, err = w.client.UpdateForm(ctx, accessToken, request)
+	return err
+}
+
+// progress returns the index of the first step answers do not complete
+func (w *Wizard) progress(answers map[string]string) int {
+	for i, step := range w.Steps {
+		if step.check(answers) != nil {
+			return i
+		}
+	}
+	return len(w.Steps)
+}
+
+// setProperties sets the values of answers in properties, adding the
+// properties that are missing
+func setProperties(properties []gopayamgostar.ExtendedProperty, answers map[string]string) []gopayamgostar.ExtendedProperty {
+	result := make([]gopayamgostar.ExtendedProperty, 0, len(properties)+len(answers))
+	seen := make(map[string]bool, len(answers))
+	for _, property := range properties {
+		if value, ok := answers[property.UserKey]; ok {
+			property.Value = value
+			seen[property.UserKey] = true
+		}
+		result = append(result, property)
+	}
+	keys := make([]string, 0, len(answers))
+	for key := range answers {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result = append(result, gopayamgostar.ExtendedProperty{UserKey: key, Value: answers[key]})
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package wizard_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/erfandiakoo/gopayamgostar/v2"
+	"github.com/erfandiakoo/gopayamgostar/v2/gopayamgostartest"
+	"github.com/erfandiakoo/gopayamgostar/v2/wizard"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func settlementWizard(client *gopayamgostar.GoPayamgostar) *wizard.Wizard {
+	return wizard.New(client, "SettlementRequest", "submitted",
+		wizard.Step{
+			Name:     "deposit",
+			Required: []string{"DepositDate", "DepositAmount"},
+			Validate: func(answers map[string]string) error {
+				if _, err := strconv.ParseInt(answers["DepositAmount"], 10, 64); err != nil {
+					return errors.New("amount must be a number")
+				}
+				return nil
+			},
+		},
+		wizard.Step{
+			Name:     "tracking",
+			Required: []string{"TrackingNumber"},
+		},
+	)
+}
+
+func TestWizard(t *testing.T) {
+	server := gopayamgostartest.NewServer()
+	defer server.Close()
+	server.AddUser("admin", "secret")
+	client := server.Client()
+
+	ctx := context.Background()
+	token, err := client.AdminAuthenticate(ctx, "admin", "secret")
+	require.NoError(t, err)
+	accessToken := token.AccessToken
+
+	w := settlementWizard(client)
+	state, err := w.Start(ctx, accessToken, "person-1", "settlement-42")
+	require.NoError(t, err)
+	require.Equal(t, 0, state.Step)
+
+	err = w.Submit(ctx, accessToken, state, map[string]string{"DepositDate": "1403/12/12"})
+	var stepErr *wizard.StepError
+	require.ErrorAs(t, err, &stepErr)
+	require.Equal(t, "DepositAmount", stepErr.Key)
+	require.ErrorIs(t, err, wizard.ErrRequired)
+
+	err = w.Submit(ctx, accessToken, state, map[string]string{"DepositDate": "1403/12/12", "DepositAmount": "lots"})
+	require.ErrorAs(t, err, &stepErr)
+	require.Equal(t, "deposit", stepErr.Step)
+
+	require.NoError(t, w.Submit(ctx, accessToken, state, map[string]string{"DepositDate": "1403/12/12", "DepositAmount": "1000"}))
+	require.Equal(t, 1, state.Step)
+	require.ErrorIs(t, w.Finalize(ctx, accessToken, state), wizard.ErrIncomplete)
+
+	// the user comes back later: starting again resumes the draft
+	resumed, err := settlementWizard(client).Start(ctx, accessToken, "person-1", "settlement-42")
+	require.NoError(t, err)
+	require.Equal(t, state.CrmId, resumed.CrmId)
+	require.Equal(t, 1, resumed.Step)
+	require.Equal(t, "1000", resumed.Answers["DepositAmount"])
+
+	require.NoError(t, w.Submit(ctx, accessToken, resumed, map[string]string{"TrackingNumber": "TRK-1"}))
+	require.Equal(t, 2, resumed.Step)
+	require.ErrorIs(t, w.Submit(ctx, accessToken, resumed, nil), wizard.ErrCompleted)
+	require.NoError(t, w.Finalize(ctx, accessToken, resumed))
+
+	form, ok := server.Form(state.CrmId)
+	require.True(t, ok)
+	require.Equal(t, "submitted", form.StageID)
+	require.Equal(t, "settlement-42", form.RefID)
+	require.Equal(t, []gopayamgostar.ExtendedProperty{
+		{UserKey: "DepositAmount", Value: "1000"},
+		{UserKey: "DepositDate", Value: "1403/12/12"},
+		{UserKey: "TrackingNumber", Value: "TRK-1"},
+	}, form.ExtendedProperties)
+
+	final, err := w.Resume(ctx, accessToken, "settlement-42")
+	require.NoError(t, err)
+	require.True(t, final.Finalized)
+	require.ErrorIs(t, w.Submit(ctx, accessToken, final, nil), wizard.ErrFinalized)
+
+	_, err = w.Resume(ctx, accessToken, "unknown")
+	require.ErrorIs(t, err, wizard.ErrNotFound)
+}